@@ -26,9 +26,9 @@ func (h *HistoryValue) IntegratedValue(generation int) int64 {
 func (h *HistoryValue) Add(generation int, amount int64) {
 	h.Lock()
 	defer h.Unlock()
-	if h.PrevGeneration < h.Generation {
-		h.Total += (int64)(generation-h.Generation) * h.Value
-	}
+	// the value held since its last generation is summed even if it was
+	// created at generation 0, where PrevGeneration == Generation
+	h.Total += (int64)(generation-h.Generation) * h.Value
 	if h.Generation < generation {
 		h.PrevGeneration, h.Generation = h.Generation, generation
 	}
@@ -176,8 +176,9 @@ type AvgSparse struct {
 }
 
 func (v *AvgSparse) Value(transition int, feature interface{}) int64 {
+	// GetValue is bounded, and the Len of a map store is not a bound
 	transitions, exists := v.Vals[feature]
-	if exists && transition < transitions.Len() {
+	if exists {
 		if histValue := transitions.GetValue(transition); histValue != nil {
 			return histValue.Value
 		}
@@ -218,6 +219,18 @@ func (v *AvgSparse) Integrate(generation int) *AvgSparse {
 	return v
 }
 
+// AddScores adds the values of a feature for some transitions to their
+// scores, as SetScores does for all transitions
+func (v *AvgSparse) AddScores(feature Feature, transitions []int, scores []int64) {
+	if store, exists := v.Vals[feature]; exists {
+		for i, transition := range transitions {
+			if histValue := store.GetValue(transition); histValue != nil {
+				scores[i] += histValue.Value
+			}
+		}
+	}
+}
+
 func (v *AvgSparse) SetScores(feature Feature, scores ScoredStore, integrated bool) {
 	if transitions, exists := v.Vals[feature]; exists {
 		// log.Println("\t\tSetting scores for feature", feature)
@@ -278,6 +291,75 @@ func (v *AvgSparse) UpdateScalarDivide(byValue int64) *AvgSparse {
 	return v
 }
 
+// Copy returns a copy of the weights whose histories restart at generation
+// 1, so the sums of weights of the copy are of its own updates only
+func (v *AvgSparse) Copy() *AvgSparse {
+	v.RLock()
+	defer v.RUnlock()
+	retval := MakeAvgSparse(v.Dense)
+	for feature, transitions := range v.Vals {
+		scoreStore := retval.newTransitionScoreStore(transitions.Len())
+		transitions.Each(func(i int, histValue *HistoryValue) {
+			if histValue != nil {
+				scoreStore.SetValue(i, &HistoryValue{Generation: 1, Value: histValue.Value})
+			}
+		})
+		retval.Vals[feature] = scoreStore
+	}
+	return retval
+}
+
+// AddSums adds the weights of a copy, and their sums up to its generation
+func (v *AvgSparse) AddSums(other *AvgSparse, generation int) {
+	other.RLock()
+	defer other.RUnlock()
+	for feature, transitions := range other.Vals {
+		transitions.Each(func(i int, histValue *HistoryValue) {
+			if histValue != nil {
+				sum := v.history(feature, i)
+				sum.Value += histValue.Value
+				sum.Total += histValue.IntegratedValue(generation)
+			}
+		})
+	}
+}
+
+// SetMixed sets the weights to those of mixed, and adds its sums of weights
+// as if they were accumulated over the mixedGeneration generations following
+// generation
+func (v *AvgSparse) SetMixed(mixed *AvgSparse, generation, mixedGeneration int) {
+	for feature, transitions := range mixed.Vals {
+		transitions.Each(func(i int, mixedValue *HistoryValue) {
+			if mixedValue != nil {
+				histValue := v.history(feature, i)
+				histValue.Total = histValue.IntegratedValue(generation) + mixedValue.Total
+				histValue.Value = mixedValue.Value
+				histValue.PrevGeneration = generation + mixedGeneration - 1
+				histValue.Generation = generation + mixedGeneration
+			}
+		})
+	}
+}
+
+// history returns the history value of a feature and transition, adding a
+// zero one if it does not exist
+func (v *AvgSparse) history(feature Feature, transition int) *HistoryValue {
+	transitions, exists := v.Vals[feature]
+	if !exists {
+		transitions = v.newTransitionScoreStore(transition + 1)
+		v.Vals[feature] = transitions
+	}
+	if histValue := transitions.GetValue(transition); histValue != nil {
+		return histValue
+	}
+	if array, isArray := transitions.(*LockedArray); isArray && transition >= array.Len() {
+		array.ExtendFor(0, transition)
+	}
+	histValue := &HistoryValue{}
+	transitions.SetValue(transition, histValue)
+	return histValue
+}
+
 func (v *AvgSparse) String() string {
 	strs := make([]string, 0, len(v.Vals))
 	v.RLock()
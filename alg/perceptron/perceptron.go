@@ -7,6 +7,7 @@ import (
 	"fmt"
 	// "io"
 	"log"
+	"sync"
 
 // "os"
 )
@@ -27,6 +28,10 @@ type LinearPerceptron struct {
 	FailedInstances int
 
 	Continue StopCondition
+
+	// parallel training with iterative parameter mixing
+	Workers    int
+	NewDecoder DecoderFactory
 }
 
 var _ SupervisedTrainer = &LinearPerceptron{}
@@ -53,7 +58,12 @@ func (m *LinearPerceptron) Train(goldInstances []DecodedInstance) {
 	if m.Continue == nil {
 		m.Continue = DefaultStopCondition
 	}
-	m.train(goldInstances, m.Decoder, m.Iterations)
+	// with a decoder factory, even a single worker trains by mixing
+	if m.Workers > 0 && m.NewDecoder != nil {
+		m.trainParallel(goldInstances, m.Iterations)
+	} else {
+		m.train(goldInstances, m.Decoder, m.Iterations)
+	}
 }
 
 func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyUpdateInstanceDecoder, iterations int) {
@@ -139,11 +149,12 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 				if PercepAllOut {
 					log.Println("Score 1 to")
 				}
-				m.Model.AddSubtract(goldFeatures, decodedFeatures, 1.0)
+				amount := m.amount(m.Model, goldDecoded, decodedInstance, goldFeatures, decodedFeatures)
+				m.Model.AddSubtract(goldFeatures, decodedFeatures, amount)
 				if PercepAllOut {
 					log.Println("Score -1 to")
 				}
-				m.Model.AddSubtract(decodedFeatures, decodedFeatures, -1.0)
+				m.Model.AddSubtract(decodedFeatures, decodedFeatures, -amount)
 				if PercepAllOut {
 					log.Println("ITERATION COMPLETE")
 				}
@@ -209,6 +220,115 @@ func (m *LinearPerceptron) train(goldInstances []DecodedInstance, decoder EarlyU
 	// debug.SetGCPercent(prevGC)
 }
 
+// trainParallel trains with iterative parameter mixing (McDonald et al.
+// 2010): each iteration, the training set is sharded across the workers,
+// each trains a copy of the model on its shard, and the copies are mixed
+// back into the model by averaging their weights (McDonald et al.'s uniform
+// mix). The updater counts the updates of all workers, so its Update must be
+// safe for concurrent use
+func (m *LinearPerceptron) trainParallel(goldInstances []DecodedInstance, iterations int) {
+	var (
+		generations int
+		goldLock    sync.Mutex
+	)
+	if m.Model == nil {
+		panic("Model not initialized")
+	}
+	mixed, ok := m.Model.(MixedModel)
+	if !ok {
+		panic("Parallel training requires a model that can be mixed")
+	}
+	if m.NewDecoder == nil {
+		panic("Parallel training requires a decoder factory")
+	}
+	decoders := make([]EarlyUpdateInstanceDecoder, m.Workers)
+	for w := range decoders {
+		decoders[w] = m.NewDecoder()
+	}
+	prevPrefix := log.Prefix()
+	for i := m.TrainI; m.Continue(i, iterations, generations, m.Model); i++ {
+		log.SetPrefix("IT #" + fmt.Sprintf("%v ", i) + prevPrefix)
+		var (
+			wg      sync.WaitGroup
+			copies  = make([]Model, m.Workers)
+			trained = make([]int, m.Workers)
+			failed  = make([]int, m.Workers)
+		)
+		for w := range copies {
+			copies[w] = m.Model.Copy()
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				trained[w], failed[w] = m.trainShard(goldInstances, w, i == 0, decoders[w], copies[w], &goldLock)
+			}(w)
+		}
+		wg.Wait()
+		mixed.Mix(copies)
+		for w := range copies {
+			generations += trained[w]
+			m.FailedInstances += failed[w]
+		}
+		if m.Log {
+			log.Println("Mixed", m.Workers, "workers after", generations, "instances")
+		}
+	}
+	log.SetPrefix(prevPrefix)
+	m.Model = m.Updater.Finalize(m.Model)
+}
+
+// trainShard trains a copy of the model on every Workers'th instance from
+// the worker's own, and returns the number of instances trained and failed
+func (m *LinearPerceptron) trainShard(goldInstances []DecodedInstance, worker int, first bool, decoder EarlyUpdateInstanceDecoder, model Model, goldLock *sync.Mutex) (int, int) {
+	var trained, failed int
+	for j := m.TrainJ + 1 + worker; j < len(goldInstances); j += m.Workers {
+		// gold decoders share the transition system's oracle
+		goldLock.Lock()
+		goldDecoded, _ := m.GoldDecoder.DecodeGold(goldInstances[j], model)
+		goldLock.Unlock()
+		if goldDecoded == nil && first {
+			if m.Log {
+				log.Println("Worker", worker, "at instance", j, "skipped (decode)")
+			}
+			failed++
+			continue
+		}
+		decodedInstance, decodedFeatures, goldFeatures, earlyUpdatedAt, goldSize, _ := decoder.DecodeEarlyUpdate(goldDecoded, model)
+		if decodedInstance == nil {
+			if m.Log {
+				log.Println("Worker", worker, "at instance", j, "skipped (parse)")
+			}
+			failed++
+			continue
+		}
+		if !goldDecoded.Equal(decodedInstance) {
+			if m.Log {
+				if earlyUpdatedAt < 0 {
+					earlyUpdatedAt = goldSize
+				}
+				log.Println("Worker", worker, "at instance", j, "failed", earlyUpdatedAt, "of", goldSize)
+			}
+			amount := m.amount(model, goldDecoded, decodedInstance, goldFeatures, decodedFeatures)
+			model.AddSubtract(goldFeatures, decodedFeatures, amount)
+			model.AddSubtract(decodedFeatures, decodedFeatures, -amount)
+		} else if m.Log {
+			log.Println("Worker", worker, "at instance", j, "success")
+		}
+		trained++
+		// called concurrently by the workers
+		m.Updater.Update(model)
+	}
+	return trained, failed
+}
+
+// amount is the amount to update the model by, 1 unless the updater scales
+// its updates
+func (m *LinearPerceptron) amount(model Model, gold, decoded DecodedInstance, goldFeatures, decodedFeatures interface{}) int64 {
+	if scaled, ok := m.Updater.(ScaledUpdateStrategy); ok {
+		return scaled.Amount(model, gold, decoded, goldFeatures, decodedFeatures)
+	}
+	return 1
+}
+
 // func (m *LinearPerceptron) Read(reader io.Reader) {
 // 	dec := gob.NewDecoder(reader)
 // 	model := make(Model)
@@ -277,6 +397,13 @@ type UpdateStrategy interface {
 	Finalize(m Model) Model
 }
 
+// ScaledUpdateStrategy is an UpdateStrategy that sets the amount of each
+// update from the gold and decoded instances and their features
+type ScaledUpdateStrategy interface {
+	UpdateStrategy
+	Amount(m Model, gold, decoded DecodedInstance, goldFeatures, decodedFeatures interface{}) int64
+}
+
 type TrivialStrategy struct{}
 
 func (u *TrivialStrategy) Init(m Model, iterations int) {
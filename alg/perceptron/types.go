@@ -83,6 +83,18 @@ type EarlyUpdateInstanceDecoder interface {
 	DecodeEarlyUpdate(i DecodedInstance, m Model) (decoded DecodedInstance, decodedFeatures, goldFeatures interface{}, earlyUpdatedAt, goldSize int, decodeScore float64)
 }
 
+// DecoderFactory makes a decoder for a parallel training worker, decoders
+// of different workers must not share state
+type DecoderFactory func() EarlyUpdateInstanceDecoder
+
+// MixedModel is a model that can be trained in parallel by iterative
+// parameter mixing: workers train copies of the model on shards of the
+// training set, and Mix sets the model to the mix of the trained copies
+type MixedModel interface {
+	Model
+	Mix(copies []Model)
+}
+
 type SupervisedTrainer interface {
 	Train(instances []DecodedInstance)
 }
@@ -4,6 +4,7 @@ import (
 	"container/heap"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +32,7 @@ type Beam struct {
 	Size                 int
 	EstimatedTransitions int
 	EarlyUpdateAt        int
+	Update               UpdateStrategy
 
 	// beam parsing variables
 	currentBeamSize int
@@ -46,6 +48,10 @@ type Beam struct {
 	ShortTempAgenda    bool
 	NoRecover          bool
 	Align              bool
+	// Lazy scores only the legal transitions of a candidate and expands only
+	// those that can make its temporary agenda; it requires ShortTempAgenda
+	// and is for parsing (not DecodeTest)
+	Lazy bool
 
 	// used for performance tuning
 	lastRoundStart time.Time
@@ -60,6 +66,7 @@ type Beam struct {
 }
 
 var _ Interface = &Beam{}
+var _ KBest = &Beam{}
 var _ perceptron.EarlyUpdateInstanceDecoder = &Beam{}
 
 func (b *Beam) Name() string {
@@ -91,18 +98,15 @@ func (b *Beam) StartItem(p Problem) []Candidate {
 	if b.EstimatedTransitions == 0 {
 		b.EstimatedTransitions = b.Size
 	}
+	if b.Lazy && (!b.ShortTempAgenda || b.DecodeTest) {
+		panic("Lazy expansion requires ShortTempAgenda, and does not score DecodeTest")
+	}
 
 	c := b.Base.Copy()
 	c.Clear()
 	c.Init(p)
 
-	if b.candidateScorePool == nil {
-		if b.ScoredStoreDense {
-			b.candidateScorePool = &sync.Pool{New: featurevector.MakeDenseStore}
-		} else {
-			b.candidateScorePool = &sync.Pool{New: featurevector.MakeMapStore}
-		}
-	}
+	b.initScorePool()
 	b.currentBeamSize = 0
 	firstCandidates := make([]Candidate, 1)
 	firstCandidate := &ScoredConfiguration{c, transition.ConstTransition(0), NewScoreState(), nil, 0, 0, true, b.Averaged}
@@ -113,6 +117,16 @@ func (b *Beam) StartItem(p Problem) []Candidate {
 	return firstCandidates
 }
 
+func (b *Beam) initScorePool() {
+	if b.candidateScorePool == nil {
+		if b.ScoredStoreDense {
+			b.candidateScorePool = &sync.Pool{New: featurevector.MakeDenseStore}
+		} else {
+			b.candidateScorePool = &sync.Pool{New: featurevector.MakeMapStore}
+		}
+	}
+}
+
 func (b *Beam) Clear(agenda Agenda) Agenda {
 	// start := time.Now()
 	if agenda == nil {
@@ -205,21 +219,24 @@ func (b *Beam) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
 			transType   byte
 			transitions []int
 		)
-		scores = b.candidateScorePool.Get().(featurevector.ScoredStore)
-		// scores.Init()
-		scores.Clear()
 		transType, transitions = b.TransFunc.GetTransitions(currentConf)
 		if AllOut {
 			// log.Println("\tSetting transitions to", transitions)
 		}
-		scores.SetTransitions(transitions)
-		scorer := b.Model.(*TransitionModel.AvgMatrixSparse)
-		if b.DecodeTest {
-			if b.ScoredStoreDense {
-
-				scores.(*featurevector.ArrayStore).Generation = b.IntegrationGeneration
-			} else {
-				scores.(*featurevector.MapStore).Generation = b.IntegrationGeneration
+		scorer := b.Model
+		// lazy expansion scores only the legal transitions, without a store
+		if !b.Lazy {
+			scores = b.candidateScorePool.Get().(featurevector.ScoredStore)
+			// scores.Init()
+			scores.Clear()
+			scores.SetTransitions(transitions)
+			if b.DecodeTest {
+				if b.ScoredStoreDense {
+
+					scores.(*featurevector.ArrayStore).Generation = b.IntegrationGeneration
+				} else {
+					scores.(*featurevector.MapStore).Generation = b.IntegrationGeneration
+				}
 			}
 		}
 
@@ -237,17 +254,25 @@ func (b *Beam) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
 		} else {
 			newFeatList = &transition.FeaturesList{feats, conf.GetLastTransition(), nil}
 		}
-		scorer.SetTransitionScores(feats, scores, b.DecodeTest)
+		var transNums []int
+		var lazyScores []int64
+		if b.Lazy {
+			transitions, transNums, lazyScores = b.lazyTransitions(candidate, currentConf.Assignment(), transitions, scorer.TransitionScores(transitions, feats))
+		} else {
+			scorer.SetTransitionScores(feats, scores, b.DecodeTest)
+		}
 		// log.Println("\t\tScores set to", scores.(*featurevector.ArrayStore).ScoreMap())
 		if AllOut {
 			log.Println("\tExpanding candidate", candidateNum+1, "last transition", currentConf.GetLastTransition(), "score", candidate.Score())
 			// log.Println("\tCandidate:", candidate.C.GetSequence())
 			log.Println("\tCandidate:", candidate)
 		}
-		for _, curTransition := range transitions {
+		for i, curTransition := range transitions {
 			yielded = true
-			// score1 = b.Model.TransitionModel().TransitionScore(transition, feats)
-			if transitionScore, transitionExists = scores.Get(curTransition); transitionExists {
+			if b.Lazy {
+				transNum, score = transNums[i], lazyScores[i]
+			} else if transitionScore, transitionExists = scores.Get(curTransition); transitionExists {
+				// score1 = b.Model.TransitionModel().TransitionScore(transition, feats)
 				score = transitionScore
 			} else {
 				score = 0.0
@@ -276,13 +301,77 @@ func (b *Beam) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
 			}
 			candidateChan <- c
 		}
-		b.candidateScorePool.Put(scores)
+		if scores != nil {
+			b.candidateScorePool.Put(scores)
+		}
 		close(candidateChan)
 	}(conf, retChan)
 	// b.DurExpanding += time.Since(start)
 	return retChan
 }
 
+// scoredTransitions is a heap of the transitions of a candidate, ordered
+// by the score of the candidate with each transition
+type scoredTransitions struct {
+	transNums []int
+	scores    []float64
+}
+
+func (s *scoredTransitions) Len() int {
+	return len(s.transNums)
+}
+
+func (s *scoredTransitions) Less(i, j int) bool {
+	return s.scores[i] < s.scores[j]
+}
+
+func (s *scoredTransitions) Swap(i, j int) {
+	s.transNums[i], s.transNums[j] = s.transNums[j], s.transNums[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
+
+func (s *scoredTransitions) Push(x interface{}) {
+	scored := x.(*scoredTransitions)
+	s.transNums = append(s.transNums, scored.transNums[0])
+	s.scores = append(s.scores, scored.scores[0])
+}
+
+func (s *scoredTransitions) Pop() interface{} {
+	n := len(s.transNums) - 1
+	s.transNums, s.scores = s.transNums[:n], s.scores[:n]
+	return nil
+}
+
+// lazyTransitions selects the transitions of a candidate that Insert would
+// keep in a temporary agenda of the beam's size, given their model scores,
+// without building their scored configurations. It makes the same heap
+// operations on the same scores as Insert, and returns the kept transitions,
+// their numbers and model scores in the order of the resulting heap, so
+// that inserting them yields the same agenda
+func (b *Beam) lazyTransitions(candidate *ScoredConfiguration, assignment uint16, transitions []int, scores []int64) ([]int, []int, []int64) {
+	var (
+		selected = &scoredTransitions{make([]int, 0, b.Size), make([]float64, 0, b.Size)}
+		next     = &scoredTransitions{make([]int, 1), make([]float64, 1)}
+	)
+	for transNum, score := range scores {
+		next.transNums[0] = transNum
+		next.scores[0] = candidate.InternalScores.ScoreWith(score, assignment, candidate.Averaged)
+		if selected.Len() == b.Size {
+			if selected.scores[0] > next.scores[0] {
+				continue
+			}
+			rlheap.Pop(selected)
+		}
+		rlheap.Push(selected, next)
+	}
+	kept := make([]int, len(selected.transNums))
+	keptScores := make([]int64, len(selected.transNums))
+	for i, transNum := range selected.transNums {
+		kept[i], keptScores[i] = transitions[transNum], scores[transNum]
+	}
+	return kept, selected.transNums, keptScores
+}
+
 func (b *Beam) Best(a Agenda) Candidate {
 	agenda := a.(*BaseAgenda)
 	// agenda.ShowSwap = true
@@ -341,6 +430,25 @@ func (b *Beam) Best(a Agenda) Candidate {
 	return agenda.Confs[0]
 }
 
+// BestK returns copies of the K highest scoring candidates of the agenda
+func (b *Beam) BestK(a Agenda, K int) []Candidate {
+	agenda := a.(*BaseAgenda)
+	confs := make([]*ScoredConfiguration, len(agenda.Confs))
+	copy(confs, agenda.Confs)
+	sort.SliceStable(confs, func(i, j int) bool {
+		return confs[i].Score() > confs[j].Score()
+	})
+	if K > len(confs) {
+		K = len(confs)
+	}
+	candidates := make([]Candidate, K)
+	for i, candidate := range confs[:K] {
+		candidate.Expand(b.TransFunc)
+		candidates[i] = candidate.Copy()
+	}
+	return candidates
+}
+
 func (b *Beam) Top(a Agenda) Candidate {
 	// start := time.Now()
 	agenda := a.(*BaseAgenda)
@@ -450,6 +558,23 @@ func (b *Beam) Parse(problem Problem) (transition.Configuration, interface{}) {
 	return beamScored.C, resultParams
 }
 
+// ParseNBest parses problem and returns up to K of the highest scoring
+// terminal configurations of the final beam with their scores, best first
+func (b *Beam) ParseNBest(problem Problem, K int) ([]transition.Configuration, []float64) {
+	start := time.Now()
+	prefix := log.Prefix()
+	candidates := SearchNBest(b, problem, b.Size, K)
+	configurations := make([]transition.Configuration, len(candidates))
+	scores := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		configurations[i] = candidate.(*ScoredConfiguration).C
+		scores[i] = candidate.Score()
+	}
+	log.SetPrefix(prefix)
+	b.DurTotal += time.Since(start)
+	return configurations, scores
+}
+
 func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
 	b.EarlyUpdateAt = -1
 	start := time.Now()
@@ -469,8 +594,12 @@ func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perc
 	// log.Println(goldSequence[len(goldSequence)-1].C.GetSequence())
 	b.ReturnModelValue = true
 
+	if b.Update != EarlyUpdate {
+		b.scoreGold(goldSequence)
+	}
+
 	// log.Println("Begin search..")
-	beamResult, goldResult := SearchEarlyUpdate(b, sent, b.Size, goldSequence)
+	beamResult, goldResult := SearchUpdate(b, sent, b.Size, goldSequence, b.Update)
 	// log.Println("Search ended")
 
 	beamScored := beamResult.(*ScoredConfiguration)
@@ -559,6 +688,32 @@ func (b *Beam) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perc
 	return &perceptron.Decoded{goldInstance.Instance(), beamScored.C}, parsedFeatures, goldFeatures, b.EarlyUpdateAt, len(goldSequence) - 1, beamScore
 }
 
+// scoreGold scores the gold sequence with the model, so gold prefixes can be
+// compared with the beam after the gold falls off it
+func (b *Beam) scoreGold(goldSequence ScoredConfigurations) {
+	b.initScorePool()
+	scores := b.candidateScorePool.Get().(featurevector.ScoredStore)
+	scorer := b.Model
+	for i, gold := range goldSequence {
+		gold.Averaged = b.Averaged
+		if i == 0 {
+			gold.InternalScores = NewScoreState()
+			continue
+		}
+		prev := goldSequence[i-1]
+		gold.InternalScores = prev.InternalScores.Copy()
+		if gold.Transition == nil || prev.Features == nil {
+			continue
+		}
+		scores.Clear()
+		scores.SetTransitions([]int{gold.Transition.Value()})
+		scorer.SetTransitionScores(prev.Features.Features, scores, false)
+		score, _ := scores.Get(gold.Transition.Value())
+		gold.AddScore(score, prev.C.Assignment())
+	}
+	b.candidateScorePool.Put(scores)
+}
+
 func (b *Beam) Aligned() bool {
 	return b.Align
 }
@@ -577,7 +732,7 @@ func (b *Beam) Idle(c Candidate, candidateNum int) Candidate {
 	scores := b.candidateScorePool.Get().(featurevector.ScoredStore)
 	scores.Clear()
 	scores.SetTransitions([]int{transition.IDLE.Value()})
-	scorer := b.Model.(*TransitionModel.AvgMatrixSparse)
+	scorer := b.Model
 	if b.DecodeTest {
 		if b.ScoredStoreDense {
 			scores.(*featurevector.ArrayStore).Generation = b.IntegrationGeneration
@@ -661,6 +816,34 @@ func (s *ScoreState) Add(score int64, assignment uint16) {
 	// log.Println("After adding", s)
 }
 
+// ScoreWith is the score of the state after adding score to an assignment,
+// as Average or Total would return it, without copying the state
+func (s ScoreState) ScoreWith(score int64, assignment uint16, averaged bool) float64 {
+	assignmentInt := int(assignment)
+	if !averaged {
+		result := score
+		for _, value := range s {
+			result += value.Total
+		}
+		return float64(result)
+	}
+	var result float64
+	for i, value := range s {
+		if i == assignmentInt {
+			value.Add(score)
+		}
+		result += value.Average()
+	}
+	for i := len(s); i <= assignmentInt; i++ {
+		var value AssignmentScore
+		if i == assignmentInt {
+			value.Add(score)
+		}
+		result += value.Average()
+	}
+	return result
+}
+
 func NewScoreState() ScoreState {
 	return []AssignmentScore{}
 }
@@ -691,16 +874,12 @@ func (scs ScoredConfigurations) Get(i int) Candidate {
 }
 
 func (scs ScoredConfigurations) Equal(otherEq util.Equaler) bool {
-	switch other := otherEq.(type) {
-	case Candidate:
-		return scs[0].Equal(other)
-	default:
-		// log.Println("Equating", scs[len(scs)-1].C, "and", otherEq)
-		// log.Println(scs[len(scs)-1].C.GetSequence())
-		// log.Println(otherEq.GetSequence())
-		return otherEq.Equal(scs[len(scs)-1].C)
-		panic("Cannot compare to other")
-	}
+	// a Candidate's Equal takes a Candidate, so it can't be an Equaler;
+	// compare with the last configuration of the sequence
+	// log.Println("Equating", scs[len(scs)-1].C, "and", otherEq)
+	// log.Println(scs[len(scs)-1].C.GetSequence())
+	// log.Println(otherEq.GetSequence())
+	return otherEq.Equal(scs[len(scs)-1].C)
 }
 
 func (s *ScoredConfiguration) AddScore(newScore int64, assignment uint16) {
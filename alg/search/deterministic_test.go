@@ -1,21 +1,95 @@
-package search
+package search_test
 
 import (
-	"yap/alg/featurevector"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
 
+	"yap/alg/featurevector"
 	"yap/alg/perceptron"
+	"yap/alg/search"
 	"yap/alg/transition"
 	TransitionModel "yap/alg/transition/model"
-	"yap/nlp/parser/dependency"
+	"yap/nlp/format/conll"
+	dependency "yap/nlp/parser/dependency/transition"
 	"yap/nlp/types"
 	"yap/util"
-	// "fmt"
-	"log"
-	"runtime"
-	"sort"
-	"testing"
 )
 
+const testConll = "1	Economic	Economic	NN	NN	_	2	ATT	_	_\n" +
+	"2	news	news	NN	NN	_	3	SBJ	_	_\n" +
+	"3	had	had	VB	VB	_	0	ROOT	_	_\n" +
+	"4	little	little	ADJ	ADJ	_	5	ATT	_	_\n" +
+	"5	effect	effect	NN	NN	_	3	OBJ	_	_\n" +
+	"6	on	on	NN	NN	_	5	ATT	_	_\n" +
+	"7	financial	financial	NN	NN	_	8	ATT	_	_\n" +
+	"8	markets	markets	NN	NN	_	6	PC	_	_\n" +
+	"9	.	.	yyDOT	yyDOT	_	3	PU	_	_\n" +
+	"\n"
+
+var (
+	TEST_RELATIONS     []types.DepRel = []types.DepRel{"ATT", "SBJ", "PC", "OBJ", "PU", "PRED", types.ROOT_LABEL}
+	TEST_RICH_FEATURES [][2]string    = [][2]string{
+		{"S0|w", "S0|w"},
+		{"S0|p", "S0|w"},
+		{"S0|w|p", "S0|w"},
+		{"N0|w", "N0|w"},
+		{"N0|p", "N0|w"},
+		{"N0|w|p", "N0|w"},
+		{"N1|w", "N1|w"},
+		{"N1|p", "N1|w"},
+		{"S0|w+N0|w", "S0|w;N0|w"},
+		{"S0|p+N0|p", "S0|w;N0|w"},
+		{"S0|p+N0|p+N1|p", "S0|w;N0|w"},
+		{"S0|w|d", "S0|w;N0|w"},
+	}
+	TRANSITIONS_ENUM                     *util.EnumSet
+	TEST_ENUM_RELATIONS                  *util.EnumSet
+	EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
+	SH, RE, PR, LA, RA                   int
+	TEST_SENT                            types.TaggedSentence
+	TEST_GRAPH                           types.LabeledDependencyGraph
+)
+
+// SetupTestEnum enumerates the relations and the test sentence, and reads
+// its gold graph
+func SetupTestEnum() {
+	TEST_ENUM_RELATIONS = util.NewEnumSet(len(TEST_RELATIONS))
+	for _, label := range TEST_RELATIONS {
+		TEST_ENUM_RELATIONS.Add(label)
+	}
+	EWord, EPOS, EWPOS = util.NewEnumSet(10), util.NewEnumSet(5), util.NewEnumSet(10)
+	EMHost, EMSuffix = util.NewEnumSet(1), util.NewEnumSet(1)
+	EMHost.Add("")
+	EMSuffix.Add("")
+	sents, err := conll.Read(strings.NewReader(testConll), 0)
+	if err != nil {
+		panic(err)
+	}
+	TEST_GRAPH = conll.Conll2Graph(sents[0], EWord, EPOS, EWPOS, TEST_ENUM_RELATIONS, EMHost, EMSuffix)
+	TEST_SENT = TEST_GRAPH.TaggedSentence()
+}
+
+// SetupEagerTransEnum enumerates the transitions as the dep command does, a
+// left and a right arc for each relation
+func SetupEagerTransEnum() {
+	TRANSITIONS_ENUM = util.NewEnumSet(len(TEST_RELATIONS)*2 + 4)
+	TRANSITIONS_ENUM.Add("NO")
+	SH, _ = TRANSITIONS_ENUM.Add("SH")
+	RE, _ = TRANSITIONS_ENUM.Add("RE")
+	PR, _ = TRANSITIONS_ENUM.Add("PR")
+	LA = TRANSITIONS_ENUM.Len()
+	for _, transition := range TEST_RELATIONS {
+		TRANSITIONS_ENUM.Add("LA-" + string(transition))
+	}
+	RA = TRANSITIONS_ENUM.Len()
+	for _, transition := range TEST_RELATIONS {
+		TRANSITIONS_ENUM.Add("RA-" + string(transition))
+	}
+}
+
 func PrintGraph(graph types.LabeledDependencyGraph) {
 	arcIndex := make(map[int]types.LabeledDepArc, graph.NumberOfNodes())
 	var (
@@ -66,52 +140,47 @@ func TestDeterministic(t *testing.T) {
 	SetupTestEnum()
 	SetupEagerTransEnum()
 	runtime.GOMAXPROCS(runtime.NumCPU())
-	extractor := &GenericExtractor{
+	extractor := &transition.GenericExtractor{
 		EFeatures: util.NewEnumSet(len(TEST_RICH_FEATURES)),
 		EWord:     EWord,
 		EPOS:      EPOS,
 		EWPOS:     EWPOS,
 		ERel:      TEST_ENUM_RELATIONS,
+		EMHost:    EMHost,
+		EMSuffix:  EMSuffix,
 	}
-	extractor.Init()
+	extractor.InitTypes([]byte{dependency.TransitionType})
 	// verify load
 	for _, featurePair := range TEST_RICH_FEATURES {
-		if err := extractor.LoadFeature(featurePair[0], featurePair[1]); err != nil {
-			t.Error("Failed to load feature", err.Error())
-			t.FailNow()
+		if err := extractor.LoadFeature(featurePair[0], featurePair[1], string(dependency.TransitionType), false, false); err != nil {
+			t.Fatal("Failed to load feature", err.Error())
 		}
 	}
-	arcSystem := &ArcStandard{
-		SHIFT:       SH,
-		LEFT:        LA,
-		RIGHT:       RA,
-		Relations:   TEST_ENUM_RELATIONS,
-		Transitions: TRANSITIONS_ENUM,
-	}
-
-	// arcSystem := &ArcEager{
-	// 	ArcStandard: ArcStandard{
-	// 		SHIFT:       SH,
-	// 		LEFT:        LA,
-	// 		RIGHT:       RA,
-	// 		Relations:   TEST_ENUM_RELATIONS,
-	// 		Transitions: TRANSITIONS_ENUM,
-	// 	},
-	// 	REDUCE:  RE,
-	// 	POPROOT: PR,
-	// }
+	arcSystem := &dependency.ArcEager{
+		ArcStandard: dependency.ArcStandard{
+			SHIFT:       SH,
+			LEFT:        LA,
+			RIGHT:       RA,
+			Relations:   TEST_ENUM_RELATIONS,
+			Transitions: TRANSITIONS_ENUM,
+		},
+		REDUCE:  RE,
+		POPROOT: PR,
+	}
 	arcSystem.AddDefaultOracle()
 	transitionSystem := transition.TransitionSystem(arcSystem)
 
-	conf := &SimpleConfiguration{
-		EWord:  EWord,
-		EPOS:   EPOS,
-		EWPOS:  EWPOS,
-		ERel:   TEST_ENUM_RELATIONS,
-		ETrans: TRANSITIONS_ENUM,
+	conf := &dependency.SimpleConfiguration{
+		EWord:    EWord,
+		EPOS:     EPOS,
+		EWPOS:    EWPOS,
+		EMHost:   EMHost,
+		EMSuffix: EMSuffix,
+		ERel:     TEST_ENUM_RELATIONS,
+		ETrans:   TRANSITIONS_ENUM,
 	}
 
-	deterministic := &Deterministic{
+	deterministic := &search.Deterministic{
 		TransFunc:          transitionSystem,
 		FeatExtractor:      extractor,
 		ReturnModelValue:   true,
@@ -119,83 +188,47 @@ func TestDeterministic(t *testing.T) {
 		ShowConsiderations: false,
 		Base:               conf,
 		NoRecover:          true,
+		DefaultTransType:   dependency.TransitionType,
 	}
 	decoder := perceptron.EarlyUpdateInstanceDecoder(deterministic)
 	goldDecoder := perceptron.InstanceDecoder(deterministic)
 	updater := new(TransitionModel.AveragedModelStrategy)
-
-	model := TransitionModel.NewAvgMatrixSparse(extractor.EFeatures.Len(), nil)
 	perceptronInstance := &perceptron.LinearPerceptron{Decoder: decoder, GoldDecoder: goldDecoder, Updater: updater}
-	perceptronInstance.Init(model)
-	goldModel := dependency.TransitionParameterModel(&PerceptronModel{model})
 
-	goldGraph, goldParams := deterministic.ParseOracle(GetTestDepGraph(), nil, goldModel)
+	goldInstance := &perceptron.Decoded{InstanceVal: TEST_SENT, DecodedVal: TEST_GRAPH}
+	_, goldParams := deterministic.ParseOracle(goldInstance)
 	if goldParams == nil {
 		t.Fatal("Got nil params from deterministic oracle parsing, can't test deterministic-perceptron model")
 	}
-	seq := goldParams.(*ParseResultParameters).Sequence
-	log.Println("\n", seq.String())
-	goldSequence := make(ScoredConfigurations, len(seq))
-	var (
-		lastFeatures *transition.FeaturesList
-		curFeats     []featurevector.Feature
-	)
-	// extractor.Log = true
-	for i := len(seq) - 1; i >= 0; i-- {
-		// for i := 0; i < len(seq); i++ {
-		val := seq[i]
-		// log.Println("Conf:", val)
-		curFeats = extractor.Features(val)
-		// log.Printf("\t%d %s %v\n", i, "Features:", curFeats)
-		lastFeatures = &transition.FeaturesList{curFeats, val.GetLastTransition(), lastFeatures}
-		goldSequence[len(seq)-i-1] = &ScoredConfiguration{val.(DependencyConfiguration), val.GetLastTransition(), 0.0, lastFeatures, 0, 0, true}
-	}
-	t.Errorf("bla")
-	goldDirected := goldGraph.(types.LabeledDependencyGraph)
-	for i := 0; i <= goldDirected.NumberOfArcs(); i++ {
-		arc := goldDirected.GetLabeledArc(i)
-		log.Println("Arc", i, arc)
-	}
-
-	goldInstances := []perceptron.DecodedInstance{
-		&perceptron.Decoded{perceptron.Instance(rawTestSent), GetTestDepGraph()}}
-	// log.Println(goldSequence)
+	goldSequence := goldParams.(*search.ParseResultParameters).Sequence
+
+	goldInstances := []perceptron.DecodedInstance{goldInstance}
 	// train with increasing iterations
-	// convergenceIterations := []int{1, 8, 16, 24, 32}
-	// deterministic.ShowConsiderations = true
-	convergenceIterations := []int{32}
+	convergenceIterations := []int{1, 4, 16}
 	convergenceSharedSequence := make([]int, 0, len(convergenceIterations))
 	for _, iterations := range convergenceIterations {
 		perceptronInstance.Iterations = iterations
-		// perceptron.Log = true
-		model = TransitionModel.NewAvgMatrixSparse(extractor.EFeatures.Len(), nil)
+		model := TransitionModel.NewAvgMatrixSparse(extractor.EFeatures.Len(), nil, false)
 		perceptronInstance.Init(model)
-
-		// deterministic.ShowConsiderations = true
 		perceptronInstance.Train(goldInstances)
 
-		parseModel := dependency.TransitionParameterModel(&PerceptronModel{model})
-		deterministic.ShowConsiderations = false
-		graph, params := deterministic.Parse(TEST_SENT, nil, parseModel)
-		labeledGraph := graph.(types.LabeledDependencyGraph)
-		seq := params.(*ParseResultParameters).Sequence
-		log.Println("\n", seq.String())
-		PrintGraph(labeledGraph)
-		sharedSteps := goldSequence[len(goldSequence)-1].C.GetSequence().SharedTransitions(seq)
+		deterministic.Model = perceptronInstance.Model.(TransitionModel.Interface)
+		_, params := deterministic.Parse(TEST_SENT)
+		seq := params.(*search.ParseResultParameters).Sequence
+		sharedSteps := goldSequence.SharedTransitions(seq)
 		convergenceSharedSequence = append(convergenceSharedSequence, sharedSteps)
 	}
 
 	// verify convergence
-	log.Println(convergenceSharedSequence)
-	if !sort.IntsAreSorted(convergenceSharedSequence) || convergenceSharedSequence[0] == convergenceSharedSequence[len(convergenceSharedSequence)-1] {
-		t.Error("Model not converging, shared sequences lengths:", convergenceSharedSequence)
+	if !sort.IntsAreSorted(convergenceSharedSequence) || convergenceSharedSequence[len(convergenceSharedSequence)-1] != len(goldSequence) {
+		t.Error("Model not converging to the gold sequence of", len(goldSequence), "transitions, shared sequences lengths:", convergenceSharedSequence)
 	}
 }
 
 func TestArrayDiff(t *testing.T) {
 	left := []featurevector.Feature{"def", "abc"}
 	right := []featurevector.Feature{"def", "ghi"}
-	oLeft, oRight := ArrayDiff(left, right)
+	oLeft, oRight := search.ArrayDiff(left, right)
 	if len(oLeft) != 1 {
 		t.Error("Wrong len for oLeft", oLeft)
 	}
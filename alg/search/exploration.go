@@ -0,0 +1,119 @@
+package search
+
+import (
+	"math/rand"
+
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	TransitionModel "yap/alg/transition/model"
+	"yap/util"
+)
+
+// ErrorExploration trains greedily with a dynamic oracle (Goldberg & Nivre
+// 2012): the instance is parsed to the end, every prediction that is not zero
+// cost is updated against the best scoring zero cost transition, and after
+// ExploreAfter decoded instances the parser follows its own wrong predictions
+// with probability ExploreProbability, learning to recover from them
+type ErrorExploration struct {
+	*Deterministic
+	Oracle             transition.DynamicOracle
+	ExploreAfter       int
+	ExploreProbability float64
+	Seed               int64
+
+	decoded int
+	random  *rand.Rand
+}
+
+var _ perceptron.InstanceDecoder = &ErrorExploration{}
+var _ perceptron.EarlyUpdateInstanceDecoder = &ErrorExploration{}
+
+// explorationGold holds the gold structure for the dynamic oracle, it equals
+// only itself, so only error free decodes equal the gold
+type explorationGold struct {
+	Gold interface{}
+}
+
+func (g *explorationGold) Equal(other util.Equaler) bool {
+	otherGold, ok := other.(*explorationGold)
+	return ok && otherGold == g
+}
+
+func (e *ErrorExploration) DecodeGold(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	return &perceptron.Decoded{InstanceVal: goldInstance.Instance(), DecodedVal: &explorationGold{Gold: goldInstance.Decoded()}}, nil
+}
+
+func (e *ErrorExploration) exploring() bool {
+	if e.random == nil {
+		e.random = rand.New(rand.NewSource(e.Seed))
+	}
+	return e.decoded > e.ExploreAfter && e.random.Float64() < e.ExploreProbability
+}
+
+func (e *ErrorExploration) DecodeEarlyUpdate(goldInstance perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	if e.TransFunc == nil {
+		panic("Can't parse without a transition system")
+	}
+	gold := goldInstance.Decoded().(*explorationGold)
+	model := m.(TransitionModel.Interface)
+	e.decoded++
+
+	c := e.Base.Copy()
+	c.Clear()
+	c.Init(goldInstance.Instance())
+	e.Oracle.SetGold(gold.Gold)
+
+	var (
+		predList, goldList = &transition.FeaturesList{}, &transition.FeaturesList{}
+		firstError         = -1
+		steps              int
+		feats              []featurevector.Feature
+	)
+	for ; !c.Terminal(); steps++ {
+		var (
+			pred, bestZeroCost   transition.Transition
+			predScore, zeroScore int64
+		)
+		tType, tChan := e.TransFunc.YieldTransitions(c)
+		feats = e.FeatExtractor.Features(c, false, tType, nil)
+		for t := range tChan {
+			score := model.TransitionScore(transition.ConstTransition(t), feats)
+			if pred == nil || score > predScore {
+				pred, predScore = &transition.TypedTransition{T: tType, V: t}, score
+			}
+		}
+		if pred == nil {
+			break
+		}
+		var predZeroCost bool
+		for _, t := range e.Oracle.ZeroCost(c) {
+			score := model.TransitionScore(transition.ConstTransition(t.Value()), feats)
+			if bestZeroCost == nil || score > zeroScore {
+				bestZeroCost, zeroScore = t, score
+			}
+			if t.Equal(pred) {
+				predZeroCost = true
+			}
+		}
+		next := pred
+		// without a zero cost transition there is nothing to update towards,
+		// so the prediction is followed without an update
+		if !predZeroCost && bestZeroCost != nil {
+			if firstError < 0 {
+				firstError = steps
+			}
+			predList.Features, goldList.Features = feats, feats
+			predList = &transition.FeaturesList{Transition: pred, Previous: predList}
+			goldList = &transition.FeaturesList{Transition: bestZeroCost, Previous: goldList}
+			if !e.exploring() {
+				next = bestZeroCost
+			}
+		}
+		c = e.TransFunc.Transition(c, next)
+	}
+	if firstError < 0 {
+		return goldInstance, predList, goldList, firstError, steps, 0
+	}
+	return &perceptron.Decoded{InstanceVal: goldInstance.Instance(), DecodedVal: c}, predList, goldList, firstError, steps, 0
+}
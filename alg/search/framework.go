@@ -56,6 +56,12 @@ type Interface interface {
 	Aligned() bool
 }
 
+// KBest is implemented by searches that can return the K best candidates
+// of the final agenda, best first
+type KBest interface {
+	BestK(a Agenda, K int) []Candidate
+}
+
 type IdleFunc func(c Candidate, candidateNum int) Candidate
 
 type Idle interface {
@@ -63,18 +69,43 @@ type Idle interface {
 }
 
 func Search(b Interface, problem Problem, B int) Candidate {
-	candidate, _ := search(b, problem, B, 1, false, nil)
+	candidate, _, _ := search(b, problem, B, 1, false, nil, EarlyUpdate)
 	return candidate
 }
 
+// SearchNBest returns up to K of the best candidates in the final agenda,
+// best first. Searches that do not implement KBest return only the best one
+func SearchNBest(b Interface, problem Problem, B, K int) []Candidate {
+	_, _, kBest := search(b, problem, B, K, false, nil, EarlyUpdate)
+	return kBest
+}
+
 func SearchEarlyUpdate(b Interface, problem Problem, B int, goldSequence Candidates) (Candidate, Candidate) {
-	return search(b, problem, B, 1, true, goldSequence)
+	return SearchUpdate(b, problem, B, goldSequence, EarlyUpdate)
 }
 
-func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates) (Candidate, Candidate) {
+// SearchUpdate searches along the gold sequence and returns the best beam
+// candidate and the gold prefix the strategy updates on. With max violation
+// and latest update the gold is followed to its end, and gold candidates
+// must be scored by the model for the violations to be found
+func SearchUpdate(b Interface, problem Problem, B int, goldSequence Candidates, strategy UpdateStrategy) (Candidate, Candidate) {
+	best, goldValue, _ := search(b, problem, B, 1, true, goldSequence, strategy)
+	return best, goldValue
+}
+
+// violation is a beam and gold prefix pair where the best candidate of the
+// beam outscores the gold
+type violation struct {
+	best, gold Candidate
+	at         int
+	margin     float64
+}
+
+func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSequence Candidates, strategy UpdateStrategy) (Candidate, Candidate, []Candidate) {
 	var (
 		goldValue Candidate
 		best      Candidate
+		kBest     []Candidate
 		agenda    Agenda
 
 		// for early update
@@ -91,7 +122,24 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 		idleCandidates        bool = false
 		idleFunc              IdleFunc
 		idleGoldTransitions   int
+
+		// for max violation and latest update
+		followGold bool = earlyUpdate && strategy != EarlyUpdate
+		updateAt   *violation
 	)
+	// record keeps the violation at a beam and gold pair the strategy prefers
+	record := func(best, gold Candidate, at int) {
+		if best == nil || best.Equal(gold) {
+			return
+		}
+		margin := best.Score() - gold.Score()
+		if margin < 0 {
+			return
+		}
+		if updateAt == nil || strategy == LatestUpdate || margin > updateAt.margin {
+			updateAt = &violation{best, gold, at, margin}
+		}
+	}
 	tempAgendas := make([][]Candidate, 0, B)
 
 	if idleCandidates {
@@ -251,7 +299,10 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 
 		// early update
 		if earlyUpdate {
-			if !goldExists || goldIndex+1 >= (goldSequence.Len()+idleGoldTransitions) {
+			if followGold {
+				record(bestBeamCandidate, goldValue, util.Min(goldIndex, bestBeamCandidate.Len()-1))
+			}
+			if (!goldExists && !followGold) || goldIndex+1 >= (goldSequence.Len()+idleGoldTransitions) {
 				if AllOut {
 					log.Println("EARLY UPDATE")
 				}
@@ -302,6 +353,9 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 
 		// if GOALTEST(problem,best)
 		if ((allTerminal || earlyUpdate) && b.GoalTest(problem, best, i)) || i > MAX_TRANSITIONS {
+			if followGold && best.Terminal() {
+				record(best, goldValue, goldIndex)
+			}
 			if AllOut {
 				log.Println("Next Round", i-1)
 				if earlyUpdate {
@@ -320,10 +374,23 @@ func search(b Interface, problem Problem, B, topK int, earlyUpdate bool, goldSeq
 			log.Println("Next Round", i-1)
 		}
 	}
+	if updateAt != nil {
+		if AllOut {
+			log.Println("UPDATE", strategy, "at", updateAt.at, "margin", updateAt.margin)
+		}
+		b.SetEarlyUpdate(updateAt.at)
+		best, goldValue = updateAt.best, updateAt.gold
+	}
 	if !earlyUpdate {
 		best = b.Best(agenda)
+		if kBestSearch, ok := b.(KBest); ok && topK > 1 {
+			kBest = kBestSearch.BestK(agenda, topK)
+		}
 	}
 	best = best.Copy()
+	if kBest == nil {
+		kBest = []Candidate{best}
+	}
 	agenda = b.Clear(agenda)
-	return best, goldValue
+	return best, goldValue, kBest
 }
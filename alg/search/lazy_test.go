@@ -0,0 +1,171 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	TransitionModel "yap/alg/transition/model"
+)
+
+// lazyTestConf is a sequence of choices of a fixed length
+type lazyTestConf struct {
+	transition.Configuration
+	n    int
+	seq  []int
+	last transition.Transition
+}
+
+func (c *lazyTestConf) Init(p interface{}) {
+	c.n, c.seq = p.(int), nil
+}
+
+func (c *lazyTestConf) Clear() {
+	c.seq = nil
+}
+
+func (c *lazyTestConf) Copy() transition.Configuration {
+	return &lazyTestConf{n: c.n, seq: append([]int(nil), c.seq...), last: c.last}
+}
+
+func (c *lazyTestConf) Terminal() bool                            { return len(c.seq) == c.n }
+func (c *lazyTestConf) Len() int                                  { return len(c.seq) }
+func (c *lazyTestConf) SetLastTransition(t transition.Transition) { c.last = t }
+func (c *lazyTestConf) GetLastTransition() transition.Transition  { return c.last }
+func (c *lazyTestConf) Assignment() uint16                        { return 0 }
+func (c *lazyTestConf) String() string                            { return fmt.Sprint(c.seq) }
+
+// lazyTestSystem allows the same transitions at every step
+type lazyTestSystem struct {
+	transition.TransitionSystem
+	transitions []int
+}
+
+func (s *lazyTestSystem) GetTransitions(conf transition.Configuration) (byte, []int) {
+	return 'T', s.transitions
+}
+
+func (s *lazyTestSystem) Transition(from transition.Configuration, t transition.Transition) transition.Configuration {
+	c := from.Copy().(*lazyTestConf)
+	c.seq = append(c.seq, t.Value())
+	c.last = t
+	return c
+}
+
+// lazyTestExtractor extracts the position and the last choice, a generated
+// feature and a missing one
+type lazyTestExtractor struct{}
+
+func (e *lazyTestExtractor) Features(instance perceptron.Instance, flag bool, transType byte, transitions []int) []featurevector.Feature {
+	c := instance.(*lazyTestConf)
+	last := -1
+	if len(c.seq) > 0 {
+		last = c.seq[len(c.seq)-1]
+	}
+	return []featurevector.Feature{len(c.seq), last, []interface{}{last, [2]int{len(c.seq), last}}, nil}
+}
+
+func (e *lazyTestExtractor) EstimatedNumberOfFeatures() int { return 4 }
+func (e *lazyTestExtractor) SetLog(bool)                    {}
+
+// lazyTestModel has random weights for most features of sequences of up to
+// n choices
+func lazyTestModel(transitions []int, n int) *TransitionModel.AvgMatrixSparse {
+	var (
+		model  = TransitionModel.NewAvgMatrixSparse(4, nil, true)
+		random = rand.New(rand.NewSource(1))
+		wg     sync.WaitGroup
+	)
+	add := func(i int, feature interface{}) {
+		for _, t := range transitions {
+			if random.Intn(4) > 0 {
+				wg.Add(1)
+				model.Mat[i].Add(0, t, feature, random.Int63n(2000000)-1000000, &wg)
+			}
+		}
+	}
+	lasts := append([]int{-1}, transitions...)
+	for pos := 0; pos < n; pos++ {
+		add(0, pos)
+		for _, last := range lasts {
+			add(2, [2]int{pos, last})
+		}
+	}
+	for _, last := range lasts {
+		add(1, last)
+		add(2, last)
+	}
+	wg.Wait()
+	return model
+}
+
+func lazyTestBeam(numTransitions, size, n int, lazy bool) *Beam {
+	transitions := make([]int, numTransitions)
+	for i := range transitions {
+		transitions[i] = 3*i + 2
+	}
+	return &Beam{
+		TransFunc:            &lazyTestSystem{transitions: transitions},
+		FeatExtractor:        &lazyTestExtractor{},
+		Base:                 &lazyTestConf{},
+		Model:                lazyTestModel(transitions, n),
+		Size:                 size,
+		Lazy:                 lazy,
+		ShortTempAgenda:      true,
+		EstimatedTransitions: numTransitions,
+		ScoredStoreDense:     true,
+	}
+}
+
+func TestBeamLazy(t *testing.T) {
+	const n = 6
+	defer func(allOut bool) { AllOut = allOut }(AllOut)
+	AllOut = false
+	for _, size := range []int{1, 4, 16} {
+		eager, lazy := lazyTestBeam(30, size, n, false), lazyTestBeam(30, size, n, true)
+		eagerConfs, eagerScores := eager.ParseNBest(n, size)
+		lazyConfs, lazyScores := lazy.ParseNBest(n, size)
+		if !reflect.DeepEqual(lazyScores, eagerScores) {
+			t.Errorf("Beam %d: Expected lazy scores %v, got %v", size, eagerScores, lazyScores)
+			continue
+		}
+		for i, conf := range eagerConfs {
+			if expected, got := conf.(*lazyTestConf).seq, lazyConfs[i].(*lazyTestConf).seq; !reflect.DeepEqual(expected, got) {
+				t.Errorf("Beam %d: Expected lazy parse %d %v, got %v", size, i, expected, got)
+			}
+		}
+		if len(eagerScores) != size {
+			t.Errorf("Beam %d: Expected %d parses, got %d", size, size, len(eagerScores))
+		}
+	}
+}
+
+func TestBeamLazyRequiresShortTempAgenda(t *testing.T) {
+	b := lazyTestBeam(3, 2, 2, true)
+	b.ShortTempAgenda = false
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected lazy expansion without ShortTempAgenda to panic")
+		}
+	}()
+	b.Parse(2)
+}
+
+func benchmarkBeamParse(bench *testing.B, lazy bool) {
+	const n = 10
+	defer func(allOut bool) { AllOut = allOut }(AllOut)
+	AllOut = false
+	b := lazyTestBeam(300, 32, n, lazy)
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		b.Parse(n)
+	}
+}
+
+func BenchmarkBeamParseEager(b *testing.B) { benchmarkBeamParse(b, false) }
+func BenchmarkBeamParseLazy(b *testing.B)  { benchmarkBeamParse(b, true) }
@@ -0,0 +1,44 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UpdateStrategy selects the pair of beam and gold prefixes a training
+// decode updates on
+type UpdateStrategy int
+
+const (
+	// EarlyUpdate stops and updates when the gold falls off the beam
+	// (Collins & Roark 2004)
+	EarlyUpdate UpdateStrategy = iota
+	// MaxViolation follows the gold to the end and updates where the best
+	// beam candidate outscores the gold prefix the most (Huang et al. 2012)
+	MaxViolation
+	// LatestUpdate follows the gold to the end and updates at the last
+	// prefix where the best beam candidate outscores it (Huang et al. 2012)
+	LatestUpdate
+)
+
+var updateStrategyNames = []string{"early", "max-violation", "latest"}
+
+// UpdateStrategies are the names accepted by ParseUpdateStrategy
+var UpdateStrategies = strings.Join(updateStrategyNames, ", ")
+
+func (u UpdateStrategy) String() string {
+	if int(u) < len(updateStrategyNames) {
+		return updateStrategyNames[u]
+	}
+	return fmt.Sprintf("UpdateStrategy(%d)", int(u))
+}
+
+// ParseUpdateStrategy returns the update strategy of a name
+func ParseUpdateStrategy(name string) (UpdateStrategy, error) {
+	for i, strategyName := range updateStrategyNames {
+		if name == strategyName {
+			return UpdateStrategy(i), nil
+		}
+	}
+	return EarlyUpdate, fmt.Errorf("Unknown update strategy %s, expected one of: %s", name, UpdateStrategies)
+}
@@ -0,0 +1,228 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"yap/alg/featurevector"
+	"yap/alg/transition"
+	TransitionModel "yap/alg/transition/model"
+)
+
+// seqCandidate is a sequence of binary choices
+type seqCandidate struct {
+	seq   []int
+	score float64
+	n     int
+}
+
+var _ Candidate = &seqCandidate{}
+
+func (c *seqCandidate) Copy() Candidate {
+	return &seqCandidate{append([]int(nil), c.seq...), c.score, c.n}
+}
+
+func (c *seqCandidate) Equal(other Candidate) bool {
+	o := other.(*seqCandidate)
+	return fmt.Sprint(c.seq) == fmt.Sprint(o.seq)
+}
+
+func (c *seqCandidate) Score() float64 { return c.score }
+func (c *seqCandidate) Len() int       { return len(c.seq) }
+func (c *seqCandidate) Terminal() bool { return len(c.seq) == c.n }
+
+type seqCandidates []Candidate
+
+func (s seqCandidates) Get(i int) Candidate { return s[i] }
+func (s seqCandidates) Len() int            { return len(s) }
+
+type seqAgenda struct {
+	candidates []Candidate
+}
+
+func (a *seqAgenda) AddCandidates(cs []Candidate, best Candidate, alignment int) (Candidate, int) {
+	for _, c := range cs {
+		a.candidates = append(a.candidates, c)
+		if best == nil || c.Score() > best.Score() {
+			best = c
+		}
+	}
+	return best, alignment
+}
+
+func (a *seqAgenda) Contains(c Candidate) bool {
+	for _, other := range a.candidates {
+		if other.Equal(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *seqAgenda) Len() int { return len(a.candidates) }
+func (a *seqAgenda) Clear()   { a.candidates = nil }
+
+// seqSearch searches binary choice sequences, the score of a choice depends
+// on its step and on the previous choice
+type seqSearch struct {
+	scores        [][2][2]float64
+	earlyUpdateAt int
+}
+
+func (s *seqSearch) choiceScore(seq []int, choice int) float64 {
+	var prev int
+	if len(seq) > 0 {
+		prev = seq[len(seq)-1]
+	}
+	return s.scores[len(seq)][prev][choice]
+}
+
+// prefix returns the scored candidate of a choice sequence
+func (s *seqSearch) prefix(seq ...int) *seqCandidate {
+	c := &seqCandidate{n: len(s.scores)}
+	for _, choice := range seq {
+		c.score += s.choiceScore(c.seq, choice)
+		c.seq = append(c.seq, choice)
+	}
+	return c
+}
+
+func (s *seqSearch) StartItem(p Problem) []Candidate {
+	return []Candidate{s.prefix()}
+}
+
+func (s *seqSearch) Clear(a Agenda) Agenda {
+	return &seqAgenda{}
+}
+
+func (s *seqSearch) Insert(cs chan Candidate, a Agenda) []Candidate {
+	var inserted []Candidate
+	for c := range cs {
+		inserted = append(inserted, c)
+	}
+	return inserted
+}
+
+func (s *seqSearch) Expand(c Candidate, p Problem, candidateNum int) chan Candidate {
+	candidate := c.(*seqCandidate)
+	expanded := make(chan Candidate, 2)
+	for choice := 0; choice < 2; choice++ {
+		next := candidate.Copy().(*seqCandidate)
+		next.score += s.choiceScore(next.seq, choice)
+		next.seq = append(next.seq, choice)
+		expanded <- next
+	}
+	close(expanded)
+	return expanded
+}
+
+func (s *seqSearch) sorted(a Agenda) []Candidate {
+	candidates := append([]Candidate(nil), a.(*seqAgenda).candidates...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score() > candidates[j].Score()
+	})
+	return candidates
+}
+
+func (s *seqSearch) Top(a Agenda) Candidate {
+	return s.sorted(a)[0]
+}
+
+func (s *seqSearch) Best(a Agenda) Candidate {
+	return s.Top(a)
+}
+
+func (s *seqSearch) GoalTest(p Problem, c Candidate, rounds int) bool {
+	return c.Terminal()
+}
+
+func (s *seqSearch) TopB(a Agenda, B int) ([]Candidate, bool) {
+	candidates := s.sorted(a)
+	if len(candidates) > B {
+		candidates = candidates[:B]
+	}
+	allTerminal := true
+	for _, c := range candidates {
+		allTerminal = allTerminal && c.Terminal()
+	}
+	return candidates, allTerminal
+}
+
+func (s *seqSearch) Concurrent() bool      { return false }
+func (s *seqSearch) SetEarlyUpdate(at int) { s.earlyUpdateAt = at }
+func (s *seqSearch) Name() string          { return "Sequence search" }
+func (s *seqSearch) Aligned() bool         { return false }
+
+func TestSearchUpdateStrategies(t *testing.T) {
+	defer func(allOut bool) { AllOut = allOut }(AllOut)
+	AllOut = false
+
+	// with a beam of one the gold, all zeros, falls off at the first
+	// choice; the violation is largest after two choices and the last one
+	// is at the end
+	s := &seqSearch{scores: [][2][2]float64{
+		{{0, 1}, {0, 1}},
+		{{0, 0}, {0, 2}},
+		{{2.5, 0}, {0, 0.1}},
+	}}
+	gold := seqCandidates{s.prefix(), s.prefix(0), s.prefix(0, 0), s.prefix(0, 0, 0)}
+
+	expected := []struct {
+		strategy   UpdateStrategy
+		best, gold *seqCandidate
+	}{
+		{EarlyUpdate, s.prefix(1), s.prefix(0)},
+		{MaxViolation, s.prefix(1, 1), s.prefix(0, 0)},
+		{LatestUpdate, s.prefix(1, 1, 1), s.prefix(0, 0, 0)},
+	}
+	for _, e := range expected {
+		best, goldPrefix := SearchUpdate(s, nil, 1, gold, e.strategy)
+		if !best.Equal(e.best) {
+			t.Errorf("%v: Expected best prefix %v, got %v", e.strategy, e.best.seq, best.(*seqCandidate).seq)
+		}
+		if !goldPrefix.Equal(e.gold) {
+			t.Errorf("%v: Expected gold prefix %v, got %v", e.strategy, e.gold.seq, goldPrefix.(*seqCandidate).seq)
+		}
+		if best.Score() < goldPrefix.Score() {
+			t.Errorf("%v: Expected best prefix to outscore the gold, got %v < %v", e.strategy, best.Score(), goldPrefix.Score())
+		}
+	}
+}
+
+// scoreTestModel scores a transition as 10 times its value plus the number
+// of features
+type scoreTestModel struct {
+	TransitionModel.Interface
+}
+
+func (m *scoreTestModel) SetTransitionScores(features []featurevector.Feature, scores featurevector.ScoredStore, integrated bool) {
+	for transition := 0; transition < 4; transition++ {
+		scores.Inc(transition, int64(10*transition+len(features)))
+	}
+}
+
+// scoreTestConfiguration is only asked for its assignment
+type scoreTestConfiguration struct {
+	transition.Configuration
+}
+
+func (c *scoreTestConfiguration) Assignment() uint16 { return 0 }
+
+func TestBeamScoreGold(t *testing.T) {
+	b := &Beam{Model: &scoreTestModel{}}
+	features := func(n int) *transition.FeaturesList {
+		return &transition.FeaturesList{Features: make([]featurevector.Feature, n)}
+	}
+	goldSequence := ScoredConfigurations{
+		{C: &scoreTestConfiguration{}, Features: features(1)},
+		{C: &scoreTestConfiguration{}, Transition: transition.ConstTransition(1), Features: features(2)},
+		{C: &scoreTestConfiguration{}, Transition: transition.ConstTransition(2)},
+	}
+	b.scoreGold(goldSequence)
+	for i, expected := range []float64{0, 11, 33} {
+		if score := goldSequence[i].Score(); score != expected {
+			t.Errorf("Expected gold prefix %d score %v, got %v", i, expected, score)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package transition
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FeatureValidator is a configuration that can tell whether the addresses
+// and attributes of feature template elements are ones it answers, so a
+// feature setup can be checked before any features are extracted
+type FeatureValidator interface {
+	ValidateAddress(address []byte) error
+	ValidateAttribute(source byte, attribute []byte) error
+}
+
+// FeatureGroupCheck is the result of checking a feature group: the number
+// of its templates, of the templates its morph combinations add, and the
+// errors found
+type FeatureGroupCheck struct {
+	Group, Transition string
+	Features          int
+	MorphFeatures     int
+	Errors            []error
+}
+
+// CheckFeatureSetup validates a feature setup the way LoadFeatureSetup loads
+// it: every template, address, attribute and requirement of every group,
+// and every morph template combination. Requirements are checked against the
+// elements of the templates loaded so far for the same transition type.
+// Errors that are not of a group (e.g. morph templates of unknown groups)
+// are returned separately
+func (x *GenericExtractor) CheckFeatureSetup(setup *FeatureSetup, validator FeatureValidator) ([]*FeatureGroupCheck, []error) {
+	var (
+		checks      = make([]*FeatureGroupCheck, 0, len(setup.FeatureGroups))
+		errs        []error
+		morphGroups = make(map[string]int)
+		groups      = make(map[string]bool)
+		elements    = make(map[byte]map[string]bool)
+	)
+	for i, morphGroup := range setup.MorphTemplates {
+		morphGroups[morphGroup.Group] = i
+	}
+	for _, group := range setup.FeatureGroups {
+		check := &FeatureGroupCheck{Group: group.Group, Transition: group.Transition}
+		checks = append(checks, check)
+		groups[group.Group] = true
+		transType := ConstTransition(0).Type()
+		if len(group.Transition) > 0 {
+			transType = group.Transition[0]
+		}
+		if _, exists := x.TransTypeGroups[transType]; !exists {
+			check.Errors = append(check.Errors, fmt.Errorf("Unknown transition %s for the transition system", group.Transition))
+			continue
+		}
+		if _, exists := elements[transType]; !exists {
+			elements[transType] = make(map[string]bool)
+		}
+		var combinations []string
+		if morphId, exists := morphGroups[group.Group]; exists {
+			combinations = setup.MorphTemplates[morphId].Combinations
+		}
+		for _, featureConfig := range group.Features {
+			featurePair := strings.Split(featureConfig, FEATURE_REQUIREMENTS_SEPARATOR)
+			if len(featurePair) != 2 {
+				check.Errors = append(check.Errors, fmt.Errorf("%s: expected a template and its requirements, separated by %s", featureConfig, FEATURE_REQUIREMENTS_SEPARATOR))
+				continue
+			}
+			check.Features++
+			check.Errors = append(check.Errors, x.CheckFeature(featurePair[0], featurePair[1], validator, elements[transType])...)
+			for _, morphTmpl := range combinations {
+				check.MorphFeatures++
+				morphAddedFeature := fmt.Sprintf("%s%s%s", featurePair[0], FEATURE_SEPARATOR, morphTmpl)
+				check.Errors = append(check.Errors, x.CheckFeature(morphAddedFeature, featurePair[1], validator, elements[transType])...)
+			}
+		}
+	}
+	for _, morphGroup := range setup.MorphTemplates {
+		if !groups[morphGroup.Group] {
+			errs = append(errs, fmt.Errorf("Morph templates of unknown feature group %s", morphGroup.Group))
+		}
+	}
+	return checks, errs
+}
+
+// CheckFeature validates a feature template and its requirements, adding
+// the elements of the template to the loaded elements
+func (x *GenericExtractor) CheckFeature(featTemplateStr, requirements string, validator FeatureValidator, loaded map[string]bool) (errs []error) {
+	template, err := x.parseFeatureTemplateChecked(featTemplateStr, requirements)
+	if err != nil {
+		return []error{err}
+	}
+	features := strings.Split(strings.Replace(featTemplateStr, " ", "", -1), FEATURE_SEPARATOR)
+	for i, element := range template.Elements {
+		if features[i][0] == 'P' {
+			// morph template elements refer to a previous element
+			if morphElement := x.ParseMorphConfiguration(features[i]); morphElement.ElementAddress < 0 || morphElement.ElementAddress >= i {
+				errs = append(errs, fmt.Errorf("%s: morph element %s refers to a missing element", featTemplateStr, features[i]))
+			}
+			continue
+		}
+		if err := validator.ValidateAddress(element.Address); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", featTemplateStr, err))
+			continue
+		}
+		for _, attribute := range element.Attributes {
+			if err := validator.ValidateAttribute(element.Address[0], attribute); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", featTemplateStr, err))
+				continue
+			}
+			loaded[string(element.Address)+ATTRIBUTE_SEPARATOR+string(attribute)] = true
+		}
+	}
+	for _, req := range template.Requirements {
+		if !loaded[req] {
+			errs = append(errs, fmt.Errorf("%s: requirement %s is not an element of a template loaded so far", featTemplateStr, req))
+		}
+	}
+	return
+}
+
+// parseFeatureTemplateChecked parses a feature template, returning the
+// panics of malformed element offsets as errors
+func (x *GenericExtractor) parseFeatureTemplateChecked(featTemplateStr, requirements string) (template *FeatureTemplate, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			template, err = nil, fmt.Errorf("%s: %v", featTemplateStr, r)
+		}
+	}()
+	return x.ParseFeatureTemplate(featTemplateStr, requirements)
+}
@@ -0,0 +1,95 @@
+package transition
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// checkTestValidator accepts the w and p attributes of stack (S), queue (N)
+// and morpheme (M) addresses
+type checkTestValidator struct{}
+
+func (v checkTestValidator) ValidateAddress(address []byte) error {
+	switch address[0] {
+	case 'S', 'N', 'M':
+		return nil
+	}
+	return fmt.Errorf("Unknown address %s", address)
+}
+
+func (v checkTestValidator) ValidateAttribute(source byte, attribute []byte) error {
+	switch string(attribute) {
+	case "w", "p":
+		return nil
+	}
+	return fmt.Errorf("Unknown attribute %s of %c", attribute, source)
+}
+
+func TestCheckFeatureSetup(t *testing.T) {
+	setup := &FeatureSetup{
+		FeatureGroups: []FeatureGroup{
+			{Group: "words", Transition: "Arc", Features: []string{
+				"S0|w,S0|w",
+				"N0|w+S0|p,N0|p",
+				"X0|w,S0|w",
+				"S0|w",
+			}},
+			{Group: "morphemes", Transition: "MD", Features: []string{
+				"Pf+M0|w,M0|w",
+				"M0|q,M0|w",
+				"M0|p,S0|w",
+			}},
+			{Group: "lexical", Transition: "Lexical", Features: []string{"M0|w,M0|w"}},
+		},
+		MorphTemplates: []MorphTemplate{
+			{Group: "words", Combinations: []string{"Pf"}},
+			{Group: "missing", Combinations: []string{"Pf"}},
+		},
+	}
+	x := new(GenericExtractor)
+	x.InitTypes([]byte("AM"))
+	checks, errs := x.CheckFeatureSetup(setup, checkTestValidator{})
+
+	expected := []struct {
+		features, morphFeatures int
+		errors                  []string
+	}{
+		{3, 3, []string{
+			// a requirement of a later element of the template
+			"N0|w+S0|p: requirement N0|p",
+			"N0|w+S0|p+Pf: requirement N0|p",
+			"X0|w: Unknown address X0",
+			"X0|w+Pf: Unknown address X0",
+			"S0|w: expected a template and its requirements",
+		}},
+		{3, 0, []string{
+			"Pf+M0|w: morph element Pf refers to a missing element",
+			"M0|q: Unknown attribute q of M",
+			// requirements are of the templates of the same transition type
+			"M0|p: requirement S0|w",
+		}},
+		{0, 0, []string{"Unknown transition Lexical"}},
+	}
+	if len(checks) != len(expected) {
+		t.Fatalf("Expected %d group checks, got %d", len(expected), len(checks))
+	}
+	for i, e := range expected {
+		check := checks[i]
+		if check.Features != e.features || check.MorphFeatures != e.morphFeatures {
+			t.Errorf("%s: Expected %d templates and %d with morph templates, got %d and %d", check.Group, e.features, e.morphFeatures, check.Features, check.MorphFeatures)
+		}
+		if len(check.Errors) != len(e.errors) {
+			t.Errorf("%s: Expected %d errors, got %v", check.Group, len(e.errors), check.Errors)
+			continue
+		}
+		for j, err := range check.Errors {
+			if !strings.HasPrefix(err.Error(), e.errors[j]) {
+				t.Errorf("%s: Expected error %q, got %q", check.Group, e.errors[j], err)
+			}
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unknown feature group missing") {
+		t.Errorf("Expected an error of the morph templates of group missing, got %v", errs)
+	}
+}
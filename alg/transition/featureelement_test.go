@@ -0,0 +1,69 @@
+package transition
+
+import (
+	"reflect"
+	"testing"
+
+	nlp "yap/nlp/types"
+)
+
+// elementTestConfiguration answers every attribute of node 0 of the word
+// Beit with its name, character attributes with their values
+type elementTestConfiguration struct {
+	Configuration
+}
+
+func (c *elementTestConfiguration) Address(location []byte, offset int) (int, bool, bool) {
+	return 0, true, false
+}
+
+func (c *elementTestConfiguration) Attribute(source byte, nodeID int, attribute []byte, transitions []int) (interface{}, bool, bool) {
+	if affix, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+		att, exists := affix.Attribute("Beit")
+		return att, exists, false
+	}
+	return string(attribute), true, false
+}
+
+func TestFeatureElementAttributes(t *testing.T) {
+	tests := []struct {
+		element    string
+		attributes []string
+		value      interface{}
+	}{
+		{"N0|w|p", []string{"wp"}, "wp"},
+		{"N0|w|pre2", []string{"w", "pre2"}, [2]interface{}{"w", "Be"}},
+		{"N0|w|p|suf3", []string{"wp", "suf3"}, [2]interface{}{"wp", "eit"}},
+		{"N0|p|w", []string{"p", "w"}, [2]interface{}{"p", "w"}},
+		{"N0|shape", []string{"shape"}, "Xx"},
+	}
+	x := new(GenericExtractor)
+	for _, test := range tests {
+		element, err := x.ParseFeatureElement(test.element)
+		if err != nil {
+			t.Errorf("Failed parsing %s: %v", test.element, err)
+			continue
+		}
+		attributes := make([]string, len(element.Attributes))
+		for i, attribute := range element.Attributes {
+			attributes[i] = string(attribute)
+		}
+		if !reflect.DeepEqual(attributes, test.attributes) {
+			t.Errorf("Expected attributes %v of %s, got %v", test.attributes, test.element, attributes)
+		}
+		value, exists, _ := x.GetFeatureElement(&elementTestConfiguration{}, element, nil, nil)
+		if !exists || value != test.value {
+			t.Errorf("Expected value %v of %s, got %v (%v)", test.value, test.element, value, exists)
+		}
+	}
+	// affixes longer than the word have no value
+	element, _ := x.ParseFeatureElement("N0|pre5")
+	if value, exists, _ := x.GetFeatureElement(&elementTestConfiguration{}, element, nil, nil); exists {
+		t.Errorf("Expected no value of N0|pre5, got %v", value)
+	}
+	for _, malformed := range []string{"N0|pre", "N0|suf0", "N0|prex"} {
+		if _, err := x.ParseFeatureElement(malformed); err == nil {
+			t.Errorf("Expected error parsing %s", malformed)
+		}
+	}
+}
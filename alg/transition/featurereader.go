@@ -46,10 +46,12 @@ func (s *FeatureSetup) NumFeatures() int {
 	return numFeatures
 }
 
-func LoadFeatureConf(conf []byte) *FeatureSetup {
+func LoadFeatureConf(conf []byte) (*FeatureSetup, error) {
 	setup := new(FeatureSetup)
-	yaml.Unmarshal(conf, setup)
-	return setup
+	if err := yaml.Unmarshal(conf, setup); err != nil {
+		return nil, err
+	}
+	return setup, nil
 }
 
 func LoadFeatureConfFile(filename string) (*FeatureSetup, error) {
@@ -57,6 +59,5 @@ func LoadFeatureConfFile(filename string) (*FeatureSetup, error) {
 	if err != nil {
 		return nil, err
 	}
-	setup := LoadFeatureConf(data)
-	return setup, nil
+	return LoadFeatureConf(data)
 }
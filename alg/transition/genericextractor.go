@@ -10,6 +10,7 @@ import (
 	"strings"
 	. "yap/alg/featurevector"
 	. "yap/alg/perceptron"
+	nlp "yap/nlp/types"
 	"yap/util"
 	// "sync"
 )
@@ -677,8 +678,18 @@ func (x *GenericExtractor) GetFeatureElement(conf Configuration, templateElement
 }
 
 func (x *GenericExtractor) ParseFeatureElement(featElementStr string) (*FeatureTemplateElement, error) {
-	featElementStrPatchedWP := strings.Replace(featElementStr, "w|p", "wp", -1)
-	elementParts := strings.Split(featElementStrPatchedWP, ATTRIBUTE_SEPARATOR)
+	parts := strings.Split(featElementStr, ATTRIBUTE_SEPARATOR)
+	elementParts := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		// w|p is the word+pos attribute wp (but w|pre2 is w and pre2)
+		if parts[i] == "w" && i+1 < len(parts) && parts[i+1] == "p" {
+			elementParts = append(elementParts, "wp")
+			i++
+			continue
+		}
+		elementParts = append(elementParts, parts[i])
+	}
+	featElementStrPatchedWP := strings.Join(elementParts, ATTRIBUTE_SEPARATOR)
 
 	if len(elementParts) < 2 {
 		return nil, errors.New("Not enough parts for element " + featElementStr)
@@ -709,6 +720,11 @@ func (x *GenericExtractor) ParseFeatureElement(featElementStr string) (*FeatureT
 
 	for i, elementStr := range elementParts[1:] {
 		element.Attributes[i] = []byte(elementStr)
+		if nlp.IsAffixAttribute(element.Attributes[i]) {
+			if err := nlp.RegisterAffixAttribute(element.Attributes[i]); err != nil {
+				return nil, fmt.Errorf("Error parsing feature element %s: %v", featElementStr, err)
+			}
+		}
 	}
 	return element, nil
 }
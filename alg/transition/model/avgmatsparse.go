@@ -49,6 +49,7 @@ type AvgMatrixSparseSerialized struct {
 }
 
 var _ perceptron.Model = &AvgMatrixSparse{}
+var _ perceptron.MixedModel = &AvgMatrixSparse{}
 var _ Interface = &AvgMatrixSparse{}
 
 func (t *AvgMatrixSparse) Score(features interface{}) int64 {
@@ -203,9 +204,14 @@ func (t *AvgMatrixSparse) IncrementGeneration() {
 	t.Generation += 1
 }
 
+// Copy returns a copy of the model for a parallel training worker, with the
+// weights of the model and generations and sums of weights restarting at 1
 func (t *AvgMatrixSparse) Copy() perceptron.Model {
-	panic("Cannot copy an avg matrix sparse representation")
-	// return nil
+	retval := &AvgMatrixSparse{make([]*AvgSparse, len(t.Mat)), t.Features, 1, t.Formatters, t.Log, t.Extractor}
+	for i, val := range t.Mat {
+		retval.Mat[i] = val.Copy()
+	}
+	return retval
 }
 
 func (t *AvgMatrixSparse) New() perceptron.Model {
@@ -216,8 +222,39 @@ func (t *AvgMatrixSparse) Make(dense bool) perceptron.Model {
 	return NewAvgMatrixSparse(t.Features, nil, dense)
 }
 
+// AddModel adds the weights and sums of weights of a trained copy, and the
+// generations it was trained for
 func (t *AvgMatrixSparse) AddModel(m perceptron.Model) {
-	panic("Cannot add two avg matrix sparse types")
+	other, ok := m.(*AvgMatrixSparse)
+	if !ok {
+		panic("Can only add an avg matrix sparse copy")
+	}
+	for i, val := range other.Mat {
+		t.Mat[i].AddSums(val, other.Generation)
+	}
+	t.Generation += other.Generation - 1
+}
+
+// Mix sets the model to the average of copies of it trained in parallel
+// (iterative parameter mixing, McDonald et al. 2010). The weights are
+// averaged, and the sums of weights of the copies are added as if their
+// updates followed one another, so the averaged model covers all of them
+func (t *AvgMatrixSparse) Mix(copies []perceptron.Model) {
+	mixed := t.New().(*AvgMatrixSparse)
+	for _, trained := range copies {
+		mixed.AddModel(trained)
+	}
+	mixed.ScalarDivide(int64(len(copies)))
+	var wg sync.WaitGroup
+	for i, val := range mixed.Mat {
+		wg.Add(1)
+		go func(j int, mixedVal *AvgSparse) {
+			defer wg.Done()
+			t.Mat[j].SetMixed(mixedVal, t.Generation, mixed.Generation)
+		}(i, val)
+	}
+	wg.Wait()
+	t.Generation += mixed.Generation
 }
 
 func (t *AvgMatrixSparse) TransitionScore(transition transition.Transition, features []Feature) int64 {
@@ -275,6 +312,29 @@ func (t *AvgMatrixSparse) SetTransitionScores(features []Feature, scores ScoredS
 	}
 }
 
+// TransitionScores returns the scores of some transitions, looking up each
+// feature once; they are the scores SetTransitionScores sets (not integrated)
+func (t *AvgMatrixSparse) TransitionScores(transitions []int, features []Feature) []int64 {
+	scores := make([]int64, len(transitions))
+	for i, feat := range features {
+		if feat != nil {
+			switch f := feat.(type) {
+			case []interface{}:
+				for _, generatedFeat := range f {
+					t.Mat[i].AddScores(generatedFeat, transitions, scores)
+				}
+			case TAF:
+				for feat, _ := range f.GetTransFeatures() {
+					t.Mat[i].AddScores(feat, transitions, scores)
+				}
+			default:
+				t.Mat[i].AddScores(feat, transitions, scores)
+			}
+		}
+	}
+	return scores
+}
+
 func (t *AvgMatrixSparse) Serialize(generation int) *AvgMatrixSparseSerialized {
 	serialized := &AvgMatrixSparseSerialized{
 		Generation: t.Generation,
@@ -338,6 +398,7 @@ func NewAvgMatrixSparse(features int, formatters []util.Format, dense bool) *Avg
 }
 
 type AveragedModelStrategy struct {
+	sync.Mutex
 	P, N       int
 	accumModel *AvgMatrixSparse
 }
@@ -354,9 +415,13 @@ func (u *AveragedModelStrategy) Init(m perceptron.Model, iterations int) {
 	u.accumModel = avgModel
 }
 
+// Update counts an update of the model, or of a parallel training copy of
+// it; parallel training workers call it concurrently
 func (u *AveragedModelStrategy) Update(m perceptron.Model) {
-	u.accumModel.IncrementGeneration()
+	m.(*AvgMatrixSparse).IncrementGeneration()
+	u.Lock()
 	u.N += 1
+	u.Unlock()
 }
 
 func (u *AveragedModelStrategy) Finalize(m perceptron.Model) perceptron.Model {
@@ -0,0 +1,246 @@
+package model
+
+import (
+	"testing"
+
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+	"yap/util"
+)
+
+func TestAvgMatrixSparseSerialAverage(t *testing.T) {
+	m := NewAvgMatrixSparse(1, nil, false)
+	// one update per generation, as in serial training
+	updates := []struct {
+		feature string
+		amount  int64
+	}{
+		{"a", 1},
+		{"b", 1},
+		{"a", 2},
+		{"", 0},
+	}
+	for _, update := range updates {
+		if update.feature != "" {
+			m.apply(&transition.FeaturesList{
+				Transition: transition.ConstTransition(0),
+				Previous:   &transition.FeaturesList{Features: []featurevector.Feature{update.feature}},
+			}, update.amount)
+		}
+		m.IncrementGeneration()
+	}
+	m.Integrate()
+	// a: 1+1+3+3 (set at generation 0), b: 0+1+1+1
+	expected := map[string]int64{"a": 8, "b": 3}
+	for feature, value := range expected {
+		if summed := m.Mat[0].Value(0, feature); summed != value {
+			t.Errorf("Expected summed weight of %v %d, got %d", feature, value, summed)
+		}
+	}
+}
+
+const toyLabels = 3
+
+type toyInstance string
+
+func (i toyInstance) Equal(other util.Equaler) bool {
+	o, ok := other.(toyInstance)
+	return ok && i == o
+}
+
+type toyLabel int
+
+func (l toyLabel) Equal(other util.Equaler) bool {
+	o, ok := other.(toyLabel)
+	return ok && l == o
+}
+
+// toyFeatures are the features of labeling an instance with a single feature
+func toyFeatures(feature string, label int) *transition.FeaturesList {
+	return &transition.FeaturesList{
+		Transition: transition.ConstTransition(label),
+		Previous:   &transition.FeaturesList{Features: []featurevector.Feature{feature}},
+	}
+}
+
+// toyDecoder labels an instance with its highest scoring label
+type toyDecoder struct{}
+
+func (d *toyDecoder) DecodeEarlyUpdate(gold perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}, interface{}, int, int, float64) {
+	feature := string(gold.Instance().(toyInstance))
+	best, bestScore := 0, m.Score(toyFeatures(feature, 0))
+	for label := 1; label < toyLabels; label++ {
+		if score := m.Score(toyFeatures(feature, label)); score > bestScore {
+			best, bestScore = label, score
+		}
+	}
+	decoded := &perceptron.Decoded{InstanceVal: gold.Instance(), DecodedVal: toyLabel(best)}
+	goldLabel := int(gold.Decoded().(toyLabel))
+	return decoded, toyFeatures(feature, best), toyFeatures(feature, goldLabel), -1, 1, float64(bestScore)
+}
+
+type toyGoldDecoder struct{}
+
+func (d *toyGoldDecoder) Decode(i perceptron.Instance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	return nil, nil
+}
+
+func (d *toyGoldDecoder) DecodeGold(i perceptron.DecodedInstance, m perceptron.Model) (perceptron.DecodedInstance, interface{}) {
+	return i, nil
+}
+
+var toyFeatureNames = []string{"a", "b", "c", "d"}
+
+func toyInstances() []perceptron.DecodedInstance {
+	data := []struct {
+		feature string
+		label   int
+	}{
+		{"a", 1}, {"b", 2}, {"c", 1}, {"a", 1}, {"d", 2},
+		{"b", 0}, {"c", 2}, {"a", 2}, {"d", 2}, {"b", 2},
+	}
+	instances := make([]perceptron.DecodedInstance, len(data))
+	for i, d := range data {
+		instances[i] = &perceptron.Decoded{InstanceVal: toyInstance(d.feature), DecodedVal: toyLabel(d.label)}
+	}
+	return instances
+}
+
+// trainToy trains an averaged model, by mixing if workers > 0
+func trainToy(workers int) *AvgMatrixSparse {
+	return trainToyWith(workers, new(AveragedModelStrategy), toyInstances())
+}
+
+func trainToyWith(workers int, updater perceptron.UpdateStrategy, instances []perceptron.DecodedInstance) *AvgMatrixSparse {
+	p := &perceptron.LinearPerceptron{
+		Decoder:     &toyDecoder{},
+		GoldDecoder: &toyGoldDecoder{},
+		Updater:     updater,
+		Iterations:  3,
+	}
+	if workers > 0 {
+		p.Workers = workers
+		p.NewDecoder = func() perceptron.EarlyUpdateInstanceDecoder { return &toyDecoder{} }
+	}
+	p.Init(NewAvgMatrixSparse(1, nil, false))
+	p.Train(instances)
+	return p.Model.(*AvgMatrixSparse)
+}
+
+func TestAvgMatrixSparseOneWorker(t *testing.T) {
+	serial, mixed := trainToy(0), trainToy(1)
+	var nonZero bool
+	for _, feature := range toyFeatureNames {
+		for label := 0; label < toyLabels; label++ {
+			serialVal, mixedVal := serial.Mat[0].Value(label, feature), mixed.Mat[0].Value(label, feature)
+			if serialVal != mixedVal {
+				t.Errorf("Expected averaged weight of %v/%d %d, got %d with one worker", feature, label, serialVal, mixedVal)
+			}
+			nonZero = nonZero || serialVal != 0
+		}
+	}
+	if !nonZero {
+		t.Errorf("Expected non-zero averaged weights")
+	}
+}
+
+// TestAvgMatrixSparseWorkers trains with every instance repeated for each
+// worker, so all workers train on the same shard and make the same updates.
+// Their uniform mix is then the serially trained model
+func TestAvgMatrixSparseWorkers(t *testing.T) {
+	serial := trainToyWith(0, new(perceptron.TrivialStrategy), toyInstances())
+	for _, workers := range []int{2, 4} {
+		var instances []perceptron.DecodedInstance
+		for _, instance := range toyInstances() {
+			for w := 0; w < workers; w++ {
+				instances = append(instances, instance)
+			}
+		}
+		mixed := trainToyWith(workers, new(perceptron.TrivialStrategy), instances)
+		for _, feature := range toyFeatureNames {
+			for label := 0; label < toyLabels; label++ {
+				serialVal, mixedVal := serial.Mat[0].Value(label, feature), mixed.Mat[0].Value(label, feature)
+				if serialVal != mixedVal {
+					t.Errorf("Expected weight of %v/%d %d, got %d with %d workers", feature, label, serialVal, mixedVal, workers)
+				}
+			}
+		}
+		averaged := trainToy(workers)
+		var nonZero bool
+		for _, feature := range toyFeatureNames {
+			for label := 0; label < toyLabels; label++ {
+				nonZero = nonZero || averaged.Mat[0].Value(label, feature) != 0
+			}
+		}
+		if !nonZero {
+			t.Errorf("Expected non-zero averaged weights with %d workers", workers)
+		}
+	}
+}
+
+func TestAvgMatrixSparseMix(t *testing.T) {
+	m := NewAvgMatrixSparse(1, nil, false)
+	m.apply(toyFeatures("a", 1), 1)
+	updates := []struct {
+		feature string
+		label   int
+		amount  int64
+	}{
+		{"a", 1, 3},
+		{"a", 1, 0},
+		{"b", 2, 6},
+	}
+	copies := make([]perceptron.Model, len(updates))
+	for i, update := range updates {
+		trained := m.Copy().(*AvgMatrixSparse)
+		trained.apply(toyFeatures(update.feature, update.label), update.amount)
+		copies[i] = trained
+	}
+	m.Mix(copies)
+	// a/1: (1+3 + 1+0 + 1)/3, b/2: (0 + 0 + 6)/3
+	expected := []struct {
+		feature string
+		label   int
+		value   int64
+	}{
+		{"a", 1, 2},
+		{"b", 2, 2},
+		{"a", 2, 0},
+	}
+	for _, e := range expected {
+		if value := m.Mat[0].Value(e.label, e.feature); value != e.value {
+			t.Errorf("Expected mixed weight of %v/%d %d, got %d", e.feature, e.label, e.value, value)
+		}
+	}
+}
+
+func TestAvgMatrixSparseTransitionScores(t *testing.T) {
+	m := NewAvgMatrixSparse(4, nil, false)
+	taf := &featurevector.SimpleTAF{FTMap: featurevector.FeatureTransMap{"t": {1: true, 2: true}}}
+	features := []featurevector.Feature{"a", []interface{}{"g1", "g2"}, taf, nil}
+	for label, amount := range []int64{3, -2, 5} {
+		m.apply(&transition.FeaturesList{
+			Transition: transition.ConstTransition(label),
+			Previous:   &transition.FeaturesList{Features: features},
+		}, amount)
+	}
+	// transition 7 has no weights
+	transitions := []int{2, 0, 7, 1}
+	scores := m.TransitionScores(transitions, features)
+	for _, dense := range []bool{true, false} {
+		store := featurevector.MakeScoredStore(dense).(featurevector.ScoredStore)
+		store.Clear()
+		store.SetTransitions(transitions)
+		m.SetTransitionScores(features, store, false)
+		for i, transition := range transitions {
+			if expected, _ := store.Get(transition); scores[i] != expected {
+				t.Errorf("Expected transition %d score %d (dense %v), got %d", transition, expected, dense, scores[i])
+			}
+		}
+	}
+	// a, the two generated features and the TAF feature of transition 2
+	if scores[0] != 4*5 || scores[2] != 0 {
+		t.Errorf("Expected scores 20 and 0 of transitions 2 and 7, got %d and %d", scores[0], scores[2])
+	}
+}
@@ -0,0 +1,422 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	. "yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+)
+
+// NeuralScale is the integer score of a network output of 1
+const NeuralScale = 1000000
+
+const (
+	// NeuralNull is the embedding of a missing feature
+	NeuralNull = iota
+	// NeuralUnknown is the embedding of a feature value unseen in training
+	NeuralUnknown
+)
+
+// Embeddings is an embedding table of feature values
+type Embeddings struct {
+	Index   map[interface{}]int
+	Vectors [][]float64
+}
+
+func NewEmbeddings(dim int, random *rand.Rand) *Embeddings {
+	e := &Embeddings{Index: make(map[interface{}]int)}
+	e.Vectors = [][]float64{make([]float64, dim), randomVector(dim, random)}
+	return e
+}
+
+// Lookup returns the embedding index of a value, or NeuralUnknown
+func (e *Embeddings) Lookup(value interface{}) int {
+	if index, exists := e.Index[value]; exists {
+		return index
+	}
+	return NeuralUnknown
+}
+
+// Add returns the embedding index of a value, adding the vector if it is new
+func (e *Embeddings) Add(value interface{}, vector []float64) int {
+	if index, exists := e.Index[value]; exists {
+		return index
+	}
+	e.Index[value] = len(e.Vectors)
+	e.Vectors = append(e.Vectors, vector)
+	return len(e.Vectors) - 1
+}
+
+func (e *Embeddings) Copy() *Embeddings {
+	newEmbeddings := &Embeddings{Index: make(map[interface{}]int, len(e.Index))}
+	for value, index := range e.Index {
+		newEmbeddings.Index[value] = index
+	}
+	newEmbeddings.Vectors = copyMatrix(e.Vectors)
+	return newEmbeddings
+}
+
+// Neural is a transition model with a feed forward network over embedded
+// feature values (Chen & Manning 2014). Each feature template is an input
+// of the network, embedded in the table of its group, so templates of the
+// same attribute (words, tags, labels) share embeddings. The embeddings
+// feed a tanh hidden layer, and the output layer scores each transition.
+// The network is trained by the perceptron updates: AddSubtract takes a
+// gradient step of the scores of the transitions, scaled by the amount
+type Neural struct {
+	Groups       []int
+	GroupNames   []string
+	Tables       []*Embeddings
+	Dim, Size    int
+	Hidden       [][]float64
+	HiddenBias   []float64
+	Output       [][]float64
+	LearningRate float64
+	Seed         int64
+	Log          bool
+
+	random *rand.Rand
+}
+
+var _ perceptron.Model = &Neural{}
+var _ Interface = &Neural{}
+
+// NewNeural makes a network for feature templates, groups names the
+// embedding table of each template; dim is the embedding size and size is
+// the hidden layer size
+func NewNeural(groups []string, dim, size int, learningRate float64, seed int64) *Neural {
+	n := &Neural{
+		Groups:       make([]int, len(groups)),
+		Dim:          dim,
+		Size:         size,
+		LearningRate: learningRate,
+		Seed:         seed,
+	}
+	tables := make(map[string]int)
+	for i, group := range groups {
+		table, exists := tables[group]
+		if !exists {
+			table = len(n.Tables)
+			tables[group] = table
+			n.GroupNames = append(n.GroupNames, group)
+			n.Tables = append(n.Tables, NewEmbeddings(dim, n.rand()))
+		}
+		n.Groups[i] = table
+	}
+	inputs := len(groups) * dim
+	n.Hidden = make([][]float64, size)
+	bound := math.Sqrt(6.0 / float64(inputs+size))
+	for j := range n.Hidden {
+		n.Hidden[j] = make([]float64, inputs)
+		for k := range n.Hidden[j] {
+			n.Hidden[j][k] = (2*n.rand().Float64() - 1) * bound
+		}
+	}
+	n.HiddenBias = make([]float64, size)
+	return n
+}
+
+// SetEmbeddings adds pretrained vectors of the values of a group
+func (n *Neural) SetEmbeddings(group string, vectors map[interface{}][]float64) error {
+	for i, name := range n.GroupNames {
+		if name != group {
+			continue
+		}
+		for value, vector := range vectors {
+			if len(vector) != n.Dim {
+				return fmt.Errorf("Got embedding of size %d for %v, expected %d", len(vector), value, n.Dim)
+			}
+			index := n.Tables[i].Add(value, vector)
+			copy(n.Tables[i].Vectors[index], vector)
+		}
+		return nil
+	}
+	return fmt.Errorf("No feature template group %s", group)
+}
+
+func (n *Neural) rand() *rand.Rand {
+	if n.random == nil {
+		n.random = rand.New(rand.NewSource(n.Seed))
+	}
+	return n.random
+}
+
+func randomVector(dim int, random *rand.Rand) []float64 {
+	vector := make([]float64, dim)
+	for i := range vector {
+		vector[i] = (2*random.Float64() - 1) * 0.5
+	}
+	return vector
+}
+
+// neuralInput holds the embedding indices of each input of the network
+type neuralInput [][]int
+
+// input resolves the embedding indices of the features; with add, feature
+// values not seen before get new embeddings
+func (n *Neural) input(features []Feature, add bool) neuralInput {
+	if len(features) > len(n.Groups) {
+		panic("Got more features than known network inputs")
+	}
+	input := make(neuralInput, len(n.Groups))
+	for i, feat := range features {
+		table := n.Tables[n.Groups[i]]
+		switch f := feat.(type) {
+		case nil:
+			input[i] = []int{NeuralNull}
+		case []interface{}:
+			input[i] = make([]int, 0, len(f))
+			for _, generatedFeat := range f {
+				input[i] = append(input[i], n.index(table, generatedFeat, add))
+			}
+			if len(input[i]) == 0 {
+				input[i] = []int{NeuralNull}
+			}
+		default:
+			input[i] = []int{n.index(table, feat, add)}
+		}
+	}
+	for i := len(features); i < len(input); i++ {
+		input[i] = []int{NeuralNull}
+	}
+	return input
+}
+
+func (n *Neural) index(table *Embeddings, value interface{}, add bool) int {
+	if add {
+		return table.Add(value, randomVector(n.Dim, n.rand()))
+	}
+	return table.Lookup(value)
+}
+
+// forward returns the input and the hidden layer activations
+func (n *Neural) forward(input neuralInput) ([]float64, []float64) {
+	x := make([]float64, len(input)*n.Dim)
+	for i, indices := range input {
+		slot := x[i*n.Dim : (i+1)*n.Dim]
+		table := n.Tables[n.Groups[i]]
+		for _, index := range indices {
+			for k, val := range table.Vectors[index] {
+				slot[k] += val / float64(len(indices))
+			}
+		}
+	}
+	h := make([]float64, n.Size)
+	for j, weights := range n.Hidden {
+		z := n.HiddenBias[j]
+		for k, val := range x {
+			z += weights[k] * val
+		}
+		h[j] = math.Tanh(z)
+	}
+	return x, h
+}
+
+func (n *Neural) outputScore(h []float64, transition int) int64 {
+	if transition >= len(n.Output) || n.Output[transition] == nil {
+		return 0
+	}
+	var score float64
+	for j, weight := range n.Output[transition] {
+		score += weight * h[j]
+	}
+	return int64(score * NeuralScale)
+}
+
+func (n *Neural) TransitionScore(transition transition.Transition, features []Feature) int64 {
+	_, h := n.forward(n.input(features, false))
+	return n.outputScore(h, transition.Value())
+}
+
+func (n *Neural) SetTransitionScores(features []Feature, scores ScoredStore, integrated bool) {
+	_, h := n.forward(n.input(features, false))
+	store := &LockedArray{Vals: make([]*HistoryValue, len(n.Output))}
+	for transition := range n.Output {
+		store.Vals[transition] = &HistoryValue{Value: n.outputScore(h, transition)}
+	}
+	scores.IncAll(store, false)
+}
+
+// TransitionScores returns the scores of some transitions with a single
+// forward pass
+func (n *Neural) TransitionScores(transitions []int, features []Feature) []int64 {
+	_, h := n.forward(n.input(features, false))
+	scores := make([]int64, len(transitions))
+	for i, transition := range transitions {
+		scores[i] = n.outputScore(h, transition)
+	}
+	return scores
+}
+
+func (n *Neural) Score(features interface{}) int64 {
+	var retval int64
+	for f := features.(*transition.FeaturesList); f != nil && f.Previous != nil; f = f.Previous {
+		retval += n.TransitionScore(f.Transition, f.Previous.Features)
+	}
+	return retval
+}
+
+// step takes a gradient step of the score of the transition
+func (n *Neural) step(transition int, features []Feature, amount int64) {
+	input := n.input(features, true)
+	x, h := n.forward(input)
+	for transition >= len(n.Output) {
+		n.Output = append(n.Output, nil)
+	}
+	if n.Output[transition] == nil {
+		n.Output[transition] = randomVector(n.Size, n.rand())
+	}
+	var (
+		rate   = n.LearningRate * float64(amount)
+		output = n.Output[transition]
+		dx     = make([]float64, len(x))
+		dz     = make([]float64, n.Size)
+	)
+	for j := range dz {
+		dz[j] = output[j] * (1 - h[j]*h[j])
+		for k, val := range n.Hidden[j] {
+			dx[k] += dz[j] * val
+		}
+	}
+	for j := range output {
+		output[j] += rate * h[j]
+	}
+	for j, weights := range n.Hidden {
+		for k, val := range x {
+			weights[k] += rate * dz[j] * val
+		}
+		n.HiddenBias[j] += rate * dz[j]
+	}
+	for i, indices := range input {
+		table := n.Tables[n.Groups[i]]
+		slot := dx[i*n.Dim : (i+1)*n.Dim]
+		for _, index := range indices {
+			vector := table.Vectors[index]
+			for k, val := range slot {
+				vector[k] += rate * val / float64(len(indices))
+			}
+		}
+	}
+}
+
+func (n *Neural) apply(features interface{}, amount int64) {
+	for f := features.(*transition.FeaturesList); f != nil && f.Previous != nil; f = f.Previous {
+		n.step(f.Transition.Value(), f.Previous.Features, amount)
+	}
+}
+
+func (n *Neural) Add(features interface{}) perceptron.Model {
+	n.apply(features, 1)
+	return n
+}
+
+func (n *Neural) Subtract(features interface{}) perceptron.Model {
+	n.apply(features, -1)
+	return n
+}
+
+// AddSubtract steps the gold features down to the length of the decoded ones
+func (n *Neural) AddSubtract(goldFeatures, decodedFeatures interface{}, amount int64) {
+	g := goldFeatures.(*transition.FeaturesList)
+	f := decodedFeatures.(*transition.FeaturesList)
+	for ; f.Previous != nil && g.Previous != nil; f, g = f.Previous, g.Previous {
+		n.step(g.Transition.Value(), g.Previous.Features, amount)
+	}
+}
+
+func (n *Neural) ScalarDivide(val int64) {
+	n.each(func(weights []float64) {
+		for k := range weights {
+			weights[k] /= float64(val)
+		}
+	})
+}
+
+// each applies f to every parameter vector of the network
+func (n *Neural) each(f func([]float64)) {
+	for _, table := range n.Tables {
+		for _, vector := range table.Vectors {
+			f(vector)
+		}
+	}
+	for _, weights := range n.Hidden {
+		f(weights)
+	}
+	f(n.HiddenBias)
+	for _, weights := range n.Output {
+		if weights != nil {
+			f(weights)
+		}
+	}
+}
+
+func (n *Neural) Copy() perceptron.Model {
+	newNeural := &Neural{}
+	*newNeural = *n
+	newNeural.Groups = append([]int(nil), n.Groups...)
+	newNeural.GroupNames = append([]string(nil), n.GroupNames...)
+	newNeural.Tables = make([]*Embeddings, len(n.Tables))
+	for i, table := range n.Tables {
+		newNeural.Tables[i] = table.Copy()
+	}
+	newNeural.Hidden = copyMatrix(n.Hidden)
+	newNeural.HiddenBias = append([]float64(nil), n.HiddenBias...)
+	newNeural.Output = copyMatrix(n.Output)
+	newNeural.random = nil
+	return newNeural
+}
+
+// AddModel adds the parameters of a copy of the network
+func (n *Neural) AddModel(m perceptron.Model) {
+	other := m.(*Neural)
+	for i, table := range n.Tables {
+		for value, index := range other.Tables[i].Index {
+			if ownIndex, exists := table.Index[value]; exists {
+				addVector(table.Vectors[ownIndex], other.Tables[i].Vectors[index])
+			}
+		}
+	}
+	for j := range n.Hidden {
+		addVector(n.Hidden[j], other.Hidden[j])
+	}
+	addVector(n.HiddenBias, other.HiddenBias)
+	for t := range n.Output {
+		if n.Output[t] != nil && t < len(other.Output) && other.Output[t] != nil {
+			addVector(n.Output[t], other.Output[t])
+		}
+	}
+}
+
+func (n *Neural) New() perceptron.Model {
+	return NewNeural(n.groupNames(), n.Dim, n.Size, n.LearningRate, n.Seed)
+}
+
+func (n *Neural) groupNames() []string {
+	groups := make([]string, len(n.Groups))
+	for i, table := range n.Groups {
+		groups[i] = n.GroupNames[table]
+	}
+	return groups
+}
+
+func (n *Neural) String() string {
+	return fmt.Sprintf("Neural %d inputs x %d dims, %d hidden, %d transitions", len(n.Groups), n.Dim, n.Size, len(n.Output))
+}
+
+func copyMatrix(matrix [][]float64) [][]float64 {
+	newMatrix := make([][]float64, len(matrix))
+	for i, row := range matrix {
+		if row != nil {
+			newMatrix[i] = append([]float64(nil), row...)
+		}
+	}
+	return newMatrix
+}
+
+func addVector(vector, other []float64) {
+	for k, val := range other {
+		vector[k] += val
+	}
+}
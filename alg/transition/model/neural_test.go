@@ -0,0 +1,79 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	. "yap/alg/featurevector"
+	"yap/alg/transition"
+)
+
+// floatScore is the unscaled network output of a transition
+func (n *Neural) floatScore(transition int, features []Feature) float64 {
+	_, h := n.forward(n.input(features, false))
+	var score float64
+	for j, weight := range n.Output[transition] {
+		score += weight * h[j]
+	}
+	return score
+}
+
+// parameters returns every parameter vector of the network, in a fixed order
+func (n *Neural) parameters() [][]float64 {
+	var params [][]float64
+	n.each(func(vector []float64) {
+		params = append(params, vector)
+	})
+	return params
+}
+
+func TestNeuralStepGradient(t *testing.T) {
+	const (
+		transition = 2
+		eps        = 1e-6
+	)
+	// shared word embeddings, a generated feature and a missing one
+	n := NewNeural([]string{"w", "w", "p", "l"}, 3, 4, 0.001, 1)
+	features := []Feature{"a", "a", []interface{}{"N", "V"}, nil}
+	// a zero step adds the embeddings and output weights of the transition
+	n.step(transition, features, 0)
+	stepped := n.Copy().(*Neural)
+	stepped.step(transition, features, 1)
+
+	params, steppedParams := n.parameters(), stepped.parameters()
+	if len(params) != len(steppedParams) {
+		t.Fatalf("Expected %d parameter vectors after a step, got %d", len(params), len(steppedParams))
+	}
+	for i, vector := range params {
+		for k, val := range vector {
+			vector[k] = val + eps
+			plus := n.floatScore(transition, features)
+			vector[k] = val - eps
+			minus := n.floatScore(transition, features)
+			vector[k] = val
+			gradient := (plus - minus) / (2 * eps)
+			stepGradient := (steppedParams[i][k] - val) / n.LearningRate
+			if math.Abs(gradient-stepGradient) > 1e-6 {
+				t.Errorf("Expected step of parameter %d/%d along gradient %v, got %v", i, k, gradient, stepGradient)
+			}
+		}
+	}
+	if after, before := stepped.floatScore(transition, features), n.floatScore(transition, features); after <= before {
+		t.Errorf("Expected a positive step to raise the score, got %v <= %v", after, before)
+	}
+}
+
+func TestNeuralTransitionScores(t *testing.T) {
+	n := NewNeural([]string{"w", "p"}, 3, 4, 0.01, 1)
+	features := []Feature{"a", "N"}
+	for label := 0; label < 3; label++ {
+		n.step(label, features, int64(label+1))
+	}
+	// transition 9 has no output weights
+	transitions := []int{2, 9, 0}
+	for i, score := range n.TransitionScores(transitions, features) {
+		if expected := n.TransitionScore(transition.ConstTransition(transitions[i]), features); score != expected {
+			t.Errorf("Expected transition %d score %d, got %d", transitions[i], expected, score)
+		}
+	}
+}
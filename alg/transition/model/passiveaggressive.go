@@ -0,0 +1,83 @@
+package model
+
+import (
+	"math"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+)
+
+// LossFunc is the loss of a decoded instance against its gold
+type LossFunc func(gold, decoded perceptron.DecodedInstance) int64
+
+// PassiveAggressiveStrategy averages the model like AveragedModelStrategy,
+// but scales each update by the passive-aggressive (MIRA) step of Crammer et
+// al. 2006: the step that makes the gold outscore the decoded instance by its
+// loss, up to the aggressiveness C (PA-I).
+// Weights are integers, so a step of 1 is Scale units of weight; the
+// minimal update is a single unit
+type PassiveAggressiveStrategy struct {
+	AveragedModelStrategy
+	C     float64
+	Scale int64
+	Loss  LossFunc
+}
+
+var _ perceptron.ScaledUpdateStrategy = &PassiveAggressiveStrategy{}
+
+// paFeature is a weight of the model, a feature of a template for a transition
+type paFeature struct {
+	template, transition int
+	feature              interface{}
+}
+
+func (u *PassiveAggressiveStrategy) Amount(m perceptron.Model, gold, decoded perceptron.DecodedInstance, goldFeatures, decodedFeatures interface{}) int64 {
+	var (
+		model      = m.(*AvgMatrixSparse)
+		goldList   = goldFeatures.(*transition.FeaturesList)
+		parsedList = decodedFeatures.(*transition.FeaturesList)
+		difference = make(map[paFeature]int64)
+		norm       int64
+	)
+	loss := int64(1)
+	if u.Loss != nil {
+		loss = u.Loss(gold, decoded)
+	}
+	// the update adds the gold features down to the length of the decoded
+	// ones, and subtracts all decoded features
+	parsedScore, parsedLen := u.difference(model, parsedList, -1, -1, difference)
+	goldScore, _ := u.difference(model, goldList, parsedLen, 1, difference)
+	margin := parsedScore - goldScore
+	for _, count := range difference {
+		norm += count * count
+	}
+	if norm == 0 {
+		return 1
+	}
+	step := float64(margin+u.Scale*loss) / float64(norm)
+	if limit := u.C * float64(u.Scale); step > limit {
+		step = limit
+	}
+	if step < 1 {
+		return 1
+	}
+	return int64(math.Ceil(step))
+}
+
+// difference adds the features of at most length transitions of the list
+// (all of them if length is negative) to the difference with the sign, and
+// returns their score and number
+func (u *PassiveAggressiveStrategy) difference(model *AvgMatrixSparse, features *transition.FeaturesList, length int, sign int64, difference map[paFeature]int64) (int64, int) {
+	var score int64
+	n := 0
+	for cur := features; cur != nil && cur.Previous != nil && n != length; cur = cur.Previous {
+		intTrans := cur.Transition.Value()
+		for i, feature := range cur.Previous.Features {
+			if feature != nil {
+				score += model.Mat[i].Value(intTrans, feature)
+				difference[paFeature{i, intTrans, feature}] += sign
+			}
+		}
+		n++
+	}
+	return score, n
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/transition"
+)
+
+// transitionFeatures chains single feature transitions, first to last
+func transitionFeatures(features []string, labels []int) *transition.FeaturesList {
+	list := &transition.FeaturesList{}
+	for i, feature := range features {
+		list.Features = []featurevector.Feature{feature}
+		list = &transition.FeaturesList{Transition: transition.ConstTransition(labels[i]), Previous: list}
+	}
+	return list
+}
+
+func TestPassiveAggressiveAmount(t *testing.T) {
+	constLoss := func(loss int64) LossFunc {
+		return func(gold, decoded perceptron.DecodedInstance) int64 { return loss }
+	}
+	tests := []struct {
+		name           string
+		c              float64
+		loss           LossFunc
+		weights        map[int]int64 // label of feature a to weight
+		gold, decoded  *transition.FeaturesList
+		expectedAmount int64
+	}{
+		// the step of a loss of 1 over a norm of 2 is half of the scale
+		{"step", 1, nil, nil, toyFeatures("a", 1), toyFeatures("a", 0), 50},
+		// the margin is added to the scaled loss, and the step rounded up
+		{"margin", 1, nil, map[int]int64{0: 3}, toyFeatures("a", 1), toyFeatures("a", 0), 52},
+		{"loss", 2, constLoss(3), nil, toyFeatures("a", 1), toyFeatures("a", 0), 150},
+		{"clipped", 0.2, nil, nil, toyFeatures("a", 1), toyFeatures("a", 0), 20},
+		// a gold outscoring the decoded instance by more than the loss is
+		// still updated by the minimal step
+		{"minimal", 1, nil, map[int]int64{1: 500}, toyFeatures("a", 1), toyFeatures("a", 0), 1},
+		// the features of the gold and decoded cancel out
+		{"zero norm", 1, nil, nil, toyFeatures("a", 1), toyFeatures("a", 1), 1},
+		// only the last gold transition counts against a single decoded one
+		{"prefix", 1, nil, nil, transitionFeatures([]string{"b", "a"}, []int{2, 1}), toyFeatures("a", 0), 50},
+	}
+	for _, test := range tests {
+		m := NewAvgMatrixSparse(1, nil, false)
+		for label, weight := range test.weights {
+			m.apply(toyFeatures("a", label), weight)
+		}
+		u := &PassiveAggressiveStrategy{C: test.c, Scale: 100, Loss: test.loss}
+		if amount := u.Amount(m, nil, nil, test.gold, test.decoded); amount != test.expectedAmount {
+			t.Errorf("%s: Expected amount %d, got %d", test.name, test.expectedAmount, amount)
+		}
+	}
+}
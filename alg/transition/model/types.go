@@ -23,6 +23,8 @@ import (
 type Interface interface {
 	perceptron.Model
 	TransitionScore(transition Transition, features []Feature) int64
+	SetTransitionScores(features []Feature, scores ScoredStore, integrated bool)
+	TransitionScores(transitions []int, features []Feature) []int64
 }
 
 func MakeFeature(transition, i int, feat interface{}) interface{} {
@@ -126,6 +126,15 @@ type Oracle interface {
 	Name() string
 }
 
+// DynamicOracle is an oracle for any configuration, including those that
+// can no longer reach the gold structure (Goldberg & Nivre 2012)
+type DynamicOracle interface {
+	Oracle
+	// ZeroCost returns the possible transitions that lose no gold parts that
+	// are still reachable from the configuration
+	ZeroCost(Configuration) []Transition
+}
+
 func (seq ConfigurationSequence) String() string {
 	var buf bytes.Buffer
 	w := new(tabwriter.Writer)
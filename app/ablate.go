@@ -0,0 +1,292 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ablateSystem, ablateFeatures string
+	ablatePrefix                 string
+	ablateIterations             int
+	ablateTemplates              bool
+)
+
+// Ablation is a feature setup without a feature group or template, and the
+// dev score of a model trained with it. Ablations whose setup is not valid
+// (e.g. other templates require the removed elements) are skipped
+type Ablation struct {
+	Name    string
+	Setup   *transition.FeatureSetup
+	Result  float64
+	Skipped error
+}
+
+// Ablations returns the base feature setup followed by the setups without
+// each feature group, or without each template if templates is set
+func Ablations(setup *transition.FeatureSetup, templates bool) []*Ablation {
+	ablations := []*Ablation{{Name: "(none)", Setup: setup}}
+	for i, group := range setup.FeatureGroups {
+		if !templates {
+			ablations = append(ablations, &Ablation{Name: group.Group, Setup: withoutFeatures(setup, i, -1)})
+			continue
+		}
+		for j, feature := range group.Features {
+			ablations = append(ablations, &Ablation{Name: fmt.Sprintf("%s: %s", group.Group, feature), Setup: withoutFeatures(setup, i, j)})
+		}
+	}
+	return ablations
+}
+
+// withoutFeatures copies a setup without a group's feature, or without the
+// group (and its morph templates) if feature is negative
+func withoutFeatures(setup *transition.FeatureSetup, group, feature int) *transition.FeatureSetup {
+	ablated := &transition.FeatureSetup{
+		FeatureGroups:  make([]transition.FeatureGroup, 0, len(setup.FeatureGroups)),
+		MorphTemplates: make([]transition.MorphTemplate, 0, len(setup.MorphTemplates)),
+	}
+	for i, featureGroup := range setup.FeatureGroups {
+		if i == group {
+			if feature < 0 {
+				continue
+			}
+			features := make([]string, 0, len(featureGroup.Features)-1)
+			features = append(features, featureGroup.Features[:feature]...)
+			featureGroup.Features = append(features, featureGroup.Features[feature+1:]...)
+		}
+		ablated.FeatureGroups = append(ablated.FeatureGroups, featureGroup)
+	}
+	for _, morphTemplate := range setup.MorphTemplates {
+		if feature < 0 && morphTemplate.Group == setup.FeatureGroups[group].Group {
+			continue
+		}
+		ablated.MorphTemplates = append(ablated.MorphTemplates, morphTemplate)
+	}
+	return ablated
+}
+
+// ablationTrainer returns a function that trains a model of a transition
+// system from scratch with a feature file, for a number of iterations. The
+// other options of the model are those set by the ablate flags when it is
+// called
+func ablationTrainer(system string, iterations int) (func(featuresFile, modelPrefix string) error, error) {
+	switch system {
+	case "dep":
+		base := DepFlagOptions()
+		return func(featuresFile, modelPrefix string) error {
+			opts := *base
+			opts.FeaturesFile, opts.ModelPrefix, opts.Iterations = featuresFile, modelPrefix, iterations
+			dep, err := SetupDep(&opts)
+			if err != nil {
+				return err
+			}
+			_, err = DepTrain(dep, fmt.Sprintf("%s.b%d", opts.ModelPrefix, opts.BeamSize))
+			return err
+		}, nil
+	case "md":
+		base := MDFlagOptions()
+		return func(featuresFile, modelPrefix string) error {
+			opts := *base
+			opts.FeaturesFile, opts.ModelPrefix, opts.Iterations = featuresFile, modelPrefix, iterations
+			md, err := SetupMD(&opts)
+			if err != nil {
+				return err
+			}
+			return MDTrain(md, fmt.Sprintf("%s.b%d", opts.ModelPrefix, opts.BeamSize))
+		}, nil
+	case "joint":
+		base := JointFlagOptions()
+		return func(featuresFile, modelPrefix string) error {
+			opts := *base
+			opts.FeaturesFile, opts.ModelFile, opts.Iterations = featuresFile, modelPrefix, iterations
+			jt, err := SetupJoint(&opts)
+			if err != nil {
+				return err
+			}
+			return JointTrain(jt)
+		}, nil
+	}
+	return nil, fmt.Errorf("Unknown transition system %s, expected one of: %s", system, FeaturesSystems)
+}
+
+// newFeatureErrors returns the errors that are not of the base feature file
+func newFeatureErrors(errs []error, baseErrors map[string]bool) []error {
+	var newErrs []error
+	for _, err := range errs {
+		if !baseErrors[err.Error()] {
+			newErrs = append(newErrs, err)
+		}
+	}
+	return newErrs
+}
+
+// runAblation writes the feature file of an ablation and trains a model
+// with it for a fixed number of iterations, keeping the dev score of the
+// last evaluation of the training stop condition. Errors of the base
+// feature file are tolerated, an ablation with other errors is skipped
+func runAblation(ablation *Ablation, index int, train func(featuresFile, modelPrefix string) error, baseErrors map[string]bool) error {
+	featuresFile := fmt.Sprintf("%s.%d.yaml", ablatePrefix, index)
+	modelPrefix := fmt.Sprintf("%s.%d", ablatePrefix, index)
+	data, err := yaml.Marshal(ablation.Setup)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(featuresFile, data, 0644); err != nil {
+		return err
+	}
+	_, errs, err := FeatureErrors(featuresFile, ablateSystem)
+	if err == nil {
+		if newErrs := newFeatureErrors(errs, baseErrors); len(newErrs) > 0 {
+			err = fmt.Errorf("Feature file %s has %d new error(s), e.g. %v", featuresFile, len(newErrs), newErrs[0])
+		}
+	}
+	if err != nil {
+		log.Println("Skipping ablation", index, ablation.Name+":", err)
+		ablation.Skipped = err
+		return nil
+	}
+	log.Println("Running ablation", index, ablation.Name, "with", featuresFile)
+	FixedIterations, DevResult = true, math.NaN()
+	if err := train(featuresFile, modelPrefix); err != nil {
+		return err
+	}
+	if math.IsNaN(DevResult) {
+		return fmt.Errorf("Ablation %s was not evaluated on the dev set", ablation.Name)
+	}
+	ablation.Result = DevResult
+	return nil
+}
+
+func Ablate(cmd *commander.Command, args []string) error {
+	// ablations are scored on the dev set
+	REQUIRED_FLAGS := []string{"f", "in", "ing"}
+	switch ablateSystem {
+	case "dep":
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "tc")
+	case "md":
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "td", "tl")
+	case "joint":
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "tc", "tl")
+	}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	if location, found := util.LocateFile(ablateFeatures, DEFAULT_CONF_DIRS); found {
+		ablateFeatures = location
+	}
+	if location, found := util.LocateFile(DepLabelsFile, DEFAULT_CONF_DIRS); found {
+		DepLabelsFile = location
+	}
+	setup, errs, err := FeatureErrors(ablateFeatures, ablateSystem)
+	if err != nil {
+		return err
+	}
+	baseErrors := make(map[string]bool, len(errs))
+	for _, err := range errs {
+		baseErrors[err.Error()] = true
+	}
+	if len(errs) > 0 {
+		log.Println("Base feature file", ablateFeatures, "has", len(errs), "error(s), ablations are skipped only for other errors")
+	}
+	train, err := ablationTrainer(ablateSystem, ablateIterations)
+	if err != nil {
+		return err
+	}
+	ablations := Ablations(setup, ablateTemplates)
+	for i, ablation := range ablations {
+		if err := runAblation(ablation, i, train, baseErrors); err != nil {
+			return err
+		}
+	}
+
+	metric := "F1"
+	if ablateSystem == "dep" {
+		metric = "LAS"
+	}
+	fmt.Printf("%-4s %-50s %8s %8s\n", "#", "Ablation", "Dev "+metric, "Delta")
+	for i, ablation := range ablations {
+		if ablation.Skipped != nil {
+			fmt.Printf("%-4d %-50s %8s %8s\n", i, ablation.Name, "skipped", "")
+			continue
+		}
+		fmt.Printf("%-4d %-50s %8.2f %+8.2f\n", i, ablation.Name, percent(ablation.Result), percent(ablation.Result)-percent(ablations[0].Result))
+	}
+	return nil
+}
+
+func AblateCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       Ablate,
+		UsageLine: "ablate <file options> [arguments]",
+		Short:     "train without each feature group and compare dev scores",
+		Long: `
+train without each feature group and compare dev scores
+
+	$ ./yap ablate -t dep|md|joint -f <features> -in <dev input> -ing <dev gold> [-tc <conll>] [-td <train disamb. lat>] [-tl <train amb. lat>] [-it <n>] [-templates] [options]
+
+Trains a model with the base feature file, then a model without each of its
+feature groups (or each template, with -templates), for a fixed number of
+iterations. The training and dev files and options are those of the dep, md
+or joint command (-t); each iteration is evaluated on the dev set (-in,
+-ing). Prints the dev score (LAS for dep, F1 for md and joint) of each
+model, and its difference from the base model. The feature files and models
+of the ablations are written to {m}.<number>.yaml and {m}.<number>.b{b}
+(joint doesn't write models). Templates that are invalid in the base feature
+file are kept; ablations with other invalid templates are skipped.
+`,
+		Flag: *flag.NewFlagSet("ablate", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&ablateSystem, "t", "dep", "Transition system ["+FeaturesSystems+"]")
+	cmd.Flag.StringVar(&ablateFeatures, "f", "", "Base Features Configuration File")
+	cmd.Flag.StringVar(&ablatePrefix, "m", "ablate", "Prefix for ablation feature and model files")
+	cmd.Flag.IntVar(&ablateIterations, "it", 1, "Number of Perceptron Iterations of each ablation")
+	cmd.Flag.BoolVar(&ablateTemplates, "templates", false, "Ablate each template instead of each feature group")
+
+	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.StringVar(&BeamUpdate, "update", "early", "Optional - Beam training update strategy ["+search.UpdateStrategies+"]")
+	cmd.Flag.IntVar(&TrainWorkers, "workers", 1, "Optional - Number of parallel training workers (iterative parameter mixing)")
+	cmd.Flag.StringVar(&Learner, "learner", "perceptron", "Optional - Training update strategy ["+Learners+"]")
+	cmd.Flag.Float64Var(&LearnerC, "mirac", 1.0, "Optional - Aggressiveness (maximal step) of mira updates")
+	cmd.Flag.StringVar(&ClustersFile, "clusters", "", "Optional - Brown word clusters (paths format) of the c<bits> feature attributes")
+	cmd.Flag.StringVar(&EmbeddingClustersFile, "embclusters", "", "Optional - Word embeddings (word2vec/GloVe text format) clustered for the e feature attribute")
+	cmd.Flag.IntVar(&EmbeddingClusters, "embk", 100, "Optional - Number of embedding clusters")
+	cmd.Flag.IntVar(&BeamSize, "b", 32, "Beam Size")
+	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System ["+ArcSystems+"] (dep, joint)")
+	cmd.Flag.BoolVar(&PseudoProjective, "pp", false, "Optional - Pseudo-projective training (dep, joint)")
+	cmd.Flag.BoolVar(&DepDynamicOracle, "dyn", false, "Optional - Train greedily with the arc eager dynamic oracle and error exploration (dep)")
+	cmd.Flag.IntVar(&DepExploreAfter, "dynk", 0, "Optional - With -dyn, follow wrong predictions only after this many training instances")
+	cmd.Flag.Float64Var(&DepExploreProbability, "dynp", 0.9, "Optional - With -dyn, probability of following a wrong prediction")
+	cmd.Flag.StringVar(&DepLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File (dep, joint)")
+	cmd.Flag.StringVar(&MdParamFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"] (md, joint)")
+	cmd.Flag.StringVar(&JointStrategy, "jointstr", "ArcGreedy", "Joint Strategy: ["+joint.JointStrategies+"]")
+	cmd.Flag.StringVar(&OracleStrategy, "oraclestr", "ArcGreedy", "Oracle Strategy: ["+joint.OracleStrategies+"]")
+	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD")
+	cmd.Flag.BoolVar(&MdCombineGold, "infusedev", false, "Infuse gold morphs into lattices for test corpus")
+
+	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File (dep, joint)")
+	cmd.Flag.StringVar(&tLatDis, "td", "", "Training Disambiguated Lattices File (md, joint)")
+	cmd.Flag.StringVar(&tLatAmb, "tl", "", "Training Ambiguous Lattices File (md, joint)")
+	cmd.Flag.StringVar(&input, "in", "", "Dev Input File (conll sentences for dep, ambiguous lattices for md and joint)")
+	cmd.Flag.StringVar(&inputGold, "ing", "", "Dev Gold File")
+	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.BoolVar(&Lemmatize, "lemmatize", true, "Choose and fill the lemmas of the output morphemes")
+	cmd.Flag.BoolVar(&TagFeats, "tagfeats", false, "Train (with the model) and apply a gen/num/per tagger to underspecified output morphemes (OOVs, NNPs)")
+	cmd.Flag.StringVar(&FeatsTaggerFile, "tagfeatsf", "feats.tagger.yaml", "Feature Tagger Features Configuration File")
+	cmd.Flag.IntVar(&FeatsTaggerIters, "tagfeatsit", 4, "Minimum Number of Feature Tagger Perceptron Iterations")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U-format input files")
+	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
+	cmd.Flag.IntVar(&limitdev, "limitdev", 0, "limit dev set (in thousands, joint)")
+	cmd.Flag.BoolVar(&search.AllOut, "showbeam", false, "Show candidates in beam")
+	return cmd
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"yap/alg/search"
+	"yap/alg/transition"
+)
+
+func ablateTestSetup() *transition.FeatureSetup {
+	return &transition.FeatureSetup{
+		FeatureGroups: []transition.FeatureGroup{
+			{Group: "words", Transition: "Arc", Features: []string{"S0|w,S0|w", "N0|w,N0|w"}},
+			{Group: "tags", Transition: "Arc", Features: []string{"S0|p,S0|p", "N0|p,N0|p"}},
+		},
+		MorphTemplates: []transition.MorphTemplate{
+			{Group: "words", Combinations: []string{"Pf"}},
+			{Group: "tags", Combinations: []string{"Pf"}},
+		},
+	}
+}
+
+func TestAblationsGroups(t *testing.T) {
+	setup := ablateTestSetup()
+	ablations := Ablations(setup, false)
+	if len(ablations) != 3 {
+		t.Fatalf("Expected the base and 2 group ablations, got %d", len(ablations))
+	}
+	if ablations[0].Name != "(none)" || ablations[0].Setup != setup {
+		t.Errorf("Expected the base setup first, got %s", ablations[0].Name)
+	}
+	ablated := ablations[1].Setup
+	if ablations[1].Name != "words" || len(ablated.FeatureGroups) != 1 || ablated.FeatureGroups[0].Group != "tags" {
+		t.Errorf("Expected ablation of group words to keep group tags, got %s: %v", ablations[1].Name, ablated.FeatureGroups)
+	}
+	// the morph templates of the dropped group are dropped with it
+	if !reflect.DeepEqual(ablated.MorphTemplates, setup.MorphTemplates[1:]) {
+		t.Errorf("Expected ablation of group words to keep morph templates %v, got %v", setup.MorphTemplates[1:], ablated.MorphTemplates)
+	}
+	if !reflect.DeepEqual(setup, ablateTestSetup()) {
+		t.Errorf("Expected the base setup to be unchanged, got %v", setup)
+	}
+}
+
+func TestAblationsTemplates(t *testing.T) {
+	setup := ablateTestSetup()
+	ablations := Ablations(setup, true)
+	if len(ablations) != 5 {
+		t.Fatalf("Expected the base and 4 template ablations, got %d", len(ablations))
+	}
+	ablation := ablations[2]
+	if ablation.Name != "words: N0|w,N0|w" {
+		t.Errorf("Expected ablation of template N0|w of group words, got %s", ablation.Name)
+	}
+	// the rest of the group and all the morph templates are kept
+	expected := []transition.FeatureGroup{
+		{Group: "words", Transition: "Arc", Features: []string{"S0|w,S0|w"}},
+		setup.FeatureGroups[1],
+	}
+	if !reflect.DeepEqual(ablation.Setup.FeatureGroups, expected) {
+		t.Errorf("Expected feature groups %v, got %v", expected, ablation.Setup.FeatureGroups)
+	}
+	if !reflect.DeepEqual(ablation.Setup.MorphTemplates, setup.MorphTemplates) {
+		t.Errorf("Expected morph templates %v, got %v", setup.MorphTemplates, ablation.Setup.MorphTemplates)
+	}
+	if !reflect.DeepEqual(setup, ablateTestSetup()) {
+		t.Errorf("Expected the base setup to be unchanged, got %v", setup)
+	}
+}
+
+func TestAblationTrainer(t *testing.T) {
+	if _, err := ablationTrainer("tagger", 1); err == nil {
+		t.Errorf("Expected an error for an unknown transition system")
+	}
+	dir, err := ioutil.TempDir("", "yapablate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// training writes the models of its iterations to the working directory
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func(allOut bool) { search.AllOut = allOut }(search.AllOut)
+	defer func(prefix string) { ablatePrefix = prefix }(ablatePrefix)
+	defer func(fixed bool) { FixedIterations = fixed }(FixedIterations)
+
+	conll := filepath.Join(wd, "testdata", "ablate.conll")
+	cmd := AblateCmd()
+	if err := cmd.Flag.Parse([]string{"-b", "4", "-m", filepath.Join(dir, "ablate"),
+		"-l", filepath.Join(wd, "testdata", "ablate.labels.conf"), "-tc", conll, "-in", conll, "-ing", conll}); err != nil {
+		t.Fatal(err)
+	}
+	features := filepath.Join(wd, "..", "conf", "zhangnivre2011.yaml")
+	setup, _, err := FeatureErrors(features, "dep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	train, err := ablationTrainer("dep", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no template requires the last one
+	all := Ablations(setup, true)
+	ablations := []*Ablation{all[0], all[len(all)-1]}
+	for i, ablation := range ablations {
+		if err := runAblation(ablation, i, train, nil); err != nil {
+			t.Fatalf("Expected ablation %s to train, got %v", ablation.Name, err)
+		}
+		if ablation.Skipped != nil || ablation.Result <= 0 || ablation.Result > 1 {
+			t.Errorf("Expected a dev LAS of ablation %s, got %v (skipped: %v)", ablation.Name, ablation.Result, ablation.Skipped)
+		}
+		if modelFile := filepath.Join(dir, fmt.Sprintf("ablate.%d.b4", i)); !VerifyExists(modelFile) {
+			t.Errorf("Expected model file %s of ablation %s", modelFile, ablation.Name)
+		}
+	}
+}
+
+func TestNewFeatureErrors(t *testing.T) {
+	base := map[string]bool{"L0|l: Unknown attribute l of L": true}
+	errs := []error{errors.New("L0|l: Unknown attribute l of L"), errors.New("S0|w: requirement N0|w is not loaded")}
+	newErrs := newFeatureErrors(errs, base)
+	if len(newErrs) != 1 || newErrs[0] != errs[1] {
+		t.Errorf("Expected only the error of S0|w, got %v", newErrs)
+	}
+}
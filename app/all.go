@@ -28,9 +28,14 @@ var AppCommands = []*commander.Command{
 	DepCmd(),
 	MdCmd(),
 	JointCmd(),
-	//MALearnCmd(),
+	MALearnCmd(),
 	MACmd(),
 	HebMACmd(),
+	MAEvalCmd(),
+	MDCompareCmd(),
+	MDEvalCmd(),
+	FeaturesCmd(),
+	AblateCmd(),
 	// ValidateMAGoldCmd(),
 	// GenLemmasCmd(),
 	// GenUnAmbLemmasCmd(),
@@ -45,6 +50,15 @@ func AllCommands() *commander.Command {
 		Flag:        *flag.NewFlagSet("app", flag.ExitOnError),
 	}
 	for _, app := range cmd.Subcommands {
+		// commands of subcommands (e.g. features check) are wrapped instead
+		for _, sub := range app.Subcommands {
+			sub.Run = NewAppWrapCommand(sub.Run)
+			sub.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
+			sub.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
+		}
+		if app.Run == nil {
+			continue
+		}
 		app.Run = NewAppWrapCommand(app.Run)
 		app.Flag.IntVar(&CPUs, NUM_CPUS_FLAG, 0, "Max CPUS to use (runtime.GOMAXPROCS); 0 = all")
 		app.Flag.StringVar(&CPUProfile, "cpuprofile", "", "write cpu profile to file")
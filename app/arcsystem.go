@@ -0,0 +1,48 @@
+package app
+
+import (
+	"fmt"
+
+	"yap/alg/transition"
+	. "yap/nlp/parser/dependency/transition"
+)
+
+// ArcSystems are the names accepted by the -a flag
+const ArcSystems = "standard, eager, swap"
+
+func transitionValue(t transition.Transition) int {
+	if t == nil {
+		return 0
+	}
+	return t.Value()
+}
+
+// NewArcSystem instantiates a dependency arc system by name, with the
+// transition values of the current enumerations (zero before they are set
+// up), and returns the stack size of its terminal configurations
+func NewArcSystem(name string) (transition.TransitionSystem, int, error) {
+	standard := ArcStandard{
+		SHIFT:       transitionValue(SH),
+		LEFT:        transitionValue(LA),
+		RIGHT:       transitionValue(RA),
+		Relations:   ERel,
+		Transitions: ETrans,
+	}
+	switch name {
+	case "standard":
+		return &standard, 1, nil
+	case "eager":
+		return &ArcEager{
+			ArcStandard: standard,
+			REDUCE:      transitionValue(RE),
+			POPROOT:     transitionValue(PR),
+		}, 0, nil
+	case "swap":
+		return &ArcSwap{
+			ArcStandard: standard,
+			SWAP:        transitionValue(SW),
+		}, 1, nil
+	default:
+		return nil, 0, fmt.Errorf("Unknown arc system %s, expected one of: %s", name, ArcSystems)
+	}
+}
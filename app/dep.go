@@ -2,6 +2,7 @@ package app
 
 import (
 	// "yap/alg/featurevector"
+	"bytes"
 	"fmt"
 	"yap/alg/perceptron"
 	"yap/alg/search"
@@ -9,6 +10,7 @@ import (
 	transitionmodel "yap/alg/transition/model"
 	"yap/nlp/format/conll"
 	"yap/nlp/format/conllu"
+	"yap/nlp/format/embedding"
 	"yap/nlp/format/lattice"
 	. "yap/nlp/parser/dependency/transition"
 	nlp "yap/nlp/types"
@@ -17,7 +19,7 @@ import (
 
 	"log"
 	"os"
-	// "strings"
+	"strings"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -30,6 +32,18 @@ var (
 	DepModelFile   string
 	//DepBeamSize   int
 	DepArcSystemStr string
+
+	// dynamic oracle training with error exploration (arc eager only)
+	DepDynamicOracle      bool
+	DepExploreAfter       int
+	DepExploreProbability float64
+
+	// dense feed forward network instead of the sparse perceptron model
+	DepNeural           bool
+	DepNeuralEmbeddings string
+	DepNeuralDim        int
+	DepNeuralHidden     int
+	DepNeuralRate       float64
 )
 
 func SetupDepEnum(relations []string) {
@@ -48,69 +62,172 @@ func EstimatedBeamTransitions() int {
 	return ERel.Len()*2 + 2
 }
 
-func DepConfigOut(outModelFile string, b search.Interface, t transition.TransitionSystem) {
+// NewDepNeural makes a neural model for the feature templates; templates of
+// the same attributes share an embedding table, and the word table starts
+// from the pretrained embeddings if given
+func NewDepNeural(templates []transition.FeatureTemplate) *transitionmodel.Neural {
+	var (
+		vectors map[string][]float64
+		err     error
+	)
+	if len(DepNeuralEmbeddings) > 0 {
+		vectors, DepNeuralDim, err = embedding.ReadFile(DepNeuralEmbeddings)
+		if err != nil {
+			log.Fatalln("Failed reading embeddings", DepNeuralEmbeddings, err)
+		}
+		if allOut {
+			log.Println("Read", len(vectors), "embeddings of size", DepNeuralDim, "from", DepNeuralEmbeddings)
+		}
+	}
+	groups := make([]string, len(templates))
+	for i, template := range templates {
+		attributes := make([]string, len(template.Elements))
+		for j, element := range template.Elements {
+			attributes[j] = string(bytes.Join(element.Attributes, []byte(transition.ATTRIBUTE_SEPARATOR)))
+		}
+		groups[i] = strings.Join(attributes, transition.FEATURE_SEPARATOR)
+	}
+	neural := transitionmodel.NewNeural(groups, DepNeuralDim, DepNeuralHidden, DepNeuralRate, 1)
+	if vectors != nil {
+		words := make(map[interface{}][]float64, len(vectors))
+		for word, vector := range vectors {
+			index, _ := EWord.Add(word)
+			words[index] = vector
+		}
+		if err = neural.SetEmbeddings("w", words); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	return neural
+}
+
+// DepOptions are the files and settings of dependency training and parsing,
+// the dep command sets them from its flags
+type DepOptions struct {
+	Train              string // training conll(u)
+	Input, InputLat    string // tagged sentences or disambiguated lattices to parse
+	InputGold          string // dev gold sentences (for convergence)
+	Test               string
+	OutConll           string
+	FeaturesFile       string
+	LabelsFile         string
+	ArcSystem          string
+	ModelPrefix        string // trained models are written to {prefix}.b{beam}
+	Iterations         int
+	BeamSize           int
+	UseConllU          bool
+	Limit              int
+	Stream             bool
+	DynamicOracle      bool
+	ExploreAfter       int
+	ExploreProbability float64
+	Neural             bool
+}
+
+// DepFlagOptions returns the dependency options set by the dep flags
+func DepFlagOptions() *DepOptions {
+	return &DepOptions{
+		Train:              tConll,
+		Input:              input,
+		InputLat:           inputLat,
+		InputGold:          inputGold,
+		Test:               test,
+		OutConll:           outConll,
+		FeaturesFile:       DepFeaturesFile,
+		LabelsFile:         DepLabelsFile,
+		ArcSystem:          DepArcSystemStr,
+		ModelPrefix:        DepModelFile,
+		Iterations:         Iterations,
+		BeamSize:           BeamSize,
+		UseConllU:          useConllU,
+		Limit:              limit,
+		Stream:             Stream,
+		DynamicOracle:      DepDynamicOracle,
+		ExploreAfter:       DepExploreAfter,
+		ExploreProbability: DepExploreProbability,
+		Neural:             DepNeural,
+	}
+}
+
+func DepConfigOut(opts *DepOptions, outModelFile string, b search.Interface, t transition.TransitionSystem) {
 	log.Println("Configuration")
 	log.Printf("Beam:             \t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
-	log.Printf("Iterations:\t\t%d", Iterations)
-	log.Printf("Beam Size:\t\t%d", BeamSize)
+	log.Printf("Iterations:\t\t%d", opts.Iterations)
+	log.Printf("Beam Size:\t\t%d", opts.BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
+	log.Printf("Beam Lazy:\t\t%v", LazyBeam)
+	log.Printf("Beam Update:\t\t%s", BeamUpdate)
+	log.Printf("Train Workers:\t\t%d", TrainWorkers)
+	if len(ClustersFile) > 0 {
+		log.Printf("Word clusters:\t\t%s", ClustersFile)
+	}
+	if len(EmbeddingClustersFile) > 0 {
+		log.Printf("Embedding clusters:\t%s (%d)", EmbeddingClustersFile, EmbeddingClusters)
+	}
+	log.Printf("Learner:\t\t%s", Learner)
+	if Learner == "mira" {
+		log.Printf("Learner C:\t\t%v", LearnerC)
+	}
 	log.Printf("Model file:\t\t%s", outModelFile)
 	log.Printf("Use Lemmas:\t\t%v", !lattice.IGNORE_LEMMA)
 	log.Printf("Word Type:\t\t%v", conll.WORD_TYPE)
+	log.Printf("Pseudo-projective:\t%v", PseudoProjective)
+	log.Printf("Dynamic oracle:\t%v", opts.DynamicOracle)
+	if opts.DynamicOracle {
+		log.Printf("Explore after:\t%d", opts.ExploreAfter)
+		log.Printf("Explore prob.:\t%v", opts.ExploreProbability)
+	}
+	log.Printf("Neural model:\t\t%v", opts.Neural)
+	if opts.Neural {
+		log.Printf("Neural embeddings:\t%s", DepNeuralEmbeddings)
+		log.Printf("Neural dimension:\t%d", DepNeuralDim)
+		log.Printf("Neural hidden:\t%d", DepNeuralHidden)
+		log.Printf("Neural rate:\t\t%v", DepNeuralRate)
+	}
 
 	log.Println()
-	log.Printf("Features File:\t%s", DepFeaturesFile)
-	if !VerifyExists(DepFeaturesFile) {
+	log.Printf("Features File:\t%s", opts.FeaturesFile)
+	if !VerifyExists(opts.FeaturesFile) {
 		os.Exit(1)
 	}
-	log.Printf("Labels File:\t\t%s", DepLabelsFile)
-	if !VerifyExists(DepLabelsFile) {
+	log.Printf("Labels File:\t\t%s", opts.LabelsFile)
+	if !VerifyExists(opts.LabelsFile) {
 		os.Exit(1)
 	}
 	log.Println()
 	log.Println("Data")
-	if len(tConll) > 0 {
-		log.Printf("Train file (conll):\t\t\t%s", tConll)
-		if !VerifyExists(tConll) {
+	if len(opts.Train) > 0 {
+		log.Printf("Train file (conll):\t\t\t%s", opts.Train)
+		if !VerifyExists(opts.Train) {
 			return
 		}
 	}
-	if len(inputLat) > 0 {
-		log.Printf("Input file  (lattice sentences):\t%s", inputLat)
-		if !VerifyExists(inputLat) {
+	if len(opts.InputLat) > 0 {
+		log.Printf("Input file  (lattice sentences):\t%s", opts.InputLat)
+		if !VerifyExists(opts.InputLat) {
 			os.Exit(1)
 		}
 	}
-	if len(input) > 0 {
-		log.Printf("Input file  (tagged sentences):\t%s", input)
-		if !VerifyExists(input) {
+	if len(opts.Input) > 0 {
+		log.Printf("Input file  (tagged sentences):\t%s", opts.Input)
+		if !VerifyExists(opts.Input) {
 			os.Exit(1)
 		}
 
 	}
-	if len(outConll) > 0 {
-		log.Printf("Out (conll) file:\t\t\t%s", outConll)
+	if len(opts.OutConll) > 0 {
+		log.Printf("Out (conll) file:\t\t\t%s", opts.OutConll)
 	}
 }
 
 func DepTrainAndParse(cmd *commander.Command, args []string) error {
+	opts := DepFlagOptions()
 	// instantiate the arc system for config output only
-	// it will be reinstantiated later on with struct values
-
-	var (
-		arcSystem     transition.TransitionSystem
-		terminalStack int
-	)
-	switch DepArcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{}
-		terminalStack = 1
-	case "eager":
-		arcSystem = &ArcEager{}
-		terminalStack = 0
-	default:
-		panic("Unknown arc system")
+	// it will be reinstantiated with the enumerations by SetupDep
+	arcSystem, _, err := NewArcSystem(opts.ArcSystem)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
 	arcSystem.AddDefaultOracle()
@@ -118,19 +235,19 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 	transitionSystem := transition.TransitionSystem(arcSystem)
 	REQUIRED_FLAGS := []string{"oc"}
 
-	featuresLocation, found := util.LocateFile(DepFeaturesFile, DEFAULT_CONF_DIRS)
+	featuresLocation, found := util.LocateFile(opts.FeaturesFile, DEFAULT_CONF_DIRS)
 	if found {
-		DepFeaturesFile = featuresLocation
+		opts.FeaturesFile = featuresLocation
 	} else {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "f")
 	}
-	labelsLocation, found := util.LocateFile(DepLabelsFile, DEFAULT_CONF_DIRS)
+	labelsLocation, found := util.LocateFile(opts.LabelsFile, DEFAULT_CONF_DIRS)
 	if found {
-		DepLabelsFile = labelsLocation
+		opts.LabelsFile = labelsLocation
 	} else {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "l")
 	}
-	if VerifyExists(inputLat) {
+	if VerifyExists(opts.InputLat) {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "inl")
 	} else {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "in")
@@ -138,8 +255,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 
 	// RegisterTypes()
 	var (
-		outModelFile string                           = fmt.Sprintf("%s.b%d", DepModelFile, BeamSize)
-		model        *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
+		outModelFile string = fmt.Sprintf("%s.b%d", opts.ModelPrefix, opts.BeamSize)
 		modelExists  bool
 	)
 	// search for model file locally or in data/ path
@@ -156,70 +272,115 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		REQUIRED_FLAGS = []string{"it", "tc"}
 		VerifyFlags(cmd, REQUIRED_FLAGS)
 	}
+	var serialization *Serialization
+	if modelExists {
+		// the model's training options decide the enumerations set up below
+		serialization = ReadModel(outModelFile)
+		serialization.Config.Apply()
+	}
 	if allOut && !parseOut {
-		DepConfigOut(outModelFile, &search.Beam{}, transitionSystem)
+		DepConfigOut(opts, outModelFile, &search.Beam{}, transitionSystem)
 	}
-	// modelExists := false
-	relations, err := conf.ReadFile(DepLabelsFile)
+	dep, err := SetupDep(opts)
 	if err != nil {
-		log.Println("Failed reading dependency labels configuration file:", DepLabelsFile)
 		log.Fatalln(err)
 	}
+
+	var classifier transitionmodel.Interface
+	if !modelExists {
+		if classifier, err = DepTrain(dep, outModelFile); err != nil {
+			return err
+		}
+	} else {
+		if allOut && !parseOut {
+			log.Println("Found model file", outModelFile, " ... loading model")
+		}
+		if serialization.NeuralModel != nil {
+			classifier = serialization.NeuralModel
+		} else {
+			model := &transitionmodel.AvgMatrixSparse{}
+			model.Deserialize(serialization.WeightModel)
+			classifier = model
+		}
+		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
+		if allOut && !parseOut {
+			log.Println("Loaded model")
+		}
+		// model.Log = true
+	}
+	if allOut {
+		log.Println()
+	}
+	return DepParse(dep, classifier)
+}
+
+// DepSetup holds what training and parsing a dependency parser share: its
+// options, arc system, features and word clusters
+type DepSetup struct {
+	Options       *DepOptions
+	Trans         transition.TransitionSystem
+	TerminalStack int
+	FeatureSetup  *transition.FeatureSetup
+	Extractor     *transition.GenericExtractor
+	Clusters      *nlp.WordClusters
+}
+
+// SetupDep sets up the enumerations, arc system and features of dependency
+// options
+func SetupDep(opts *DepOptions) (*DepSetup, error) {
+	clusters := LoadWordClusters()
+	relations, err := conf.ReadFile(opts.LabelsFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading dependency labels configuration file %s: %v", opts.LabelsFile, err)
+	}
 	if allOut && !parseOut {
 		log.Println()
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
-	SetupDepEnum(relations.Values)
+	SetupDepEnum(PseudoProjectiveRelations(relations.Values))
 
 	// after calling SetupDepEnum, enums are instantiated and set according to the relations
-	// therefore we re-instantiate the arc system with the right parameters
-	switch DepArcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{
-			SHIFT:       SH.Value(),
-			LEFT:        LA.Value(),
-			RIGHT:       RA.Value(),
-			Transitions: ETrans,
-			Relations:   ERel,
-		}
-	case "eager":
-		arcSystem = &ArcEager{
-			ArcStandard: ArcStandard{
-				SHIFT:       SH.Value(),
-				LEFT:        LA.Value(),
-				RIGHT:       RA.Value(),
-				Relations:   ERel,
-				Transitions: ETrans,
-			},
-			REDUCE:  RE.Value(),
-			POPROOT: PR.Value(),
-		}
-	default:
-		panic("Unknown arc system")
+	// therefore the arc system is instantiated only now
+	arcSystem, terminalStack, err := NewArcSystem(opts.ArcSystem)
+	if err != nil {
+		return nil, err
 	}
 
 	arcSystem.AddDefaultOracle()
 
-	transitionSystem = transition.TransitionSystem(arcSystem)
-
 	if allOut && !parseOut {
 		log.Println()
 		log.Println("Loading features")
 	}
 
-	// features, err := conf.ReadFile(featuresFile)
-	if err != nil {
-		log.Println("Failed reading feature configuration file:", DepFeaturesFile)
-		log.Fatalln(err)
-	}
-	featureSetup, err := transition.LoadFeatureConfFile(DepFeaturesFile)
+	featureSetup, err := transition.LoadFeatureConfFile(opts.FeaturesFile)
 	if err != nil {
-		log.Println("Failed reading feature configuration file:", DepFeaturesFile)
-		log.Fatalln(err)
+		return nil, fmt.Errorf("Failed reading feature configuration file %s: %v", opts.FeaturesFile, err)
 	}
 	extractor := SetupExtractor(featureSetup, []byte("A"))
 	// extractor.Log = true
+	return &DepSetup{
+		Options:       opts,
+		Trans:         arcSystem,
+		TerminalStack: terminalStack,
+		FeatureSetup:  featureSetup,
+		Extractor:     extractor,
+		Clusters:      clusters,
+	}, nil
+}
+
+// DepTrain trains a dependency parser on the training sentences of its
+// options, writes it to outModelFile and returns it
+func DepTrain(dep *DepSetup, outModelFile string) (transitionmodel.Interface, error) {
+	var (
+		opts             = dep.Options
+		transitionSystem = dep.Trans
+		extractor        = dep.Extractor
+		model            *transitionmodel.AvgMatrixSparse
+		neural           *transitionmodel.Neural
+		classifier       transitionmodel.Interface
+	)
 	group, _ := extractor.TransTypeGroups['A']
 	formatters := make([]util.Format, len(group.FeatureTemplates))
 	for i, formatter := range group.FeatureTemplates {
@@ -227,240 +388,284 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 	}
 
 	var (
-		sents       []interface{}
-		sentsStream chan interface{}
+		sents         []interface{}
+		asMorphGraphs []interface{}
+		//goldMorphGraphs []interface{}
 	)
-	if !modelExists {
-		var (
-			asMorphGraphs []interface{}
-			//goldMorphGraphs []interface{}
-		)
+	if allOut {
+		log.Println("Model file", outModelFile, "not found, training")
+	}
+	var asGraphs []interface{}
+	if opts.UseConllU {
+		devi, _, e2 := conllu.ReadFile(opts.Input, opts.Limit)
+		if e2 != nil {
+			log.Fatalln(e2)
+		}
+		// const NUM_SENTS = 20
+
+		// s = s[:NUM_SENTS]
 		if allOut {
-			log.Println("Model file", outModelFile, "not found, training")
+			log.Println("Read", len(devi), "sentences from", opts.Input)
+			log.Println("Converting from conllu to internal format")
 		}
-		var asGraphs []interface{}
-		if useConllU {
-			devi, _, e2 := conllu.ReadFile(input, limit)
-			if e2 != nil {
-				log.Fatalln(e2)
-			}
-			// const NUM_SENTS = 20
+		asGraphs = conllu.ConllU2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		asMorphGraphs = conllu.ConllU2MorphGraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+	} else {
+		devi, e2 := conll.ReadFile(opts.Input, opts.Limit)
+		if e2 != nil {
+			log.Fatalln(e2)
+		}
+		// const NUM_SENTS = 20
 
-			// s = s[:NUM_SENTS]
-			if allOut {
-				log.Println("Read", len(devi), "sentences from", input)
-				log.Println("Converting from conllu to internal format")
-			}
-			asGraphs = conllu.ConllU2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
-			asMorphGraphs = conllu.ConllU2MorphGraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-		} else {
-			devi, e2 := conll.ReadFile(input, limit)
-			if e2 != nil {
-				log.Fatalln(e2)
-			}
-			// const NUM_SENTS = 20
+		// s = s[:NUM_SENTS]
+		if allOut {
+			log.Println("Read", len(devi), "sentences from", opts.Input)
+			log.Println("Converting from conll to internal format")
+		}
+		asGraphs = conll.Conll2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	}
 
-			// s = s[:NUM_SENTS]
-			if allOut {
-				log.Println("Read", len(devi), "sentences from", input)
-				log.Println("Converting from conll to internal format")
-			}
-			asGraphs = conll.Conll2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	//check tagged returns morph
+	sents = make([]interface{}, len(asGraphs))
+	for i, instance := range asGraphs {
+		sents[i] = GetAsTaggedSentence(instance)
+	}
+	if allOut {
+		log.Println()
+
+		log.Println("Generating Gold Sequences For Training")
+		log.Println("Reading training sentences from", opts.Train)
+	}
+	var goldGraphs []interface{}
+	if opts.UseConllU {
+		s, _, e := conllu.ReadFile(opts.Train, opts.Limit)
+		if e != nil {
+			log.Println(e)
+			return nil, e
+		}
+		if allOut {
+			log.Println("Conll:\tRead", len(s), "sentences")
+			log.Println("Conll:\tConverting from conll to internal structure")
+		}
+		if PseudoProjective {
+			ProjectivizeConllU(s)
 		}
+		goldGraphs = conllu.ConllU2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		//goldMorphGraphs = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
 
-		//check tagged returns morph
-		sents = make([]interface{}, len(asGraphs))
-		for i, instance := range asGraphs {
-			sents[i] = GetAsTaggedSentence(instance)
+	} else {
+		s, e := conll.ReadFile(opts.Train, opts.Limit)
+		if e != nil {
+			log.Println(e)
+			return nil, e
 		}
 		if allOut {
-			log.Println()
+			log.Println("Conll:\tRead", len(s), "sentences")
+			log.Println("Conll:\tConverting from conll to internal structure")
+		}
+		if PseudoProjective {
+			ProjectivizeConll(s)
+		}
+		goldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	}
+	if allOut {
+		log.Println()
+
+		log.Println("Parsing with gold to get training sequences")
+	}
+	// goldGraphs = goldGraphs[:NUM_SENTS]
+	goldSequences := TrainingSequences(goldGraphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
+	if allOut {
+		log.Println("Generated", len(goldSequences), "training sequences")
+		log.Println()
+		log.Println("Training", opts.Iterations, "iteration(s)")
+	}
+	if opts.Neural {
+		if TrainWorkers > 1 || Learner != "perceptron" {
+			log.Fatalln("The neural model trains with a single worker and perceptron updates")
+		}
+		neural = NewDepNeural(group.FeatureTemplates)
+		classifier = neural
+	} else {
+		model = transitionmodel.NewAvgMatrixSparse(dep.FeatureSetup.NumFeatures(), formatters, true)
+		classifier = model
+	}
+	// model.Log = true
+
+	conf := &SimpleConfiguration{
+		EWord:         EWord,
+		EPOS:          EPOS,
+		EWPOS:         EWPOS,
+		EMHost:        EMHost,
+		EMSuffix:      EMSuffix,
+		ERel:          ERel,
+		ETrans:        ETrans,
+		Clusters:      dep.Clusters,
+		TerminalStack: dep.TerminalStack,
+		TerminalQueue: 0,
+	}
+
+	deterministic := &search.Deterministic{
+		TransFunc:          transitionSystem,
+		FeatExtractor:      extractor,
+		ReturnModelValue:   false,
+		ReturnSequence:     true,
+		ShowConsiderations: false,
+		Base:               conf,
+		NoRecover:          false,
+		DefaultTransType:   'A', // use Arc as default transition type
+	}
+
+	beam := &search.Beam{
+		TransFunc:            transitionSystem,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 opts.BeamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		Update:               BeamUpdateStrategy(),
+		EstimatedTransitions: EstimatedBeamTransitions(),
+		ScoredStoreDense:     true,
+	}
+
+	var (
+		decoder     perceptron.EarlyUpdateInstanceDecoder = beam
+		goldDecoder perceptron.InstanceDecoder            = deterministic
+		newDecoder  perceptron.DecoderFactory             = BeamDecoders(beam)
+	)
+	if opts.DynamicOracle {
+		arcEager, isEager := transitionSystem.(*ArcEager)
+		if !isEager {
+			log.Fatalln("Dynamic oracle training requires the eager arc system")
+		}
+		exploration := &search.ErrorExploration{
+			Deterministic:      deterministic,
+			Oracle:             arcEager.DynamicOracle(),
+			ExploreAfter:       opts.ExploreAfter,
+			ExploreProbability: opts.ExploreProbability,
+		}
+		decoder, goldDecoder = exploration, exploration
+		var workers int64
+		newDecoder = func() perceptron.EarlyUpdateInstanceDecoder {
+			workers++
+			workerDeterministic := &search.Deterministic{}
+			*workerDeterministic = *deterministic
+			workerExploration := &search.ErrorExploration{}
+			*workerExploration = *exploration
+			workerExploration.Deterministic = workerDeterministic
+			workerExploration.Oracle = arcEager.DynamicOracle()
+			workerExploration.Seed = workers
+			return workerExploration
+		}
+	}
 
-			log.Println("Generating Gold Sequences For Training")
-			log.Println("Reading training sentences from", tConll)
+	var evaluator perceptron.StopCondition
+
+	if len(opts.InputGold) > 0 {
+		if allOut {
+			log.Println("Setting convergence tester")
 		}
-		var goldGraphs []interface{}
-		if useConllU {
-			s, _, e := conllu.ReadFile(tConll, limit)
+		decodeTestBeam := &search.Beam{}
+		*decodeTestBeam = *beam
+		decodeTestBeam.Model = classifier
+		decodeTestBeam.DecodeTest = true
+		decodeTestBeam.ShortTempAgenda = true
+		var asGoldGraphs []interface{}
+		var asMorphGoldGraphs []interface{}
+		if opts.UseConllU {
+			s, _, e := conllu.ReadFile(opts.InputGold, opts.Limit)
 			if e != nil {
 				log.Println(e)
-				return e
+				return nil, e
 			}
 			if allOut {
 				log.Println("Conll:\tRead", len(s), "sentences")
 				log.Println("Conll:\tConverting from conll to internal structure")
 			}
-			goldGraphs = conllu.ConllU2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
-			//goldMorphGraphs = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-
+			asGoldGraphs = conllu.ConllU2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+			asMorphGoldGraphs = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
 		} else {
-			s, e := conll.ReadFile(tConll, limit)
+			s, e := conll.ReadFile(opts.InputGold, opts.Limit)
 			if e != nil {
 				log.Println(e)
-				return e
+				return nil, e
 			}
 			if allOut {
 				log.Println("Conll:\tRead", len(s), "sentences")
 				log.Println("Conll:\tConverting from conll to internal structure")
 			}
-			goldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+			asGoldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 		}
-		if allOut {
-			log.Println()
 
-			log.Println("Parsing with gold to get training sequences")
-		}
-		// goldGraphs = goldGraphs[:NUM_SENTS]
-		goldSequences := TrainingSequences(goldGraphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
-		if allOut {
-			log.Println("Generated", len(goldSequences), "training sequences")
-			log.Println()
-			log.Println("Training", Iterations, "iteration(s)")
+		goldSents := make([]interface{}, len(asGoldGraphs))
+		for i, instance := range asGraphs {
+			goldSents[i] = GetAsLabeledDepGraph(instance)
 		}
-		model = transitionmodel.NewAvgMatrixSparse(featureSetup.NumFeatures(), formatters, true)
-		// model.Log = true
-
-		conf := &SimpleConfiguration{
-			EWord:         EWord,
-			EPOS:          EPOS,
-			EWPOS:         EWPOS,
-			EMHost:        EMHost,
-			EMSuffix:      EMSuffix,
-			ERel:          ERel,
-			ETrans:        ETrans,
-			TerminalStack: terminalStack,
-			TerminalQueue: 0,
-		}
-
-		deterministic := &search.Deterministic{
-			TransFunc:          transitionSystem,
-			FeatExtractor:      extractor,
-			ReturnModelValue:   false,
-			ReturnSequence:     true,
-			ShowConsiderations: false,
-			Base:               conf,
-			NoRecover:          false,
-			DefaultTransType:   'A', // use Arc as default transition type
-		}
-
-		beam := &search.Beam{
-			TransFunc:            transitionSystem,
-			FeatExtractor:        extractor,
-			Base:                 conf,
-			Size:                 BeamSize,
-			ConcurrentExec:       ConcurrentBeam,
-			EstimatedTransitions: EstimatedBeamTransitions(),
-			ScoredStoreDense:     true,
-		}
-
-		var evaluator perceptron.StopCondition
-
-		if len(inputGold) > 0 {
+		var testAsGraphs []interface{}
+		var testAsMorphGraphs []interface{}
+		var testSents []interface{}
+		if len(opts.Test) > 0 {
 			if allOut {
-				log.Println("Setting convergence tester")
+				log.Println("Reading test file for per iteration parse")
 			}
-			decodeTestBeam := &search.Beam{}
-			*decodeTestBeam = *beam
-			decodeTestBeam.Model = model
-			decodeTestBeam.DecodeTest = true
-			decodeTestBeam.ShortTempAgenda = true
-			var asGoldGraphs []interface{}
-			var asMorphGoldGraphs []interface{}
-			if useConllU {
-				s, _, e := conllu.ReadFile(inputGold, limit)
-				if e != nil {
-					log.Println(e)
-					return e
+			if opts.UseConllU {
+				testi, _, e3 := conllu.ReadFile(opts.Test, opts.Limit)
+				if e3 != nil {
+					log.Fatalln(e3)
 				}
 				if allOut {
-					log.Println("Conll:\tRead", len(s), "sentences")
-					log.Println("Conll:\tConverting from conll to internal structure")
+					log.Println("Read", len(testi), "sentences from", opts.Test)
+					log.Println("Converting from conll to internal format")
 				}
-				asGoldGraphs = conllu.ConllU2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
-				asMorphGoldGraphs = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+				testAsGraphs = conllu.ConllU2GraphCorpus(testi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+				testAsMorphGraphs = conllu.ConllU2MorphGraphCorpus(testi, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
 			} else {
-				s, e := conll.ReadFile(inputGold, limit)
-				if e != nil {
-					log.Println(e)
-					return e
+				testi, e3 := conll.ReadFile(opts.Test, opts.Limit)
+				if e3 != nil {
+					log.Fatalln(e3)
 				}
 				if allOut {
-					log.Println("Conll:\tRead", len(s), "sentences")
-					log.Println("Conll:\tConverting from conll to internal structure")
+					log.Println("Read", len(testi), "sentences from", opts.Test)
+					log.Println("Converting from conll to internal format")
 				}
-				asGoldGraphs = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+				testAsGraphs = conll.Conll2GraphCorpus(testi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 			}
-
-			goldSents := make([]interface{}, len(asGoldGraphs))
-			for i, instance := range asGraphs {
-				goldSents[i] = GetAsLabeledDepGraph(instance)
-			}
-			var testAsGraphs []interface{}
-			var testAsMorphGraphs []interface{}
-			var testSents []interface{}
-			if len(test) > 0 {
-				if allOut {
-					log.Println("Reading test file for per iteration parse")
-				}
-				if useConllU {
-					testi, _, e3 := conllu.ReadFile(test, limit)
-					if e3 != nil {
-						log.Fatalln(e3)
-					}
-					if allOut {
-						log.Println("Read", len(testi), "sentences from", test)
-						log.Println("Converting from conll to internal format")
-					}
-					testAsGraphs = conllu.ConllU2GraphCorpus(testi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
-					testAsMorphGraphs = conllu.ConllU2MorphGraphCorpus(testi, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-				} else {
-					testi, e3 := conll.ReadFile(test, limit)
-					if e3 != nil {
-						log.Fatalln(e3)
-					}
-					if allOut {
-						log.Println("Read", len(testi), "sentences from", test)
-						log.Println("Converting from conll to internal format")
-					}
-					testAsGraphs = conll.Conll2GraphCorpus(testi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
-				}
-				testSents = make([]interface{}, len(testAsGraphs))
-				for i, instance := range testAsGraphs {
-					testSents[i] = GetAsTaggedSentence(instance)
-				}
+			testSents = make([]interface{}, len(testAsGraphs))
+			for i, instance := range testAsGraphs {
+				testSents[i] = GetAsTaggedSentence(instance)
 			}
-			evaluator = MakeDepEvalStopCondition(sents, goldSents, testSents, asMorphGraphs, asMorphGoldGraphs, testAsMorphGraphs, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
-		}
-		_ = Train(goldSequences, Iterations, DepModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
-		if allOut {
-			log.Println("Done Training")
-			log.Println()
-			log.Println("Writing model to", outModelFile)
-		}
-		serialization := &Serialization{
-			model.Serialize(-1),
-			EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
 		}
-		WriteModel(outModelFile, serialization)
-		if allOut {
-			log.Println("Done writing model")
-		}
-	} else {
-		if allOut && !parseOut {
-			log.Println("Found model file", outModelFile, " ... loading model")
-		}
-		serialization := ReadModel(outModelFile)
-		model.Deserialize(serialization.WeightModel)
-		EWord, EPOS, EWPOS, EMHost, EMSuffix = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix
-		if allOut && !parseOut {
-			log.Println("Loaded model")
-		}
-		// model.Log = true
+		evaluator = MakeDepEvalStopCondition(sents, goldSents, testSents, asMorphGraphs, asMorphGoldGraphs, testAsMorphGraphs, decodeTestBeam, perceptron.InstanceDecoder(deterministic), opts.BeamSize)
 	}
+	_ = Train(goldSequences, opts.Iterations, opts.ModelPrefix, classifier, decoder, goldDecoder, evaluator, newDecoder)
 	if allOut {
+		log.Println("Done Training")
 		log.Println()
+		log.Println("Writing model to", outModelFile)
+	}
+	serialization := &Serialization{
+		EWord: EWord, EPOS: EPOS, EWPOS: EWPOS, EMHost: EMHost, EMSuffix: EMSuffix,
+		EMorphProp: EMorphProp, ETrans: ETrans, ETokens: ETokens,
+		NeuralModel: neural,
+		Config:      NewModelConfig(),
 	}
+	if !opts.Neural {
+		serialization.WeightModel = model.Serialize(-1)
+	}
+	WriteModel(outModelFile, serialization)
+	if allOut {
+		log.Println("Done writing model")
+	}
+	return classifier, nil
+}
 
+// DepParse parses the input of dependency options with a trained classifier
+// and writes the parses to their output file
+func DepParse(dep *DepSetup, classifier transitionmodel.Interface) error {
+	var (
+		opts        = dep.Options
+		sents       []interface{}
+		sentsStream chan interface{}
+	)
 	// group, _ = extractor.TransTypeGroups[transition.ConstTransition(0).Type()]
 	// formatters = make([]util.Format, len(group.FeatureTemplates))
 	// for i, _ := range group.FeatureTemplates {
@@ -471,9 +676,9 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 	// model.Formatters = formatters
 	// sents = sents[:NUM_SENTS]
 	var asMorphGraphs, asGraphs []interface{}
-	if len(inputLat) > 0 {
-		if Stream {
-			lDisamb, lDisambE := lattice.StreamFile(inputLat, limit)
+	if len(opts.InputLat) > 0 {
+		if opts.Stream {
+			lDisamb, lDisambE := lattice.StreamFile(opts.InputLat, opts.Limit)
 			if lDisambE != nil {
 				log.Fatalln(lDisambE)
 			}
@@ -493,12 +698,12 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 				close(sentsStream)
 			}()
 		} else {
-			lDisamb, lDisambE := lattice.ReadFile(inputLat, limit)
+			lDisamb, lDisambE := lattice.ReadFile(opts.InputLat, opts.Limit)
 			if lDisambE != nil {
 				log.Fatalln(lDisambE)
 			}
 			if allOut {
-				log.Println("Read", len(lDisamb), "disambiguated lattices from", inputLat)
+				log.Println("Read", len(lDisamb), "disambiguated lattices from", opts.InputLat)
 				log.Println("Converting lattice format to TaggedSentence internal structure")
 				log.Println("\tlattice format to sentence")
 			}
@@ -512,8 +717,8 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 			}
 		}
 	} else {
-		if useConllU {
-			devi, _, e2 := conllu.ReadFile(input, limit)
+		if opts.UseConllU {
+			devi, _, e2 := conllu.ReadFile(opts.Input, opts.Limit)
 			if e2 != nil {
 				log.Fatalln(e2)
 			}
@@ -521,13 +726,13 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 
 			// s = s[:NUM_SENTS]
 			if allOut {
-				log.Println("Read", len(devi), "sentences from", input)
+				log.Println("Read", len(devi), "sentences from", opts.Input)
 				log.Println("Converting from conllu to internal format")
 			}
 			asGraphs = conllu.ConllU2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
 			asMorphGraphs = conllu.ConllU2MorphGraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
 		} else {
-			devi, e2 := conll.ReadFile(input, limit)
+			devi, e2 := conll.ReadFile(opts.Input, opts.Limit)
 			if e2 != nil {
 				log.Fatalln(e2)
 			}
@@ -535,7 +740,7 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 
 			// s = s[:NUM_SENTS]
 			if allOut {
-				log.Println("Read", len(devi), "sentences from", input)
+				log.Println("Read", len(devi), "sentences from", opts.Input)
 				log.Println("Converting from conll to internal format")
 			}
 			asGraphs = conll.Conll2GraphCorpus(devi, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
@@ -554,22 +759,24 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		EMSuffix:      EMSuffix,
 		ERel:          ERel,
 		ETrans:        ETrans,
-		TerminalStack: terminalStack,
+		Clusters:      dep.Clusters,
+		TerminalStack: dep.TerminalStack,
 		TerminalQueue: 0,
 	}
 
 	beam := &search.Beam{
-		TransFunc:            transitionSystem,
-		FeatExtractor:        extractor,
+		TransFunc:            dep.Trans,
+		FeatExtractor:        dep.Extractor,
 		Base:                 conf,
-		Model:                model,
-		Size:                 BeamSize,
+		Model:                classifier,
+		Size:                 opts.BeamSize,
 		ConcurrentExec:       ConcurrentBeam,
+		Lazy:                 LazyBeam,
 		ShortTempAgenda:      true,
 		EstimatedTransitions: EstimatedBeamTransitions(),
 		ScoredStoreDense:     true,
 	}
-	if Stream {
+	if opts.Stream {
 		parsedStream := make(chan interface{}, 2)
 		if allOut {
 			log.Println("Starting parser")
@@ -578,9 +785,9 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Streaming conversion to conll")
 		graphAsConllStream := conll.Graph2ConllStream(parsedStream, EMHost, EMSuffix)
 		if allOut {
-			log.Println("Creating writer stream to", outConll)
+			log.Println("Creating writer stream to", opts.OutConll)
 		}
-		conll.WriteStreamToFile(outConll, graphAsConllStream)
+		conll.WriteStreamToFile(opts.OutConll, DeprojectivizeStream(graphAsConllStream))
 		return nil
 	}
 	if allOut {
@@ -596,32 +803,34 @@ func DepTrainAndParse(cmd *commander.Command, args []string) error {
 		if !parseOut {
 			log.Println("Converting to conll")
 		}
-		if useConllU {
+		if opts.UseConllU {
 			graphAsConll := conllu.Graph2ConllUCorpus(parsedGraphs, EMHost, EMSuffix)
 			morphGraphs := conllu.MergeGraphAndMorphCorpus(graphAsConll, asMorphGraphs)
-			conllu.WriteFile(outConll, morphGraphs)
+			conllu.WriteFile(opts.OutConll, DeprojectivizeCorpus(morphGraphs))
 			if !parseOut {
-				log.Println("Wrote", len(parsedGraphs), "in conllu format to", outConll)
+				log.Println("Wrote", len(parsedGraphs), "in conllu format to", opts.OutConll)
 			}
 		} else {
 			graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
-			conll.WriteFile(outConll, graphAsConll)
+			conll.WriteFile(opts.OutConll, DeprojectivizeCorpus(graphAsConll))
 			if !parseOut {
-				log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
+				log.Println("Wrote", len(parsedGraphs), "in conll format to", opts.OutConll)
 			}
 		}
 	} else {
 		search.AllOut = true
 		// runtime.GOMAXPROCS(1)
-		model.Log = true
+		if model, isSparse := classifier.(*transitionmodel.AvgMatrixSparse); isSparse {
+			model.Log = true
+		}
 		search.AllOut = true
 		log.SetPrefix("")
 		log.SetFlags(0)
 		log.Print("Parsing started")
 		parsedGraphs := Parse(sents, beam)
 		graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, EMHost, EMSuffix)
-		conll.WriteFile(outConll, graphAsConll)
-		log.Println("Wrote", len(parsedGraphs), "in conll format to", outConll)
+		conll.WriteFile(opts.OutConll, DeprojectivizeCorpus(graphAsConll))
+		log.Println("Wrote", len(parsedGraphs), "in conll format to", opts.OutConll)
 	}
 	return nil
 }
@@ -640,11 +849,28 @@ runs dependency training/parsing
 		Flag: *flag.NewFlagSet("dep", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.BoolVar(&LazyBeam, "lazy", false, "Optional - Lazy beam expansion: score only the legal transitions of a candidate and build those that can make the beam (parsing)")
+	cmd.Flag.StringVar(&BeamUpdate, "update", "early", "Optional - Beam training update strategy ["+search.UpdateStrategies+"]")
+	cmd.Flag.IntVar(&TrainWorkers, "workers", 1, "Optional - Number of parallel training workers (iterative parameter mixing)")
+	cmd.Flag.BoolVar(&DepNeural, "neural", false, "Optional - Train a feed forward network over feature embeddings instead of the sparse perceptron model (dep only, md and joint always train the perceptron)")
+	cmd.Flag.StringVar(&DepNeuralEmbeddings, "nnemb", "", "Optional - Pretrained word embeddings of the neural model (word2vec/GloVe text format)")
+	cmd.Flag.IntVar(&DepNeuralDim, "nndim", 50, "Optional - Embedding size of the neural model (set by pretrained embeddings)")
+	cmd.Flag.IntVar(&DepNeuralHidden, "nnhidden", 200, "Optional - Hidden layer size of the neural model")
+	cmd.Flag.Float64Var(&DepNeuralRate, "nnrate", 0.01, "Optional - Learning rate of the neural model")
+	cmd.Flag.StringVar(&Learner, "learner", "perceptron", "Optional - Training update strategy ["+Learners+"]")
+	cmd.Flag.Float64Var(&LearnerC, "mirac", 1.0, "Optional - Aggressiveness (maximal step) of mira updates")
+	cmd.Flag.StringVar(&ClustersFile, "clusters", "", "Optional - Brown word clusters (paths format) of the c<bits> feature attributes")
+	cmd.Flag.StringVar(&EmbeddingClustersFile, "embclusters", "", "Optional - Word embeddings (word2vec/GloVe text format) clustered for the e feature attribute")
+	cmd.Flag.IntVar(&EmbeddingClusters, "embk", 100, "Optional - Number of embedding clusters")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Dependency Beam Size")
 	cmd.Flag.StringVar(&DepModelFile, "m", "model", "Prefix for model file ({m}.b{b}.i{it}.model)")
 	cmd.Flag.StringVar(&DepModelName, "mn", "dep.b64", "Modelfile")
-	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
+	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System ["+ArcSystems+"]")
+	cmd.Flag.BoolVar(&PseudoProjective, "pp", false, "Optional - Pseudo-projective parsing: lift non-projective training arcs and reattach them in the output")
+	cmd.Flag.BoolVar(&DepDynamicOracle, "dyn", false, "Optional - Train greedily with the arc eager dynamic oracle and error exploration")
+	cmd.Flag.IntVar(&DepExploreAfter, "dynk", 0, "Optional - With -dyn, follow wrong predictions only after this many training instances")
+	cmd.Flag.Float64Var(&DepExploreProbability, "dynp", 0.9, "Optional - With -dyn, probability of following a wrong prediction")
 
 	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
 	cmd.Flag.StringVar(&input, "in", "", "Dev Tagged Sentences File")
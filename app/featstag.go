@@ -0,0 +1,253 @@
+package app
+
+import (
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"log"
+)
+
+var (
+	TagFeats         bool
+	FeatsTaggerFile  string = "feats.tagger.yaml"
+	FeatsTaggerIters int    = 4
+	FeatsTaggerBeam  int    = 4
+
+	// feature tagger of the MD/joint model
+	FeatsTagger *FeatsTaggerModel
+
+	featsTaggerBeam *search.Beam
+)
+
+// FeatsTaggerModel is a serialized post-MD morphological feature tagger
+type FeatsTaggerModel struct {
+	WeightModel *transitionmodel.AvgMatrixSparseSerialized
+	Transitions *util.EnumSet
+	Bundles     map[string][]string
+}
+
+func featsTaggerExtractor() *transition.GenericExtractor {
+	featuresLocation, found := util.LocateFile(FeatsTaggerFile, DEFAULT_CONF_DIRS)
+	if found {
+		FeatsTaggerFile = featuresLocation
+	}
+	featureSetup, err := transition.LoadFeatureConfFile(FeatsTaggerFile)
+	if err != nil {
+		log.Fatalln("Failed reading feature tagger features configuration file:", FeatsTaggerFile, err)
+	}
+	extractor := &transition.GenericExtractor{
+		EFeatures: util.NewEnumSet(featureSetup.NumFeatures()),
+	}
+	extractor.InitTypes([]byte("F"))
+	extractor.LoadFeatureSetup(featureSetup)
+	return extractor
+}
+
+func featsTaggerDecoders(trans *disambig.FeatsTrans, extractor *transition.GenericExtractor) (*search.Beam, *search.Deterministic) {
+	conf := &disambig.FeatsConfig{Transitions: trans.Transitions}
+	beam := &search.Beam{
+		TransFunc:            trans,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 FeatsTaggerBeam,
+		Transitions:          trans.Transitions,
+		EstimatedTransitions: 100,
+	}
+	deterministic := &search.Deterministic{
+		TransFunc:        trans,
+		FeatExtractor:    extractor,
+		ReturnSequence:   true,
+		Base:             conf,
+		DefaultTransType: 'F',
+	}
+	return beam, deterministic
+}
+
+// readFeatsTaggerGold reads disambiguated lattices for training the feature
+// tagger, NNP features are kept even if stripped for MD (-stripnnpfeats)
+func readFeatsTaggerGold(filename string) ([]interface{}, error) {
+	defer func(ignoreNNPFeats bool) {
+		lattice.IGNORE_NNP_FEATS = ignoreNNPFeats
+	}(lattice.IGNORE_NNP_FEATS)
+	lattice.IGNORE_NNP_FEATS = false
+	lDis, err := lattice.ReadFile(filename, limit)
+	if err != nil {
+		return nil, err
+	}
+	return lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix), nil
+}
+
+// featsTaggerInstances returns the tagger inputs and gold bundles of
+// disambiguated lattice sentences
+func featsTaggerInstances(goldDisLat []interface{}) []perceptron.DecodedInstance {
+	instances := make([]perceptron.DecodedInstance, 0, len(goldDisLat))
+	for _, sent := range goldDisLat {
+		latSent, ok := sent.(nlp.LatticeSentence)
+		if !ok {
+			continue
+		}
+		featsSent, gold := disambig.NewFeatsTrainingSentence(latSent)
+		if len(gold) == 0 {
+			continue
+		}
+		instances = append(instances, &perceptron.Decoded{InstanceVal: featsSent, DecodedVal: gold})
+	}
+	return instances
+}
+
+// MakeFeatsEvalStopCondition stops training the feature tagger after the
+// minimal iterations once its bundle accuracy on the dev instances drops or
+// stays the same, as MakeMorphEvalStopCondition does for MD
+func MakeFeatsEvalStopCondition(instances []perceptron.DecodedInstance, parser Parser) perceptron.StopCondition {
+	var (
+		equalIterations int
+		prevResult      float64
+	)
+	return func(curIteration, iterations, generations int, model perceptron.Model) bool {
+		// Don't test before initial run
+		if curIteration == 0 {
+			return true
+		}
+		if beam, isBeam := parser.(*search.Beam); isBeam {
+			beam.IntegrationGeneration = generations
+		}
+		var correct, total int
+		for _, instance := range instances {
+			gold := instance.Decoded().(disambig.FeatsBundles)
+			total += len(gold)
+			result, _ := parser.Parse(instance.Instance())
+			tagged, ok := result.(*disambig.FeatsConfig)
+			if !ok {
+				continue
+			}
+			for i, bundle := range gold {
+				if i < len(tagged.Feats) && tagged.Feats[i] == bundle {
+					correct++
+				}
+			}
+		}
+		curResult := ratio(correct, total)
+		if curResult == prevResult {
+			equalIterations += 1
+		}
+		retval := (curIteration >= iterations) && (curResult < prevResult || equalIterations > 2)
+		if FixedIterations {
+			retval = curIteration >= iterations
+		}
+		log.Println("Feature tagger dev accuracy:", curResult, "Correct:", correct, "in", total)
+		if retval {
+			log.Println("Stopping")
+		} else {
+			log.Println("Continuing")
+		}
+		prevResult = curResult
+		return !retval
+	}
+}
+
+// TrainFeatsTagger sets FeatsTagger to a feature tagger trained on the gold
+// disambiguated lattices. If NNP features were stripped the lattices are
+// read again from goldFile (if set) with their features. If devGoldFile is
+// set training continues past FeatsTaggerIters while the accuracy on its
+// lattices improves
+func TrainFeatsTagger(goldDisLat []interface{}, goldFile, devGoldFile string) error {
+	if lattice.IGNORE_NNP_FEATS && len(goldFile) > 0 {
+		var err error
+		if goldDisLat, err = readFeatsTaggerGold(goldFile); err != nil {
+			return err
+		}
+	}
+	log.Println("Training feature tagger with", FeatsTaggerFile)
+	extractor := featsTaggerExtractor()
+	trans := &disambig.FeatsTrans{Transitions: util.NewEnumSet(APPROX_MORPH_TRANSITIONS)}
+	trans.AddDefaultOracle()
+
+	instances := featsTaggerInstances(goldDisLat)
+	for _, instance := range instances {
+		trans.AddBundles(instance.Instance().(*disambig.FeatsSentence), instance.Decoded().(disambig.FeatsBundles))
+	}
+
+	group, _ := extractor.TransTypeGroups['F']
+	formatters := make([]util.Format, len(group.FeatureTemplates))
+	for i, formatter := range group.FeatureTemplates {
+		formatters[i] = formatter
+	}
+	model := transitionmodel.NewAvgMatrixSparse(len(group.FeatureTemplates), formatters, false)
+	beam, deterministic := featsTaggerDecoders(trans, extractor)
+
+	var evaluator perceptron.StopCondition
+	if len(devGoldFile) > 0 {
+		devDisLat, err := readFeatsTaggerGold(devGoldFile)
+		if err != nil {
+			return err
+		}
+		decodeTestBeam := &search.Beam{}
+		*decodeTestBeam = *beam
+		decodeTestBeam.Model = model
+		decodeTestBeam.DecodeTest = true
+		decodeTestBeam.ShortTempAgenda = true
+		evaluator = MakeFeatsEvalStopCondition(featsTaggerInstances(devDisLat), decodeTestBeam)
+	}
+	_ = Train(instances, FeatsTaggerIters, "", model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator, nil)
+
+	FeatsTagger = &FeatsTaggerModel{
+		WeightModel: model.Serialize(-1),
+		Transitions: trans.Transitions,
+		Bundles:     trans.Bundles,
+	}
+	log.Println("Trained feature tagger on", len(instances), "sentences,", trans.Transitions.Len(), "bundles")
+	return nil
+}
+
+// SetupFeatsTagger prepares the feature tagger of a loaded model for
+// parsing, tagging is disabled if the model has no tagger
+func SetupFeatsTagger() {
+	if !TagFeats {
+		return
+	}
+	if FeatsTagger == nil {
+		log.Println("Model has no feature tagger (train with -tagfeats), not tagging features")
+		TagFeats = false
+		return
+	}
+	extractor := featsTaggerExtractor()
+	trans := &disambig.FeatsTrans{Transitions: FeatsTagger.Transitions, Bundles: FeatsTagger.Bundles}
+	model := &transitionmodel.AvgMatrixSparse{}
+	model.Deserialize(FeatsTagger.WeightModel)
+	featsTaggerBeam, _ = featsTaggerDecoders(trans, extractor)
+	featsTaggerBeam.Model = model
+	featsTaggerBeam.ShortTempAgenda = true
+}
+
+// TagFeatsInstance tags the features of the underspecified morphemes of a
+// parsed MD or joint configuration if TagFeats is set, other configurations
+// are left as is
+func TagFeatsInstance(instance interface{}) {
+	if !TagFeats || featsTaggerBeam == nil {
+		return
+	}
+	var conf *disambig.MDConfig
+	switch c := instance.(type) {
+	case *disambig.MDConfig:
+		conf = c
+	case *joint.JointConfig:
+		conf = &c.MDConfig
+	default:
+		return
+	}
+	sent := disambig.NewFeatsSentence(conf, FeatsTagger.Bundles)
+	if !sent.Underspecified() {
+		return
+	}
+	result, _ := featsTaggerBeam.Parse(sent)
+	if tagged, ok := result.(*disambig.FeatsConfig); ok {
+		conf.ReplaceMorphemes(sent.SetFeats(tagged.Feats, EMorphProp))
+	}
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"testing"
+
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/parser/disambig"
+)
+
+// featsTestParser tags every sentence with the bundles of its next result
+type featsTestParser struct {
+	results [][]string
+}
+
+func (p *featsTestParser) Parse(problem search.Problem) (transition.Configuration, interface{}) {
+	feats := p.results[0]
+	p.results = p.results[1:]
+	return &disambig.FeatsConfig{Feats: feats}, nil
+}
+
+func TestFeatsEvalStopCondition(t *testing.T) {
+	instances := []perceptron.DecodedInstance{
+		&perceptron.Decoded{InstanceVal: &disambig.FeatsSentence{}, DecodedVal: disambig.FeatsBundles{"gen=F", "num=S"}},
+	}
+	parser := &featsTestParser{results: [][]string{
+		{"gen=M", "num=S"},
+		{"gen=F", "num=S"},
+		{"gen=F", "num=P"},
+	}}
+	stop := MakeFeatsEvalStopCondition(instances, parser)
+	if !stop(0, 1, 0, nil) {
+		t.Errorf("Expected to continue before the first iteration")
+	}
+	if !stop(1, 1, 0, nil) || !stop(2, 1, 0, nil) {
+		t.Errorf("Expected to continue while the accuracy improves")
+	}
+	if stop(3, 1, 0, nil) {
+		t.Errorf("Expected to stop when the accuracy drops")
+	}
+}
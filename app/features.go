@@ -0,0 +1,375 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/alg/transition"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	dep "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/disambig"
+	"yap/nlp/parser/joint"
+	nlp "yap/nlp/types"
+	"yap/util"
+	"yap/util/conf"
+
+	"fmt"
+	"log"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	featuresSystem, featuresFile   string
+	featuresConll                  string
+	featuresLatDis, featuresLatAmb string
+	featuresSamples                int
+)
+
+// FeaturesSystems are the transition systems a feature file is checked against
+const FeaturesSystems = "dep, md, joint, feats"
+
+// featuresValidator returns the configuration validating the features of a
+// transition system, and its transition types
+func featuresValidator(system string) (transition.FeatureValidator, []byte, error) {
+	switch system {
+	case "dep":
+		return &dep.SimpleConfiguration{}, []byte("A"), nil
+	case "md":
+		return &disambig.MDConfig{}, []byte("MPL"), nil
+	case "joint":
+		return &joint.JointConfig{}, []byte("MPLA"), nil
+	case "feats":
+		return &disambig.FeatsConfig{}, []byte("F"), nil
+	}
+	return nil, nil, fmt.Errorf("Unknown transition system %s, expected one of: %s", system, FeaturesSystems)
+}
+
+// CheckFeatures validates a feature file against a transition system and
+// logs the number of templates of each group; it returns an error if any
+// template is invalid
+func CheckFeatures(filename, system string) (*transition.FeatureSetup, error) {
+	setup, errs, err := FeatureErrors(filename, system)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Feature file %s has %d error(s)", filename, len(errs))
+	}
+	return setup, nil
+}
+
+// FeatureErrors validates a feature file against a transition system, logs
+// the number of templates of each group and returns the invalid templates;
+// err is set if the file can't be checked at all
+func FeatureErrors(filename, system string) (setup *transition.FeatureSetup, errs []error, err error) {
+	validator, transTypes, err := featuresValidator(system)
+	if err != nil {
+		return nil, nil, err
+	}
+	setup, err = transition.LoadFeatureConfFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed reading feature configuration file %s: %v", filename, err)
+	}
+	if len(setup.FeatureGroups) == 0 {
+		return nil, nil, fmt.Errorf("Feature file %s has no feature groups", filename)
+	}
+	extractor := &transition.GenericExtractor{}
+	extractor.InitTypes(transTypes)
+	checks, errs := extractor.CheckFeatureSetup(setup, validator)
+
+	var numFeatures, numMorphFeatures int
+	log.Printf("Feature file:\t%s (%s)", filename, system)
+	for _, err := range errs {
+		log.Printf("\tError: %v", err)
+	}
+	for _, check := range checks {
+		transitionType := check.Transition
+		if len(transitionType) == 0 {
+			transitionType = "default"
+		}
+		if check.MorphFeatures > 0 {
+			log.Printf("\t%s (%s):\t%d templates, %d with morph templates", check.Group, transitionType, check.Features, check.MorphFeatures)
+		} else {
+			log.Printf("\t%s (%s):\t%d templates", check.Group, transitionType, check.Features)
+		}
+		for _, err := range check.Errors {
+			log.Printf("\t\tError: %v", err)
+		}
+		numFeatures += check.Features
+		numMorphFeatures += check.MorphFeatures
+		errs = append(errs, check.Errors...)
+	}
+	log.Printf("Groups:\t\t%d", len(checks))
+	log.Printf("Templates:\t%d (%d with morph templates)", numFeatures+numMorphFeatures, numMorphFeatures)
+	return setup, errs, nil
+}
+
+// featuresFormat renders a feature value, falling back to its default
+// format for values a template can't render
+func featuresFormat(template transition.FeatureTemplate, value interface{}) (formatted string) {
+	defer func() {
+		if r := recover(); r != nil {
+			formatted = fmt.Sprintf("%v", value)
+		}
+	}()
+	return template.Format(value)
+}
+
+// SampleFeatures runs the oracle of a transition system on gold instances
+// and logs the features extracted at each configuration
+func SampleFeatures(extractor *transition.GenericExtractor, deterministic *search.Deterministic, instances []interface{}, instFunc InstanceFunc, goldFunc GoldFunc) {
+	for i, decoded := range TrainingSequences(instances, instFunc, goldFunc) {
+		log.Println()
+		log.Println("Sentence", i+1)
+		_, result := deterministic.ParseOracle(decoded)
+		if result == nil {
+			log.Println("\tOracle failed")
+			continue
+		}
+		seq := result.(*search.ParseResultParameters).Sequence
+		// the sequence is last configuration first
+		for j := len(seq) - 1; j > 0; j-- {
+			configuration, next := seq[j], seq[j-1].GetLastTransition()
+			group, exists := extractor.TransTypeGroups[next.Type()]
+			if !exists {
+				continue
+			}
+			log.Printf("\t%v", configuration)
+			log.Printf("\tTransition %v (%c)", ETrans.ValueOf(next.Value()), next.Type())
+			features := extractor.Features(configuration, false, next.Type(), []int{next.Value()})
+			for k, feature := range features {
+				if feature == nil {
+					continue
+				}
+				template := group.FeatureTemplates[k]
+				log.Printf("\t\t%v\t%s", extractor.EFeatures.ValueOf(template.ID), featuresFormat(template, feature))
+			}
+		}
+	}
+}
+
+func FeaturesCheck(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"f"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	if location, found := util.LocateFile(featuresFile, DEFAULT_CONF_DIRS); found {
+		featuresFile = location
+	}
+	setup, err := CheckFeatures(featuresFile, featuresSystem)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case featuresSystem == "dep" && len(featuresConll) > 0:
+		relations, err := featuresRelations()
+		if err != nil {
+			return err
+		}
+		SetupDepEnum(relations)
+		arcSystem, terminalStack, err := NewArcSystem(DepArcSystemStr)
+		if err != nil {
+			return err
+		}
+		arcSystem.AddDefaultOracle()
+		extractor := SetupExtractor(setup, []byte("A"))
+		sents, err := conll.ReadFile(featuresConll, featuresSamples)
+		if err != nil {
+			return err
+		}
+		deterministic := &search.Deterministic{
+			TransFunc:      arcSystem,
+			FeatExtractor:  extractor,
+			ReturnSequence: true,
+			Base: &dep.SimpleConfiguration{
+				EWord:         EWord,
+				EPOS:          EPOS,
+				EWPOS:         EWPOS,
+				EMHost:        EMHost,
+				EMSuffix:      EMSuffix,
+				ERel:          ERel,
+				ETrans:        ETrans,
+				TerminalStack: terminalStack,
+				TerminalQueue: 0,
+			},
+			DefaultTransType: 'A',
+		}
+		graphs := conll.Conll2GraphCorpus(sents, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		SampleFeatures(extractor, deterministic, graphs, GetAsTaggedSentence, GetAsLabeledDepGraph)
+	case featuresSystem == "md" && len(featuresLatDis) > 0 && len(featuresLatAmb) > 0:
+		paramFunc, exists := nlp.MDParams[MdParamFuncName]
+		if !exists {
+			return fmt.Errorf("Param Func %s does not exist", MdParamFuncName)
+		}
+		SetupMDEnum()
+		disambig.UsePOP = UsePOP
+		mdTrans := NewMDTransitionSystem(paramFunc)
+		SetMDTransitions(mdTrans)
+		mdTrans.AddDefaultOracle()
+		extractor := SetupExtractor(setup, []byte("MPL"))
+		nlp.InitOpenParamFamily("HEBTB")
+		lDis, err := lattice.ReadFile(featuresLatDis, featuresSamples)
+		if err != nil {
+			return err
+		}
+		lAmb, err := lattice.ReadFile(featuresLatAmb, featuresSamples)
+		if err != nil {
+			return err
+		}
+		goldDisLat := lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		goldAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		combined, _, _, _ := CombineLatticesCorpus(goldDisLat, goldAmbLat)
+		deterministic := &search.Deterministic{
+			TransFunc:      mdTrans,
+			FeatExtractor:  extractor,
+			ReturnSequence: true,
+			Base: &disambig.MDConfig{
+				ETokens:     ETokens,
+				POP:         POP,
+				Transitions: ETrans,
+				ParamFunc:   paramFunc,
+			},
+			DefaultTransType: 'M',
+		}
+		SampleFeatures(extractor, deterministic, combined, GetMDConfigAsLattices, GetMDConfigAsMappings)
+	case featuresSystem == "joint" && len(featuresConll) > 0 && len(featuresLatDis) > 0 && len(featuresLatAmb) > 0:
+		paramFunc, exists := nlp.MDParams[MdParamFuncName]
+		if !exists {
+			return fmt.Errorf("Param Func %s does not exist", MdParamFuncName)
+		}
+		if _, err := joint.GetJointStrategy(JointStrategy); err != nil {
+			return err
+		}
+		if _, err := joint.GetOracleStrategy(OracleStrategy); err != nil {
+			return err
+		}
+		relations, err := featuresRelations()
+		if err != nil {
+			return err
+		}
+		SetupEnum(relations)
+		arcSystem, terminalStack, err := NewArcSystem(DepArcSystemStr)
+		if err != nil {
+			return err
+		}
+		arcSystem.AddDefaultOracle()
+		disambig.UsePOP = UsePOP
+		mdTrans := NewMDTransitionSystem(paramFunc)
+		SetMDTransitions(mdTrans)
+		mdTrans.AddDefaultOracle()
+		jointTrans := &joint.JointTrans{
+			MDTrans:       mdTrans,
+			ArcSys:        arcSystem,
+			Transitions:   ETrans,
+			MDTransition:  MD,
+			JointStrategy: JointStrategy,
+		}
+		jointTrans.AddDefaultOracle()
+		jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = OracleStrategy
+		extractor := SetupExtractor(setup, []byte("MPLA"))
+		nlp.InitOpenParamFamily("HEBTB")
+		sents, err := conll.ReadFile(featuresConll, featuresSamples)
+		if err != nil {
+			return err
+		}
+		lDis, err := lattice.ReadFile(featuresLatDis, featuresSamples)
+		if err != nil {
+			return err
+		}
+		lAmb, err := lattice.ReadFile(featuresLatAmb, featuresSamples)
+		if err != nil {
+			return err
+		}
+		goldConll := conll.Conll2GraphCorpus(sents, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+		goldDisLat := lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		goldAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		combined, _ := CombineJointCorpus(goldConll, goldDisLat, goldAmbLat)
+		deterministic := &search.Deterministic{
+			TransFunc:      jointTrans,
+			FeatExtractor:  extractor,
+			ReturnSequence: true,
+			Base: &joint.JointConfig{
+				SimpleConfiguration: dep.SimpleConfiguration{
+					EWord:         EWord,
+					EPOS:          EPOS,
+					EWPOS:         EWPOS,
+					EMHost:        EMHost,
+					EMSuffix:      EMSuffix,
+					ERel:          ERel,
+					ETrans:        ETrans,
+					TerminalStack: terminalStack,
+					TerminalQueue: 0,
+				},
+				MDConfig: disambig.MDConfig{
+					ETokens:     ETokens,
+					POP:         POP,
+					Transitions: ETrans,
+					ParamFunc:   paramFunc,
+				},
+				MDTrans: MD,
+			},
+			DefaultTransType: 'M',
+		}
+		SampleFeatures(extractor, deterministic, combined, GetMorphGraphAsLattices, GetMorphGraph)
+	case len(featuresConll) > 0 || len(featuresLatDis) > 0 || len(featuresLatAmb) > 0:
+		return fmt.Errorf("Sample sentences are given with -tc for dep, -td and -tl for md, or all three for joint")
+	}
+	return nil
+}
+
+// featuresRelations reads the dependency labels of the sample sentences
+func featuresRelations() ([]string, error) {
+	if location, found := util.LocateFile(DepLabelsFile, DEFAULT_CONF_DIRS); found {
+		DepLabelsFile = location
+	}
+	relations, err := conf.ReadFile(DepLabelsFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading dependency labels configuration file %s: %v", DepLabelsFile, err)
+	}
+	return relations.Values, nil
+}
+
+func FeaturesCheckCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       FeaturesCheck,
+		UsageLine: "check <file options> [arguments]",
+		Short:     "validate a feature file and show the features of sample sentences",
+		Long: `
+validate a feature file and show the features of sample sentences
+
+	$ ./yap features check -t dep|md|joint|feats -f <features> [-tc <conll>] [-td <disamb. lat> -tl <amb. lat>] [options]
+
+Validates every address, attribute and requirement of the feature templates
+against the transition system, and reports the number of templates of each
+group and morph template. With sample sentences (a gold conll file for dep,
+disambiguated and ambiguous lattices for md, all three for joint), runs the
+oracle on the first -n sentences and shows the rendered features of every
+configuration.
+`,
+		Flag: *flag.NewFlagSet("check", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&featuresSystem, "t", "dep", "Transition system ["+FeaturesSystems+"]")
+	cmd.Flag.StringVar(&featuresFile, "f", "", "Features Configuration File")
+	cmd.Flag.StringVar(&featuresConll, "tc", "", "Optional - Sample Gold Conll File (dep, joint)")
+	cmd.Flag.StringVar(&featuresLatDis, "td", "", "Optional - Sample Disambiguated Lattices File (md, joint)")
+	cmd.Flag.StringVar(&featuresLatAmb, "tl", "", "Optional - Sample Ambiguous Lattices File (md, joint)")
+	cmd.Flag.IntVar(&featuresSamples, "n", 2, "Number of sample sentences")
+	cmd.Flag.StringVar(&DepLabelsFile, "l", "hebtb.labels.conf", "Dependency Labels Configuration File (dep, joint)")
+	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System ["+ArcSystems+"] (dep, joint)")
+	cmd.Flag.StringVar(&MdParamFuncName, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"] (md, joint)")
+	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD (md, joint)")
+	cmd.Flag.StringVar(&JointStrategy, "jointstr", "ArcGreedy", "Joint Strategy: ["+joint.JointStrategies+"] (joint)")
+	cmd.Flag.StringVar(&OracleStrategy, "oraclestr", "ArcGreedy", "Oracle Strategy: ["+joint.OracleStrategies+"] (joint)")
+	return cmd
+}
+
+func FeaturesCmd() *commander.Command {
+	return &commander.Command{
+		UsageLine: "features <command>",
+		Short:     "feature file tools",
+		Subcommands: []*commander.Command{
+			FeaturesCheckCmd(),
+		},
+		Flag: *flag.NewFlagSet("features", flag.ExitOnError),
+	}
+}
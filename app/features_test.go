@@ -0,0 +1,124 @@
+package app
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"yap/alg/transition"
+)
+
+// shippedFeatures are the systems of the feature files in conf, and the
+// errors they are known to have: the L0|l and L-1|h templates never have a
+// value, but removing them would renumber the templates of trained models
+var shippedFeatures = map[string]struct {
+	system string
+	errors []string
+}{
+	"chenmanning2014.yaml":             {system: "dep"},
+	"rich.linguistic.arcstandard.yaml": {system: "dep"},
+	"richling.yaml":                    {system: "dep"},
+	"zhangnivre2011.yaml":              {system: "dep"},
+	"standalone.md.yaml":               {"md", latticeLabelErrors},
+	"standalone.wbmd.yaml":             {system: "md"},
+	"taf.md.yaml":                      {system: "md"},
+	"joint.yaml":                       {system: "joint"},
+	"jointstandard.yaml":               {"joint", latticeLabelErrors},
+	"jointzeager.yaml":                 {"joint", latticeLabelErrors},
+	"feats.tagger.yaml":                {system: "feats"},
+}
+
+var latticeLabelErrors = []string{
+	"L0|l: Unknown attribute l of L",
+	"L0|l|t: Unknown attribute l of L",
+	"L-1|h: Unknown attribute h of L",
+	"L0|l+L1|t: Unknown attribute l of L",
+}
+
+// notFeatures are the other yaml files in conf
+var notFeatures = map[string]bool{"heb2ud.yaml": true}
+
+func TestShippedFeatures(t *testing.T) {
+	files, err := filepath.Glob("../conf/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		if notFeatures[name] {
+			continue
+		}
+		shipped, exists := shippedFeatures[name]
+		if !exists {
+			t.Errorf("Feature file %s has no system to check it against", name)
+			continue
+		}
+		validator, transTypes, err := featuresValidator(shipped.system)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setup, err := transition.LoadFeatureConfFile(file)
+		if err != nil {
+			t.Errorf("Failed reading %s: %v", name, err)
+			continue
+		}
+		extractor := &transition.GenericExtractor{}
+		extractor.InitTypes(transTypes)
+		checks, errs := extractor.CheckFeatureSetup(setup, validator)
+		var messages []string
+		for _, check := range checks {
+			errs = append(errs, check.Errors...)
+		}
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		if !reflect.DeepEqual(messages, shipped.errors) {
+			t.Errorf("Expected %s (%s) errors %q, got %q", name, shipped.system, shipped.errors, messages)
+		}
+	}
+}
+
+func TestFeaturesValidatorAttributes(t *testing.T) {
+	tests := []struct {
+		system    string
+		source    byte
+		attribute string
+		valid     bool
+	}{
+		{"dep", 'S', "w", true},
+		{"dep", 'N', "wp", true},
+		{"dep", 'S', "l", true},
+		{"dep", 'S', "sl", true},
+		{"dep", 'S', "vf", true},
+		{"dep", 'S', "d", true},
+		{"dep", 'N', "c4", true},
+		{"dep", 'N', "e", true},
+		{"dep", 'S', "vx", false},
+		{"dep", 'S', "fx", false},
+		{"dep", 'N', "q", false},
+		{"md", 'M', "mp", true},
+		{"md", 'M', "c", true},
+		{"md", 'L', "cmp2", true},
+		{"md", 'L', "cq", true},
+		{"md", 'L', "n", true},
+		{"md", 'L', "i", true},
+		{"md", 'L', "c", false},
+		{"md", 'L', "cz", false},
+		{"md", 'L', "l", false},
+		{"joint", 'S', "l", true},
+		{"joint", 'L', "h", false},
+		{"feats", 'M', "fgen", true},
+		{"feats", 'N', "f", false},
+		{"feats", 'N', "s", true},
+	}
+	for _, test := range tests {
+		validator, _, err := featuresValidator(test.system)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = validator.ValidateAttribute(test.source, []byte(test.attribute))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: Expected %c|%s valid %v, got error %v", test.system, test.source, test.attribute, test.valid, err)
+		}
+	}
+}
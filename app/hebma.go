@@ -25,6 +25,8 @@ var (
 	HebMaXliter8out, HebMaAlwaysnnp   bool
 	HebMaNnpnofeats              bool
 	HebMaShowoov                 bool
+	Heb2UDConvFile               string
+	HebMaCacheSize               int
 	outJSON                 bool
 	HEB_MA_DEFAULT_DATA_DIRS       = []string{".", "data/bgulex"}
 )
@@ -35,6 +37,10 @@ func HebMAConfigOut() {
 	log.Printf("Heb Prefix:\t\t%s", HebMaLexiconFile)
 	log.Printf("OOV Strategy:\t%v", "Const:NNP")
 	log.Printf("xliter8 out:\t\t%v", HebMaXliter8out)
+	log.Printf("Lattice cache:\t%v", HebMaCacheSize)
+	if len(Heb2UDConvFile) > 0 {
+		log.Printf("UD Conversion:\t%s", Heb2UDConvFile)
+	}
 	log.Println()
 	if useConllU {
 		if len(conlluFile) > 0 {
@@ -51,6 +57,50 @@ func HebMAConfigOut() {
 	log.Println()
 }
 
+// LoadHeb2UDConversion replaces the built-in Hebrew to UD conversion table
+// with the one in filename, if given
+func LoadHeb2UDConversion(filename string) {
+	if len(filename) == 0 {
+		return
+	}
+	if location, found := util.LocateFile(filename, DEFAULT_CONF_DIRS); found {
+		filename = location
+	}
+	conv, err := util.LoadHeb2UDConversionFile(filename)
+	if err != nil {
+		log.Fatalln("Failed loading UD conversion table", filename, "-", err)
+	}
+	log.Println("Loaded UD conversion table", filename)
+	util.HEB2UD = conv
+}
+
+// LoadHebMA loads the BGU prefixes and lexicon for the given output format
+func LoadHebMA(format string) *ma.BGULex {
+	if format == "ud" {
+		// override the skips in HEBLEX with the UD conversion table's
+		lexConf := util.HEB2UD.Lexicon
+		lex.SKIP_POLAR = lexConf.SkipPolar
+		lex.SKIP_BINYAN = lexConf.SkipBinyan
+		lex.SKIP_ALL_TYPE = lexConf.SkipAllType
+		lex.SKIP_TYPES = make(map[string]bool, len(lexConf.SkipTypes))
+		for _, skipType := range lexConf.SkipTypes {
+			lex.SKIP_TYPES[skipType] = true
+		}
+		lattice.IGNORE_LEMMA = false
+		lex.STRIP_ALL_NNP_OF_FEATS = lexConf.StripPROPNFeatures
+	}
+	maData := new(ma.BGULex)
+	maData.MAType = format
+	log.Println("Reading Morphological Analyzer BGU Prefixes")
+	maData.LoadPrefixes(HebMaPrefixFile)
+	log.Println("Reading Morphological Analyzer BGU Lexicon")
+	maData.LoadLex(HebMaLexiconFile, HebMaNnpnofeats)
+	if HebMaCacheSize > 0 {
+		maData.Cache = ma.NewLatticeCache(HebMaCacheSize)
+	}
+	return maData
+}
+
 func HebMA(cmd *commander.Command, args []string) error {
 	useConllU = len(conlluFile) > 0
 	var REQUIRED_FLAGS []string
@@ -74,27 +124,15 @@ func HebMA(cmd *commander.Command, args []string) error {
 	}
 	VerifyFlags(cmd, REQUIRED_FLAGS)
 	HebMAConfigOut()
-	if outFormat == "ud" {
-		// override all skips in HEBLEX
-		lex.SKIP_POLAR = false
-		lex.SKIP_BINYAN = false
-		lex.SKIP_ALL_TYPE = false
-		lex.SKIP_TYPES = make(map[string]bool)
-		lattice.IGNORE_LEMMA = false
-		// Compatibility: No features for PROPN in UD Hebrew
-		lex.STRIP_ALL_NNP_OF_FEATS = true
-	}
-	maData := new(ma.BGULex)
-	maData.MAType = outFormat
-	log.Println("Reading Morphological Analyzer BGU Prefixes")
-	maData.LoadPrefixes(HebMaPrefixFile)
-	log.Println("Reading Morphological Analyzer BGU Lexicon")
-	maData.LoadLex(HebMaLexiconFile, HebMaNnpnofeats)
+	LoadHeb2UDConversion(Heb2UDConvFile)
+	maData := LoadHebMA(outFormat)
 	log.Println()
 	var (
 		sents        []nlp.BasicSentence
 		sentComments [][]string
+		tokenRanges  [][]nlp.TokenRange
 		sentsStream  chan nlp.BasicSentence
+		rangesStream chan []nlp.TokenRange
 		err          error
 	)
 	if Stream {
@@ -105,6 +143,7 @@ func HebMA(cmd *commander.Command, args []string) error {
 				panic(fmt.Sprintf("Failed reading CoNLL-U file - %v", err))
 			}
 			sentsStream = make(chan nlp.BasicSentence, 2)
+			rangesStream = make(chan []nlp.TokenRange, 2)
 			go func() {
 				var i int
 				for sent := range conllStream {
@@ -113,6 +152,7 @@ func HebMA(cmd *commander.Command, args []string) error {
 						newSent[j] = nlp.Token(token)
 					}
 					i++
+					rangesStream <- sent.TokenRanges
 					sentsStream <- newSent
 				}
 				close(sentsStream)
@@ -127,16 +167,18 @@ func HebMA(cmd *commander.Command, args []string) error {
 			}
 			sents = make([]nlp.BasicSentence, len(conllSents))
 			sentComments = make([][]string, len(conllSents))
+			tokenRanges = make([][]nlp.TokenRange, len(conllSents))
 			for i, sent := range conllSents {
 				newSent := make([]nlp.Token, len(sent.Tokens))
 				for j, token := range sent.Tokens {
 					newSent[j] = nlp.Token(token)
 				}
 				sentComments[i] = sent.Comments
+				tokenRanges[i] = sent.TokenRanges
 				sents[i] = newSent
 			}
 		} else {
-			sents, err = raw.ReadFile(inRawFile, limit)
+			sents, tokenRanges, err = raw.ReadFileWithRanges(inRawFile, limit)
 			if err != nil {
 				panic(fmt.Sprintf("Failed reading raw file - %v", err))
 			}
@@ -157,6 +199,7 @@ func HebMA(cmd *commander.Command, args []string) error {
 			for sent := range sentsStream {
 				// log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
 				lattice, ind := maData.Analyze(sent.Tokens())
+				lattice.SetTokenRanges(<-rangesStream)
 				oovInd = append(oovInd, ind)
 				if i%100 == 0 {
 					log.Println("At sent", i)
@@ -183,6 +226,7 @@ func HebMA(cmd *commander.Command, args []string) error {
 		for i, sent := range sents {
 			log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
 			lattices[i], oovInd[i] = maData.Analyze(sent.Tokens())
+			lattices[i].SetTokenRanges(tokenRanges[i])
 		}
 		var hebrew xliter8.Interface
 		if HebMaXliter8out {
@@ -211,6 +255,9 @@ func HebMA(cmd *commander.Command, args []string) error {
 	log.SetPrefix(prefix)
 	log.Println("Analyzed", stats.TotalTokens, "occurences of", len(stats.UniqTokens), "unique tokens")
 	log.Println("Encountered", stats.OOVTokens, "occurences of", len(stats.UniqOOVTokens), "unknown tokens")
+	if maData.Cache != nil {
+		log.Printf("Lattice cache hit rate %.4f (%d hits, %d misses)", stats.CacheHitRate(), stats.CacheHits, stats.CacheMisses)
+	}
 	return nil
 }
 
@@ -223,6 +270,7 @@ func HebMACmd() *commander.Command {
 run lexicon-based morphological analyzer on raw input
 
 	$ ./yap hebma -prefix <prefix file> -lexicon <lexicon file> -raw <raw file> -out <output file> [options]
+	$ ./yap hebma -raw <raw file> -out <output file> -format ud [-udconv heb2ud.yaml] [options]
 
 `,
 		Flag: *flag.NewFlagSet("ma", flag.ExitOnError),
@@ -237,10 +285,13 @@ run lexicon-based morphological analyzer on raw input
 	cmd.Flag.BoolVar(&HebMaNnpnofeats, "addnnpnofeats", false, "Add NNP in lex but without features")
 	cmd.Flag.IntVar(&limit, "limit", 0, "Limit input set")
 	cmd.Flag.BoolVar(&HebMaShowoov, "showoov", false, "Output OOV tokens")
+	cmd.Flag.IntVar(&HebMaCacheSize, "cache", 10000, "Number of token lattices to cache (0 disables)")
 	cmd.Flag.StringVar(&oovFile, "oov", "", "Output OOV File")
 	cmd.Flag.BoolVar(&lex.LOG_FAILURES, "showlexerror", false, "Log errors encountered when loading the lexicon")
 	cmd.Flag.StringVar(&outFormat, "format", "spmrl", "Output lattice format [spmrl|ud]")
+	cmd.Flag.StringVar(&Heb2UDConvFile, "udconv", "", "Optional - Hebrew to UD conversion table (YAML, see conf/heb2ud.yaml) for -format ud")
 	cmd.Flag.BoolVar(&outJSON, "json", false, "Output using JSON")
+	cmd.Flag.BoolVar(&lattice.WRITE_TOKEN_RANGE, "tokenrange", false, "Add token character ranges column to spmrl lattices")
 	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
 	return cmd
 }
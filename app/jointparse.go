@@ -49,7 +49,7 @@ func SetupEnum(relations []string) {
 
 func CombineJointCorpus(graphs, goldLats, ambLats []interface{}) ([]interface{}, int) {
 	if len(graphs) != len(goldLats) || len(graphs) != len(ambLats) {
-		panic(fmt.Sprintf("Got mismatched training slice inputs (graphs, gold lattices, ambiguous lattices):", len(graphs), len(goldLats), len(ambLats)))
+		panic(fmt.Sprintf("Got mismatched training slice inputs (graphs, gold lattices, ambiguous lattices): %d, %d, %d", len(graphs), len(goldLats), len(ambLats)))
 	}
 	morphGraphs := make([]interface{}, len(graphs))
 	var (
@@ -71,7 +71,7 @@ func CombineJointCorpus(graphs, goldLats, ambLats []interface{}) ([]interface{},
 
 func CombineToGoldMorphs(goldLats, ambLats []interface{}) ([]interface{}, int) {
 	if len(goldLats) != len(ambLats) {
-		panic(fmt.Sprintf("Got mismatched training slice inputs (gold lattices, ambiguous lattices):", len(goldLats), len(ambLats)))
+		panic(fmt.Sprintf("Got mismatched training slice inputs (gold lattices, ambiguous lattices): %d, %d", len(goldLats), len(ambLats)))
 	}
 	morphGraphs := make([]interface{}, len(goldLats))
 	var (
@@ -92,25 +92,117 @@ func CombineToGoldMorphs(goldLats, ambLats []interface{}) ([]interface{}, int) {
 	return morphGraphs, numSentNoGold
 }
 
-func JointConfigOut(outModelFile string, b search.Interface, t transition.TransitionSystem) {
+// JointOptions are the files and settings of joint training and parsing, the
+// joint command sets them from its flags
+type JointOptions struct {
+	Train              string // training conll(u)
+	TrainDis, TrainAmb string // training disambiguated and ambiguous lattices
+	Input, InputGold   string // dev-test ambiguous and gold lattices
+	Test, TestGold     string // test ambiguous and gold lattices
+	OutConll           string
+	OutSeg             string
+	OutMap             string
+	TrainSeg           string
+	FeaturesFile       string
+	LabelsFile         string
+	ArcSystem          string
+	ParamFuncName      string
+	JointStrategy      string
+	OracleStrategy     string
+	ModelFile          string // the model parsed with, and the prefix of the models of training
+	Iterations         int
+	BeamSize           int
+	UsePOP             bool
+	WordBased          bool
+	UseConllU          bool
+	CombineGold        bool // infuse the gold dev-test paths into its lattices
+	NoConverge         bool
+	Limit, LimitDev    int
+	NBest              int
+	ConstraintsFile    string
+}
+
+// JointFlagOptions returns the joint options set by the joint flags
+func JointFlagOptions() *JointOptions {
+	return &JointOptions{
+		Train:           tConll,
+		TrainDis:        tLatDis,
+		TrainAmb:        tLatAmb,
+		Input:           input,
+		InputGold:       inputGold,
+		Test:            test,
+		TestGold:        testGold,
+		OutConll:        outConll,
+		OutSeg:          outSeg,
+		OutMap:          outMap,
+		TrainSeg:        tSeg,
+		FeaturesFile:    JointFeaturesFile,
+		LabelsFile:      DepLabelsFile,
+		ArcSystem:       DepArcSystemStr,
+		ParamFuncName:   MdParamFuncName,
+		JointStrategy:   JointStrategy,
+		OracleStrategy:  OracleStrategy,
+		ModelFile:       JointModelFile,
+		Iterations:      Iterations,
+		BeamSize:        BeamSize,
+		UsePOP:          UsePOP,
+		WordBased:       MdUseWB,
+		UseConllU:       useConllU,
+		CombineGold:     MdCombineGold,
+		NoConverge:      MdNoconverge,
+		Limit:           limit,
+		LimitDev:        limitdev,
+		NBest:           NBest,
+		ConstraintsFile: ConstraintsFile,
+	}
+}
+
+// JointConfigOut logs the configuration of joint options, and locates their
+// features and labels files in the default directories
+func JointConfigOut(opts *JointOptions, outModelFile string, b search.Interface, t transition.TransitionSystem) {
 	log.Println("*** CONFIGURATION ***")
 	log.Printf("Beam:             \t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
 	log.Printf("Transition Oracle:\t%s", t.Oracle().Name())
-	log.Printf("Iterations:\t\t%d", Iterations)
-	log.Printf("Beam Size:\t\t%d", BeamSize)
+	log.Printf("Iterations:\t\t%d", opts.Iterations)
+	log.Printf("Beam Size:\t\t%d", opts.BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
-	log.Printf("Parameter Func:\t%v", MdParamFuncName)
+	log.Printf("Beam Lazy:\t\t%v", LazyBeam)
+	log.Printf("Beam Update:\t\t%s", BeamUpdate)
+	log.Printf("Train Workers:\t\t%d", TrainWorkers)
+	if len(ClustersFile) > 0 {
+		log.Printf("Word clusters:\t\t%s", ClustersFile)
+	}
+	if len(EmbeddingClustersFile) > 0 {
+		log.Printf("Embedding clusters:\t%s (%d)", EmbeddingClustersFile, EmbeddingClusters)
+	}
+	log.Printf("Learner:\t\t%s", Learner)
+	if Learner == "mira" {
+		log.Printf("Learner C:\t\t%v", LearnerC)
+	}
+	log.Printf("Parameter Func:\t%v", opts.ParamFuncName)
 	log.Printf("Use Lemmas:\t\t%v", !lattice.IGNORE_LEMMA)
-	log.Printf("Use POP:\t\t%v", UsePOP)
-	log.Printf("Infuse Gold Dev:\t%v", MdCombineGold)
-	log.Printf("Limit (thousands):\t%v", limit)
-	log.Printf("Use CoNLL-U:\t\t%v", useConllU)
+	log.Printf("Lemmatize:\t\t%v", Lemmatize)
+	log.Printf("Tag Features:\t\t%v", TagFeats)
+	log.Printf("Use POP:\t\t%v", opts.UsePOP)
+	log.Printf("Infuse Gold Dev:\t%v", opts.CombineGold)
+	log.Printf("Limit (thousands):\t%v", opts.Limit)
+	if opts.NBest > 0 {
+		log.Printf("N-Best:\t\t%v (%s)", opts.NBest, NBestFile(opts.OutMap))
+	}
+	if len(opts.ConstraintsFile) > 0 {
+		log.Printf("Constraints:\t\t%s", opts.ConstraintsFile)
+	}
+	log.Printf("Use CoNLL-U:\t\t%v", opts.UseConllU)
+	log.Printf("Pseudo-projective:\t%v", PseudoProjective)
+	if conllu.HEB2UD {
+		log.Printf("Heb2UD Output:\t%v", Heb2UDConvFile)
+	}
 	// log.Printf("Model file:\t\t%s", outModelFile)
 
 	log.Println()
-	log.Printf("Features File:\t%s", JointFeaturesFile)
-	outFeaturesFile := JointFeaturesFile
+	log.Printf("Features File:\t%s", opts.FeaturesFile)
+	outFeaturesFile := opts.FeaturesFile
 	featuresExists := VerifyExists(outFeaturesFile)
 	if !featuresExists {
 		outFeaturesFile, featuresExists = util.LocateFile(outFeaturesFile, DEFAULT_CONF_DIRS)
@@ -118,9 +210,9 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	if !featuresExists {
 		os.Exit(1)
 	}
-	JointFeaturesFile = outFeaturesFile
-	log.Printf("Labels File:\t\t%s", DepLabelsFile)
-	outLabelsFile := DepLabelsFile
+	opts.FeaturesFile = outFeaturesFile
+	log.Printf("Labels File:\t\t%s", opts.LabelsFile)
+	outLabelsFile := opts.LabelsFile
 	labelsExists := VerifyExists(outLabelsFile)
 	if !labelsExists {
 		outLabelsFile, labelsExists = util.LocateFile(outLabelsFile, DEFAULT_CONF_DIRS)
@@ -128,96 +220,110 @@ func JointConfigOut(outModelFile string, b search.Interface, t transition.Transi
 	if !labelsExists {
 		os.Exit(1)
 	}
-	DepLabelsFile = outLabelsFile
+	opts.LabelsFile = outLabelsFile
 	log.Println()
 	log.Println("Data")
-	if len(tConll) > 0 {
-		log.Printf("Train file (conll):\t\t\t%s", tConll)
-		if !VerifyExists(tConll) {
+	if len(opts.Train) > 0 {
+		log.Printf("Train file (conll):\t\t\t%s", opts.Train)
+		if !VerifyExists(opts.Train) {
 			return
 		}
 	}
-	if len(tLatDis) > 0 {
-		log.Printf("Train file (disamb. lattice):\t%s", tLatDis)
-		if !VerifyExists(tLatDis) {
+	if len(opts.TrainDis) > 0 {
+		log.Printf("Train file (disamb. lattice):\t%s", opts.TrainDis)
+		if !VerifyExists(opts.TrainDis) {
 			return
 		}
 	}
-	if len(tLatAmb) > 0 {
-		log.Printf("Train file (ambig.  lattice):\t%s", tLatAmb)
-		if !VerifyExists(tLatAmb) {
+	if len(opts.TrainAmb) > 0 {
+		log.Printf("Train file (ambig.  lattice):\t%s", opts.TrainAmb)
+		if !VerifyExists(opts.TrainAmb) {
 			return
 		}
 	}
-	if len(input) > 0 {
-		log.Printf("Test file  (ambig.  lattice):\t%s", input)
-		if !VerifyExists(input) {
+	if len(opts.Input) > 0 {
+		log.Printf("Test file  (ambig.  lattice):\t%s", opts.Input)
+		if !VerifyExists(opts.Input) {
 			return
 		}
 	}
-	if len(inputGold) > 0 {
-		log.Printf("Test file  (disambig.  lattice):\t%s", inputGold)
-		if !VerifyExists(inputGold) {
+	if len(opts.InputGold) > 0 {
+		log.Printf("Test file  (disambig.  lattice):\t%s", opts.InputGold)
+		if !VerifyExists(opts.InputGold) {
 			return
 		}
 	}
-	if len(outConll) > 0 {
-		log.Printf("Out (disamb.) file:\t\t\t%s", outConll)
+	if len(opts.OutConll) > 0 {
+		log.Printf("Out (disamb.) file:\t\t\t%s", opts.OutConll)
 	}
-	if len(outSeg) > 0 {
-		log.Printf("Out (segmt.) file:\t\t\t%s", outSeg)
+	if len(opts.OutSeg) > 0 {
+		log.Printf("Out (segmt.) file:\t\t\t%s", opts.OutSeg)
 	}
-	if len(outMap) > 0 {
-		log.Printf("Out (mapping.) file:\t\t\t%s", outMap)
+	if len(opts.OutMap) > 0 {
+		log.Printf("Out (mapping.) file:\t\t\t%s", opts.OutMap)
 	}
-	if len(tSeg) > 0 {
-		log.Printf("Out Train (segmt.) file:\t\t%s", tSeg)
+	if len(opts.TrainSeg) > 0 {
+		log.Printf("Out Train (segmt.) file:\t\t%s", opts.TrainSeg)
 	}
 }
 
-func JointTrainAndParse(cmd *commander.Command, args []string) error {
-	// *** SETUP ***
-	paramFunc, exists := nlp.MDParams[MdParamFuncName]
-	if !exists {
-		log.Fatalln("Param Func", MdParamFuncName, "does not exist")
+// newJointTrans returns the joint transition system of joint options, with
+// its oracle. It needs setJointTransitions once the enumerations are set up
+func newJointTrans(opts *JointOptions, paramFunc nlp.MDParam) (*joint.JointTrans, int, error) {
+	if _, err := joint.GetJointStrategy(opts.JointStrategy); err != nil {
+		return nil, 0, err
 	}
-
-	mdTrans := &disambig.MDTrans{
-		ParamFunc: paramFunc,
-		UsePOP:    UsePOP,
+	if _, err := joint.GetOracleStrategy(opts.OracleStrategy); err != nil {
+		return nil, 0, err
 	}
-
-	var (
-		arcSystem     transition.TransitionSystem
-		model         *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
-		terminalStack int
-	)
-
-	switch DepArcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{}
-		terminalStack = 1
-	case "eager":
-		arcSystem = &ArcEager{
-			ArcStandard: ArcStandard{},
-		}
-		terminalStack = 0
-	default:
-		panic("Unknown arc system")
+	arcSystem, terminalStack, err := NewArcSystem(opts.ArcSystem)
+	if err != nil {
+		return nil, 0, err
 	}
-
 	arcSystem.AddDefaultOracle()
-
 	jointTrans := &joint.JointTrans{
-		MDTrans:       mdTrans,
+		MDTrans:       newMDTransitionSystem(paramFunc, opts.WordBased, opts.UsePOP),
 		ArcSys:        arcSystem,
-		JointStrategy: JointStrategy,
+		JointStrategy: opts.JointStrategy,
 	}
 	jointTrans.AddDefaultOracle()
-	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = OracleStrategy
-	transitionSystem := transition.TransitionSystem(jointTrans)
+	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = opts.OracleStrategy
+	return jointTrans, terminalStack, nil
+}
 
-	outModelFile := JointModelFile
+// setJointTransitions sets the transition enumerations of a joint transition
+// system and of its md transitions
+func setJointTransitions(jointTrans *joint.JointTrans, opts *JointOptions) {
+	jointTrans.Transitions = ETrans
+	SetMDTransitions(jointTrans.MDTrans)
+	disambig.UsePOP = opts.UsePOP
+	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
+	disambig.LEMMAS = !lattice.IGNORE_LEMMA
+	jointTrans.MDTrans.AddDefaultOracle()
+	jointTrans.MDTransition = MD
+	jointTrans.AddDefaultOracle()
+	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = opts.OracleStrategy
+}
+
+func JointTrainAndParse(cmd *commander.Command, args []string) error {
+	// *** SETUP ***
+	opts := JointFlagOptions()
+	paramFunc, exists := nlp.MDParams[opts.ParamFuncName]
+	if !exists {
+		log.Fatalln("Param Func", opts.ParamFuncName, "does not exist")
+	}
+	if conllu.HEB2UD {
+		LoadHeb2UDConversion(Heb2UDConvFile)
+	}
+
+	// instantiate the transition system for config output only
+	// it will be reinstantiated with the enumerations by SetupJoint
+	transitionSystem, _, err := newJointTrans(opts, paramFunc)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	outModelFile := opts.ModelFile
 	modelExists := VerifyExists(outModelFile)
 	if !modelExists {
 		outModelFile, modelExists = util.LocateFile(outModelFile, DEFAULT_MODEL_DIRS)
@@ -226,7 +332,11 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	VerifyFlags(cmd, REQUIRED_FLAGS)
 
 	if !modelExists {
-		REQUIRED_FLAGS = []string{"it", "tc", "td", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
+		REQUIRED_FLAGS = []string{"it", "tc", "tl", "in", "oc", "om", "os", "ots", "f", "l", "jointstr", "oraclestr"}
+		if !opts.UseConllU {
+			// CoNLL-U training files include the disambiguated lattices
+			REQUIRED_FLAGS = append(REQUIRED_FLAGS, "td")
+		}
 		VerifyFlags(cmd, REQUIRED_FLAGS)
 	}
 
@@ -238,83 +348,88 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		confBeam.Averaged = AverageScores
 	}
 
-	JointConfigOut(outModelFile, confBeam, transitionSystem)
-
-	relations, err := conf.ReadFile(DepLabelsFile)
+	var serialization *Serialization
+	if modelExists {
+		// the model's training options decide the enumerations set up below
+		serialization = ReadModel(outModelFile)
+		serialization.Config.Apply()
+	}
+	JointConfigOut(opts, outModelFile, confBeam, transitionSystem)
+	jt, err := SetupJoint(opts)
 	if err != nil {
-		log.Println("Failed reading dependency labels configuration file:", DepLabelsFile)
 		log.Fatalln(err)
 	}
+
+	if !modelExists {
+		return JointTrain(jt)
+	}
+	if allOut && !parseOut {
+		log.Println("Found model file", outModelFile, " ... loading model")
+	}
+	return JointParse(jt, serialization)
+}
+
+// JointSetup holds what joint training and parsing share: its options,
+// transition system, features and word clusters
+type JointSetup struct {
+	Options       *JointOptions
+	ParamFunc     nlp.MDParam
+	Trans         *joint.JointTrans
+	TerminalStack int
+	FeatureSetup  *transition.FeatureSetup
+	Extractor     *transition.GenericExtractor
+	Clusters      *nlp.WordClusters
+}
+
+// jointFeatureGroups are the transition types of the joint features:
+// M - MD
+// P - POP
+// L - Lemma (not in use right now)
+// A - Arc (syntactic)
+var jointFeatureGroups = []byte("MPLA")
+
+// SetupJoint sets up the enumerations, transitions and features of joint
+// options
+func SetupJoint(opts *JointOptions) (*JointSetup, error) {
+	paramFunc, exists := nlp.MDParams[opts.ParamFuncName]
+	if !exists {
+		return nil, fmt.Errorf("Param Func %s does not exist", opts.ParamFuncName)
+	}
+	clusters := LoadWordClusters()
+
+	relations, err := conf.ReadFile(opts.LabelsFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed reading dependency labels configuration file %s: %v", opts.LabelsFile, err)
+	}
 	if allOut {
 		log.Println()
 		// start processing - setup enumerations
 		log.Println("Setup enumerations")
 	}
-	SetupEnum(relations.Values)
+	SetupEnum(PseudoProjectiveRelations(relations.Values))
 
 	// after calling SetupEnum, enums are instantiated and set according to the relations
-	// therefore we re-instantiate the arc system with the right parameters
+	// therefore the transition system is instantiated only now
 	// DON'T REMOVE!!
-	switch DepArcSystemStr {
-	case "standard":
-		arcSystem = &ArcStandard{
-			SHIFT:       SH.Value(),
-			LEFT:        LA.Value(),
-			RIGHT:       RA.Value(),
-			Transitions: ETrans,
-			Relations:   ERel,
-		}
-	case "eager":
-		arcSystem = &ArcEager{
-			ArcStandard: ArcStandard{
-				SHIFT:       SH.Value(),
-				LEFT:        LA.Value(),
-				RIGHT:       RA.Value(),
-				Relations:   ERel,
-				Transitions: ETrans,
-			},
-			REDUCE:  RE.Value(),
-			POPROOT: PR.Value(),
-		}
-	default:
-		panic("Unknown arc system")
+	jointTrans, terminalStack, err := newJointTrans(opts, paramFunc)
+	if err != nil {
+		return nil, err
 	}
-	arcSystem.AddDefaultOracle()
-	jointTrans.ArcSys = arcSystem
-	jointTrans.Transitions = ETrans
-	mdTrans.Transitions = ETrans
-	mdTrans.UsePOP = UsePOP
-	mdTrans.POP = POP
-	disambig.UsePOP = UsePOP
-	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
-	disambig.LEMMAS = !lattice.IGNORE_LEMMA
-	mdTrans.AddDefaultOracle()
-	jointTrans.MDTransition = MD
-	jointTrans.JointStrategy = JointStrategy
-	jointTrans.AddDefaultOracle()
-	jointTrans.Oracle().(*joint.JointOracle).OracleStrategy = OracleStrategy
-
-	transitionSystem = transition.TransitionSystem(jointTrans)
+	setJointTransitions(jointTrans, opts)
 
 	if allOut {
 		log.Println()
 		log.Println("Loading features")
 	}
 
-	featureSetup, err := transition.LoadFeatureConfFile(JointFeaturesFile)
+	featureSetup, err := transition.LoadFeatureConfFile(opts.FeaturesFile)
 	if err != nil {
-		log.Println("Failed reading feature configuration file:", JointFeaturesFile)
-		log.Fatalln(err)
+		return nil, fmt.Errorf("Failed reading feature configuration file %s: %v", opts.FeaturesFile, err)
 	}
-	// M - MD
-	// P - POP
-	// L - Lemma (not in use right now)
-	// A - Arc (syntactic)
-	groups := []byte("MPLA")
-	extractor := SetupExtractor(featureSetup, groups)
+	extractor := SetupExtractor(featureSetup, jointFeatureGroups)
 
 	log.Println()
-	if useConllU {
+	if opts.UseConllU {
 		nlp.InitOpenParamFamily("UD")
 		conllu.IGNORE_LEMMA = lattice.IGNORE_LEMMA
 		if hebMACompat {
@@ -324,236 +439,326 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		nlp.InitOpenParamFamily("HEBTB")
 	}
 	log.Println()
+	return &JointSetup{
+		Options:       opts,
+		ParamFunc:     paramFunc,
+		Trans:         jointTrans,
+		TerminalStack: terminalStack,
+		FeatureSetup:  featureSetup,
+		Extractor:     extractor,
+		Clusters:      clusters,
+	}, nil
+}
 
-	if !modelExists {
-		log.Println("")
-		log.Println("*** TRAINING ***")
-		// *** TRAINING ***
+// jointConfig returns the configuration joint parsing starts from
+func jointConfig(jt *JointSetup) *joint.JointConfig {
+	return &joint.JointConfig{
+		SimpleConfiguration: SimpleConfiguration{
+			EWord:         EWord,
+			EPOS:          EPOS,
+			EWPOS:         EWPOS,
+			EMHost:        EMHost,
+			EMSuffix:      EMSuffix,
+			ERel:          ERel,
+			ETrans:        ETrans,
+			Clusters:      jt.Clusters,
+			TerminalStack: jt.TerminalStack,
+			TerminalQueue: 0,
+		},
+		MDConfig: disambig.MDConfig{
+			ETokens:     ETokens,
+			POP:         POP,
+			Transitions: ETrans,
+			ParamFunc:   jt.ParamFunc,
+			Clusters:    jt.Clusters,
+		},
+		MDTrans: MD,
+	}
+}
+
+// JointTrain trains a joint parser on the training files of its options;
+// the models of its iterations are written with the model file as prefix
+func JointTrain(jt *JointSetup) error {
+	var (
+		opts             = jt.Options
+		transitionSystem = transition.TransitionSystem(jt.Trans)
+		extractor        = jt.Extractor
+	)
+	log.Println("")
+	log.Println("*** TRAINING ***")
+	// *** TRAINING ***
+
+	if allOut {
+		log.Println("Generating Gold Sequences For Training")
+		log.Println("Conll:\tReading training conll sentences from", opts.Train)
+	}
+	var goldConll []interface{}
+	if opts.UseConllU {
+		s, _, e := conllu.ReadFile(opts.Train, opts.Limit)
+		if e != nil {
+			log.Println(e)
+			return e
+		}
+		if allOut {
+			log.Println("Conll:\tRead", len(s), "sentences")
+			log.Println("Conll:\tConverting from conll to internal structure")
+		}
+		if PseudoProjective {
+			ProjectivizeConllU(s)
+		}
+		goldConll = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+	} else {
+		s, e := conll.ReadFile(opts.Train, opts.Limit)
+		if e != nil {
+			log.Println(e)
+			return e
+		}
+		if allOut {
+			log.Println("Conll:\tRead", len(s), "sentences")
+			log.Println("Conll:\tConverting from conll to internal structure")
+		}
+		if PseudoProjective {
+			ProjectivizeConll(s)
+		}
+		goldConll = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
+	}
+
+	var goldDisLat []interface{}
+	if !opts.UseConllU {
+		if allOut {
+			log.Println("Dis. Lat.:\tReading training disambiguated lattices from", opts.TrainDis)
+		}
+		lDis, lDisE := lattice.ReadFile(opts.TrainDis, opts.Limit)
+		if lDisE != nil {
+			log.Println(lDisE)
+			return lDisE
+		}
+		if allOut {
+			log.Println("Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
+			log.Println("Dis. Lat.:\tConverting lattice format to internal structure")
+		}
+		goldDisLat = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	} else {
+		goldDisLat = make([]interface{}, len(goldConll))
+		for i, sent := range goldConll {
+			goldDisLat[i] = sent.(*morph.BasicMorphGraph).Lattice
+		}
+	}
+
+	if allOut {
+		log.Println("Amb. Lat:\tReading ambiguous lattices from", opts.TrainAmb)
+	}
+	var (
+		lAmb  []lattice.Lattice
+		lAmbE error
+	)
+	if opts.UseConllU {
+		lAmb, lAmbE = ReadULLattices(opts.TrainAmb, opts.Limit)
+	} else {
+		lAmb, lAmbE = lattice.ReadFile(opts.TrainAmb, opts.Limit)
+	}
+	if lAmbE != nil {
+		log.Println(lAmbE)
+		return lAmbE
+	}
+	if allOut {
+		log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
+		log.Println("Amb. Lat:\tConverting lattice format to internal structure")
+	}
+	goldAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	TrainLemmatizer(goldDisLat)
+	if TagFeats {
+		featsGoldFile, featsDevFile := opts.TrainDis, ""
+		if len(opts.InputGold) > 0 && !opts.NoConverge {
+			featsDevFile = opts.InputGold
+		}
+		if opts.UseConllU {
+			featsGoldFile, featsDevFile = "", ""
+		}
+		if err := TrainFeatsTagger(goldDisLat, featsGoldFile, featsDevFile); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	if allOut {
+		log.Println("Combining train files into gold morph graphs with original lattices")
+	}
+	combined, missingGold := CombineJointCorpus(goldConll, goldDisLat, goldAmbLat)
+
+	if allOut {
+		log.Println("Combined", len(combined), "graphs, with", missingGold, "missing at least one gold path in lattice")
+
+		log.Println()
+
+	}
+
+	if allOut {
+		log.Println()
+
+		log.Println("Parsing with gold to get training sequences")
+	}
+
+	// const NUM_SENTS = 20
+	// combined = combined[:NUM_SENTS]
+	goldSequences := TrainingSequences(combined, GetMorphGraphAsLattices, GetMorphGraph)
+	if allOut {
+		log.Println("Generated", len(goldSequences), "training sequences")
+		log.Println()
+		// util.LogMemory()
+		log.Println("Training", opts.Iterations, "iteration(s)")
+	}
+	formatters := make([]util.Format, 0, 100)
+	for _, g := range jointFeatureGroups {
+		group, _ := extractor.TransTypeGroups[g]
+		for _, formatter := range group.FeatureTemplates {
+			formatters = append(formatters, formatter)
+		}
+	}
+	model := transitionmodel.NewAvgMatrixSparse(NumFeatures, formatters, false)
+	model.Extractor = extractor
+	// model.Classifier = func(t transition.Transition) string {
+	// 	if t.Value() < MD.Value() {
+	// 		return "Arc"
+	// 	} else {
+	// 		return "MD"
+	// 	}
+	// }
+
+	conf := jointConfig(jt)
 
+	beam := &search.Beam{
+		TransFunc:            transitionSystem,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 opts.BeamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		Update:               BeamUpdateStrategy(),
+		Transitions:          ETrans,
+		EstimatedTransitions: 1000,
+		NoRecover:            false,
+	}
+
+	if !alignAverageParseOnly {
+		beam.Align = AlignBeam
+		beam.Averaged = AverageScores
+	}
+
+	deterministic := &search.Deterministic{
+		TransFunc:          transitionSystem,
+		FeatExtractor:      extractor,
+		ReturnModelValue:   false,
+		ReturnSequence:     true,
+		ShowConsiderations: false,
+		Base:               conf,
+		NoRecover:          false,
+		DefaultTransType:   'M',
+	}
+
+	var evaluator perceptron.StopCondition
+	if len(opts.InputGold) > 0 && !opts.NoConverge {
+		var (
+			convCombined []interface{}
+			convDisLat   []interface{}
+			convAmbLat   []interface{}
+		)
 		if allOut {
-			log.Println("Generating Gold Sequences For Training")
-			log.Println("Conll:\tReading training conll sentences from", tConll)
+			log.Println("Setting convergence tester")
 		}
-		var goldConll []interface{}
-		if useConllU {
-			s, _, e := conllu.ReadFile(tConll, limit)
+		decodeTestBeam := &search.Beam{}
+		*decodeTestBeam = *beam
+		decodeTestBeam.Model = model
+		decodeTestBeam.DecodeTest = true
+		decodeTestBeam.ShortTempAgenda = true
+
+		if opts.UseConllU {
+
+			s, _, e := conllu.ReadFile(opts.InputGold, opts.LimitDev)
 			if e != nil {
 				log.Println(e)
 				return e
 			}
 			if allOut {
-				log.Println("Conll:\tRead", len(s), "sentences")
-				log.Println("Conll:\tConverting from conll to internal structure")
-			}
-			goldConll = conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-		} else {
-			s, e := conll.ReadFile(tConll, limit)
-			if e != nil {
-				log.Println(e)
-				return e
+				log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(s), "disambiguated lattices")
+				log.Println("Convergence Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
 			}
-			if allOut {
-				log.Println("Conll:\tRead", len(s), "sentences")
-				log.Println("Conll:\tConverting from conll to internal structure")
+			asGraph := conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+			convDisLat = make([]interface{}, len(asGraph))
+			for i, sent := range asGraph {
+				convDisLat[i] = sent.(*morph.BasicMorphGraph).Lattice
 			}
-			goldConll = conll.Conll2GraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMHost, EMSuffix)
-		}
+		} else {
 
-		var goldDisLat []interface{}
-		if !useConllU {
-			if allOut {
-				log.Println("Dis. Lat.:\tReading training disambiguated lattices from", tLatDis)
-			}
-			lDis, lDisE := lattice.ReadFile(tLatDis, limit)
-			if lDisE != nil {
-				log.Println(lDisE)
-				return lDisE
+			lConvDis, lConvDisE := lattice.ReadFile(opts.InputGold, opts.LimitDev)
+			if lConvDisE != nil {
+				log.Println(lConvDisE)
+				return lConvDisE
 			}
 			if allOut {
-				log.Println("Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
-				log.Println("Dis. Lat.:\tConverting lattice format to internal structure")
-			}
-			goldDisLat = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-		} else {
-			goldDisLat = make([]interface{}, len(goldConll))
-			for i, sent := range goldConll {
-				goldDisLat[i] = sent.(*morph.BasicMorphGraph).Lattice
+				log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
+				log.Println("Convergence Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
 			}
+
+			convDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
 		}
 
 		if allOut {
-			log.Println("Amb. Lat:\tReading ambiguous lattices from", tLatAmb)
+			log.Println("Reading dev test ambiguous lattices (for convergence testing) from", opts.Input)
 		}
+
 		var (
-			lAmb  []lattice.Lattice
-			lAmbE error
+			lConvAmb  []lattice.Lattice
+			lConvAmbE error
 		)
-		if useConllU {
-			lAmb, lAmbE = lattice.ReadUDFile(tLatAmb, limit)
+		if opts.UseConllU {
+			lConvAmb, lConvAmbE = ReadULLattices(opts.Input, opts.LimitDev)
 		} else {
-			lAmb, lAmbE = lattice.ReadFile(tLatAmb, limit)
+			lConvAmb, lConvAmbE = lattice.ReadFile(opts.Input, opts.LimitDev)
 		}
-		if lAmbE != nil {
-			log.Println(lAmbE)
-			return lAmbE
+		// lConvAmb = lConvAmb[:NUM_SENTS]
+		if lConvAmbE != nil {
+			log.Println(lConvAmbE)
+			return lConvAmbE
 		}
+		// lAmb = lAmb[:NUM_SENTS]
 		if allOut {
-			log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
-			log.Println("Amb. Lat:\tConverting lattice format to internal structure")
+			log.Println("Read", len(lConvAmb), "ambiguous lattices from", opts.Input)
+			log.Println("Converting lattice format to internal structure")
 		}
-		goldAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-		if allOut {
-			log.Println("Combining train files into gold morph graphs with original lattices")
-		}
-		combined, missingGold := CombineJointCorpus(goldConll, goldDisLat, goldAmbLat)
-
-		if allOut {
-			log.Println("Combined", len(combined), "graphs, with", missingGold, "missing at least one gold path in lattice")
-
-			log.Println()
-
-		}
-
-		if allOut {
-			log.Println()
-
-			log.Println("Parsing with gold to get training sequences")
+		convAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		if opts.CombineGold {
+			var devMissingGold, devSentMissingGold, devLattices int
+			convCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(convDisLat, convAmbLat)
+			log.Println("Combined", len(convCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
+		} else {
+			convCombined, _, _, _ = CombineLatticesCorpus(convDisLat, convDisLat)
 		}
-
-		// const NUM_SENTS = 20
-		// combined = combined[:NUM_SENTS]
-		goldSequences := TrainingSequences(combined, GetMorphGraphAsLattices, GetMorphGraph)
 		if allOut {
-			log.Println("Generated", len(goldSequences), "training sequences")
-			log.Println()
-			// util.LogMemory()
-			log.Println("Training", Iterations, "iteration(s)")
-		}
-		formatters := make([]util.Format, 0, 100)
-		for _, g := range groups {
-			group, _ := extractor.TransTypeGroups[g]
-			for _, formatter := range group.FeatureTemplates {
-				formatters = append(formatters, formatter)
-			}
+			log.Println("Setting convergence tester")
 		}
-		model := transitionmodel.NewAvgMatrixSparse(NumFeatures, formatters, false)
-		model.Extractor = extractor
-		// model.Classifier = func(t transition.Transition) string {
-		// 	if t.Value() < MD.Value() {
-		// 		return "Arc"
-		// 	} else {
-		// 		return "MD"
-		// 	}
-		// }
-
-		conf := &joint.JointConfig{
-			SimpleConfiguration: SimpleConfiguration{
-				EWord:         EWord,
-				EPOS:          EPOS,
-				EWPOS:         EWPOS,
-				EMHost:        EMHost,
-				EMSuffix:      EMSuffix,
-				ERel:          ERel,
-				ETrans:        ETrans,
-				TerminalStack: terminalStack,
-				TerminalQueue: 0,
-			},
-			MDConfig: disambig.MDConfig{
-				ETokens:     ETokens,
-				POP:         POP,
-				Transitions: ETrans,
-				ParamFunc:   paramFunc,
-			},
-			MDTrans: MD,
-		}
-
-		beam := &search.Beam{
-			TransFunc:            transitionSystem,
-			FeatExtractor:        extractor,
-			Base:                 conf,
-			Size:                 BeamSize,
-			ConcurrentExec:       ConcurrentBeam,
-			Transitions:          ETrans,
-			EstimatedTransitions: 1000,
-			NoRecover:            false,
-		}
-
-		if !alignAverageParseOnly {
-			beam.Align = AlignBeam
-			beam.Averaged = AverageScores
-		}
-
-		deterministic := &search.Deterministic{
-			TransFunc:          transitionSystem,
-			FeatExtractor:      extractor,
-			ReturnModelValue:   false,
-			ReturnSequence:     true,
-			ShowConsiderations: false,
-			Base:               conf,
-			NoRecover:          false,
-			DefaultTransType:   'M',
-		}
-
-		var evaluator perceptron.StopCondition
-		if len(inputGold) > 0 && !MdNoconverge {
-			var (
-				convCombined []interface{}
-				convDisLat   []interface{}
-				convAmbLat   []interface{}
-			)
-			if allOut {
-				log.Println("Setting convergence tester")
-			}
-			decodeTestBeam := &search.Beam{}
-			*decodeTestBeam = *beam
-			decodeTestBeam.Model = model
-			decodeTestBeam.DecodeTest = true
-			decodeTestBeam.ShortTempAgenda = true
-
-			if useConllU {
-
-				s, _, e := conllu.ReadFile(inputGold, limitdev)
-				if e != nil {
-					log.Println(e)
-					return e
-				}
-				if allOut {
-					log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(s), "disambiguated lattices")
-					log.Println("Convergence Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
-				}
-				asGraph := conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-				convDisLat = make([]interface{}, len(asGraph))
-				for i, sent := range asGraph {
-					convDisLat[i] = sent.(*morph.BasicMorphGraph).Lattice
-				}
-			} else {
-
-				lConvDis, lConvDisE := lattice.ReadFile(inputGold, limitdev)
+		var testCombined []interface{}
+		var testDisLat []interface{}
+		var testAmbLat []interface{}
+
+		if len(opts.Test) > 0 {
+			if len(opts.TestGold) > 0 {
+				log.Println("Reading test disambiguated lattice (for convergence testing) from", opts.TestGold)
+				lConvDis, lConvDisE := lattice.ReadFile(opts.TestGold, opts.LimitDev)
 				if lConvDisE != nil {
 					log.Println(lConvDisE)
 					return lConvDisE
 				}
 				if allOut {
-					log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
-					log.Println("Convergence Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
+					log.Println("Convergence Test Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
+					log.Println("Convergence Test Gold Dis. Lat.:\tConverting lattice format to internal structure")
 				}
 
-				convDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+				testDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
 			}
-
 			if allOut {
-				log.Println("Reading dev test ambiguous lattices (for convergence testing) from", input)
+				log.Println("Reading test ambiguous lattices from", opts.Test)
 			}
 
-			var (
-				lConvAmb  []lattice.Lattice
-				lConvAmbE error
-			)
-			if useConllU {
-				lConvAmb, lConvAmbE = lattice.ReadUDFile(input, limitdev)
-			} else {
-				lConvAmb, lConvAmbE = lattice.ReadFile(input, limitdev)
-			}
+			lConvAmb, lConvAmbE := lattice.ReadFile(opts.Test, opts.LimitDev)
 			// lConvAmb = lConvAmb[:NUM_SENTS]
 			if lConvAmbE != nil {
 				log.Println(lConvAmbE)
@@ -561,153 +766,82 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			}
 			// lAmb = lAmb[:NUM_SENTS]
 			if allOut {
-				log.Println("Read", len(lConvAmb), "ambiguous lattices from", input)
+				log.Println("Read", len(lConvAmb), "ambiguous lattices from", opts.Test)
 				log.Println("Converting lattice format to internal structure")
 			}
-			convAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-			if MdCombineGold {
+			testAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+			if opts.CombineGold {
 				var devMissingGold, devSentMissingGold, devLattices int
-				convCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(convDisLat, convAmbLat)
-				log.Println("Combined", len(convCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
+				testCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(testDisLat, testAmbLat)
+				log.Println("Combined", len(testCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
 			} else {
-				convCombined, _, _, _ = CombineLatticesCorpus(convDisLat, convDisLat)
+				testCombined, _, _, _ = CombineLatticesCorpus(testDisLat, testDisLat)
 			}
-			if allOut {
-				log.Println("Setting convergence tester")
-			}
-			var testCombined []interface{}
-			var testDisLat []interface{}
-			var testAmbLat []interface{}
-
-			if len(test) > 0 {
-				if len(testGold) > 0 {
-					log.Println("Reading test disambiguated lattice (for convergence testing) from", testGold)
-					lConvDis, lConvDisE := lattice.ReadFile(testGold, limitdev)
-					if lConvDisE != nil {
-						log.Println(lConvDisE)
-						return lConvDisE
-					}
-					if allOut {
-						log.Println("Convergence Test Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
-						log.Println("Convergence Test Gold Dis. Lat.:\tConverting lattice format to internal structure")
-					}
-
-					testDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-				}
-				if allOut {
-					log.Println("Reading test ambiguous lattices from", test)
-				}
-
-				lConvAmb, lConvAmbE := lattice.ReadFile(test, limitdev)
-				// lConvAmb = lConvAmb[:NUM_SENTS]
-				if lConvAmbE != nil {
-					log.Println(lConvAmbE)
-					return lConvAmbE
-				}
-				// lAmb = lAmb[:NUM_SENTS]
-				if allOut {
-					log.Println("Read", len(lConvAmb), "ambiguous lattices from", test)
-					log.Println("Converting lattice format to internal structure")
-				}
-				testAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-				if MdCombineGold {
-					var devMissingGold, devSentMissingGold, devLattices int
-					testCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(testDisLat, testAmbLat)
-					log.Println("Combined", len(testCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
-				} else {
-					testCombined, _, _, _ = CombineLatticesCorpus(testDisLat, testDisLat)
-				}
-				// if limit > 0 {
-				// 	testCombined = Limit(testCombined, limit*1000)
-				// 	testAmbLat = Limit(testAmbLat, limit*1000)
-				// }
-				// convCombined = convCombined[:100]
-			}
-			// TODO: replace nil param with test sentences
-			evaluator = MakeJointEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
-		}
-		_ = Train(goldSequences, Iterations, JointModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
-		search.AllOut = false
-		if allOut {
-			log.Println("Done Training")
-			// util.LogMemory()
-			// log.Println()
-			// serialization := &Serialization{
-			// 	model.Serialize(-1),
-			// 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
-			// }
-			// log.Println("Writing final model to", outModelFile)
-			// WriteModel(outModelFile, serialization)
-			// if allOut {
-			// 	log.Println("Done writing model")
+			// if limit > 0 {
+			// 	testCombined = Limit(testCombined, limit*1000)
+			// 	testAmbLat = Limit(testAmbLat, limit*1000)
 			// }
+			// convCombined = convCombined[:100]
 		}
-		return nil
-	} else {
-		if allOut && !parseOut {
-			log.Println("Found model file", outModelFile, " ... loading model")
-		}
-		serialization := ReadModel(outModelFile)
-		model.Deserialize(serialization.WeightModel)
-		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
-		if allOut && !parseOut {
-			log.Println("Loaded model")
-		}
-		switch DepArcSystemStr {
-		case "standard":
-			arcSystem = &ArcStandard{
-				SHIFT:       SH.Value(),
-				LEFT:        LA.Value(),
-				RIGHT:       RA.Value(),
-				Transitions: ETrans,
-				Relations:   ERel,
-			}
-		case "eager":
-			arcSystem = &ArcEager{
-				ArcStandard: ArcStandard{
-					SHIFT:       SH.Value(),
-					LEFT:        LA.Value(),
-					RIGHT:       RA.Value(),
-					Relations:   ERel,
-					Transitions: ETrans,
-				},
-				REDUCE:  RE.Value(),
-				POPROOT: PR.Value(),
-			}
-		default:
-			panic("Unknown arc system")
-		}
-		arcSystem.AddDefaultOracle()
-		jointTrans.ArcSys = arcSystem
-		jointTrans.Transitions = ETrans
-		mdTrans.Transitions = ETrans
-		mdTrans.UsePOP = UsePOP
-		mdTrans.POP = POP
-		disambig.UsePOP = UsePOP
-		disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
-		disambig.LEMMAS = !lattice.IGNORE_LEMMA
-		mdTrans.AddDefaultOracle()
-		jointTrans.MDTransition = MD
-		jointTrans.JointStrategy = JointStrategy
+		// TODO: replace nil param with test sentences
+		evaluator = MakeJointEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), opts.BeamSize)
+	}
+	_ = Train(goldSequences, opts.Iterations, opts.ModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator, BeamDecoders(beam))
+	search.AllOut = false
+	if allOut {
+		log.Println("Done Training")
+		// util.LogMemory()
+		// log.Println()
+		// serialization := &Serialization{
+		// 	model.Serialize(-1),
+		// 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+		// }
+		// log.Println("Writing final model to", outModelFile)
+		// WriteModel(outModelFile, serialization)
+		// if allOut {
+		// 	log.Println("Done writing model")
+		// }
+	}
+	return nil
+}
 
-		transitionSystem = transition.TransitionSystem(jointTrans)
+// JointParse parses the input lattices of joint options with a trained model
+// and writes the parses to their output files
+func JointParse(jt *JointSetup, serialization *Serialization) error {
+	var (
+		opts  = jt.Options
+		model = &transitionmodel.AvgMatrixSparse{}
+	)
+	model.Deserialize(serialization.WeightModel)
+	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
+	Lemmatizer = serialization.Lemmas
+	FeatsTagger = serialization.Feats
+	SetupFeatsTagger()
+	if allOut && !parseOut {
+		log.Println("Loaded model")
+	}
+	// the transitions of the model replace those of the setup
+	jointTrans, _, err := newJointTrans(opts, jt.ParamFunc)
+	if err != nil {
+		return err
 	}
+	setJointTransitions(jointTrans, opts)
 
 	// *** PARSING ***
 	log.Println()
 	log.Println("*** PARSING ***")
 	log.Print("Parsing test")
 
-	log.Println("Reading ambiguous lattices from", input)
+	log.Println("Reading ambiguous lattices from", opts.Input)
 
 	var (
 		lAmb  []lattice.Lattice
 		lAmbE error
 	)
-	if useConllU {
-		lAmb, lAmbE = lattice.ReadUDFile(input, limit)
+	if opts.UseConllU {
+		lAmb, lAmbE = ReadULLattices(opts.Input, opts.Limit)
 	} else {
-		lAmb, lAmbE = lattice.ReadFile(input, limit)
+		lAmb, lAmbE = lattice.ReadFile(opts.Input, opts.Limit)
 	}
 	if lAmbE != nil {
 		log.Println(lAmbE)
@@ -715,33 +849,42 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 	}
 	// lAmb = lAmb[:NUM_SENTS]
 	if allOut {
-		log.Println("Read", len(lAmb), "ambiguous lattices from", input)
+		log.Println("Read", len(lAmb), "ambiguous lattices from", opts.Input)
 		log.Println("Converting lattice format to internal structure")
 	}
 	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
 
-	if len(inputGold) > 0 {
+	if len(opts.InputGold) > 0 {
 		log.Println("Reading test disambiguated lattice (for test ambiguous infusion)")
-		var (
-			lDis  []lattice.Lattice
-			lDisE error
-		)
-		if useConllU {
-			lDis, lDisE = lattice.ReadUDFile(inputGold, limit)
+		var predDisLat []interface{}
+		if opts.UseConllU {
+			s, _, e := conllu.ReadFile(opts.InputGold, opts.Limit)
+			if e != nil {
+				log.Println(e)
+				return e
+			}
+			if allOut {
+				log.Println("Dev Gold Dis. Lat.:\tRead", len(s), "disambiguated lattices")
+				log.Println("Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
+			}
+			asGraph := conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+			predDisLat = make([]interface{}, len(asGraph))
+			for i, sent := range asGraph {
+				predDisLat[i] = sent.(*morph.BasicMorphGraph).Lattice
+			}
 		} else {
-			lDis, lDisE = lattice.ReadFile(inputGold, limit)
-		}
-		if lDisE != nil {
-			log.Println(lDisE)
-			return lDisE
-		}
-		if allOut {
-			log.Println("Dev Gold Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
-			log.Println("Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
+			lDis, lDisE := lattice.ReadFile(opts.InputGold, opts.Limit)
+			if lDisE != nil {
+				log.Println(lDisE)
+				return lDisE
+			}
+			if allOut {
+				log.Println("Dev Gold Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
+				log.Println("Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
+			}
+			predDisLat = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
 		}
 
-		predDisLat := lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-
 		if allOut {
 			log.Println("Infusing test's dev disambiguation into ambiguous lattice")
 		}
@@ -754,38 +897,30 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 			log.Println()
 		}
 	}
-	conf := &joint.JointConfig{
-		SimpleConfiguration: SimpleConfiguration{
-			EWord:         EWord,
-			EPOS:          EPOS,
-			EWPOS:         EWPOS,
-			EMHost:        EMHost,
-			EMSuffix:      EMSuffix,
-			ERel:          ERel,
-			ETrans:        ETrans,
-			TerminalStack: terminalStack,
-			TerminalQueue: 0,
-		},
-		MDConfig: disambig.MDConfig{
-			ETokens:     ETokens,
-			POP:         POP,
-			Transitions: ETrans,
-			ParamFunc:   paramFunc,
-		},
-		MDTrans: MD,
-	}
 	beam := &search.Beam{
-		TransFunc:            transitionSystem,
-		FeatExtractor:        extractor,
-		Base:                 conf,
-		Size:                 BeamSize,
+		TransFunc:            jointTrans,
+		FeatExtractor:        jt.Extractor,
+		Base:                 jointConfig(jt),
+		Size:                 opts.BeamSize,
 		ConcurrentExec:       ConcurrentBeam,
+		Lazy:                 LazyBeam,
 		Transitions:          ETrans,
 		EstimatedTransitions: 1000, // chosen by random dice roll
 	}
 	beam.Model = model
 	beam.ShortTempAgenda = true
-	parsedGraphs := Parse(predAmbLat, beam)
+	if len(opts.ConstraintsFile) > 0 {
+		LoadConstraints(opts.ConstraintsFile, predAmbLat)
+	}
+	var (
+		parsedGraphs []interface{}
+		nbests       []*disambig.NBest
+	)
+	if opts.NBest > 0 {
+		parsedGraphs, nbests = ParseNBest(predAmbLat, beam, opts.NBest)
+	} else {
+		parsedGraphs = Parse(predAmbLat, beam)
+	}
 
 	if allOut {
 		log.Println("Converting", len(parsedGraphs), "to conll")
@@ -794,28 +929,34 @@ func JointTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Writing to output file")
 	}
 	var graphAsConll []interface{}
-	if useConllU {
+	if opts.UseConllU || conllu.HEB2UD {
 		graphAsConll = conllu.MorphGraph2ConllCorpus(parsedGraphs)
-		conllu.WriteFile(outConll, graphAsConll)
+		conllu.WriteFile(opts.OutConll, DeprojectivizeCorpus(graphAsConll))
 	} else {
 		graphAsConll = conll.MorphGraph2ConllCorpus(parsedGraphs)
-		conll.WriteFile(outConll, graphAsConll)
+		conll.WriteFile(opts.OutConll, DeprojectivizeCorpus(graphAsConll))
 	}
 	if allOut {
-		log.Println("Wrote", len(graphAsConll), "in conll format to", outConll)
+		log.Println("Wrote", len(graphAsConll), "in conll format to", opts.OutConll)
 
 		log.Println("Writing to segmentation file")
 	}
-	segmentation.WriteFile(outSeg, parsedGraphs)
+	segmentation.WriteFile(opts.OutSeg, parsedGraphs)
 	if allOut {
-		log.Println("Wrote", len(parsedGraphs), "in segmentation format to", outSeg)
+		log.Println("Wrote", len(parsedGraphs), "in segmentation format to", opts.OutSeg)
 
 		log.Println("Writing to mapping file")
 	}
-	mapping.WriteFile(outMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	mapping.WriteFile(opts.OutMap, GetInstances(parsedGraphs, GetJointMDConfig))
+	if allOut {
+		log.Println("Wrote", len(parsedGraphs), "in mapping format to", opts.OutMap)
+	}
+	if opts.NBest > 0 {
+		if err := WriteNBest(opts.OutMap, nbests); err != nil {
+			return err
+		}
+	}
 	if allOut {
-		log.Println("Wrote", len(parsedGraphs), "in mapping format to", outMap)
-
 		log.Println("Writing to gold segmentation file")
 	}
 	return nil
@@ -835,10 +976,19 @@ runs morpho-syntactic training and parsing
 		Flag: *flag.NewFlagSet("joint", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.BoolVar(&LazyBeam, "lazy", false, "Optional - Lazy beam expansion: score only the legal transitions of a candidate and build those that can make the beam (parsing)")
+	cmd.Flag.StringVar(&BeamUpdate, "update", "early", "Optional - Beam training update strategy ["+search.UpdateStrategies+"]")
+	cmd.Flag.IntVar(&TrainWorkers, "workers", 1, "Optional - Number of parallel training workers (iterative parameter mixing)")
+	cmd.Flag.StringVar(&Learner, "learner", "perceptron", "Optional - Training update strategy ["+Learners+"]")
+	cmd.Flag.Float64Var(&LearnerC, "mirac", 1.0, "Optional - Aggressiveness (maximal step) of mira updates")
+	cmd.Flag.StringVar(&ClustersFile, "clusters", "", "Optional - Brown word clusters (paths format) of the c<bits> feature attributes")
+	cmd.Flag.StringVar(&EmbeddingClustersFile, "embclusters", "", "Optional - Word embeddings (word2vec/GloVe text format) clustered for the e feature attribute")
+	cmd.Flag.IntVar(&EmbeddingClusters, "embk", 100, "Optional - Number of embedding clusters")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Number of Perceptron Iterations")
 	cmd.Flag.IntVar(&BeamSize, "b", 64, "Beam Size")
 	cmd.Flag.StringVar(&JointModelFile, "m", "joint_arc_zeager_model_temp_i33.b64", "Joint model name")
-	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System [standard, eager]")
+	cmd.Flag.StringVar(&DepArcSystemStr, "a", "eager", "Optional - Arc System ["+ArcSystems+"]")
+	cmd.Flag.BoolVar(&PseudoProjective, "pp", false, "Optional - Pseudo-projective parsing: lift non-projective training arcs and reattach them in the output")
 
 	cmd.Flag.StringVar(&tConll, "tc", "", "Training Conll File")
 	cmd.Flag.StringVar(&tLatDis, "td", "", "Training Disambiguated Lattices File")
@@ -861,12 +1011,23 @@ runs morpho-syntactic training and parsing
 	cmd.Flag.BoolVar(&search.ShowFeats, "showfeats", false, "Show features of candidates in beam")
 	cmd.Flag.BoolVar(&MdCombineGold, "infusedev", false, "Infuse gold morphs into lattices for test corpus")
 	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD")
+	cmd.Flag.BoolVar(&MdUseWB, "wb", false, "Word Based MD (needs a model trained with -wb, none is shipped)")
 	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.BoolVar(&Lemmatize, "lemmatize", true, "Choose and fill the lemmas of the output morphemes")
+	cmd.Flag.BoolVar(&TagFeats, "tagfeats", false, "Train (with the model) and apply a gen/num/per tagger to underspecified output morphemes (OOVs, NNPs)")
+	cmd.Flag.StringVar(&FeatsTaggerFile, "tagfeatsf", "feats.tagger.yaml", "Feature Tagger Features Configuration File")
+	cmd.Flag.IntVar(&FeatsTaggerIters, "tagfeatsit", 4, "Minimum Number of Feature Tagger Perceptron Iterations")
+	cmd.Flag.BoolVar(&lattice.WRITE_TOKEN_RANGE, "tokenrange", false, "Add token character ranges to the output mapping (a column, TokenRange in the MISC of CoNLL-U)")
+	cmd.Flag.StringVar(&ConstraintsFile, "constraints", "", "Optional - Token constraints file (sentence, token, segmentation, pos, feats) restricting the analyses of the input")
+	cmd.Flag.IntVar(&NBest, "nbest", 0, "Also output the K best distinct disambiguations of each sentence with scores (at most the beam size; 0 = best only)")
+	cmd.Flag.StringVar(&outNBest, "onbest", "", "Optional - Output N-Best Mapping File (default {om}.nbest)")
 	cmd.Flag.BoolVar(&MdNoconverge, "noconverge", false, "don't test convergence (run -it number of iterations)")
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set (in thousands)")
 	cmd.Flag.IntVar(&limitdev, "limitdev", 0, "limit dev set (in thousands)")
-	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U-format input file (for disamb lattices)")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U-format input file (for disamb lattices) and UD-format ambiguous lattices")
 	cmd.Flag.BoolVar(&hebMACompat, "hebcompat", false, "Read CoNLLu with hebrew lexicon compatability")
+	cmd.Flag.BoolVar(&conllu.HEB2UD, "heb2ud", false, "Write CoNLL-U output (-oc) with POS and features converted from Hebrew to UD")
+	cmd.Flag.StringVar(&Heb2UDConvFile, "udconv", "", "Optional - Hebrew to UD conversion table (YAML, see conf/heb2ud.yaml) for -heb2ud")
 	// cmd.Flag.BoolVar(&AlignBeam, "align", false, "Use Beam Alignment")
 	// cmd.Flag.BoolVar(&AverageScores, "average", false, "Use Average Scoring")
 	// cmd.Flag.BoolVar(&alignAverageParseOnly, "parseonly", false, "Use Alignment & Average Scoring in parsing only")
@@ -79,6 +79,7 @@ func MA(cmd *commander.Command, args []string) error {
 	var (
 		sents        []nlp.BasicSentence
 		sentComments [][]string
+		tokenRanges  [][]nlp.TokenRange
 		oovVectors   []interface{}
 		rawOOV       interface{}
 		err          error
@@ -90,16 +91,18 @@ func MA(cmd *commander.Command, args []string) error {
 		}
 		sents = make([]nlp.BasicSentence, len(conllSents))
 		sentComments = make([][]string, len(conllSents))
+		tokenRanges = make([][]nlp.TokenRange, len(conllSents))
 		for i, sent := range conllSents {
 			newSent := make([]nlp.Token, len(sent.Tokens))
 			for j, token := range sent.Tokens {
 				newSent[j] = nlp.Token(token)
 			}
 			sentComments[i] = sent.Comments
+			tokenRanges[i] = sent.TokenRanges
 			sents[i] = newSent
 		}
 	} else {
-		sents, err = raw.ReadFile(inRawFile, limit)
+		sents, tokenRanges, err = raw.ReadFileWithRanges(inRawFile, limit)
 		sentComments = make([][]string, len(sents))
 		for i, sent := range sents {
 			sentComments[i] = []string{fmt.Sprintf("# text %s", strings.Join(sent.Tokens(), " ")) }
@@ -140,11 +143,13 @@ func MA(cmd *commander.Command, args []string) error {
 	for i, sent := range sents {
 		if streamOut {
 			lattices[0], rawOOV = maData.Analyze(sent.Tokens())
+			lattices[0].SetTokenRanges(tokenRanges[i])
 			output := lattice.Sentence2LatticeCorpus(lattices, nil)
 			lattice.UDWrite(outFile, output, sentComments[i:i+1], []nlp.BasicSentence{rawOOV.(nlp.BasicSentence)})
 			latticesWritten += 1
 		} else {
 			lattices[i], rawOOV = maData.Analyze(sent.Tokens())
+			lattices[i].SetTokenRanges(tokenRanges[i])
 			if oovVectors != nil {
 				oovVectors[i] = rawOOV
 			}
@@ -155,9 +160,9 @@ func MA(cmd *commander.Command, args []string) error {
 	if !streamOut {
 		output := lattice.Sentence2LatticeCorpus(lattices, nil)
 		if outFormat == "ud" {
-			if !outJSON {
-				// lattice.WriteUDJSONFile(outLatticeFile, output)
-				// } else {
+			if outJSON {
+				lattice.WriteUDJSONFile(outLatticeFile, output)
+			} else {
 				lattice.WriteUDFile(outLatticeFile, output, sentComments, nil)
 			}
 		} else if outFormat == "spmrl" {
@@ -185,6 +190,10 @@ func MACmd() *commander.Command {
 run data-driven morphological analyzer on raw input
 
 	$ ./yap ma -dict <dict file> [-udlex <udlex file>] -raw <raw file> [-format <sprml|ud>] -out <output file> [options]
+	$ ./yap ma -dict <dict file> -conllu <conllu file> -format ud -out <output file> [options]
+
+UD lattices (-format ud) can be used as ambiguous lattices for
+"./yap md -conllu" and "./yap joint -conllu".
 
 `,
 		Flag: *flag.NewFlagSet("ma", flag.ExitOnError),
@@ -200,5 +209,6 @@ run data-driven morphological analyzer on raw input
 	cmd.Flag.BoolVar(&dopeOOV, "dope", false, "Dope potential OOV tokens")
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
 	cmd.Flag.BoolVar(&outJSON, "json", false, "Output using JSON")
+	cmd.Flag.BoolVar(&lattice.WRITE_TOKEN_RANGE, "tokenrange", false, "Add token character ranges to lattices (a column in spmrl, TokenRange in the MISC of ud)")
 	return cmd
 }
@@ -0,0 +1,408 @@
+package app
+
+import (
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	"yap/nlp/format/raw"
+	"yap/nlp/parser/dependency/transition/morph"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+const (
+	MISS_PREFIX   = "missing prefix"
+	MISS_HOST     = "missing host"
+	MISS_FEATURES = "wrong features"
+	MISS_OTHER    = "other"
+)
+
+var (
+	maEvalParamFuncName string
+	maEvalMissFile      string
+)
+
+// LatticeRecall collects lattice recall statistics of ambiguous lattices
+// with respect to gold disambiguated lattices
+type LatticeRecall struct {
+	ParamFunc string
+
+	Sentences, Tokens, TokensFound int
+	GoldMorphs, MorphsFound        int
+	Paths                          int
+
+	MissByCause map[string]int
+	TokensByPOS map[string]int
+	MissByPOS   map[string]int
+
+	Misses []*LatticeMiss
+}
+
+// LatticeMiss is a gold token analysis that is not a path in its lattice
+type LatticeMiss struct {
+	Sentence, Token int
+	TokenStr        string
+	Gold            nlp.Spellout
+	HostPOS, Cause  string
+}
+
+func (m *LatticeMiss) String() string {
+	return fmt.Sprintf("%d\t%d\t%s\t%s\t%s\t%s", m.Sentence, m.Token, m.TokenStr, m.HostPOS, m.Cause, nlp.ProjectSpellout(m.Gold, nlp.Form_POS_Prop))
+}
+
+func NewLatticeRecall(paramFunc string) *LatticeRecall {
+	return &LatticeRecall{
+		ParamFunc:   paramFunc,
+		MissByCause: make(map[string]int, 4),
+		TokensByPOS: make(map[string]int, 50),
+		MissByPOS:   make(map[string]int, 50),
+		Misses:      make([]*LatticeMiss, 0, 100),
+	}
+}
+
+// goldHost returns the index of the host morpheme of a gold spellout,
+// the first open class morpheme or the last morpheme if there is none
+func goldHost(gold nlp.Spellout) int {
+	for i, m := range gold {
+		if _, exists := nlp.Main_POS[m.CPOS]; exists {
+			return i
+		}
+	}
+	return len(gold) - 1
+}
+
+func (r *LatticeRecall) AddSentence(sentNum int, amb, gold nlp.LatticeSentence) {
+	if len(amb) != len(gold) {
+		log.Println("Warning: sentence", sentNum, "has", len(amb), "ambiguous and", len(gold), "gold lattices, skipping")
+		return
+	}
+	r.Sentences++
+	for i := range amb {
+		r.AddToken(sentNum, i, &amb[i], &gold[i])
+	}
+}
+
+func (r *LatticeRecall) AddToken(sentNum, tokNum int, amb, gold *nlp.Lattice) {
+	gold.GenSpellouts()
+	if len(gold.Spellouts) == 0 {
+		return
+	}
+	goldSpellout := gold.Spellouts[0]
+	host := goldHost(goldSpellout)
+	hostPOS := goldSpellout[host].CPOS
+	r.Tokens++
+	r.TokensByPOS[hostPOS]++
+	r.GoldMorphs += len(goldSpellout)
+
+	var (
+		found, prefixFound, segPOSFound bool
+		bestTP, numPaths                int
+	)
+	goldPrefix := nlp.ProjectSpellout(goldSpellout[:host], nlp.Form_POS)
+	goldSegPOS := nlp.ProjectSpellout(goldSpellout, nlp.Form_POS)
+	for path := range amb.YieldPaths() {
+		spellout := amb.Path(int(path))
+		numPaths++
+		TP, TN, FP, _ := spellout.Compare(goldSpellout, r.ParamFunc)
+		if TP > bestTP {
+			bestTP = TP
+		}
+		if TN == 0 && FP == 0 {
+			found = true
+		}
+		if len(spellout) >= host && nlp.ProjectSpellout(spellout[:host], nlp.Form_POS) == goldPrefix {
+			prefixFound = true
+		}
+		if nlp.ProjectSpellout(spellout, nlp.Form_POS) == goldSegPOS {
+			segPOSFound = true
+		}
+	}
+	r.Paths += numPaths
+	r.MorphsFound += util.Min(bestTP, len(goldSpellout))
+	if found {
+		r.TokensFound++
+		return
+	}
+
+	var cause string
+	goldHostProj := nlp.Form_POS(goldSpellout[host])
+	hostFound := false
+	for _, m := range amb.Morphemes {
+		if nlp.Form_POS(m) == goldHostProj {
+			hostFound = true
+			break
+		}
+	}
+	switch {
+	case !prefixFound:
+		cause = MISS_PREFIX
+	case !hostFound:
+		cause = MISS_HOST
+	case segPOSFound:
+		cause = MISS_FEATURES
+	default:
+		cause = MISS_OTHER
+	}
+	r.MissByCause[cause]++
+	r.MissByPOS[hostPOS]++
+	tokenStr := string(amb.Token)
+	if len(tokenStr) == 0 {
+		// SPMRL lattices carry no token, use the gold surface forms
+		tokenStr = nlp.ProjectSpellout(goldSpellout, nlp.Form)
+	}
+	r.Misses = append(r.Misses, &LatticeMiss{sentNum, tokNum + 1, tokenStr, goldSpellout, hostPOS, cause})
+}
+
+// ratio is 0 for an empty denominator, so empty inputs report 0 and not NaN
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+func (r *LatticeRecall) TokenRecall() float64 {
+	return ratio(r.TokensFound, r.Tokens)
+}
+
+func (r *LatticeRecall) MorphRecall() float64 {
+	return ratio(r.MorphsFound, r.GoldMorphs)
+}
+
+func (r *LatticeRecall) AvgPaths() float64 {
+	return ratio(r.Paths, r.Tokens)
+}
+
+func (r *LatticeRecall) Report() {
+	log.Println("*** LATTICE RECALL ***")
+	log.Printf("Sentences:\t\t%d", r.Sentences)
+	log.Printf("Tokens:\t\t%d", r.Tokens)
+	log.Printf("Token Recall:\t\t%.4f (%d/%d)", r.TokenRecall(), r.TokensFound, r.Tokens)
+	log.Printf("Morpheme Recall:\t%.4f (%d/%d)", r.MorphRecall(), r.MorphsFound, r.GoldMorphs)
+	log.Printf("Avg. Paths/Token:\t%.4f", r.AvgPaths())
+	log.Println()
+	log.Println("Misses by cause:")
+	for _, cause := range []string{MISS_PREFIX, MISS_HOST, MISS_FEATURES, MISS_OTHER} {
+		log.Printf("\t%-16s\t%d", cause, r.MissByCause[cause])
+	}
+	log.Println()
+	log.Println("Misses by (host) POS:")
+	posList := make([]string, 0, len(r.TokensByPOS))
+	for pos := range r.TokensByPOS {
+		posList = append(posList, pos)
+	}
+	sort.Strings(posList)
+	for _, pos := range posList {
+		tokens, missed := r.TokensByPOS[pos], r.MissByPOS[pos]
+		log.Printf("\t%-16s\t%d/%d\trecall %.4f", pos, missed, tokens, ratio(tokens-missed, tokens))
+	}
+}
+
+func (r *LatticeRecall) WriteMisses(filename string) error {
+	file, err := os.Create(filename)
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+	for _, miss := range r.Misses {
+		fmt.Fprintln(file, miss.String())
+	}
+	return nil
+}
+
+func MAEvalConfigOut() {
+	log.Println("Configuration")
+	log.Printf("Parameter Func:\t%v", maEvalParamFuncName)
+	log.Printf("Use CoNLL-U:\t\t%v", useConllU)
+	log.Printf("Limit:\t\t%v", limit)
+	log.Println()
+	log.Println("Data")
+	if len(inRawFile) > 0 {
+		log.Printf("Raw file:\t\t%s", inRawFile)
+		if len(dictFile) > 0 {
+			log.Printf("MA Dict:\t\t%s", dictFile)
+		} else {
+			log.Printf("Heb Lexicon:\t\t%s", HebMaLexiconFile)
+			log.Printf("Heb Prefix:\t\t%s", HebMaPrefixFile)
+		}
+	} else {
+		log.Printf("Ambig.  lattice file:\t%s", tLatAmb)
+	}
+	log.Printf("Disamb. lattice file:\t%s", tLatDis)
+	if len(maEvalMissFile) > 0 {
+		log.Printf("Out (misses) file:\t%s", maEvalMissFile)
+	}
+	log.Println()
+}
+
+// analyzeRaw runs a morphological analyzer on a raw file, the resulting
+// lattices are normalized through the lattice format as if they were read
+// from an analyzer's output file
+func analyzeRaw() ([]interface{}, error) {
+	sents, err := raw.ReadFile(inRawFile, limit)
+	if err != nil {
+		return nil, err
+	}
+	var analyzer ma.MorphologicalAnalyzer
+	if len(dictFile) > 0 {
+		maData := new(ma.MADict)
+		if err := maData.ReadFile(dictFile); err != nil {
+			return nil, err
+		}
+		maData.ComputeOOVMSRs(maxOOVMSRPerPOS)
+		maData.Init()
+		analyzer = maData
+	} else {
+		format := "spmrl"
+		if useConllU {
+			format = "ud"
+		}
+		for _, f := range []*string{&HebMaPrefixFile, &HebMaLexiconFile} {
+			if location, found := util.LocateFile(*f, HEB_MA_DEFAULT_DATA_DIRS); found {
+				*f = location
+			}
+		}
+		analyzer = LoadHebMA(format)
+	}
+	lattices := make([]nlp.LatticeSentence, len(sents))
+	for i, sent := range sents {
+		lattices[i], _ = analyzer.Analyze(sent.Tokens())
+	}
+	asLattices := lattice.Sentence2LatticeCorpus(lattices, nil)
+	return lattice.Lattice2SentenceCorpus(asLattices, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix), nil
+}
+
+func MAEval(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"d"}
+	if len(inRawFile) == 0 {
+		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "l")
+	}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+	if _, exists := nlp.MDParams[maEvalParamFuncName]; !exists {
+		log.Fatalln("Param Func", maEvalParamFuncName, "does not exist")
+	}
+
+	MAEvalConfigOut()
+
+	EWord, EPOS, EWPOS = util.NewEnumSet(APPROX_WORDS), util.NewEnumSet(APPROX_POS), util.NewEnumSet(APPROX_WORDS*5)
+	EMHost, EMSuffix = util.NewEnumSet(APPROX_MHOSTS), util.NewEnumSet(APPROX_MSUFFIXES)
+	EMorphProp = util.NewEnumSet(130)
+	ERel = util.NewEnumSet(50)
+	if useConllU {
+		nlp.InitOpenParamFamily("UD")
+	} else {
+		nlp.InitOpenParamFamily("HEBTB")
+	}
+
+	var (
+		ambLats, goldLats []interface{}
+		err               error
+	)
+	if len(inRawFile) > 0 {
+		log.Println("Analyzing raw input", inRawFile)
+		if ambLats, err = analyzeRaw(); err != nil {
+			log.Println(err)
+			return err
+		}
+	} else {
+		var lAmb []lattice.Lattice
+		if useConllU {
+			lAmb, err = lattice.ReadULFile(tLatAmb, limit)
+		} else {
+			lAmb, err = lattice.ReadFile(tLatAmb, limit)
+		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		ambLats = lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	}
+	log.Println("Amb. Lat:\tRead", len(ambLats), "ambiguous lattices")
+
+	if useConllU {
+		s, _, e := conllu.ReadFile(tLatDis, limit)
+		if e != nil {
+			log.Println(e)
+			return e
+		}
+		asGraph := conllu.ConllU2MorphGraphCorpus(s, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+		goldLats = make([]interface{}, len(asGraph))
+		for i, sent := range asGraph {
+			goldLats[i] = sent.(*morph.BasicMorphGraph).Lattice
+		}
+	} else {
+		lDis, e := lattice.ReadFile(tLatDis, limit)
+		if e != nil {
+			log.Println(e)
+			return e
+		}
+		goldLats = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	}
+	log.Println("Dis. Lat.:\tRead", len(goldLats), "disambiguated lattices")
+	if len(ambLats) != len(goldLats) {
+		log.Println("Warning: number of ambiguous and gold sentences differ, evaluating the first", util.Min(len(ambLats), len(goldLats)))
+	}
+	log.Println()
+
+	recall := NewLatticeRecall(maEvalParamFuncName)
+	for i := 0; i < util.Min(len(ambLats), len(goldLats)); i++ {
+		recall.AddSentence(i+1, ambLats[i].(nlp.LatticeSentence), goldLats[i].(nlp.LatticeSentence))
+	}
+	recall.Report()
+	if len(maEvalMissFile) > 0 {
+		if err := recall.WriteMisses(maEvalMissFile); err != nil {
+			log.Println("Failed writing misses", err)
+			return err
+		}
+		log.Println()
+		log.Println("Wrote", len(recall.Misses), "misses to", maEvalMissFile)
+	}
+	return nil
+}
+
+func MAEvalCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       MAEval,
+		UsageLine: "ma-eval <file options> [arguments]",
+		Short:     "evaluate lattice recall of morphological analysis against gold lattices",
+		Long: `
+evaluate lattice recall of morphological analysis against gold lattices
+
+	$ ./yap ma-eval -l <amb. lat> -d <disamb. lat> [-p <param func>] [-o <misses file>] [options]
+	$ ./yap ma-eval -raw <raw file> [-dict <dict file>] -d <disamb. lat> [options]
+	$ ./yap ma-eval -conllu -l <UD amb. lat> -d <gold conllu> [options]
+
+Reports the share of gold tokens (and morphemes) found in the ambiguous
+lattices, the average number of paths per token, and a breakdown of the
+missed tokens by the POS of their host morpheme and by cause:
+
+	` + MISS_PREFIX + `	no path has the gold prefix morphemes (form and POS)
+	` + MISS_HOST + `	the gold host morpheme (form and POS) is not in the lattice
+	` + MISS_FEATURES + `	a path has the gold segmentation and POS but differs in features
+	` + MISS_OTHER + `		any other mismatch (e.g. suffixes)
+
+With -raw the input is analyzed with the hebma lexicon, or with a
+data-driven dictionary if -dict is set.
+`,
+		Flag: *flag.NewFlagSet("ma-eval", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&tLatAmb, "l", "", "Ambiguous Lattices File")
+	cmd.Flag.StringVar(&tLatDis, "d", "", "Disambiguated (Gold) Lattices File")
+	cmd.Flag.StringVar(&inRawFile, "raw", "", "Input raw (tokenized) file, analyzed instead of -l")
+	cmd.Flag.StringVar(&dictFile, "dict", "", "Data-driven dictionary for analyzing -raw (default is the hebma lexicon)")
+	cmd.Flag.StringVar(&HebMaPrefixFile, "prefix", "bgupreflex_withdef.utf8.hr", "Prefix file for morphological analyzer")
+	cmd.Flag.StringVar(&HebMaLexiconFile, "lexicon", "bgulex.utf8.hr", "Lexicon file for morphological analyzer")
+	cmd.Flag.StringVar(&maEvalParamFuncName, "p", "Form_POS_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.StringVar(&maEvalMissFile, "o", "", "Optional - Output file of missed tokens")
+	cmd.Flag.BoolVar(&useConllU, "conllu", false, "use CoNLL-U gold file and UD-format ambiguous lattices")
+	cmd.Flag.IntVar(&limit, "limit", 0, "limit number of sentences")
+	return cmd
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+)
+
+// readTestLattice reads a single sentence from lattice format rows
+func readTestLattice(t *testing.T, rows ...string) nlp.LatticeSentence {
+	lats, err := lattice.Read(strings.NewReader(strings.Join(rows, "\n")+"\n\n"), 0)
+	if err != nil {
+		t.Fatalf("Failed reading lattice: %v", err)
+	}
+	if len(lats) != 1 {
+		t.Fatalf("Expected 1 lattice, got %d", len(lats))
+	}
+	return lattice.Lattice2Sentence(lats[0], EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+}
+
+func TestLatticeRecallEmpty(t *testing.T) {
+	r := NewLatticeRecall("Funcs_Main_POS_Both_Prop")
+	if r.TokenRecall() != 0 || r.MorphRecall() != 0 || r.AvgPaths() != 0 {
+		t.Errorf("Expected 0 ratios of an empty input, got %v %v %v", r.TokenRecall(), r.MorphRecall(), r.AvgPaths())
+	}
+}
+
+func TestLatticeRecall(t *testing.T) {
+	SetupMDEnum()
+	amb := readTestLattice(t,
+		"0	1	ב	ב	PREPOSITION	PREPOSITION	_	1",
+		"1	2	בית	בית	NN	NN	gen=M|num=S	1",
+		"0	2	בבית	בבית	NNP	NNP	_	1",
+		"2	3	גדול	גדול	JJ	JJ	gen=M|num=S	2",
+	)
+	gold := readTestLattice(t,
+		"0	1	ב	ב	PREPOSITION	PREPOSITION	_	1",
+		"1	2	בית	בית	NN	NN	gen=M|num=S	1",
+		"2	3	גדולה	גדול	JJ	JJ	gen=F|num=S	2",
+	)
+	r := NewLatticeRecall("Funcs_Main_POS_Both_Prop")
+	r.AddSentence(1, amb, gold)
+	if r.Tokens != 2 || r.TokensFound != 1 || r.GoldMorphs != 3 || r.Paths != 3 {
+		t.Errorf("Expected 2 tokens, 1 found, 3 gold morphemes and 3 paths, got %d %d %d %d", r.Tokens, r.TokensFound, r.GoldMorphs, r.Paths)
+	}
+	if r.TokenRecall() != 0.5 || r.AvgPaths() != 1.5 {
+		t.Errorf("Expected token recall 0.5 and 1.5 paths per token, got %v %v", r.TokenRecall(), r.AvgPaths())
+	}
+	if len(r.Misses) != 1 || r.Misses[0].Token != 2 || r.Misses[0].Cause != MISS_HOST {
+		t.Errorf("Expected the second token to miss its host, got %v", r.Misses)
+	}
+}
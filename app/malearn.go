@@ -19,6 +19,7 @@ var (
 	latFile, rawFile, conlluFile, dataFile string
 	useConllU                              bool // TODO: whatever i don't care anymore
 	maxPOS, maxMSRPerPOS                   int
+	maLanguage                             string
 )
 
 func MALearnConfigOut() {
@@ -29,6 +30,7 @@ func MALearnConfigOut() {
 		log.Printf("Lattice:\t%s", latFile)
 		log.Printf("Raw:\t\t%s", rawFile)
 	}
+	log.Printf("Language:\t%s", maLanguage)
 	log.Printf("Limit:\t%v", limit)
 	log.Println()
 	log.Printf("Output:\t%s", dataFile)
@@ -50,7 +52,7 @@ func MALearn(cmd *commander.Command, args []string) error {
 	MALearnConfigOut()
 	log.Println("Starting learning for data-driven morphological analyzer")
 	maData := new(ma.MADict)
-	maData.Language = "Test"
+	maData.Language = maLanguage
 	maData.MaxTopPOS = maxPOS
 	maData.MaxMSRsPerPOS = maxMSRPerPOS
 	var (
@@ -67,7 +69,11 @@ func MALearn(cmd *commander.Command, args []string) error {
 		return err
 	}
 	log.Println("Learned", numLearned, "new tokens")
-	maData.WriteFile(dataFile)
+	if err = maData.WriteFile(dataFile); err != nil {
+		log.Println("Got error writing dictionary", err)
+		return err
+	}
+	log.Println("Wrote dictionary to", dataFile)
 	return nil
 }
 
@@ -79,7 +85,11 @@ func MALearnCmd() *commander.Command {
 		Long: `
 generate a data-driven morphological analysis dictionary for a set of files
 
-	$ ./yap malearn -lattice <lattice file> -raw <raw file> [options]
+	$ ./yap malearn -lattice <lattice file> -raw <raw file> -out <dict file> [options]
+	$ ./yap malearn -conllu <conllu file> -out <dict file> [options]
+
+The dictionary is written as JSON, see ma.MADict for the format.
+Use the dictionary with "./yap ma -dict <dict file>".
 
 `,
 		Flag: *flag.NewFlagSet("malearn", flag.ExitOnError),
@@ -88,6 +98,7 @@ generate a data-driven morphological analysis dictionary for a set of files
 	cmd.Flag.StringVar(&rawFile, "raw", "", "raw sentences input file")
 	cmd.Flag.StringVar(&conlluFile, "conllu", "", "CoNLL-U-format input file")
 	cmd.Flag.StringVar(&dataFile, "out", "", "output file")
+	cmd.Flag.StringVar(&maLanguage, "lang", "", "Language of the dictionary (informational)")
 	cmd.Flag.IntVar(&maxMSRPerPOS, "maxmsrperpos", 5, "For OOV tokens, max MSRs per POS to add")
 	cmd.Flag.IntVar(&maxPOS, "maxpos", 5, "For OOV tokens, max POS to add")
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
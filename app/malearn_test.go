@@ -0,0 +1,105 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/dependency/transition/morph"
+	"yap/nlp/parser/ma"
+	nlp "yap/nlp/types"
+)
+
+// TestMALearnRoundTrip follows malearn, ma -dict -format ud and the reading
+// of the training files by joint -conllu
+func TestMALearnRoundTrip(t *testing.T) {
+	const trainFile = "testdata/malearn.conllu"
+	defer func(ignoreLemma bool) { conllu.IGNORE_LEMMA = ignoreLemma }(conllu.IGNORE_LEMMA)
+	dir, err := ioutil.TempDir("", "yapmalearn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// malearn
+	learned := new(ma.MADict)
+	learned.MaxTopPOS, learned.MaxMSRsPerPOS = 5, 5
+	if _, err := learned.LearnFromConllU(trainFile, 0); err != nil {
+		t.Fatal(err)
+	}
+	dictFile := filepath.Join(dir, "dict.json")
+	if err := learned.WriteFile(dictFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// ma -dict
+	dict := new(ma.MADict)
+	if err := dict.ReadFile(dictFile); err != nil {
+		t.Fatal(err)
+	}
+	dict.ComputeOOVMSRs(5)
+	dict.Init()
+	sents, _, err := conllu.ReadFile(trainFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latFile := filepath.Join(dir, "train.lattices")
+	out, err := os.Create(latFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sent := range sents {
+		analyzed, oov := dict.Analyze(sent.Tokens)
+		analyzed.SetTokenRanges(sent.TokenRanges)
+		output := lattice.Sentence2LatticeCorpus([]nlp.LatticeSentence{analyzed}, nil)
+		if err := lattice.UDWrite(out, output, [][]string{sent.Comments}, []nlp.BasicSentence{oov.(nlp.BasicSentence)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Close()
+
+	// joint -conllu, lemmas are ignored by default
+	SetupEnum([]string{"root", "case", "det", "nmod"})
+	conllu.IGNORE_LEMMA = true
+	nlp.InitOpenParamFamily("UD")
+	goldSents, _, err := conllu.ReadFile(trainFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goldConll := conllu.ConllU2MorphGraphCorpus(goldSents, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+	goldDisLat := make([]interface{}, len(goldConll))
+	for i, sent := range goldConll {
+		goldDisLat[i] = sent.(*morph.BasicMorphGraph).Lattice
+	}
+	lAmb, err := ReadULLattices(latFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lAmb) != len(sents) {
+		t.Fatalf("Expected %d ambiguous lattices, got %d", len(sents), len(lAmb))
+	}
+	goldAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+
+	// the multiword token is a single path of its two morphemes
+	multiword := goldAmbLat[0].(nlp.LatticeSentence)[1]
+	multiword.GenSpellouts()
+	if multiword.Token != "inthe" || len(multiword.Spellouts) != 1 || len(multiword.Spellouts[0]) != 2 {
+		t.Fatalf("Expected the single 2 morpheme path of inthe, got %s: %v", multiword.Token, multiword.Spellouts)
+	}
+	if forms := multiword.Spellouts[0][0].Form + " " + multiword.Spellouts[0][1].Form; forms != "in the" {
+		t.Errorf("Expected inthe to be analyzed as in the, got %s", forms)
+	}
+
+	combined, missingGold := CombineJointCorpus(goldConll, goldDisLat, goldAmbLat)
+	if missingGold != 0 {
+		t.Errorf("Expected all gold paths in the lattices, got %d missing", missingGold)
+	}
+	for i, graph := range combined {
+		if graph == nil {
+			t.Errorf("Expected graph %d to combine, got nil", i)
+		}
+	}
+}
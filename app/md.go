@@ -6,6 +6,7 @@ import (
 	"yap/alg/transition"
 	transitionmodel "yap/alg/transition/model"
 	"yap/nlp/format/conllu"
+	"yap/nlp/format/constraint"
 	"yap/nlp/format/lattice"
 	"yap/nlp/format/mapping"
 
@@ -18,6 +19,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 
 	"github.com/gonuts/commander"
 	"github.com/gonuts/flag"
@@ -29,12 +31,63 @@ var (
 	MdUseWB          bool
 	MdCombineGold    bool
 	MdNoconverge     bool
+	ConstraintsFile  string
 	MdModelName    string
 	MdModelFile    string
 	MdFeaturesFile string
 	//MdBeamSize     int
 )
 
+// MDOptions are the files and settings of md training and parsing, the md
+// command sets them from its flags
+type MDOptions struct {
+	TrainDis, TrainAmb string // training disambiguated and ambiguous lattices
+	Input, InputGold   string // dev-test ambiguous and gold lattices
+	Test, TestGold     string // test ambiguous and gold lattices
+	OutMap             string
+	FeaturesFile       string
+	ParamFuncName      string
+	ModelPrefix        string // trained models are written to {prefix}.b{beam}
+	Iterations         int
+	BeamSize           int
+	UsePOP             bool
+	WordBased          bool
+	UseConllU          bool
+	CombineGold        bool // infuse the gold dev-test paths into its lattices
+	NoConverge         bool
+	Limit              int
+	Stream             bool
+	NBest              int
+	ConstraintsFile    string
+}
+
+// MDFlagOptions returns the md options set by the md flags
+func MDFlagOptions() *MDOptions {
+	return &MDOptions{
+		TrainDis:        tLatDis,
+		TrainAmb:        tLatAmb,
+		Input:           input,
+		InputGold:       inputGold,
+		Test:            test,
+		TestGold:        testGold,
+		OutMap:          outMap,
+		FeaturesFile:    MdFeaturesFile,
+		ParamFuncName:   MdParamFuncName,
+		ModelPrefix:     MdModelFile,
+		Iterations:      Iterations,
+		BeamSize:        BeamSize,
+		UsePOP:          UsePOP,
+		WordBased:       MdUseWB,
+		UseConllU:       useConllU,
+		CombineGold:     MdCombineGold,
+		NoConverge:      MdNoconverge,
+		Limit:           limit,
+		Stream:          Stream,
+		NBest:           NBest,
+		ConstraintsFile: ConstraintsFile,
+	}
+}
+
 func SetupMDEnum() {
 	EWord, EPOS, EWPOS = util.NewEnumSet(APPROX_WORDS), util.NewEnumSet(APPROX_POS), util.NewEnumSet(APPROX_WORDS*5)
 	EMHost, EMSuffix = util.NewEnumSet(APPROX_MHOSTS), util.NewEnumSet(APPROX_MSUFFIXES)
@@ -49,6 +102,40 @@ func SetupMDEnum() {
 	ETokens = util.NewEnumSet(10000)
 }
 
+// NewMDTransitionSystem returns the morpheme-based MD transition system, or
+// the word-based one if MdUseWB is set
+func NewMDTransitionSystem(paramFunc nlp.MDParam) transition.TransitionSystem {
+	return newMDTransitionSystem(paramFunc, MdUseWB, UsePOP)
+}
+
+func newMDTransitionSystem(paramFunc nlp.MDParam, wordBased, usePOP bool) transition.TransitionSystem {
+	if wordBased {
+		return &disambig.MDWBTrans{
+			ParamFunc: paramFunc,
+			UsePOP:    usePOP,
+		}
+	}
+	return &disambig.MDTrans{
+		ParamFunc: paramFunc,
+		UsePOP:    usePOP,
+	}
+}
+
+// SetMDTransitions sets the transition enumeration and POP transition of an
+// MD transition system created by NewMDTransitionSystem
+func SetMDTransitions(mdTrans transition.TransitionSystem) {
+	switch t := mdTrans.(type) {
+	case *disambig.MDTrans:
+		t.Transitions = ETrans
+		t.POP = POP
+	case *disambig.MDWBTrans:
+		t.Transitions = ETrans
+		t.POP = POP
+	default:
+		panic(fmt.Sprintf("Unknown MD transition system %T", mdTrans))
+	}
+}
+
 func CombineToGoldMorph(goldLat, ambLat nlp.LatticeSentence) (m *disambig.MDConfig, spelloutsAdded int) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -135,6 +222,46 @@ func CombineLatticesCorpus(goldLats, ambLats []interface{}) ([]interface{}, int,
 	return configs, numLatticeNoGold, totalLattices, numSentNoGold
 }
 
+// ConstrainLattices restricts the ambiguous lattices to the analyses allowed
+// by the token constraints, returning an error for each constraint that could
+// not be applied (e.g. one that no analysis of its token matches)
+func ConstrainLattices(ambLats []interface{}, constraints []nlp.TokenConstraint) []error {
+	var errs []error
+	bySentence := constraint.BySentence(constraints)
+	sentIndices := make([]int, 0, len(bySentence))
+	for sentIndex := range bySentence {
+		sentIndices = append(sentIndices, sentIndex)
+	}
+	sort.Ints(sentIndices)
+	for _, sentIndex := range sentIndices {
+		sentConstraints := bySentence[sentIndex]
+		if sentIndex >= len(ambLats) {
+			for _, sentConstraint := range sentConstraints {
+				errs = append(errs, fmt.Errorf("Constraint sentence out of range (%d sentences): %v", len(ambLats), sentConstraint))
+			}
+			continue
+		}
+		errs = append(errs, ambLats[sentIndex].(nlp.LatticeSentence).Constrain(sentConstraints)...)
+	}
+	return errs
+}
+
+// LoadConstraints reads the constraints file and applies it to the
+// ambiguous lattices
+func LoadConstraints(filename string, ambLats []interface{}) {
+	constraints, err := constraint.ReadFile(filename)
+	if err != nil {
+		log.Fatalln("Failed reading constraints file", filename, "-", err)
+	}
+	errs := ConstrainLattices(ambLats, constraints)
+	for _, err := range errs {
+		log.Println("Constraints:", err)
+	}
+	if allOut {
+		log.Println("Applied", len(constraints)-len(errs), "of", len(constraints), "token constraints from", filename)
+	}
+}
+
 func conllul2Lattices(cls []conllul.ConlluLattice) ([]lattice.Lattice) {
 	result := []lattice.Lattice{}
 	for _, cl := range cls {
@@ -171,98 +298,102 @@ func conllul2Lattice(cl conllul.ConlluLattice) (lattice.Lattice) {
 }
 
 
-func MDConfigOut(outModelFile string, b search.Interface, t transition.TransitionSystem) {
+func MDConfigOut(opts *MDOptions, outModelFile string, b search.Interface, t transition.TransitionSystem) {
 	log.Println("Configuration")
 	log.Printf("Beam:\t\t%s", b.Name())
 	log.Printf("Transition System:\t%s", t.Name())
-	log.Printf("Iterations:\t\t%d", Iterations)
-	log.Printf("Beam Size:\t\t%d", BeamSize)
+	log.Printf("Iterations:\t\t%d", opts.Iterations)
+	log.Printf("Beam Size:\t\t%d", opts.BeamSize)
 	log.Printf("Beam Concurrent:\t%v", ConcurrentBeam)
-	log.Printf("Parameter Func:\t%v", MdParamFuncName)
-	log.Printf("Use POP:\t\t%v", UsePOP)
-	log.Printf("Infuse Gold Dev:\t%v", MdCombineGold)
+	log.Printf("Beam Lazy:\t\t%v", LazyBeam)
+	log.Printf("Beam Update:\t\t%s", BeamUpdate)
+	log.Printf("Train Workers:\t\t%d", TrainWorkers)
+	if len(ClustersFile) > 0 {
+		log.Printf("Word clusters:\t\t%s", ClustersFile)
+	}
+	if len(EmbeddingClustersFile) > 0 {
+		log.Printf("Embedding clusters:\t%s (%d)", EmbeddingClustersFile, EmbeddingClusters)
+	}
+	log.Printf("Learner:\t\t%s", Learner)
+	if Learner == "mira" {
+		log.Printf("Learner C:\t\t%v", LearnerC)
+	}
+	log.Printf("Parameter Func:\t%v", opts.ParamFuncName)
+	log.Printf("Use POP:\t\t%v", opts.UsePOP)
+	log.Printf("Infuse Gold Dev:\t%v", opts.CombineGold)
 	log.Printf("Use Lemmas:\t\t%v", !lattice.IGNORE_LEMMA)
-	log.Printf("Use CoNLL-U:\t\t%v", useConllU)
+	log.Printf("Lemmatize:\t\t%v", Lemmatize)
+	log.Printf("Tag Features:\t\t%v", TagFeats)
+	log.Printf("Use CoNLL-U:\t\t%v", opts.UseConllU)
 	log.Printf("No NNP Feat:\t\t%v", lattice.IGNORE_NNP_FEATS)
-	log.Printf("Limit:\t\t%v", limit)
+	log.Printf("Limit:\t\t%v", opts.Limit)
+	if len(opts.ConstraintsFile) > 0 {
+		log.Printf("Constraints:\t\t%s", opts.ConstraintsFile)
+	}
+	if opts.NBest > 0 {
+		log.Printf("N-Best:\t\t%v (%s)", opts.NBest, NBestFile(opts.OutMap))
+	}
 	if len(outModelFile) > 0 {
 		log.Printf("Model file:\t\t%s", outModelFile)
 	}
 
 	log.Println()
-	log.Printf("Features File:\t%s", MdFeaturesFile)
-	if !VerifyExists(MdFeaturesFile) {
+	log.Printf("Features File:\t%s", opts.FeaturesFile)
+	if !VerifyExists(opts.FeaturesFile) {
 		os.Exit(1)
 	}
 	log.Println()
 	log.Println("Data")
-	if len(tLatDis) > 0 {
-		log.Printf("Train file (disamb. lattice):\t%s", tLatDis)
-		if !VerifyExists(tLatDis) {
+	if len(opts.TrainDis) > 0 {
+		log.Printf("Train file (disamb. lattice):\t%s", opts.TrainDis)
+		if !VerifyExists(opts.TrainDis) {
 			return
 		}
 	}
-	if len(tLatAmb) > 0 {
-		log.Printf("Train file (ambig.  lattice):\t%s", tLatAmb)
-		if !VerifyExists(tLatAmb) {
+	if len(opts.TrainAmb) > 0 {
+		log.Printf("Train file (ambig.  lattice):\t%s", opts.TrainAmb)
+		if !VerifyExists(opts.TrainAmb) {
 			return
 		}
 	}
-	if len(input) > 0 {
-		log.Printf("Test file  (ambig.  lattice):\t%s", input)
-		if !VerifyExists(input) {
+	if len(opts.Input) > 0 {
+		log.Printf("Test file  (ambig.  lattice):\t%s", opts.Input)
+		if !VerifyExists(opts.Input) {
 			return
 		}
 	}
-	if len(inputGold) > 0 {
-		log.Printf("Test file  (disambig.  lattice):\t%s", inputGold)
-		if !VerifyExists(inputGold) {
+	if len(opts.InputGold) > 0 {
+		log.Printf("Test file  (disambig.  lattice):\t%s", opts.InputGold)
+		if !VerifyExists(opts.InputGold) {
 			return
 		}
 	}
-	if len(outMap) > 0 {
-		log.Printf("Out (disamb.) file:\t\t\t%s", outMap)
+	if len(opts.OutMap) > 0 {
+		log.Printf("Out (disamb.) file:\t\t\t%s", opts.OutMap)
 	}
 }
 
 func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	//BeamSize = MdBeamSize
-	paramFunc, exists := nlp.MDParams[MdParamFuncName]
-	if !exists {
-		log.Fatalln("Param Func", MdParamFuncName, "does not exist")
-	}
-	var (
-		mdTrans transition.TransitionSystem
-		model   *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
-	)
-	if MdUseWB {
-		mdTrans = &disambig.MDWBTrans{
-			ParamFunc: paramFunc,
-			UsePOP:    UsePOP,
-		}
-	} else {
-		mdTrans = &disambig.MDTrans{
-			ParamFunc: paramFunc,
-			UsePOP:    UsePOP,
-		}
-	}
-	disambig.UsePOP = UsePOP
-
-	// arcSystem := &morph.Idle{morphArcSystem, IDLE}
-	transitionSystem := transition.TransitionSystem(mdTrans)
-
+	opts := MDFlagOptions()
 	REQUIRED_FLAGS := []string{"in", "om"}
 
-	featuresLocation, found := util.LocateFile(MdFeaturesFile, DEFAULT_CONF_DIRS)
+	featuresLocation, found := util.LocateFile(opts.FeaturesFile, DEFAULT_CONF_DIRS)
 	if found {
-		MdFeaturesFile = featuresLocation
+		opts.FeaturesFile = featuresLocation
 	} else {
 		REQUIRED_FLAGS = append(REQUIRED_FLAGS, "f")
 	}
 	VerifyFlags(cmd, REQUIRED_FLAGS)
+	if opts.NBest > 0 && opts.Stream {
+		log.Fatalln("N-best output (-nbest) is not supported when streaming (-stream)")
+	}
+	if len(opts.ConstraintsFile) > 0 && opts.Stream {
+		log.Fatalln("Constraints (-constraints) are not supported when streaming (-stream)")
+	}
 
 	var (
-		outModelFile string = fmt.Sprintf("%s.b%d", MdModelFile, BeamSize)
+		outModelFile string = fmt.Sprintf("%s.b%d", opts.ModelPrefix, opts.BeamSize)
 		modelExists  bool
 	)
 	// search for model file locally or in data/ path
@@ -289,7 +420,45 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		confBeam.Averaged = AverageScores
 	}
 
-	MDConfigOut(outModelFile, confBeam, transitionSystem)
+	var serialization *Serialization
+	if modelExists {
+		if allOut {
+			log.Println("Found model file", outModelFile, " ... loading model")
+		}
+		serialization = ReadModel(outModelFile)
+		serialization.Config.Apply()
+	}
+	md, err := SetupMD(opts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	MDConfigOut(opts, outModelFile, confBeam, md.Trans)
+	if !modelExists {
+		return MDTrain(md, outModelFile)
+	}
+	return MDParse(md, serialization)
+}
+
+// MDSetup holds what training and parsing a disambiguator share: its
+// options, transition system, features and word clusters
+type MDSetup struct {
+	Options      *MDOptions
+	ParamFunc    nlp.MDParam
+	Trans        transition.TransitionSystem
+	FeatureSetup *transition.FeatureSetup
+	Extractor    *transition.GenericExtractor
+	Clusters     *nlp.WordClusters
+}
+
+// SetupMD sets up the enumerations, transitions and features of md options
+func SetupMD(opts *MDOptions) (*MDSetup, error) {
+	paramFunc, exists := nlp.MDParams[opts.ParamFuncName]
+	if !exists {
+		return nil, fmt.Errorf("Param Func %s does not exist", opts.ParamFuncName)
+	}
+	mdTrans := newMDTransitionSystem(paramFunc, opts.WordBased, opts.UsePOP)
+	disambig.UsePOP = opts.UsePOP
+	clusters := LoadWordClusters()
 
 	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
 	if allOut {
@@ -298,397 +467,424 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		log.Println("Setup enumerations")
 	}
 	SetupMDEnum()
-	if MdUseWB {
-		mdTrans.(*disambig.MDWBTrans).POP = POP
-		mdTrans.(*disambig.MDWBTrans).Transitions = ETrans
-	} else {
-		mdTrans.(*disambig.MDTrans).POP = POP
-		mdTrans.(*disambig.MDTrans).Transitions = ETrans
-	}
+	SetMDTransitions(mdTrans)
 	mdTrans.AddDefaultOracle()
 	if allOut {
 		log.Println()
 		log.Println("Loading features")
 	}
-	featureSetup, err := transition.LoadFeatureConfFile(MdFeaturesFile)
+	featureSetup, err := transition.LoadFeatureConfFile(opts.FeaturesFile)
 	if err != nil {
-		log.Println("Failed reading feature configuration file:", MdFeaturesFile)
-		log.Fatalln(err)
+		return nil, fmt.Errorf("Failed reading feature configuration file %s: %v", opts.FeaturesFile, err)
 	}
 	extractor := SetupExtractor(featureSetup, []byte("MPL"))
 
 	log.Println()
-	if useConllU {
+	if opts.UseConllU {
 		nlp.InitOpenParamFamily("UD")
 	} else {
 		nlp.InitOpenParamFamily("HEBTB")
 	}
 	log.Println()
+	return &MDSetup{
+		Options:      opts,
+		ParamFunc:    paramFunc,
+		Trans:        mdTrans,
+		FeatureSetup: featureSetup,
+		Extractor:    extractor,
+		Clusters:     clusters,
+	}, nil
+}
 
-	if !modelExists {
+// MDTrain trains a disambiguator on the training lattices of its options and
+// writes it to outModelFile
+func MDTrain(md *MDSetup, outModelFile string) error {
+	var (
+		opts             = md.Options
+		paramFunc        = md.ParamFunc
+		transitionSystem = md.Trans
+		extractor        = md.Extractor
+		clusters         = md.Clusters
+		model            *transitionmodel.AvgMatrixSparse
+	)
+	if allOut {
+		log.Println("Generating Gold Sequences For Training")
+	}
+
+	const NUM_SENTS = 10
+	var goldDisLat, goldAmbLat []interface{}
+	if opts.UseConllU {
+		conllu.IGNORE_LEMMA = lattice.IGNORE_LEMMA
 		if allOut {
-			log.Println("Generating Gold Sequences For Training")
+			log.Println("Dis. Lat.:\tReading training disambiguated lattices from (conllU)", opts.TrainDis)
 		}
-
-		const NUM_SENTS = 10
-		var goldDisLat, goldAmbLat []interface{}
-		if useConllU {
-			conllu.IGNORE_LEMMA = lattice.IGNORE_LEMMA
-			if allOut {
-				log.Println("Dis. Lat.:\tReading training disambiguated lattices from (conllU)", tLatDis)
+		conllus, hasSegmentation, err := conllu.ReadFile(opts.TrainDis, opts.Limit)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if allOut {
+			if hasSegmentation {
+				log.Println("Dis. Lat.:\tRead", len(conllus), "disambiguated lattices (conllU) WITH SEGMENTATION")
+			} else {
+				log.Println("Dis. Lat.:\tRead", len(conllus), "disambiguated lattices (conllU) WITHOUT SEGMENTATION")
 			}
-			conllus, hasSegmentation, err := conllu.ReadFile(tLatDis, limit)
+			log.Println("Dis. Lat.:\tConverting lattice format to internal structure")
+		}
+		ERel = util.NewEnumSet(100)
+		morphGraphs := conllu.ConllU2MorphGraphCorpus(conllus, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+		goldDisLat = make([]interface{}, len(morphGraphs))
+		for i, val := range morphGraphs {
+			basicMorphGraph := val.(*morph.BasicMorphGraph)
+			goldDisLat[i] = basicMorphGraph.Lattice
+		}
+		if allOut {
+			log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", opts.TrainAmb)
+		}
+		//lAmb, lAmbE := lattice.ReadUDFile(tLatAmb, limit)
+		lAmb, lAmbE := ReadULLattices(opts.TrainAmb, opts.Limit)
+		if lAmbE != nil {
+			log.Println(lAmbE)
+			return lAmbE
+		}
+		//clAmb, clAmbE := conllul.ReadFile(tLatAmb, limit)
+		//if clAmbE != nil {
+		//	log.Println(clAmbE)
+		//	return clAmbE
+		//}
+		//lAmb := conllul2Lattices(clAmb)
+		if allOut {
+			log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
+			log.Println("Amb. Lat:\tConverting lattice format to internal structure")
+		}
+		goldAmbLat = lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	} else {
+		if allOut {
+			log.Println("Dis. Lat.:\tReading training disambiguated lattices from", opts.TrainDis)
+		}
+		lDis, lDisE := lattice.ReadFile(opts.TrainDis, opts.Limit)
+		if lDisE != nil {
+			log.Println(lDisE)
+			return lDisE
+		}
+		if allOut {
+			log.Println("Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
+			log.Println("Dis. Lat.:\tConverting lattice format to internal structure")
+		}
+		goldDisLat = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		if allOut {
+			log.Println("Amb. Lat:\tReading ambiguous lattices from", opts.TrainAmb)
+		}
+		lAmb, lAmbE := lattice.ReadFile(opts.TrainAmb, opts.Limit)
+		if lAmbE != nil {
+			log.Println(lAmbE)
+			return lAmbE
+		}
+		if allOut {
+			log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
+			log.Println("Amb. Lat:\tConverting lattice format to internal structure")
+		}
+		goldAmbLat = lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+	}
+	TrainLemmatizer(goldDisLat)
+	if TagFeats {
+		featsGoldFile, featsDevFile := opts.TrainDis, ""
+		if len(opts.InputGold) > 0 && !opts.NoConverge {
+			featsDevFile = opts.InputGold
+		}
+		if opts.UseConllU {
+			featsGoldFile, featsDevFile = "", ""
+		}
+		if err := TrainFeatsTagger(goldDisLat, featsGoldFile, featsDevFile); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	if allOut {
+		log.Println("Combining train files into gold morph graphs with original lattices")
+	}
+	combined, missingGold, numLattices, sentMissingGold := CombineLatticesCorpus(goldDisLat, goldAmbLat)
+	if opts.Limit > 0 {
+		combined = Limit(combined, opts.Limit*1000)
+	}
+
+	if allOut {
+		log.Println("Combined", len(combined), "graphs, with", missingGold, "lattices of", numLattices, "missing at least one gold path in lattice in", sentMissingGold, "sentences")
+		log.Println()
+	}
+
+	if allOut {
+		log.Println()
+
+		log.Println("Parsing with gold to get training sequences")
+	}
+	// combined = combined[:NUM_SENTS]
+	goldSequences := TrainingSequences(combined, GetMDConfigAsLattices, GetMDConfigAsMappings)
+	if allOut {
+		log.Println("Generated", len(goldSequences), "training sequences")
+		log.Println()
+		// util.LogMemory()
+		log.Println("Training", opts.Iterations, "iteration(s)")
+	}
+	group, _ := extractor.TransTypeGroups['M']
+	formatters := make([]util.Format, len(group.FeatureTemplates))
+	for i, formatter := range group.FeatureTemplates {
+		formatters[i] = formatter
+	}
+	model = transitionmodel.NewAvgMatrixSparse(NumFeatures, formatters, false)
+
+	conf := &disambig.MDConfig{
+		ETokens:     ETokens,
+		POP:         POP,
+		Transitions: ETrans,
+		ParamFunc:   paramFunc,
+		Clusters:    clusters,
+	}
+
+	beam := &search.Beam{
+		TransFunc:            transitionSystem,
+		FeatExtractor:        extractor,
+		Base:                 conf,
+		Size:                 opts.BeamSize,
+		ConcurrentExec:       ConcurrentBeam,
+		Update:               BeamUpdateStrategy(),
+		Transitions:          ETrans,
+		EstimatedTransitions: 1000, // chosen by random dice roll
+	}
+
+	// old research stuff
+	// if !alignAverageParseOnly {
+	// 	beam.Align = AlignBeam
+	// 	beam.Averaged = AverageScores
+	// }
+
+	deterministic := &search.Deterministic{
+		TransFunc:          transitionSystem,
+		FeatExtractor:      extractor,
+		ReturnModelValue:   false,
+		ReturnSequence:     true,
+		ShowConsiderations: false,
+		Base:               conf,
+		NoRecover:          false,
+		DefaultTransType:   'M',
+	}
+
+	var (
+		lConvAmb []lattice.Lattice
+		lConvAmbE error
+		convCombined []interface{}
+		convDisLat []interface{}
+		convAmbLat []interface{}
+	)
+
+	if len(opts.InputGold) > 0 {
+		log.Println("Reading dev test disambiguated lattice (for convergence testing) from", opts.InputGold)
+		if opts.UseConllU {
+			conllus, _, err := conllu.ReadFile(opts.InputGold, opts.Limit)
 			if err != nil {
 				log.Println(err)
 				return err
 			}
+			// conllus = conllus[:NUM_SENTS]
 			if allOut {
-				if hasSegmentation {
-					log.Println("Dis. Lat.:\tRead", len(conllus), "disambiguated lattices (conllU) WITH SEGMENTATION")
-				} else {
-					log.Println("Dis. Lat.:\tRead", len(conllus), "disambiguated lattices (conllU) WITHOUT SEGMENTATION")
-				}
-				log.Println("Dis. Lat.:\tConverting lattice format to internal structure")
+				log.Println("Dev Gold Dis. Lat.:\tRead", len(conllus), "disambiguated lattices")
+				log.Println("Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
 			}
-			ERel = util.NewEnumSet(100)
 			morphGraphs := conllu.ConllU2MorphGraphCorpus(conllus, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-			goldDisLat = make([]interface{}, len(morphGraphs))
+			convDisLat = make([]interface{}, len(morphGraphs))
 			for i, val := range morphGraphs {
 				basicMorphGraph := val.(*morph.BasicMorphGraph)
-				goldDisLat[i] = basicMorphGraph.Lattice
+				convDisLat[i] = basicMorphGraph.Lattice
+			}
+		} else {
+			lConvDis, lConvDisE := lattice.ReadFile(opts.InputGold, opts.Limit)
+			if lConvDisE != nil {
+				log.Println(lConvDisE)
+				return lConvDisE
 			}
 			if allOut {
-				log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", tLatAmb)
+				log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
+				log.Println("Convergence Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
 			}
-			//lAmb, lAmbE := lattice.ReadUDFile(tLatAmb, limit)
-			lAmb, lAmbE := lattice.ReadULFile(tLatAmb, limit)
-			if lAmbE != nil {
-				log.Println(lAmbE)
-				return lAmbE
+
+			convDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		}
+		if allOut {
+			log.Println("Reading dev test ambiguous lattices (for convergence testing) from", opts.Input)
+		}
+
+		if opts.UseConllU {
+			//lConvAmb, lConvAmbE = lattice.ReadUDFile(input, limit)
+			lConvAmb, lConvAmbE = ReadULLattices(opts.Input, opts.Limit)
+			if lConvAmbE != nil {
+				log.Println(lConvAmbE)
+				return lConvAmbE
 			}
-			//clAmb, clAmbE := conllul.ReadFile(tLatAmb, limit)
+			//clAmb, clAmbE := conllul.ReadFile(input, limit)
 			//if clAmbE != nil {
 			//	log.Println(clAmbE)
 			//	return clAmbE
 			//}
-			//lAmb := conllul2Lattices(clAmb)
-			if allOut {
-				log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
-				log.Println("Amb. Lat:\tConverting lattice format to internal structure")
-			}
-			goldAmbLat = lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+			//lConvAmb = conllul2Lattices(clAmb)
 		} else {
-			if allOut {
-				log.Println("Dis. Lat.:\tReading training disambiguated lattices from", tLatDis)
-			}
-			lDis, lDisE := lattice.ReadFile(tLatDis, limit)
-			if lDisE != nil {
-				log.Println(lDisE)
-				return lDisE
-			}
-			if allOut {
-				log.Println("Dis. Lat.:\tRead", len(lDis), "disambiguated lattices")
-				log.Println("Dis. Lat.:\tConverting lattice format to internal structure")
-			}
-			goldDisLat = lattice.Lattice2SentenceCorpus(lDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-			if allOut {
-				log.Println("Amb. Lat:\tReading ambiguous lattices from", tLatAmb)
-			}
-			lAmb, lAmbE := lattice.ReadFile(tLatAmb, limit)
-			if lAmbE != nil {
-				log.Println(lAmbE)
-				return lAmbE
-			}
-			if allOut {
-				log.Println("Amb. Lat:\tRead", len(lAmb), "ambiguous lattices")
-				log.Println("Amb. Lat:\tConverting lattice format to internal structure")
+			lConvAmb, lConvAmbE = lattice.ReadFile(opts.Input, opts.Limit)
+			if lConvAmbE != nil {
+				log.Println(lConvAmbE)
+				return lConvAmbE
 			}
-			goldAmbLat = lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-		}
-		if allOut {
-			log.Println("Combining train files into gold morph graphs with original lattices")
-		}
-		combined, missingGold, numLattices, sentMissingGold := CombineLatticesCorpus(goldDisLat, goldAmbLat)
-		if limit > 0 {
-			combined = Limit(combined, limit*1000)
-		}
-
-		if allOut {
-			log.Println("Combined", len(combined), "graphs, with", missingGold, "lattices of", numLattices, "missing at least one gold path in lattice in", sentMissingGold, "sentences")
-			log.Println()
-		}
-
-		if allOut {
-			log.Println()
-
-			log.Println("Parsing with gold to get training sequences")
 		}
-		// combined = combined[:NUM_SENTS]
-		goldSequences := TrainingSequences(combined, GetMDConfigAsLattices, GetMDConfigAsMappings)
+		//lConvAmb, lConvAmbE := lattice.ReadFile(input, limit)
+		// lConvAmb = lConvAmb[:NUM_SENTS]
+		//if lConvAmbE != nil {
+		//	log.Println(lConvAmbE)
+		//	return lConvAmbE
+		//}
+		// lAmb = lAmb[:NUM_SENTS]
 		if allOut {
-			log.Println("Generated", len(goldSequences), "training sequences")
-			log.Println()
-			// util.LogMemory()
-			log.Println("Training", Iterations, "iteration(s)")
-		}
-		group, _ := extractor.TransTypeGroups['M']
-		formatters := make([]util.Format, len(group.FeatureTemplates))
-		for i, formatter := range group.FeatureTemplates {
-			formatters[i] = formatter
-		}
-		model = transitionmodel.NewAvgMatrixSparse(NumFeatures, formatters, false)
-
-		conf := &disambig.MDConfig{
-			ETokens:     ETokens,
-			POP:         POP,
-			Transitions: ETrans,
-			ParamFunc:   paramFunc,
+			log.Println("Read", len(lConvAmb), "ambiguous lattices from", opts.Input)
+			log.Println("Converting lattice format to internal structure")
 		}
-
-		beam := &search.Beam{
-			TransFunc:            transitionSystem,
-			FeatExtractor:        extractor,
-			Base:                 conf,
-			Size:                 BeamSize,
-			ConcurrentExec:       ConcurrentBeam,
-			Transitions:          ETrans,
-			EstimatedTransitions: 1000, // chosen by random dice roll
+		convAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		if opts.CombineGold {
+			var devMissingGold, devSentMissingGold, devLattices int
+			convCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(convDisLat, convAmbLat)
+			log.Println("Combined", len(convCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
+		} else {
+			convCombined, _, _, _ = CombineLatticesCorpus(convDisLat, convDisLat)
 		}
 
-		// old research stuff
-		// if !alignAverageParseOnly {
-		// 	beam.Align = AlignBeam
-		// 	beam.Averaged = AverageScores
+		// if limit > 0 {
+		// 	convCombined = Limit(convCombined, limit*1000)
+		// 	convAmbLat = Limit(convAmbLat, limit*1000)
+		// 	log.Println("Limited to", limit*1000)
 		// }
+		// convCombined = convCombined[:100]
+	}
 
-		deterministic := &search.Deterministic{
-			TransFunc:          transitionSystem,
-			FeatExtractor:      extractor,
-			ReturnModelValue:   false,
-			ReturnSequence:     true,
-			ShowConsiderations: false,
-			Base:               conf,
-			NoRecover:          false,
-			DefaultTransType:   'M',
-		}
-
-		var (
-			lConvAmb []lattice.Lattice
-			lConvAmbE error
-			convCombined []interface{}
-			convDisLat []interface{}
-			convAmbLat []interface{}
-		)
-
-		if len(inputGold) > 0 {
-			log.Println("Reading dev test disambiguated lattice (for convergence testing) from", inputGold)
-			if useConllU {
-				conllus, _, err := conllu.ReadFile(inputGold, limit)
-				if err != nil {
-					log.Println(err)
-					return err
-				}
-				// conllus = conllus[:NUM_SENTS]
-				if allOut {
-					log.Println("Dev Gold Dis. Lat.:\tRead", len(conllus), "disambiguated lattices")
-					log.Println("Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
-				}
-				morphGraphs := conllu.ConllU2MorphGraphCorpus(conllus, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-				convDisLat = make([]interface{}, len(morphGraphs))
-				for i, val := range morphGraphs {
-					basicMorphGraph := val.(*morph.BasicMorphGraph)
-					convDisLat[i] = basicMorphGraph.Lattice
-				}
-			} else {
-				lConvDis, lConvDisE := lattice.ReadFile(inputGold, limit)
-				if lConvDisE != nil {
-					log.Println(lConvDisE)
-					return lConvDisE
-				}
-				if allOut {
-					log.Println("Convergence Dev Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
-					log.Println("Convergence Dev Gold Dis. Lat.:\tConverting lattice format to internal structure")
-				}
-
-				convDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-			}
-			if allOut {
-				log.Println("Reading dev test ambiguous lattices (for convergence testing) from", input)
-			}
+	var testCombined []interface{}
+	var testDisLat []interface{}
+	var testAmbLat []interface{}
 
-			if useConllU {
-				//lConvAmb, lConvAmbE = lattice.ReadUDFile(input, limit)
-				lConvAmb, lConvAmbE = lattice.ReadULFile(input, limit)
-				if lConvAmbE != nil {
-					log.Println(lConvAmbE)
-					return lConvAmbE
-				}
-				//clAmb, clAmbE := conllul.ReadFile(input, limit)
-				//if clAmbE != nil {
-				//	log.Println(clAmbE)
-				//	return clAmbE
-				//}
-				//lConvAmb = conllul2Lattices(clAmb)
-			} else {
-				lConvAmb, lConvAmbE = lattice.ReadFile(input, limit)
-				if lConvAmbE != nil {
-					log.Println(lConvAmbE)
-					return lConvAmbE
-				}
+	if len(opts.Test) > 0 {
+		log.Println("Reading test disambiguated lattice (for convergence testing) from", opts.TestGold)
+		if opts.UseConllU {
+			conllus, _, err := conllu.ReadFile(opts.TestGold, 0)
+			if err != nil {
+				log.Println(err)
+				return err
 			}
-			//lConvAmb, lConvAmbE := lattice.ReadFile(input, limit)
-			// lConvAmb = lConvAmb[:NUM_SENTS]
-			//if lConvAmbE != nil {
-			//	log.Println(lConvAmbE)
-			//	return lConvAmbE
-			//}
-			// lAmb = lAmb[:NUM_SENTS]
+			// conllus = conllus[:NUM_SENTS]
 			if allOut {
-				log.Println("Read", len(lConvAmb), "ambiguous lattices from", input)
-				log.Println("Converting lattice format to internal structure")
+				log.Println("Test Gold Dis. Lat.:\tRead", len(conllus), "disambiguated lattices")
+				log.Println("Test Gold Dis. Lat.:\tConverting lattice format to internal structure")
 			}
-			convAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-			if MdCombineGold {
-				var devMissingGold, devSentMissingGold, devLattices int
-				convCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(convDisLat, convAmbLat)
-				log.Println("Combined", len(convCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
-			} else {
-				convCombined, _, _, _ = CombineLatticesCorpus(convDisLat, convDisLat)
+			morphGraphs := conllu.ConllU2MorphGraphCorpus(conllus, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
+			testDisLat = make([]interface{}, len(morphGraphs))
+			for i, val := range morphGraphs {
+				basicMorphGraph := val.(*morph.BasicMorphGraph)
+				testDisLat[i] = basicMorphGraph.Lattice
 			}
-
-			// if limit > 0 {
-			// 	convCombined = Limit(convCombined, limit*1000)
-			// 	convAmbLat = Limit(convAmbLat, limit*1000)
-			// 	log.Println("Limited to", limit*1000)
-			// }
-			// convCombined = convCombined[:100]
-		}
-
-		var testCombined []interface{}
-		var testDisLat []interface{}
-		var testAmbLat []interface{}
-
-		if len(test) > 0 {
-			log.Println("Reading test disambiguated lattice (for convergence testing) from", testGold)
-			if useConllU {
-				conllus, _, err := conllu.ReadFile(testGold, 0)
-				if err != nil {
-					log.Println(err)
-					return err
-				}
-				// conllus = conllus[:NUM_SENTS]
-				if allOut {
-					log.Println("Test Gold Dis. Lat.:\tRead", len(conllus), "disambiguated lattices")
-					log.Println("Test Gold Dis. Lat.:\tConverting lattice format to internal structure")
-				}
-				morphGraphs := conllu.ConllU2MorphGraphCorpus(conllus, EWord, EPOS, EWPOS, ERel, EMorphProp, EMHost, EMSuffix)
-				testDisLat = make([]interface{}, len(morphGraphs))
-				for i, val := range morphGraphs {
-					basicMorphGraph := val.(*morph.BasicMorphGraph)
-					testDisLat[i] = basicMorphGraph.Lattice
-				}
-			} else {
-				lConvDis, lConvDisE := lattice.ReadFile(testGold, 0)
-				if lConvDisE != nil {
-					log.Println(lConvDisE)
-					return lConvDisE
-				}
-				if allOut {
-					log.Println("Convergence Test Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
-					log.Println("Convergence Test Gold Dis. Lat.:\tConverting lattice format to internal structure")
-				}
-
-				testDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		} else {
+			lConvDis, lConvDisE := lattice.ReadFile(opts.TestGold, 0)
+			if lConvDisE != nil {
+				log.Println(lConvDisE)
+				return lConvDisE
 			}
 			if allOut {
-				log.Println("Reading test ambiguous lattices from", test)
+				log.Println("Convergence Test Gold Dis. Lat.:\tRead", len(lConvDis), "disambiguated lattices")
+				log.Println("Convergence Test Gold Dis. Lat.:\tConverting lattice format to internal structure")
 			}
 
-			lConvAmb, lConvAmbE := lattice.ReadFile(test, 0)
-			// lConvAmb = lConvAmb[:NUM_SENTS]
-			if lConvAmbE != nil {
-				log.Println(lConvAmbE)
-				return lConvAmbE
-			}
-			// lAmb = lAmb[:NUM_SENTS]
-			if allOut {
-				log.Println("Read", len(lConvAmb), "ambiguous lattices from", test)
-				log.Println("Converting lattice format to internal structure")
-			}
-			testAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
-			if MdCombineGold {
-				var devMissingGold, devSentMissingGold, devLattices int
-				testCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(testDisLat, testAmbLat)
-				log.Println("Combined", len(testCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
-			} else {
-				testCombined, _, _, _ = CombineLatticesCorpus(testDisLat, testDisLat)
-			}
-			// if limit > 0 {
-			// 	testCombined = Limit(testCombined, limit*1000)
-			// 	testAmbLat = Limit(testAmbLat, limit*1000)
-			// }
-			// convCombined = convCombined[:100]
-		}
-		decodeTestBeam := &search.Beam{}
-		*decodeTestBeam = *beam
-		decodeTestBeam.Model = model
-		decodeTestBeam.DecodeTest = true
-		decodeTestBeam.ShortTempAgenda = true
-		log.Println("Parse beam alignment:", AlignBeam)
-		decodeTestBeam.Align = AlignBeam
-		log.Println("Parse beam averaging:", AverageScores)
-		decodeTestBeam.Averaged = AverageScores
-		var evaluator perceptron.StopCondition
-		if len(inputGold) > 0 {
-			if !MdNoconverge {
-				if allOut {
-					log.Println("Setting convergence tester")
-				}
-				evaluator = MakeMorphEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), BeamSize)
-			}
+			testDisLat = lattice.Lattice2SentenceCorpus(lConvDis, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		}
+		if allOut {
+			log.Println("Reading test ambiguous lattices from", opts.Test)
 		}
-		_ = Train(goldSequences, Iterations, MdModelFile, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator)
 
+		lConvAmb, lConvAmbE := lattice.ReadFile(opts.Test, 0)
+		// lConvAmb = lConvAmb[:NUM_SENTS]
+		if lConvAmbE != nil {
+			log.Println(lConvAmbE)
+			return lConvAmbE
+		}
+		// lAmb = lAmb[:NUM_SENTS]
 		if allOut {
-			log.Println("Done Training")
-			// util.LogMemory()
-			log.Println()
-			log.Println("Writing final model to", outModelFile)
-			serialization := &Serialization{
-				model.Serialize(-1),
-				EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+			log.Println("Read", len(lConvAmb), "ambiguous lattices from", opts.Test)
+			log.Println("Converting lattice format to internal structure")
+		}
+		testAmbLat = lattice.Lattice2SentenceCorpus(lConvAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
+		if opts.CombineGold {
+			var devMissingGold, devSentMissingGold, devLattices int
+			testCombined, devMissingGold, devLattices, devSentMissingGold = CombineLatticesCorpus(testDisLat, testAmbLat)
+			log.Println("Combined", len(testCombined), "graphs, with", devMissingGold, "lattices of", devLattices, "missing at least one gold path in lattice in", devSentMissingGold, "sentences")
+		} else {
+			testCombined, _, _, _ = CombineLatticesCorpus(testDisLat, testDisLat)
+		}
+		// if limit > 0 {
+		// 	testCombined = Limit(testCombined, limit*1000)
+		// 	testAmbLat = Limit(testAmbLat, limit*1000)
+		// }
+		// convCombined = convCombined[:100]
+	}
+	decodeTestBeam := &search.Beam{}
+	*decodeTestBeam = *beam
+	decodeTestBeam.Model = model
+	decodeTestBeam.DecodeTest = true
+	decodeTestBeam.ShortTempAgenda = true
+	log.Println("Parse beam alignment:", AlignBeam)
+	decodeTestBeam.Align = AlignBeam
+	log.Println("Parse beam averaging:", AverageScores)
+	decodeTestBeam.Averaged = AverageScores
+	var evaluator perceptron.StopCondition
+	if len(opts.InputGold) > 0 {
+		if !opts.NoConverge {
+			if allOut {
+				log.Println("Setting convergence tester")
 			}
-			WriteModel(outModelFile, serialization)
-			log.Println("Done")
-			// log.Print("Parsing test")
+			evaluator = MakeMorphEvalStopCondition(convAmbLat, convCombined, testAmbLat, testCombined, decodeTestBeam, perceptron.InstanceDecoder(deterministic), opts.BeamSize)
 		}
-		return nil
 	}
+	_ = Train(goldSequences, opts.Iterations, opts.ModelPrefix, model, perceptron.EarlyUpdateInstanceDecoder(beam), perceptron.InstanceDecoder(deterministic), evaluator, BeamDecoders(beam))
+
 	if allOut {
-		log.Println("Found model file", outModelFile, " ... loading model")
+		log.Println("Done Training")
+		// util.LogMemory()
+		log.Println()
+		log.Println("Writing final model to", outModelFile)
+		serialization := &Serialization{
+			WeightModel: model.Serialize(-1),
+			EWord:       EWord, EPOS: EPOS, EWPOS: EWPOS, EMHost: EMHost, EMSuffix: EMSuffix,
+			EMorphProp: EMorphProp, ETrans: ETrans, ETokens: ETokens,
+			Lemmas: Lemmatizer, Feats: FeatsTagger,
+			Config: NewModelConfig(),
+		}
+		WriteModel(outModelFile, serialization)
+		log.Println("Done")
+		// log.Print("Parsing test")
 	}
-	serialization := ReadModel(outModelFile)
+	return nil
+}
+
+// MDParse disambiguates the input lattices with a read model, its
+// configuration must be applied before setting up md
+func MDParse(md *MDSetup, serialization *Serialization) error {
+	var (
+		opts      = md.Options
+		paramFunc = md.ParamFunc
+		clusters  = md.Clusters
+		model     = &transitionmodel.AvgMatrixSparse{}
+	)
 	model.Deserialize(serialization.WeightModel)
 	EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens = serialization.EWord, serialization.EPOS, serialization.EWPOS, serialization.EMHost, serialization.EMSuffix, serialization.EMorphProp, serialization.ETrans, serialization.ETokens
+	Lemmatizer = serialization.Lemmas
+	FeatsTagger = serialization.Feats
+	SetupFeatsTagger()
 
-	if MdUseWB {
-		mdTrans = &disambig.MDWBTrans{
-			ParamFunc:   paramFunc,
-			UsePOP:      UsePOP,
-			POP:         POP,
-			Transitions: ETrans,
-		}
-	} else {
-		mdTrans = &disambig.MDTrans{
-			ParamFunc:   paramFunc,
-			UsePOP:      UsePOP,
-			POP:         POP,
-			Transitions: ETrans,
-		}
-	}
+	mdTrans := newMDTransitionSystem(paramFunc, opts.WordBased, opts.UsePOP)
+	SetMDTransitions(mdTrans)
 
-	transitionSystem = transition.TransitionSystem(mdTrans)
-	extractor = SetupExtractor(featureSetup, []byte("MPL"))
+	transitionSystem := transition.TransitionSystem(mdTrans)
+	extractor := SetupExtractor(md.FeatureSetup, []byte("MPL"))
 
 	// setup configuration and beam
 	conf := &disambig.MDConfig{
@@ -696,23 +892,25 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		POP:         POP,
 		Transitions: ETrans,
 		ParamFunc:   paramFunc,
+		Clusters:    clusters,
 	}
 
 	beam := &search.Beam{
 		TransFunc:            transitionSystem,
 		FeatExtractor:        extractor,
 		Base:                 conf,
-		Size:                 BeamSize,
+		Size:                 opts.BeamSize,
 		ConcurrentExec:       ConcurrentBeam,
+		Lazy:                 LazyBeam,
 		Transitions:          ETrans,
 		EstimatedTransitions: 1000, // chosen by random dice roll
 	}
-	if Stream {
+	if opts.Stream {
 
 		if allOut {
-			log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", input)
+			log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", opts.Input)
 		}
-		lAmb, lAmbE := lattice.StreamFile(input, limit)
+		lAmb, lAmbE := lattice.StreamFile(opts.Input, opts.Limit)
 		if lAmbE != nil {
 			log.Println(lAmbE)
 			return lAmbE
@@ -729,9 +927,9 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 		go ParseStream(predAmbLatStream, mappings, beam)
 		if allOut {
-			log.Println("Creating writer stream to", outMap)
+			log.Println("Creating writer stream to", opts.OutMap)
 		}
-		mapping.WriteStreamToFile(outMap, mappings)
+		mapping.WriteStreamToFile(opts.OutMap, mappings)
 
 		return nil
 	}
@@ -741,13 +939,13 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		clAmb []conllul.ConlluLattice
 		clAmbE error
 	)
-	if useConllU {
+	if opts.UseConllU {
 
 		if allOut {
-			log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", input)
+			log.Println("Amb. Lat:\tReading ambiguous conllu lattices from", opts.Input)
 		}
 		//lAmb, lAmbE = lattice.ReadUDFile(input, limit)
-		clAmb, clAmbE = conllul.ReadFile(input, limit)
+		clAmb, clAmbE = conllul.ReadFile(opts.Input, opts.Limit)
 		if clAmbE != nil {
 			log.Println(clAmbE)
 			return clAmbE
@@ -759,27 +957,27 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 		}
 	} else {
 		if allOut {
-			log.Println("Reading ambiguous lattices from", input)
+			log.Println("Reading ambiguous lattices from", opts.Input)
 		}
 
-		lAmb, lAmbE = lattice.ReadFile(input, limit)
+		lAmb, lAmbE = lattice.ReadFile(opts.Input, opts.Limit)
 		if lAmbE != nil {
 			log.Println(lAmbE)
 			return lAmbE
 		}
 		// lAmb = lAmb[:NUM_SENTS]
 		if allOut {
-			log.Println("Read", len(lAmb), "ambiguous lattices from", input)
+			log.Println("Read", len(lAmb), "ambiguous lattices from", opts.Input)
 			log.Println("Converting lattice format to internal structure")
 		}
 	}
 	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, EWord, EPOS, EWPOS, EMorphProp, EMHost, EMSuffix)
 
-	if len(inputGold) > 0 {
+	if len(opts.InputGold) > 0 {
 		log.Println("Reading test disambiguated lattice (for test ambiguous infusion)")
 		var predDisLat []interface{}
-		if useConllU {
-			conllus, _, err := conllu.ReadFile(tLatDis, limit)
+		if opts.UseConllU {
+			conllus, _, err := conllu.ReadFile(opts.TrainDis, opts.Limit)
 			if err != nil {
 				log.Println(err)
 				return err
@@ -796,7 +994,7 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 				predDisLat[i] = basicMorphGraph.Lattice
 			}
 		} else {
-			lDis, lDisE := lattice.ReadFile(inputGold, limit)
+			lDis, lDisE := lattice.ReadFile(opts.InputGold, opts.Limit)
 			if lDisE != nil {
 				log.Println(lDisE)
 				return lDisE
@@ -823,7 +1021,19 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	beam.ShortTempAgenda = true
 	beam.Model = model
 
-	mappings := Parse(predAmbLat, beam)
+	if len(opts.ConstraintsFile) > 0 {
+		LoadConstraints(opts.ConstraintsFile, predAmbLat)
+	}
+	var mappings []interface{}
+	if opts.NBest > 0 {
+		var nbests []*disambig.NBest
+		mappings, nbests = ParseNBest(predAmbLat, beam, opts.NBest)
+		if err := WriteNBest(opts.OutMap, nbests); err != nil {
+			return err
+		}
+	} else {
+		mappings = Parse(predAmbLat, beam)
+	}
 
 	/*	if allOut {
 			log.Println("Converting", len(parsedGraphs), "to conll")
@@ -849,14 +1059,14 @@ func MDTrainAndParse(cmd *commander.Command, args []string) error {
 	if allOut {
 		log.Println("Writing to mapping file")
 	}
-	if useConllU {
-		mapping.UDWriteFile(outMap, mappings, clAmb)
+	if opts.UseConllU {
+		mapping.UDWriteFile(opts.OutMap, mappings, clAmb)
 	} else {
-		mapping.WriteFile(outMap, mappings)
+		mapping.WriteFile(opts.OutMap, mappings)
 	}
 
 	if allOut {
-		log.Println("Wrote", len(mappings), "in mapping format to", outMap)
+		log.Println("Wrote", len(mappings), "in mapping format to", opts.OutMap)
 	}
 	return nil
 }
@@ -875,6 +1085,14 @@ runs standalone morphological disambiguation training and parsing
 		Flag: *flag.NewFlagSet("md", flag.ExitOnError),
 	}
 	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.BoolVar(&LazyBeam, "lazy", false, "Optional - Lazy beam expansion: score only the legal transitions of a candidate and build those that can make the beam (parsing)")
+	cmd.Flag.StringVar(&BeamUpdate, "update", "early", "Optional - Beam training update strategy ["+search.UpdateStrategies+"]")
+	cmd.Flag.IntVar(&TrainWorkers, "workers", 1, "Optional - Number of parallel training workers (iterative parameter mixing)")
+	cmd.Flag.StringVar(&Learner, "learner", "perceptron", "Optional - Training update strategy ["+Learners+"]")
+	cmd.Flag.Float64Var(&LearnerC, "mirac", 1.0, "Optional - Aggressiveness (maximal step) of mira updates")
+	cmd.Flag.StringVar(&ClustersFile, "clusters", "", "Optional - Brown word clusters (paths format) of the c<bits> feature attributes")
+	cmd.Flag.StringVar(&EmbeddingClustersFile, "embclusters", "", "Optional - Word embeddings (word2vec/GloVe text format) clustered for the e feature attribute")
+	cmd.Flag.IntVar(&EmbeddingClusters, "embk", 100, "Optional - Number of embedding clusters")
 	cmd.Flag.IntVar(&Iterations, "it", 1, "Minimum Number of Perceptron Iterations")
 	cmd.Flag.IntVar(&BeamSize, "b", 32, "Beam Size")
 	cmd.Flag.StringVar(&MdModelFile, "m", "model", "Prefix for model file ({m}.b{b}.model)")
@@ -894,8 +1112,13 @@ runs standalone morphological disambiguation training and parsing
 	cmd.Flag.BoolVar(&alignAverageParseOnly, "parseonly", false, "Use Alignment & Average Scoring in parsing only")
 	cmd.Flag.BoolVar(&UsePOP, "pop", true, "Add POP operation to MD")
 	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.BoolVar(&Lemmatize, "lemmatize", true, "Choose and fill the lemmas of the output morphemes")
+	cmd.Flag.BoolVar(&TagFeats, "tagfeats", false, "Train (with the model) and apply a gen/num/per tagger to underspecified output morphemes (OOVs, NNPs)")
+	cmd.Flag.StringVar(&FeatsTaggerFile, "tagfeatsf", "feats.tagger.yaml", "Feature Tagger Features Configuration File")
+	cmd.Flag.IntVar(&FeatsTaggerIters, "tagfeatsit", 4, "Minimum Number of Feature Tagger Perceptron Iterations")
 	cmd.Flag.BoolVar(&lattice.IGNORE_NNP_FEATS, "stripnnpfeats", false, "Strip all NNPs of features")
-	cmd.Flag.BoolVar(&MdUseWB, "wb", false, "Word Based MD")
+	cmd.Flag.BoolVar(&lattice.WRITE_TOKEN_RANGE, "tokenrange", false, "Add token character ranges to the output mapping (a column, TokenRange in the MISC of CoNLL-U)")
+	cmd.Flag.BoolVar(&MdUseWB, "wb", false, "Word Based MD (needs a model trained with -wb, none is shipped)")
 	cmd.Flag.BoolVar(&search.AllOut, "showbeam", false, "Show candidates in beam")
 	cmd.Flag.BoolVar(&search.SHOW_ORACLE, "showoracle", false, "Show oracle transitions")
 	cmd.Flag.BoolVar(&search.ShowFeats, "showfeats", false, "Show features of candidates in beam")
@@ -904,5 +1127,8 @@ runs standalone morphological disambiguation training and parsing
 	cmd.Flag.IntVar(&limit, "limit", 0, "limit training set")
 	cmd.Flag.BoolVar(&MdNoconverge, "noconverge", false, "don't test convergence (run -it number of iterations)")
 	cmd.Flag.BoolVar(&Stream, "stream", false, "Stream data from input through parser to output")
+	cmd.Flag.IntVar(&NBest, "nbest", 0, "Also output the K best distinct disambiguations of each sentence with scores (at most the beam size; 0 = best only)")
+	cmd.Flag.StringVar(&outNBest, "onbest", "", "Optional - Output N-Best Mapping File (default {om}.nbest)")
+	cmd.Flag.StringVar(&ConstraintsFile, "constraints", "", "Optional - Token constraints file (sentence, token, segmentation, pos, feats) restricting the analyses of the input")
 	return cmd
 }
@@ -0,0 +1,223 @@
+package app
+
+import (
+	"yap/alg/search"
+	"yap/eval"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	mdCompareTrainDis, mdCompareTrainAmb   string
+	mdCompareInput, mdCompareInputGold     string
+	mdCompareFeatures, mdCompareWBFeatures string
+	mdCompareModelPrefix, mdCompareOut     string
+	mdCompareIterations, mdCompareBeam     int
+	mdCompareParamFunc                     string
+)
+
+// MDCompareResult holds the accuracy and speed of one MD transition system
+type MDCompareResult struct {
+	Name                  string
+	TrainTime, ParseTime  time.Duration
+	Sentences, Tokens     int
+	Seg, POS, Full, Lemma *eval.Total
+}
+
+// percent formats a score, undefined scores (no true positives) are 0
+func percent(score float64) float64 {
+	if math.IsNaN(score) {
+		return 0
+	}
+	return 100 * score
+}
+
+func (r *MDCompareResult) TokensPerSecond() float64 {
+	return float64(r.Tokens) / r.ParseTime.Seconds()
+}
+
+// ReadMappingsFile reads disambiguated lattices as mappings, one spellout
+// per token
+func ReadMappingsFile(filename string) ([]nlp.Mappings, error) {
+	lats, err := lattice.ReadFile(filename, 0)
+	if err != nil {
+		return nil, err
+	}
+	eWord, ePOS, eWPOS := util.NewEnumSet(APPROX_WORDS), util.NewEnumSet(APPROX_POS), util.NewEnumSet(APPROX_WORDS*5)
+	eMorphProp, eMHost, eMSuffix := util.NewEnumSet(10000), util.NewEnumSet(APPROX_MHOSTS), util.NewEnumSet(APPROX_MSUFFIXES)
+	sents := lattice.Lattice2SentenceCorpus(lats, eWord, ePOS, eWPOS, eMorphProp, eMHost, eMSuffix)
+	mappings := make([]nlp.Mappings, len(sents))
+	for i, sent := range sents {
+		latSent := sent.(nlp.LatticeSentence)
+		mappings[i] = make(nlp.Mappings, len(latSent))
+		for j, lat := range latSent {
+			mappings[i][j] = &nlp.Mapping{Token: lat.Token}
+			if len(lat.Spellouts) > 0 {
+				mappings[i][j].Spellout = lat.Spellouts[0]
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// EvalMappings computes segmentation (Form), POS (Form_POS), full
+// morphological (Form_POS_Prop) and lemma (Lemma) scores of the output
+// mappings
+func EvalMappings(output, gold []nlp.Mappings) (seg, pos, full, lemma *eval.Total, err error) {
+	if len(output) != len(gold) {
+		return nil, nil, nil, nil, fmt.Errorf("Output has %d sentences, gold has %d", len(output), len(gold))
+	}
+	seg, pos, full, lemma = &eval.Total{}, &eval.Total{}, &eval.Total{}, &eval.Total{}
+	for i, mappings := range output {
+		if len(mappings) != len(gold[i]) {
+			return nil, nil, nil, nil, fmt.Errorf("Sentence %d has %d tokens, gold has %d", i+1, len(mappings), len(gold[i]))
+		}
+		conf := &disambig.MDConfig{Mappings: mappings}
+		seg.Add(MorphEval(conf, gold[i], "Form"))
+		pos.Add(MorphEval(conf, gold[i], "Form_POS"))
+		full.Add(MorphEval(conf, gold[i], "Form_POS_Prop"))
+		lemma.Add(MorphEval(conf, gold[i], "Lemma"))
+	}
+	return
+}
+
+// runMDCompare trains a model with the md-compare options and parses the
+// input with it, returning the output mappings file
+func runMDCompare(result *MDCompareResult, featuresFile string, wb bool) (string, error) {
+	modelPrefix := fmt.Sprintf("%s.%s", mdCompareModelPrefix, result.Name)
+	modelFile := fmt.Sprintf("%s.b%d", modelPrefix, mdCompareBeam)
+	if VerifyExists(modelFile) {
+		return "", fmt.Errorf("Model file %s exists, remove it or set another prefix (-m)", modelFile)
+	}
+	if featuresLocation, found := util.LocateFile(featuresFile, DEFAULT_CONF_DIRS); found {
+		featuresFile = featuresLocation
+	} else if !VerifyExists(featuresFile) {
+		return "", fmt.Errorf("Features file %s not found", featuresFile)
+	}
+	opts := &MDOptions{
+		TrainDis:      mdCompareTrainDis,
+		TrainAmb:      mdCompareTrainAmb,
+		Input:         mdCompareInput,
+		OutMap:        fmt.Sprintf("%s.%s", mdCompareOut, result.Name),
+		FeaturesFile:  featuresFile,
+		ParamFuncName: mdCompareParamFunc,
+		ModelPrefix:   modelPrefix,
+		Iterations:    mdCompareIterations,
+		BeamSize:      mdCompareBeam,
+		UsePOP:        true,
+		WordBased:     wb,
+		NoConverge:    true,
+	}
+	md, err := SetupMD(opts)
+	if err != nil {
+		return "", err
+	}
+
+	log.Println("Training md", result.Name)
+	start := time.Now()
+	if err := MDTrain(md, modelFile); err != nil {
+		return "", err
+	}
+	result.TrainTime = time.Since(start)
+
+	log.Println("Parsing md", result.Name)
+	start = time.Now()
+	if err := MDParse(md, ReadModel(modelFile)); err != nil {
+		return "", err
+	}
+	result.ParseTime = time.Since(start)
+	return opts.OutMap, nil
+}
+
+func MDCompare(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"td", "tl", "in", "ing"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+
+	gold, err := ReadMappingsFile(mdCompareInputGold)
+	if err != nil {
+		return err
+	}
+	results := []*MDCompareResult{{Name: "morpheme"}, {Name: "word"}}
+	for i, result := range results {
+		featuresFile := mdCompareFeatures
+		if i == 1 {
+			featuresFile = mdCompareWBFeatures
+		}
+		outFile, err := runMDCompare(result, featuresFile, i == 1)
+		if err != nil {
+			return err
+		}
+		output, err := ReadMappingsFile(outFile)
+		if err != nil {
+			return err
+		}
+		result.Sentences = len(output)
+		for _, mappings := range output {
+			result.Tokens += len(mappings)
+		}
+		result.Seg, result.POS, result.Full, result.Lemma, err = EvalMappings(output, gold)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%-10s %8s %8s %8s %8s %8s %10s %10s %10s\n", "MD", "Seg F1", "POS F1", "Full F1", "Lemma F1", "Exact", "Train", "Parse", "Tokens/s")
+	for _, result := range results {
+		fmt.Printf("%-10s %8.2f %8.2f %8.2f %8.2f %8.2f %10v %10v %10.1f\n",
+			result.Name,
+			percent(result.Seg.F1()), percent(result.POS.F1()), percent(result.Full.F1()), percent(result.Lemma.F1()), percent(result.Full.ExactMatch()),
+			result.TrainTime.Round(time.Millisecond), result.ParseTime.Round(time.Millisecond), result.TokensPerSecond())
+	}
+	return nil
+}
+
+func MDCompareCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       MDCompare,
+		UsageLine: "md-compare <file options> [arguments]",
+		Short:     "train and compare the morpheme-based and word-based disambiguators",
+		Long: `
+train and compare the morpheme-based and word-based disambiguators
+
+	$ ./yap md-compare -td <train disamb. lat> -tl <train amb. lat> -in <input lat> -ing <gold input lat> [options]
+
+Trains a morpheme-based and a word-based (md -wb) model on the same data,
+disambiguates the input with each and reports segmentation, POS and full
+morphological and lemma F1, exact match (sentences), training and parsing time.
+Models are written to {m}.morpheme.b{b} and {m}.word.b{b}, outputs to
+{om}.morpheme and {om}.word.
+`,
+		Flag: *flag.NewFlagSet("md-compare", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&mdCompareTrainDis, "td", "", "Training Disambiguated Lattices File")
+	cmd.Flag.StringVar(&mdCompareTrainAmb, "tl", "", "Training Ambiguous Lattices File")
+	cmd.Flag.StringVar(&mdCompareInput, "in", "", "Dev-Test Ambiguous Lattices File")
+	cmd.Flag.StringVar(&mdCompareInputGold, "ing", "", "Gold Dev-Test Lattices File")
+	cmd.Flag.StringVar(&mdCompareFeatures, "f", "standalone.md.yaml", "Morpheme-based Features Configuration File")
+	cmd.Flag.StringVar(&mdCompareWBFeatures, "wbf", "standalone.wbmd.yaml", "Word-based Features Configuration File")
+	cmd.Flag.StringVar(&mdCompareParamFunc, "p", "Funcs_Main_POS_Both_Prop", "Param Func types: ["+nlp.AllParamFuncNames+"]")
+	cmd.Flag.StringVar(&mdCompareModelPrefix, "m", "mdcompare", "Prefix for model files")
+	cmd.Flag.StringVar(&mdCompareOut, "om", "mdcompare.out", "Prefix for output mapping files")
+	cmd.Flag.IntVar(&mdCompareIterations, "it", 1, "Number of Perceptron Iterations")
+	cmd.Flag.IntVar(&mdCompareBeam, "b", 32, "Beam Size")
+	// settings shared by the md and the other trainers, defaults as in md
+	cmd.Flag.BoolVar(&ConcurrentBeam, "bconc", true, "Concurrent Beam")
+	cmd.Flag.StringVar(&BeamUpdate, "update", "early", "Optional - Beam training update strategy ["+search.UpdateStrategies+"]")
+	cmd.Flag.IntVar(&TrainWorkers, "workers", 1, "Optional - Number of parallel training workers (iterative parameter mixing)")
+	cmd.Flag.StringVar(&Learner, "learner", "perceptron", "Optional - Training update strategy ["+Learners+"]")
+	cmd.Flag.Float64Var(&LearnerC, "mirac", 1.0, "Optional - Aggressiveness (maximal step) of mira updates")
+	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.BoolVar(&Lemmatize, "lemmatize", true, "Choose and fill the lemmas of the output morphemes")
+	cmd.Flag.BoolVar(&search.AllOut, "showbeam", false, "Show candidates in beam")
+	return cmd
+}
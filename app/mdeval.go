@@ -0,0 +1,459 @@
+package app
+
+import (
+	"yap/eval"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/raw"
+	nlp "yap/nlp/types"
+
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/gonuts/commander"
+	"github.com/gonuts/flag"
+)
+
+var (
+	mdEvalPred, mdEvalGold string
+	mdEvalJSON             string
+	mdEvalConfusion        int
+	mdEvalAlign            bool
+	mdEvalPredConll        string
+	mdEvalGoldConll        string
+	mdEvalPredRaw          string
+	mdEvalGoldRaw          string
+)
+
+// MDEvalScore holds the counts and scores of one morphological metric
+type MDEvalScore struct {
+	Gold      int     `json:"gold"`
+	Pred      int     `json:"pred"`
+	TP        int     `json:"tp"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Exact     float64 `json:"exact_match,omitempty"`
+}
+
+func (s *MDEvalScore) compute() {
+	s.Precision = percent(eval.Precision(s.TP, s.Pred))
+	s.Recall = percent(eval.Recall(s.TP, s.Gold))
+	s.F1 = percent(eval.F1(s.Precision/100, s.Recall/100))
+}
+
+func newMDEvalResultScore(result *eval.Result) *MDEvalScore {
+	// Spellout.Compare and eval.Align count missed gold units as TN
+	score := &MDEvalScore{Gold: result.TP + result.TN, Pred: result.TP + result.FP, TP: result.TP}
+	score.compute()
+	return score
+}
+
+func newMDEvalScore(total *eval.Total) *MDEvalScore {
+	score := newMDEvalResultScore(&total.Result)
+	score.Exact = percent(total.ExactMatch())
+	return score
+}
+
+// MDEvalReport is the evaluation of disambiguated mappings against gold
+type MDEvalReport struct {
+	Sentences int          `json:"sentences"`
+	Tokens    int          `json:"tokens"`
+	Seg       *MDEvalScore `json:"seg"`
+	POS       *MDEvalScore `json:"pos"`
+	Full      *MDEvalScore `json:"full"`
+	Lemma     *MDEvalScore `json:"lemma"`
+	// Aligned scores are of output tokens and morphemes aligned to gold by
+	// character content, with feature and attachment scores
+	Aligned *MDAlignedScores `json:"aligned,omitempty"`
+	// PerPOS scores segmentation and POS (Form_POS) by gold/predicted POS
+	PerPOS map[string]*MDEvalScore `json:"per_pos,omitempty"`
+	// PerFeature scores segmentation, POS and each feature value
+	// (Form_POS_name=value) by feature name
+	PerFeature map[string]*MDEvalScore `json:"per_feature,omitempty"`
+	// Confusion counts gold POS (rows) by predicted POS of morphemes with
+	// the same form in the same token
+	Confusion map[string]map[string]int `json:"confusion,omitempty"`
+}
+
+// MDAlignedScores are the scores of an aligned evaluation not covered by
+// the morpheme scores
+type MDAlignedScores struct {
+	Sentences *MDEvalScore `json:"sentences"`
+	Tokens    *MDEvalScore `json:"tokens"`
+	Feats     *MDEvalScore `json:"feats"`
+	UAS       *MDEvalScore `json:"uas,omitempty"`
+	LAS       *MDEvalScore `json:"las,omitempty"`
+}
+
+func countKeys(scores map[string]*MDEvalScore, pred, gold map[string]string) {
+	get := func(class string) *MDEvalScore {
+		score, exists := scores[class]
+		if !exists {
+			score = &MDEvalScore{}
+			scores[class] = score
+		}
+		return score
+	}
+	for key, class := range pred {
+		get(class).Pred++
+		if _, exists := gold[key]; exists {
+			get(class).TP++
+		}
+	}
+	for _, class := range gold {
+		get(class).Gold++
+	}
+}
+
+// spelloutKeys returns the Form_POS keys of a spellout by POS and the
+// Form_POS_name=value keys by feature name
+func spelloutKeys(s nlp.Spellout) (pos, feats map[string]string) {
+	pos, feats = make(map[string]string, len(s)), make(map[string]string)
+	for _, m := range s {
+		key := nlp.Form_POS(m)
+		pos[key] = m.CPOS
+		for name, value := range m.Features {
+			feats[fmt.Sprintf("%s_%s=%s", key, name, value)] = name
+		}
+	}
+	return
+}
+
+// spelloutForms returns the POS tags of a spellout's morphemes by form,
+// repeated forms are numbered
+func spelloutForms(s nlp.Spellout) map[string]string {
+	forms := make(map[string]string, len(s))
+	for _, m := range s {
+		key := m.Form
+		for i := 2; ; i++ {
+			if _, exists := forms[key]; !exists {
+				break
+			}
+			key = fmt.Sprintf("%s#%d", m.Form, i)
+		}
+		forms[key] = m.CPOS
+	}
+	return forms
+}
+
+// EvalMDReport evaluates output mappings against gold mappings
+func EvalMDReport(output, gold []nlp.Mappings) (*MDEvalReport, error) {
+	seg, pos, full, lemma, err := EvalMappings(output, gold)
+	if err != nil {
+		return nil, err
+	}
+	report := &MDEvalReport{
+		Sentences:  len(output),
+		Seg:        newMDEvalScore(seg),
+		POS:        newMDEvalScore(pos),
+		Full:       newMDEvalScore(full),
+		Lemma:      newMDEvalScore(lemma),
+		PerPOS:     make(map[string]*MDEvalScore),
+		PerFeature: make(map[string]*MDEvalScore),
+		Confusion:  make(map[string]map[string]int),
+	}
+	for i, mappings := range output {
+		report.Tokens += len(mappings)
+		for j, mapping := range mappings {
+			predPOS, predFeats := spelloutKeys(mapping.Spellout)
+			goldPOS, goldFeats := spelloutKeys(gold[i][j].Spellout)
+			countKeys(report.PerPOS, predPOS, goldPOS)
+			countKeys(report.PerFeature, predFeats, goldFeats)
+
+			predForms := spelloutForms(mapping.Spellout)
+			for form, goldTag := range spelloutForms(gold[i][j].Spellout) {
+				predTag, exists := predForms[form]
+				if !exists {
+					continue
+				}
+				row, exists := report.Confusion[goldTag]
+				if !exists {
+					row = make(map[string]int)
+					report.Confusion[goldTag] = row
+				}
+				row[predTag]++
+			}
+		}
+	}
+	for _, scores := range []map[string]*MDEvalScore{report.PerPOS, report.PerFeature} {
+		for _, score := range scores {
+			score.compute()
+		}
+	}
+	return report, nil
+}
+
+// setMappingTokens sets the tokens of mappings read from lattices, which
+// have only morphemes, to those of the raw input
+func setMappingTokens(mappings []nlp.Mappings, filename string) error {
+	sents, err := raw.ReadFile(filename, 0)
+	if err != nil {
+		return err
+	}
+	if len(sents) != len(mappings) {
+		return fmt.Errorf("Mappings have %d sentences, %s has %d", len(mappings), filename, len(sents))
+	}
+	for i, sent := range sents {
+		if len(sent) != len(mappings[i]) {
+			return fmt.Errorf("Sentence %d has %d mappings, %s has %d tokens", i+1, len(mappings[i]), filename, len(sent))
+		}
+		for j, token := range sent {
+			mappings[i][j].Token = token
+		}
+	}
+	return nil
+}
+
+// mappingsAlignTokens converts mappings to tokens for alignment, with the
+// heads and relations of the rows of parsed sentences, if given
+func mappingsAlignTokens(mappings []nlp.Mappings, graphs []conll.Sentence) ([][]eval.AlignToken, error) {
+	if graphs != nil && len(graphs) != len(mappings) {
+		return nil, fmt.Errorf("Mappings have %d sentences, conll has %d", len(mappings), len(graphs))
+	}
+	sents := make([][]eval.AlignToken, len(mappings))
+	for i, sent := range mappings {
+		var id int
+		sents[i] = make([]eval.AlignToken, len(sent))
+		for j, mapping := range sent {
+			token := eval.AlignToken{Form: string(mapping.Token), Words: make([]eval.AlignWord, 0, len(mapping.Spellout))}
+			for _, m := range mapping.Spellout {
+				id++
+				word := eval.AlignWord{Form: m.Form, Lemma: m.Lemma, POS: m.CPOS, Feats: m.FeatureStr}
+				if graphs != nil {
+					row, exists := graphs[i][id]
+					if !exists {
+						return nil, fmt.Errorf("Sentence %d has no conll row for morpheme %d", i+1, id)
+					}
+					word.Head, word.Rel = row.Head, row.DepRel
+				}
+				token.Words = append(token.Words, word)
+			}
+			sents[i][j] = token
+		}
+		if graphs != nil && len(graphs[i]) != id {
+			return nil, fmt.Errorf("Sentence %d has %d morphemes, conll has %d", i+1, id, len(graphs[i]))
+		}
+	}
+	return sents, nil
+}
+
+// EvalAlignedMDReport evaluates output mappings against gold mappings with
+// tokens aligned by character content, so the sentences and tokens may
+// differ. Attachment is scored if both parsed sentences are given
+func EvalAlignedMDReport(output, gold []nlp.Mappings, outputGraphs, goldGraphs []conll.Sentence) (*MDEvalReport, error) {
+	if outputGraphs == nil || goldGraphs == nil {
+		outputGraphs, goldGraphs = nil, nil
+	}
+	outputTokens, err := mappingsAlignTokens(output, outputGraphs)
+	if err != nil {
+		return nil, err
+	}
+	goldTokens, err := mappingsAlignTokens(gold, goldGraphs)
+	if err != nil {
+		return nil, err
+	}
+	alignment, err := eval.Align(goldTokens, outputTokens)
+	if err != nil {
+		return nil, err
+	}
+	report := &MDEvalReport{
+		Sentences: len(output),
+		Seg:       newMDEvalResultScore(alignment.Words),
+		POS:       newMDEvalResultScore(alignment.POS),
+		Full:      newMDEvalResultScore(alignment.AllTags),
+		Lemma:     newMDEvalResultScore(alignment.Lemmas),
+		Aligned: &MDAlignedScores{
+			Sentences: newMDEvalResultScore(alignment.Sentences),
+			Tokens:    newMDEvalResultScore(alignment.Tokens),
+			Feats:     newMDEvalResultScore(alignment.Feats),
+		},
+	}
+	for _, mappings := range output {
+		report.Tokens += len(mappings)
+	}
+	if outputGraphs != nil {
+		report.Aligned.UAS = newMDEvalResultScore(alignment.UAS)
+		report.Aligned.LAS = newMDEvalResultScore(alignment.LAS)
+	}
+	return report, nil
+}
+
+func sortedScoreKeys(scores map[string]*MDEvalScore) []string {
+	keys := make([]string, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteText writes the report as tables, with the top most confused POS
+// pairs
+func (r *MDEvalReport) WriteText(w io.Writer, confusions int) {
+	fmt.Fprintf(w, "Sentences: %d, Tokens: %d\n\n", r.Sentences, r.Tokens)
+	type metric struct {
+		name  string
+		score *MDEvalScore
+	}
+	metrics := []metric{{"Seg", r.Seg}, {"POS", r.POS}, {"Full", r.Full}, {"Lemma", r.Lemma}}
+	if r.Aligned == nil {
+		fmt.Fprintf(w, "%-12s %8s %8s %8s %8s %8s %8s %8s\n", "Metric", "Gold", "Pred", "TP", "P", "R", "F1", "Exact")
+		for _, m := range metrics {
+			s := m.score
+			fmt.Fprintf(w, "%-12s %8d %8d %8d %8.2f %8.2f %8.2f %8.2f\n", m.name, s.Gold, s.Pred, s.TP, s.Precision, s.Recall, s.F1, s.Exact)
+		}
+	} else {
+		// exact match is per sentence, which aligned sentences need not be
+		a := r.Aligned
+		metrics = append([]metric{{"Sentences", a.Sentences}, {"Tokens", a.Tokens}}, metrics...)
+		metrics = append(metrics, metric{"Feats", a.Feats})
+		if a.UAS != nil {
+			metrics = append(metrics, metric{"UAS", a.UAS}, metric{"LAS", a.LAS})
+		}
+		fmt.Fprintf(w, "%-12s %8s %8s %8s %8s %8s %8s\n", "Metric", "Gold", "Pred", "TP", "P", "R", "F1")
+		for _, m := range metrics {
+			s := m.score
+			fmt.Fprintf(w, "%-12s %8d %8d %8d %8.2f %8.2f %8.2f\n", m.name, s.Gold, s.Pred, s.TP, s.Precision, s.Recall, s.F1)
+		}
+	}
+	for _, table := range []struct {
+		name   string
+		scores map[string]*MDEvalScore
+	}{{"POS", r.PerPOS}, {"Feature", r.PerFeature}} {
+		if len(table.scores) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%-12s %8s %8s %8s %8s %8s %8s\n", table.name, "Gold", "Pred", "TP", "P", "R", "F1")
+		for _, key := range sortedScoreKeys(table.scores) {
+			s := table.scores[key]
+			fmt.Fprintf(w, "%-12s %8d %8d %8d %8.2f %8.2f %8.2f\n", key, s.Gold, s.Pred, s.TP, s.Precision, s.Recall, s.F1)
+		}
+	}
+	if confusions == 0 || len(r.Confusion) == 0 {
+		return
+	}
+	type confusion struct {
+		gold, pred string
+		count      int
+	}
+	var pairs []confusion
+	for goldTag, row := range r.Confusion {
+		for predTag, count := range row {
+			if goldTag != predTag {
+				pairs = append(pairs, confusion{goldTag, predTag, count})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].count != pairs[j].count {
+			return pairs[i].count > pairs[j].count
+		}
+		return pairs[i].gold+"\t"+pairs[i].pred < pairs[j].gold+"\t"+pairs[j].pred
+	})
+	if confusions > 0 && len(pairs) > confusions {
+		pairs = pairs[:confusions]
+	}
+	fmt.Fprintf(w, "\n%-12s %-12s %8s\n", "Gold POS", "Pred POS", "Count")
+	for _, pair := range pairs {
+		fmt.Fprintf(w, "%-12s %-12s %8d\n", pair.gold, pair.pred, pair.count)
+	}
+}
+
+func MDEval(cmd *commander.Command, args []string) error {
+	REQUIRED_FLAGS := []string{"p", "g"}
+	VerifyFlags(cmd, REQUIRED_FLAGS)
+
+	output, err := ReadMappingsFile(mdEvalPred)
+	if err != nil {
+		return err
+	}
+	gold, err := ReadMappingsFile(mdEvalGold)
+	if err != nil {
+		return err
+	}
+	var report *MDEvalReport
+	if mdEvalAlign {
+		if len(mdEvalPredRaw) == 0 || len(mdEvalGoldRaw) == 0 {
+			return errors.New("Aligned evaluation needs the raw tokens of output and gold (-pt, -gt)")
+		}
+		if err = setMappingTokens(output, mdEvalPredRaw); err != nil {
+			return err
+		}
+		if err = setMappingTokens(gold, mdEvalGoldRaw); err != nil {
+			return err
+		}
+		var outputGraphs, goldGraphs []conll.Sentence
+		if len(mdEvalPredConll) > 0 && len(mdEvalGoldConll) > 0 {
+			if outputGraphs, err = conll.ReadFile(mdEvalPredConll, 0); err != nil {
+				return err
+			}
+			if goldGraphs, err = conll.ReadFile(mdEvalGoldConll, 0); err != nil {
+				return err
+			}
+		}
+		report, err = EvalAlignedMDReport(output, gold, outputGraphs, goldGraphs)
+	} else {
+		report, err = EvalMDReport(output, gold)
+	}
+	if err != nil {
+		return err
+	}
+	if len(mdEvalJSON) == 0 {
+		report.WriteText(os.Stdout, mdEvalConfusion)
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if mdEvalJSON == "-" {
+		_, err = fmt.Println(string(data))
+		return err
+	}
+	return ioutil.WriteFile(mdEvalJSON, append(data, '\n'), 0644)
+}
+
+func MDEvalCmd() *commander.Command {
+	cmd := &commander.Command{
+		Run:       MDEval,
+		UsageLine: "md-eval <file options> [arguments]",
+		Short:     "evaluate morphological disambiguation output against gold",
+		Long: `
+evaluate morphological disambiguation output against gold
+
+	$ ./yap md-eval -p <output mapping/lattice> -g <gold mapping/lattice> [-json <file>|-] [options]
+	$ ./yap md-eval -align -p <output mapping/lattice> -pt <output raw tokens> -g <gold mapping/lattice> -gt <gold raw tokens> [-pc <output conll> -gc <gold conll>] [options]
+
+Reports segmentation (form), POS (form and POS), full morphological (form, POS
+and features) and lemma precision, recall, F1 and exact match (sentences),
+POS and feature scores by POS tag and feature name, and the most confused POS
+tags of morphemes segmented as in gold. Both files need the same sentences
+and tokens.
+
+With -align, output tokens and morphemes are aligned to gold by character
+content as in the CoNLL 2018 shared task evaluation, so sentence splitting
+and tokenization of raw input may differ from gold. Lattices have no token
+text, so the raw tokens of each (-pt, -gt, one token per line) are needed,
+in the same sentences and tokens as their lattices. It reports sentence,
+token, segmentation, POS, features, full and lemma scores, and unlabeled and
+labeled attachment scores if the dependency trees of both (-pc, -gc) are
+given, with morphemes in the order of the mappings.
+`,
+		Flag: *flag.NewFlagSet("md-eval", flag.ExitOnError),
+	}
+	cmd.Flag.StringVar(&mdEvalPred, "p", "", "Output Mapping or Lattice File")
+	cmd.Flag.StringVar(&mdEvalGold, "g", "", "Gold Mapping or Lattice File")
+	cmd.Flag.StringVar(&mdEvalJSON, "json", "", "Write the full evaluation as JSON to a file (- for standard output)")
+	cmd.Flag.BoolVar(&mdEvalAlign, "align", false, "Align output and gold tokens by character content")
+	cmd.Flag.StringVar(&mdEvalPredRaw, "pt", "", "Output Raw Tokens File (with -align)")
+	cmd.Flag.StringVar(&mdEvalGoldRaw, "gt", "", "Gold Raw Tokens File (with -align)")
+	cmd.Flag.StringVar(&mdEvalPredConll, "pc", "", "Output Conll File (with -align)")
+	cmd.Flag.StringVar(&mdEvalGoldConll, "gc", "", "Gold Conll File (with -align)")
+	cmd.Flag.IntVar(&mdEvalConfusion, "confusion", 20, "Number of most confused POS pairs to show (-1 for all)")
+	return cmd
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"yap/nlp/format/conll"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of testdata")
+
+// checkGolden compares output to the golden file, or writes it with -update
+func checkGolden(t *testing.T, name string, output []byte) {
+	golden := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, output, 0644); err != nil {
+			t.Fatalf("Failed writing %s: %v", golden, err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed reading %s: %v", golden, err)
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("Expected %s:\n%s\ngot:\n%s", golden, expected, output)
+	}
+}
+
+func TestEvalMDReportGolden(t *testing.T) {
+	output, err := ReadMappingsFile(filepath.Join("testdata", "mdeval.pred.lattice"))
+	if err != nil {
+		t.Fatalf("Failed reading output: %v", err)
+	}
+	gold, err := ReadMappingsFile(filepath.Join("testdata", "mdeval.gold.lattice"))
+	if err != nil {
+		t.Fatalf("Failed reading gold: %v", err)
+	}
+	report, err := EvalMDReport(output, gold)
+	if err != nil {
+		t.Fatalf("Failed evaluating: %v", err)
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text, 10)
+	checkGolden(t, "mdeval.golden.txt", text.Bytes())
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed writing JSON: %v", err)
+	}
+	checkGolden(t, "mdeval.golden.json", append(data, '\n'))
+}
+
+// TestEvalAlignedMDReportGolden evaluates output whose tokenization differs
+// from the gold, a final period is attached to the preceding token
+func TestEvalAlignedMDReportGolden(t *testing.T) {
+	output, err := ReadMappingsFile(filepath.Join("testdata", "mdeval.align.pred.lattice"))
+	if err != nil {
+		t.Fatalf("Failed reading output: %v", err)
+	}
+	gold, err := ReadMappingsFile(filepath.Join("testdata", "mdeval.align.gold.lattice"))
+	if err != nil {
+		t.Fatalf("Failed reading gold: %v", err)
+	}
+	if err := setMappingTokens(output, filepath.Join("testdata", "mdeval.align.pred.raw")); err != nil {
+		t.Fatalf("Failed setting output tokens: %v", err)
+	}
+	if err := setMappingTokens(gold, filepath.Join("testdata", "mdeval.align.gold.raw")); err != nil {
+		t.Fatalf("Failed setting gold tokens: %v", err)
+	}
+	outputGraphs, err := conll.ReadFile(filepath.Join("testdata", "mdeval.align.pred.conll"), 0)
+	if err != nil {
+		t.Fatalf("Failed reading output conll: %v", err)
+	}
+	goldGraphs, err := conll.ReadFile(filepath.Join("testdata", "mdeval.align.gold.conll"), 0)
+	if err != nil {
+		t.Fatalf("Failed reading gold conll: %v", err)
+	}
+	report, err := EvalAlignedMDReport(output, gold, outputGraphs, goldGraphs)
+	if err != nil {
+		t.Fatalf("Failed evaluating: %v", err)
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text, 10)
+	checkGolden(t, "mdeval.align.golden.txt", text.Bytes())
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed writing JSON: %v", err)
+	}
+	checkGolden(t, "mdeval.align.golden.json", append(data, '\n'))
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"log"
+
+	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
+	"yap/nlp/parser/dependency"
+)
+
+// PseudoProjective projectivizes the training trees and deprojectivizes the
+// parser output, see dependency.Projectivize. The relations are extended
+// with their encoded variations, so models trained with it must be used
+// with it
+var PseudoProjective bool
+
+// PseudoProjectiveRelations returns the relations of the labels file,
+// extended when parsing pseudo-projectively
+func PseudoProjectiveRelations(relations []string) []string {
+	if !PseudoProjective {
+		return relations
+	}
+	return dependency.PseudoProjectiveRelations(relations)
+}
+
+func conllTree(sent conll.Sentence) ([]int, []string) {
+	heads, rels := make([]int, len(sent)+1), make([]string, len(sent)+1)
+	for id, row := range sent {
+		heads[id], rels[id] = row.Head, row.DepRel
+	}
+	return heads, rels
+}
+
+func setConllTree(sent conll.Sentence, heads []int, rels []string) {
+	for id, row := range sent {
+		row.Head, row.DepRel = heads[id], rels[id]
+		sent[id] = row
+	}
+}
+
+func conllUTree(sent *conllu.Sentence) ([]int, []string) {
+	heads, rels := make([]int, len(sent.Deps)+1), make([]string, len(sent.Deps)+1)
+	for id, row := range sent.Deps {
+		heads[id], rels[id] = row.Head, row.DepRel
+	}
+	return heads, rels
+}
+
+func setConllUTree(sent *conllu.Sentence, heads []int, rels []string) {
+	for id, row := range sent.Deps {
+		row.Head, row.DepRel = heads[id], rels[id]
+		sent.Deps[id] = row
+	}
+}
+
+func projectivize(heads []int, rels []string, nonProjective, lifts *int) {
+	if dependency.IsProjective(heads) {
+		return
+	}
+	*nonProjective++
+	*lifts += dependency.Projectivize(heads, rels)
+}
+
+func logProjectivized(nonProjective, total, lifts int) {
+	if allOut {
+		log.Printf("Pseudo-projective:\t%d of %d training sentences were non-projective, lifted %d arcs", nonProjective, total, lifts)
+	}
+}
+
+// ProjectivizeConll projectivizes training sentences in place
+func ProjectivizeConll(sents []conll.Sentence) {
+	var nonProjective, lifts int
+	for _, sent := range sents {
+		heads, rels := conllTree(sent)
+		projectivize(heads, rels, &nonProjective, &lifts)
+		setConllTree(sent, heads, rels)
+	}
+	logProjectivized(nonProjective, len(sents), lifts)
+}
+
+// ProjectivizeConllU projectivizes training sentences in place
+func ProjectivizeConllU(sents []*conllu.Sentence) {
+	var nonProjective, lifts int
+	for _, sent := range sents {
+		heads, rels := conllUTree(sent)
+		projectivize(heads, rels, &nonProjective, &lifts)
+		setConllUTree(sent, heads, rels)
+	}
+	logProjectivized(nonProjective, len(sents), lifts)
+}
+
+// Deprojectivize deprojectivizes a conll or conllu output sentence in place
+func Deprojectivize(sent interface{}) interface{} {
+	switch s := sent.(type) {
+	case conll.Sentence:
+		heads, rels := conllTree(s)
+		dependency.Deprojectivize(heads, rels)
+		setConllTree(s, heads, rels)
+	case conllu.Sentence:
+		heads, rels := conllUTree(&s)
+		dependency.Deprojectivize(heads, rels)
+		setConllUTree(&s, heads, rels)
+	default:
+		panic("Can't deprojectivize, unknown sentence type")
+	}
+	return sent
+}
+
+// DeprojectivizeCorpus deprojectivizes output sentences, if parsing
+// pseudo-projectively
+func DeprojectivizeCorpus(sents []interface{}) []interface{} {
+	if PseudoProjective {
+		for _, sent := range sents {
+			Deprojectivize(sent)
+		}
+	}
+	return sents
+}
+
+// DeprojectivizeStream deprojectivizes streamed output sentences, if
+// parsing pseudo-projectively
+func DeprojectivizeStream(sents chan interface{}) chan interface{} {
+	if !PseudoProjective {
+		return sents
+	}
+	out := make(chan interface{}, 2)
+	go func() {
+		for sent := range sents {
+			out <- Deprojectivize(sent)
+		}
+		close(out)
+	}()
+	return out
+}
@@ -8,7 +8,10 @@ import (
 	"yap/alg/transition/model"
 	// dep "yap/nlp/parser/dependency/transition"
 	"yap/eval"
+	"yap/nlp/format/cluster"
 	"yap/nlp/format/conll"
+	"yap/nlp/format/embedding"
+	"yap/nlp/format/lattice"
 	"yap/nlp/format/mapping"
 	"yap/nlp/format/raw"
 	"yap/nlp/format/segmentation"
@@ -24,6 +27,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	// "runtime"
 	"time"
 	// "strings"
@@ -47,10 +51,17 @@ var (
 	Iterations			int
 	BeamSize          int
 	ConcurrentBeam       bool
+	LazyBeam             bool
 	NumFeatures          int
 	UsePOP               bool
 	limit                int
 	Stream               bool
+	NBest                int
+	outNBest             string
+	Lemmatize            bool
+
+	// lemma model of the MD/joint model
+	Lemmatizer *disambig.LemmaModel
 
 	// global enumerations
 	ERel, ETrans, EWord, EPOS, EWPOS, EMHost, EMSuffix *util.EnumSet
@@ -61,7 +72,7 @@ var (
 	//DepEMorphProp                                         *util.EnumSet
 
 	// enumeration offsets of transitions
-	SH, RE, PR, LA, RA, IDLE, POP, MD transition.Transition
+	SH, RE, PR, SW, LA, RA, IDLE, POP, MD transition.Transition
 	//DepSH, DepRE, DepPR, DepLA, DepRA, DepIDLE, DepPOP, DepMD transition.Transition
 
 	// file names
@@ -106,6 +117,66 @@ type Serialization struct {
 	EMorphProp                           *util.EnumSet
 	ETrans                               *util.EnumSet
 	ETokens                              *util.EnumSet
+	Lemmas                               *disambig.LemmaModel
+	Feats                                *FeatsTaggerModel
+	NeuralModel                          *model.Neural
+	Config                               *ModelConfig
+}
+
+// ModelConfig records the training options a model must be parsed with
+type ModelConfig struct {
+	PseudoProjective bool
+	// word cluster files and the number of embedding clusters, the cluster
+	// feature values are meaningless with other clusters
+	ClustersFile          string
+	EmbeddingClustersFile string
+	EmbeddingClusters     int
+}
+
+// NewModelConfig returns the configuration of the model being trained
+func NewModelConfig() *ModelConfig {
+	return &ModelConfig{
+		PseudoProjective:      PseudoProjective,
+		ClustersFile:          ClustersFile,
+		EmbeddingClustersFile: EmbeddingClustersFile,
+		EmbeddingClusters:     EmbeddingClusters,
+	}
+}
+
+// Apply sets the options the model was trained with, overriding the
+// command line. Models saved without a configuration are left as is.
+// Cluster files missing from the command line are set by the model, other
+// cluster files than the model's are fatal; only their directory may differ
+func (c *ModelConfig) Apply() {
+	if c == nil {
+		return
+	}
+	if c.PseudoProjective != PseudoProjective {
+		log.Printf("Pseudo-projective:\t%v (set by model)", c.PseudoProjective)
+		PseudoProjective = c.PseudoProjective
+	}
+	applyModelFile("Word clusters", &ClustersFile, c.ClustersFile)
+	applyModelFile("Embedding clusters", &EmbeddingClustersFile, c.EmbeddingClustersFile)
+	if len(c.EmbeddingClustersFile) > 0 && c.EmbeddingClusters != EmbeddingClusters {
+		log.Printf("Embedding clusters:\t%d (set by model)", c.EmbeddingClusters)
+		EmbeddingClusters = c.EmbeddingClusters
+	}
+}
+
+// applyModelFile checks the file option of the command line against the file
+// the model was trained with
+func applyModelFile(name string, file *string, modelFile string) {
+	switch {
+	case *file == modelFile:
+	case len(modelFile) == 0:
+		log.Printf("%s:\tnone (set by model, ignoring %s)", name, *file)
+		*file = ""
+	case len(*file) == 0:
+		log.Printf("%s:\t%s (set by model)", name, modelFile)
+		*file = modelFile
+	case filepath.Base(*file) != filepath.Base(modelFile):
+		log.Fatalf("%s %s differ from %s of the model", name, *file, modelFile)
+	}
 }
 
 func WriteModel(file string, data *Serialization) {
@@ -165,7 +236,13 @@ func SetupTransEnum(relations []string) {
 	SH = transition.ConstTransition(iSH)
 	RE = transition.ConstTransition(iRE)
 	PR = transition.ConstTransition(iPR)
-	LA = transition.ConstTransition(iPR + 1)
+	// swap is only added for the swap arc system, so the transitions of
+	// models of the other arc systems keep their values
+	if DepArcSystemStr == "swap" {
+		iSW, _ := ETrans.Add("SW")
+		SW = transition.ConstTransition(iSW)
+	}
+	LA = transition.ConstTransition(ETrans.Len())
 	ETrans.Add("LA-" + string(nlp.ROOT_LABEL))
 	for _, transition := range relations {
 		ETrans.Add("LA-" + string(transition))
@@ -189,9 +266,14 @@ func SetupMorphTransEnum(relations []string) {
 	SH = transition.ConstTransition(iSH)
 	RE = transition.ConstTransition(iRE)
 	PR = transition.ConstTransition(iPR)
+	// see SetupTransEnum
+	if DepArcSystemStr == "swap" {
+		iSW, _ := ETrans.Add("SW")
+		SW = transition.ConstTransition(iSW)
+	}
 	// IDLE = transition.Transition(iIDLE)
 	// LA = IDLE + 1
-	LA = transition.ConstTransition(iPR + 1)
+	LA = transition.ConstTransition(ETrans.Len())
 	ETrans.Add("LA-" + string(nlp.ROOT_LABEL))
 	for _, transition := range relations {
 		ETrans.Add("LA-" + string(transition))
@@ -403,8 +485,187 @@ func MorphEval(test, gold interface{}, metric string) *eval.Result {
 	return retval
 }
 
-func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename string, paramModel perceptron.Model, decoder perceptron.EarlyUpdateInstanceDecoder, goldDecoder perceptron.InstanceDecoder, converge perceptron.StopCondition) *perceptron.LinearPerceptron {
-	updater := new(model.AveragedModelStrategy)
+// BeamUpdate names the update strategy of beam training
+var BeamUpdate string = "early"
+
+// BeamUpdateStrategy returns the update strategy named by BeamUpdate
+func BeamUpdateStrategy() search.UpdateStrategy {
+	strategy, err := search.ParseUpdateStrategy(BeamUpdate)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return strategy
+}
+
+// Word cluster files of the cluster feature attributes (c<bits>, e), the
+// model records them for parsing
+var (
+	ClustersFile          string
+	EmbeddingClustersFile string
+	EmbeddingClusters     int = 100
+)
+
+// LoadWordClusters reads the word cluster files, it returns nil without any
+func LoadWordClusters() *nlp.WordClusters {
+	if len(ClustersFile) == 0 && len(EmbeddingClustersFile) == 0 {
+		return nil
+	}
+	clusters := nlp.NewWordClusters()
+	if len(ClustersFile) > 0 {
+		if err := cluster.ReadFile(ClustersFile, clusters); err != nil {
+			log.Fatalln("Failed reading word clusters", ClustersFile, err)
+		}
+		if allOut {
+			log.Println("Read", len(clusters.Brown), "word clusters from", ClustersFile)
+		}
+	}
+	if len(EmbeddingClustersFile) > 0 {
+		vectors, _, err := embedding.ReadFile(EmbeddingClustersFile)
+		if err != nil {
+			log.Fatalln("Failed reading embeddings", EmbeddingClustersFile, err)
+		}
+		clusters.AddEmbeddings(vectors, EmbeddingClusters, 10)
+		if allOut {
+			log.Println("Clustered", len(vectors), "embeddings from", EmbeddingClustersFile, "into", EmbeddingClusters, "clusters")
+		}
+	}
+	return clusters
+}
+
+// With FixedIterations the eval stop conditions stop after the number of
+// iterations instead of at convergence (e.g. for ablations). DevResult is
+// the dev score of their last evaluation: LAS for dep, F1 for md and joint
+var (
+	FixedIterations bool
+	DevResult       float64
+)
+
+// TrainWorkers is the number of parallel training workers
+var TrainWorkers int = 1
+
+// BeamDecoders makes a copy of a training beam for each parallel worker
+func BeamDecoders(beam *search.Beam) perceptron.DecoderFactory {
+	return func() perceptron.EarlyUpdateInstanceDecoder {
+		workerBeam := &search.Beam{}
+		*workerBeam = *beam
+		return workerBeam
+	}
+}
+
+// Learner names the update strategy of training
+var Learner string = "perceptron"
+
+// Learners are the names accepted for Learner
+var Learners = "perceptron, mira"
+
+// LearnerC is the aggressiveness of passive-aggressive (mira) updates
+var LearnerC float64 = 1.0
+
+// LearnerScale is the weight of a passive-aggressive step of 1, the weights
+// are integers
+var LearnerScale int64 = 100
+
+// LearnerStrategy returns the update strategy named by Learner
+func LearnerStrategy() perceptron.UpdateStrategy {
+	switch Learner {
+	case "perceptron":
+		return new(model.AveragedModelStrategy)
+	case "mira":
+		return &model.PassiveAggressiveStrategy{C: LearnerC, Scale: LearnerScale, Loss: HammingLoss}
+	default:
+		log.Fatalln("Unknown learner", Learner, "expected one of:", Learners)
+	}
+	return nil
+}
+
+// HammingLoss is the loss of passive-aggressive updates: the number of arcs
+// and morphemes of the decoded configuration that differ from the gold
+// prefix of the same length
+func HammingLoss(gold, decoded perceptron.DecodedInstance) int64 {
+	goldSequence, goldOk := gold.Decoded().(search.ScoredConfigurations)
+	parsed, parsedOk := decoded.Decoded().(transition.Configuration)
+	if !goldOk || !parsedOk || len(goldSequence) == 0 {
+		// the dynamic oracle has no gold sequence, it updates on a single
+		// wrong transition
+		return 1
+	}
+	index := parsed.Len() - 1
+	if index >= len(goldSequence) {
+		index = len(goldSequence) - 1
+	}
+	goldConf := goldSequence[index].C
+	return arcLoss(goldConf, parsed) + morphemeLoss(goldConf, parsed)
+}
+
+func configurationArcs(configuration transition.Configuration) map[int]nlp.LabeledDepArc {
+	var arcSet dep.ArcSet
+	switch conf := configuration.(type) {
+	case *dep.SimpleConfiguration:
+		arcSet = conf.Arcs()
+	case *joint.JointConfig:
+		arcSet = conf.SimpleConfiguration.Arcs()
+	default:
+		return nil
+	}
+	arcs := make(map[int]nlp.LabeledDepArc)
+	for _, arc := range arcSet.(*dep.ArcSetSimple).Arcs {
+		arcs[arc.GetModifier()] = arc
+	}
+	return arcs
+}
+
+// arcLoss is the number of modifiers with a different arc in the
+// configurations
+func arcLoss(gold, parsed transition.Configuration) int64 {
+	var loss int64
+	goldArcs, parsedArcs := configurationArcs(gold), configurationArcs(parsed)
+	for modifier, goldArc := range goldArcs {
+		parsedArc, exists := parsedArcs[modifier]
+		if !exists || parsedArc.GetHead() != goldArc.GetHead() || parsedArc.GetRelation() != goldArc.GetRelation() {
+			loss++
+		}
+	}
+	for modifier := range parsedArcs {
+		if _, exists := goldArcs[modifier]; !exists {
+			loss++
+		}
+	}
+	return loss
+}
+
+// morphemeLoss is the number of morphemes that differ in the spellouts of
+// the tokens disambiguated by both configurations
+func morphemeLoss(gold, parsed transition.Configuration) int64 {
+	var loss int64
+	switch gold.(type) {
+	case *disambig.MDConfig, *joint.JointConfig:
+	default:
+		return 0
+	}
+	goldMappings, parsedMappings := configurationMD(gold).Mappings, configurationMD(parsed).Mappings
+	for i := 0; i < len(goldMappings) && i < len(parsedMappings); i++ {
+		goldSpellout, parsedSpellout := goldMappings[i].Spellout, parsedMappings[i].Spellout
+		length := len(goldSpellout)
+		if len(parsedSpellout) > length {
+			length = len(parsedSpellout)
+		}
+		for j := 0; j < length; j++ {
+			if j >= len(goldSpellout) || j >= len(parsedSpellout) || !goldSpellout[j].Equal(parsedSpellout[j]) {
+				loss++
+			}
+		}
+	}
+	return loss
+}
+
+// Train trains the model; with more than one TrainWorkers and a decoder
+// factory, it trains in parallel with iterative parameter mixing
+func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename string, paramModel perceptron.Model, decoder perceptron.EarlyUpdateInstanceDecoder, goldDecoder perceptron.InstanceDecoder, converge perceptron.StopCondition, newDecoder perceptron.DecoderFactory) *perceptron.LinearPerceptron {
+	// averaging and passive-aggressive updates are of the sparse model
+	var updater perceptron.UpdateStrategy = new(perceptron.TrivialStrategy)
+	if _, isSparse := paramModel.(*model.AvgMatrixSparse); isSparse {
+		updater = LearnerStrategy()
+	}
 
 	perceptron := &perceptron.LinearPerceptron{
 		Decoder:     decoder,
@@ -413,6 +674,9 @@ func Train(trainingSet []perceptron.DecodedInstance, Iterations int, filename st
 		Continue:    converge,
 		Tempfile:    filename,
 		TempLines:   500}
+	if TrainWorkers > 1 && newDecoder != nil {
+		perceptron.Workers, perceptron.NewDecoder = TrainWorkers, newDecoder
+	}
 
 	perceptron.Iterations = Iterations
 	perceptron.Init(paramModel)
@@ -448,6 +712,8 @@ func ParseStream(instances chan interface{}, writeStream chan interface{}, parse
 		log.Println("Parsing instance", i) //, "len", len(sent.Tokens()))
 		// }
 		result, _ := parser.Parse(instance)
+		TagFeatsInstance(result)
+		LemmatizeInstance(result)
 		writeStream <- result
 		i++
 	}
@@ -475,6 +741,8 @@ func Parse(instances []interface{}, parser Parser) []interface{} {
 		log.Println("Parsing instance", i) //, "len", len(sent.Tokens()))
 		// }
 		result, _ := parser.Parse(instance)
+		TagFeatsInstance(result)
+		LemmatizeInstance(result)
 		parsed[i] = result
 	}
 	if allOut {
@@ -485,6 +753,117 @@ func Parse(instances []interface{}, parser Parser) []interface{} {
 	return parsed
 }
 
+type NBestParser interface {
+	ParseNBest(search.Problem, int) ([]transition.Configuration, []float64)
+}
+
+// ParseNBest parses instances keeping the K best distinct disambiguation
+// paths of each, and returns the best configuration of each instance along
+// with its n-best paths
+func ParseNBest(instances []interface{}, parser NBestParser, K int) ([]interface{}, []*disambig.NBest) {
+	startTime := time.Now()
+	parsed := make([]interface{}, len(instances))
+	nbests := make([]*disambig.NBest, len(instances))
+	for i, instance := range instances {
+		log.Println("Parsing instance", i)
+		// the whole beam is requested since paths may differ only in
+		// their transitions and not in their mappings
+		configurations, scores := parser.ParseNBest(instance, BeamSize)
+		paths := make([]*disambig.MDConfig, len(configurations))
+		for j, configuration := range configurations {
+			paths[j] = configurationMD(configuration)
+			TagFeatsInstance(configuration)
+			LemmatizeInstance(configuration)
+		}
+		parsed[i] = configurations[0]
+		nbests[i] = disambig.NewNBest(paths, scores, K)
+	}
+	if allOut {
+		parseTime := time.Since(startTime)
+		log.Println("PARSE Total Time:", parseTime)
+	}
+	return parsed, nbests
+}
+
+// LemmatizeInstance fills the lemmas of a parsed MD or joint configuration
+// if Lemmatize is set, other configurations are left as is. Lemmas chosen
+// by the parser are kept if lemmas are used (-nolemma=false)
+func LemmatizeInstance(instance interface{}) {
+	if !Lemmatize {
+		return
+	}
+	switch conf := instance.(type) {
+	case *disambig.MDConfig:
+		Lemmatizer.Lemmatize(conf, !lattice.IGNORE_LEMMA)
+	case *joint.JointConfig:
+		Lemmatizer.Lemmatize(&conf.MDConfig, !lattice.IGNORE_LEMMA)
+	}
+}
+
+// TrainLemmatizer sets Lemmatizer to a lemma model of the gold
+// disambiguated lattices
+func TrainLemmatizer(goldDisLat []interface{}) {
+	Lemmatizer = disambig.NewLemmaModel()
+	for _, sent := range goldDisLat {
+		if latSent, ok := sent.(nlp.LatticeSentence); ok {
+			Lemmatizer.AddSentence(latSent)
+		}
+	}
+}
+
+// ReadULLattices reads ambiguous UD lattices, when ignoring lemmas their
+// lemmas are dropped as they are in the CoNLL-U gold, so that gold paths
+// are found in the lattices
+func ReadULLattices(filename string, limit int) ([]lattice.Lattice, error) {
+	lats, err := lattice.ReadULFile(filename, limit)
+	if err != nil || !conllu.IGNORE_LEMMA {
+		return lats, err
+	}
+	for _, lat := range lats {
+		for _, edges := range lat {
+			for i := range edges {
+				edges[i].Lemma = ""
+			}
+		}
+	}
+	return lats, nil
+}
+
+// NBestFile is the n-best output file, by default next to the mapping file
+func NBestFile(outMap string) string {
+	if len(outNBest) > 0 {
+		return outNBest
+	}
+	return outMap + ".nbest"
+}
+
+// WriteNBest writes the n-best paths of parsed instances, the regular
+// outputs are written as without n-best
+func WriteNBest(outMap string, nbests []*disambig.NBest) error {
+	filename := NBestFile(outMap)
+	if allOut {
+		log.Println("Writing to n-best mapping file")
+	}
+	if err := mapping.WriteNBestFile(filename, nbests); err != nil {
+		return err
+	}
+	if allOut {
+		log.Println("Wrote n-best paths of", len(nbests), "sentences in mapping format to", filename)
+	}
+	return nil
+}
+
+func configurationMD(configuration transition.Configuration) *disambig.MDConfig {
+	switch conf := configuration.(type) {
+	case *disambig.MDConfig:
+		return conf
+	case *joint.JointConfig:
+		return &conf.MDConfig
+	default:
+		panic(fmt.Sprintf("Configuration %T has no morphological disambiguation", configuration))
+	}
+}
+
 func GetMDConfigAsLattices(instance interface{}) util.Equaler {
 	return instance.(*disambig.MDConfig).Lattices
 }
@@ -544,6 +923,7 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 		var posonlytotal = &eval.Total{
 			Results: make([]*eval.Result, 0, len(instances)),
 		}
+		var lemmatotal = &eval.Total{}
 		// Don't test before initial run
 		if curIteration == 0 {
 			return true
@@ -568,6 +948,7 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 				// log.Println("Correct: ", result.TP)
 				total.Add(result)
 				posonlytotal.Add(posresult)
+				lemmatotal.Add(MorphEval(instance, goldInstance.Decoded(), "Lemma"))
 			}
 		}
 		curResult = total.F1()
@@ -577,8 +958,12 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 			equalIterations += 1
 		}
 		retval := (curIteration >= iterations) && (curResult < prevResult || equalIterations > 2)
+		if FixedIterations {
+			retval = curIteration >= iterations
+		}
+		DevResult = curResult
 		// retval := curIteration >= iterations
-		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult)
+		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult, "Lemma F1:", lemmatotal.F1())
 		if retval {
 			log.Println("Stopping")
 		} else {
@@ -595,6 +980,7 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 			testposonlytotal := &eval.Total{
 				Results: make([]*eval.Result, 0, len(instances)),
 			}
+			testlemmatotal := &eval.Total{}
 			testParsed := Parse(testInstances, parser)
 			testGoldInstances := TrainingSequences(testGoldInstances, GetMDConfigAsLattices, GetMDConfigAsMappings)
 			log.Println("START Test Evaluation")
@@ -614,10 +1000,11 @@ func MakeMorphEvalStopCondition(instances []interface{}, goldInstances []interfa
 					// log.Println("Correct: ", result.TP)
 					testTotal.Add(result)
 					testposonlytotal.Add(posresult)
+					testlemmatotal.Add(MorphEval(instance, testInstance.Decoded(), "Lemma"))
 
 				}
 			}
-			log.Println("Test Result (F1): ", testTotal.F1(), "Exact:", testTotal.Exact, "TruePos:", testTotal.TP, "in", testTotal.Population, "POS F1:", testposonlytotal.F1())
+			log.Println("Test Result (F1): ", testTotal.F1(), "Exact:", testTotal.Exact, "TruePos:", testTotal.TP, "in", testTotal.Population, "POS F1:", testposonlytotal.F1(), "Lemma F1:", testlemmatotal.F1())
 			log.Println("Writing test results to", fmt.Sprintf("test.i%v.b%v.%v", curIteration, beamSize, outMap))
 			mapping.WriteFile(fmt.Sprintf("test.i%v.b%v.%v", curIteration, beamSize, outMap), testParsed)
 			raw.WriteFile(fmt.Sprintf("err.test.i%v.b%v.%v.raw", curIteration, beamSize, outMap), testErrorVectors)
@@ -675,7 +1062,11 @@ func MakeDepEvalStopCondition(instances []interface{}, goldInstances []interface
 		if curResult == prevResult {
 			equalIterations += 1
 		}
-		retval := (Iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
+		retval := (iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
+		if FixedIterations {
+			retval = curIteration >= iterations
+		}
+		DevResult = curResult
 		// retval := curIteration >= iterations
 		log.Println("Result (UAS, LAS, UEM #, UEM %): ", utotal.Precision(), total.Precision(), utotal.Exact, float64(utotal.Exact)/float64(total.Population), "TruePos:", total.TP, "in", total.Population)
 		if retval {
@@ -773,8 +1164,12 @@ func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interfa
 			bestIteration = curIteration
 			bestModelFile = curModelFile
 		}
-		retval := (Iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
-		log.Println("It", Iterations, "CurIt", curIteration, "Continuous", continuousDecreases, "CurResult", curResult, "PrevResult", prevResult, "Comp", curResult < prevResult, "Retval", retval)
+		retval := (iterations < curIteration) && ((continuousDecreases > 1 && curResult < prevResult) || equalIterations > 3)
+		if FixedIterations {
+			retval = curIteration >= iterations
+		}
+		DevResult = curResult
+		log.Println("It", iterations, "CurIt", curIteration, "Continuous", continuousDecreases, "CurResult", curResult, "PrevResult", prevResult, "Comp", curResult < prevResult, "Retval", retval)
 		// retval := curIteration >= iterations
 		log.Println("Result (F1): ", curResult, "Exact:", total.Exact, "TruePos:", total.TP, "in", total.Population, "POS F1:", curPosResult)
 		if retval {
@@ -840,8 +1235,15 @@ func MakeJointEvalStopCondition(instances []interface{}, goldInstances []interfa
 
 func serialize(perceptronModel perceptron.Model, iteration, generations int) string {
 	serialization := &Serialization{
-		perceptronModel.(*model.AvgMatrixSparse).Serialize(generations),
-		EWord, EPOS, EWPOS, EMHost, EMSuffix, EMorphProp, ETrans, ETokens,
+		EWord: EWord, EPOS: EPOS, EWPOS: EWPOS, EMHost: EMHost, EMSuffix: EMSuffix,
+		EMorphProp: EMorphProp, ETrans: ETrans, ETokens: ETokens,
+		Lemmas: Lemmatizer, Feats: FeatsTagger,
+		Config: NewModelConfig(),
+	}
+	if neural, isNeural := perceptronModel.(*model.Neural); isNeural {
+		serialization.NeuralModel = neural
+	} else {
+		serialization.WeightModel = perceptronModel.(*model.AvgMatrixSparse).Serialize(generations)
 	}
 	modelFile := fmt.Sprintf("model.temp.i%d", iteration)
 	WriteModel(modelFile, serialization)
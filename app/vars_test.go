@@ -0,0 +1,202 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"yap/alg/featurevector"
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	"yap/alg/transition"
+	transitionmodel "yap/alg/transition/model"
+	dep "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+func TestModelConfigRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yapmodel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(pp bool) { PseudoProjective = pp }(PseudoProjective)
+
+	file := filepath.Join(dir, "model")
+	PseudoProjective = true
+	WriteModel(file, &Serialization{Config: NewModelConfig()})
+
+	PseudoProjective = false
+	serialization := ReadModel(file)
+	if serialization.Config == nil {
+		t.Fatal("Expected model config to be read")
+	}
+	serialization.Config.Apply()
+	if !PseudoProjective {
+		t.Errorf("Expected pseudo-projective set by model")
+	}
+
+	// models saved without a config keep the command line setting
+	var missing *ModelConfig
+	missing.Apply()
+	if !PseudoProjective {
+		t.Errorf("Expected pseudo-projective unchanged by missing config")
+	}
+}
+
+func TestModelConfigClusters(t *testing.T) {
+	defer func(clusters, embeddings string, k int) {
+		ClustersFile, EmbeddingClustersFile, EmbeddingClusters = clusters, embeddings, k
+	}(ClustersFile, EmbeddingClustersFile, EmbeddingClusters)
+
+	ClustersFile, EmbeddingClustersFile, EmbeddingClusters = "data/paths", "data/vectors.txt", 50
+	config := NewModelConfig()
+
+	// missing files and the number of clusters are set by the model
+	ClustersFile, EmbeddingClustersFile, EmbeddingClusters = "", "", 100
+	config.Apply()
+	if ClustersFile != "data/paths" || EmbeddingClustersFile != "data/vectors.txt" || EmbeddingClusters != 50 {
+		t.Errorf("Expected clusters set by model, got %s, %s (%d)", ClustersFile, EmbeddingClustersFile, EmbeddingClusters)
+	}
+	// the files may move
+	ClustersFile = "/other/paths"
+	config.Apply()
+	if ClustersFile != "/other/paths" {
+		t.Errorf("Expected moved clusters file kept, got %s", ClustersFile)
+	}
+	// clusters are ignored by models trained without them
+	ClustersFile, EmbeddingClusters = "data/paths", 100
+	(&ModelConfig{}).Apply()
+	if ClustersFile != "" || EmbeddingClustersFile != "" || EmbeddingClusters != 100 {
+		t.Errorf("Expected no clusters set by model, got %s, %s (%d)", ClustersFile, EmbeddingClustersFile, EmbeddingClusters)
+	}
+}
+
+func TestNeuralModelRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yapmodel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	neural := transitionmodel.NewNeural([]string{"w", "p", "w"}, 4, 5, 0.01, 1)
+	features := [][]featurevector.Feature{
+		{"a", [2]interface{}{"N", 1}, nil},
+		{"b", []interface{}{"N", "V"}, "a"},
+		{"unseen", "X", "b"},
+	}
+	neural.Add(&transition.FeaturesList{
+		Transition: transition.ConstTransition(1),
+		Previous: &transition.FeaturesList{
+			Features:   features[0],
+			Transition: transition.ConstTransition(3),
+			Previous:   &transition.FeaturesList{Features: features[1]},
+		},
+	})
+
+	file := filepath.Join(dir, "model")
+	WriteModel(file, &Serialization{NeuralModel: neural})
+	read := ReadModel(file).NeuralModel
+	if read == nil {
+		t.Fatal("Expected neural model to be read")
+	}
+	for _, feats := range features {
+		for _, trans := range []int{0, 1, 3} {
+			expected := neural.TransitionScore(transition.ConstTransition(trans), feats)
+			if score := read.TransitionScore(transition.ConstTransition(trans), feats); score != expected {
+				t.Errorf("Expected score %d of transition %d of %v, got %d", expected, trans, feats, score)
+			}
+		}
+	}
+}
+
+// arcConf is a dependency configuration with arcs of head, modifier and
+// relation
+func arcConf(arcs ...dep.BasicDepArc) *dep.SimpleConfiguration {
+	arcSet := dep.NewArcSetSimple(len(arcs))
+	for i := range arcs {
+		arcSet.Add(&arcs[i])
+	}
+	return &dep.SimpleConfiguration{InternalArcs: arcSet}
+}
+
+func TestArcLoss(t *testing.T) {
+	gold := arcConf(
+		dep.BasicDepArc{Head: 1, Modifier: 0, RawRelation: "ATT"},
+		dep.BasicDepArc{Head: 2, Modifier: 1, RawRelation: "SBJ"},
+		dep.BasicDepArc{Head: -1, Modifier: 2, RawRelation: nlp.ROOT_LABEL},
+		dep.BasicDepArc{Head: 2, Modifier: 3, RawRelation: "OBJ"},
+	)
+	parsed := arcConf(
+		dep.BasicDepArc{Head: 1, Modifier: 0, RawRelation: "ATT"},
+		dep.BasicDepArc{Head: 0, Modifier: 1, RawRelation: "SBJ"},
+		dep.BasicDepArc{Head: 2, Modifier: 3, RawRelation: "ATT"},
+		dep.BasicDepArc{Head: 3, Modifier: 4, RawRelation: "ATT"},
+	)
+	// wrong head of 1, no arc of 2, wrong relation of 3, extra arc of 4
+	if loss := arcLoss(gold, parsed); loss != 4 {
+		t.Errorf("Expected arc loss 4, got %d", loss)
+	}
+	if loss := arcLoss(gold, gold); loss != 0 {
+		t.Errorf("Expected arc loss 0 of the gold, got %d", loss)
+	}
+}
+
+func lossMorph(form, pos string) *nlp.EMorpheme {
+	return &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: form, CPOS: pos, POS: pos}}
+}
+
+func TestMorphemeLoss(t *testing.T) {
+	gold := &disambig.MDConfig{Mappings: nlp.Mappings{
+		{Token: "HBIT", Spellout: nlp.Spellout{lossMorph("H", "DEF"), lossMorph("BIT", "NN")}},
+		{Token: "GDWL", Spellout: nlp.Spellout{lossMorph("GDWL", "JJ")}},
+		{Token: "MAWD", Spellout: nlp.Spellout{lossMorph("MAWD", "RB")}},
+	}}
+	parsed := &disambig.MDConfig{Mappings: nlp.Mappings{
+		{Token: "HBIT", Spellout: nlp.Spellout{lossMorph("HBIT", "NNP")}},
+		{Token: "GDWL", Spellout: nlp.Spellout{lossMorph("GDWL", "JJ")}},
+	}}
+	// a different and a missing morpheme, the last token isn't
+	// disambiguated by the parsed configuration yet
+	if loss := morphemeLoss(gold, parsed); loss != 2 {
+		t.Errorf("Expected morpheme loss 2, got %d", loss)
+	}
+	if loss := morphemeLoss(arcConf(), arcConf()); loss != 0 {
+		t.Errorf("Expected morpheme loss 0 of dependency configurations, got %d", loss)
+	}
+}
+
+func TestHammingLoss(t *testing.T) {
+	goldArc := dep.BasicDepArc{Head: -1, Modifier: 0, RawRelation: nlp.ROOT_LABEL}
+	wrongArc := dep.BasicDepArc{Head: 1, Modifier: 0, RawRelation: "ATT"}
+	goldSequence := search.ScoredConfigurations{
+		{C: arcConf()},
+		{C: arcConf(goldArc)},
+		{C: arcConf(goldArc, dep.BasicDepArc{Head: 0, Modifier: 1, RawRelation: "ATT"})},
+	}
+	gold := &perceptron.Decoded{DecodedVal: goldSequence}
+
+	// a configuration of two transitions is compared to the second gold one
+	first := arcConf()
+	parsed := arcConf(wrongArc)
+	parsed.InternalPrevious = first
+	if loss := HammingLoss(gold, &perceptron.Decoded{DecodedVal: parsed}); loss != 1 {
+		t.Errorf("Expected loss 1 against the gold prefix, got %d", loss)
+	}
+	// longer configurations are compared to the last gold one
+	longer := arcConf(goldArc)
+	longer.InternalPrevious = arcConf()
+	longer.InternalPrevious.InternalPrevious = arcConf()
+	longer.InternalPrevious.InternalPrevious.InternalPrevious = arcConf()
+	if loss := HammingLoss(gold, &perceptron.Decoded{DecodedVal: longer}); loss != 1 {
+		t.Errorf("Expected loss 1 against the last gold configuration, got %d", loss)
+	}
+	// without a gold sequence the loss is of a single transition
+	var conf transition.Configuration = arcConf(wrongArc)
+	noSequence := &perceptron.Decoded{DecodedVal: conf}
+	if loss := HammingLoss(noSequence, noSequence); loss != 1 {
+		t.Errorf("Expected loss 1 without a gold sequence, got %d", loss)
+	}
+}
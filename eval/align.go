@@ -0,0 +1,264 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// AlignWord is a syntactic word (morpheme) of a token. Head is the 1-based
+// index of the head word in the sentence, 0 for the root
+type AlignWord struct {
+	Form, Lemma, POS, Feats string
+	Head                    int
+	Rel                     string
+}
+
+// AlignToken is a surface token and the words it is segmented into
+type AlignToken struct {
+	Form  string
+	Words []AlignWord
+}
+
+// Alignment holds the scores of system words aligned to gold words by
+// character content, as in the CoNLL 2018 shared task evaluation. TP counts
+// correct system units, FP incorrect system units and TN missed gold units
+type Alignment struct {
+	Sentences, Tokens, Words    *Result
+	POS, Feats, AllTags, Lemmas *Result
+	UAS, LAS                    *Result
+}
+
+type span struct {
+	start, end int
+}
+
+type alignedWord struct {
+	AlignWord
+	span      span
+	multiword bool
+	// head is the index of the head word in the document, -1 for the root
+	head int
+}
+
+type alignDoc struct {
+	text              []rune
+	sentences, tokens []span
+	words             []*alignedWord
+}
+
+func newAlignDoc(sents [][]AlignToken) *alignDoc {
+	doc := &alignDoc{}
+	for _, sent := range sents {
+		sentStart, sentOffset := len(doc.text), len(doc.words)
+		for _, token := range sent {
+			start := len(doc.text)
+			for _, r := range token.Form {
+				if !unicode.IsSpace(r) {
+					doc.text = append(doc.text, r)
+				}
+			}
+			tokenSpan := span{start, len(doc.text)}
+			doc.tokens = append(doc.tokens, tokenSpan)
+			for _, word := range token.Words {
+				head := -1
+				if word.Head > 0 {
+					head = sentOffset + word.Head - 1
+				}
+				doc.words = append(doc.words, &alignedWord{word, tokenSpan, len(token.Words) > 1, head})
+			}
+		}
+		doc.sentences = append(doc.sentences, span{sentStart, len(doc.text)})
+	}
+	return doc
+}
+
+func spanResult(gold, system []span) *Result {
+	result := &Result{}
+	var gi, si int
+	for gi < len(gold) && si < len(system) {
+		switch {
+		case gold[gi].start > system[si].start:
+			si++
+		case gold[gi].start < system[si].start:
+			gi++
+		default:
+			if gold[gi].end == system[si].end {
+				result.TP++
+			}
+			gi++
+			si++
+		}
+	}
+	result.FP = len(system) - result.TP
+	result.TN = len(gold) - result.TP
+	return result
+}
+
+func beyondEnd(words []*alignedWord, i, end int) bool {
+	if i >= len(words) {
+		return true
+	}
+	if words[i].multiword {
+		return words[i].span.start >= end
+	}
+	return words[i].span.end > end
+}
+
+func extendEnd(word *alignedWord, end int) int {
+	if word.multiword && word.span.end > end {
+		return word.span.end
+	}
+	return end
+}
+
+// multiwordSpan returns the gold and system word ranges covering the
+// overlapping multiword tokens at gi, si
+func multiwordSpan(gold, system []*alignedWord, gi, si int) (gs, ss, ge, se int) {
+	var end int
+	if gold[gi].multiword {
+		end = gold[gi].span.end
+		if !system[si].multiword && system[si].span.start < gold[gi].span.start {
+			si++
+		}
+	} else {
+		end = system[si].span.end
+		if !gold[gi].multiword && gold[gi].span.start < system[si].span.start {
+			gi++
+		}
+	}
+	gs, ss = gi, si
+	for !beyondEnd(gold, gi, end) || !beyondEnd(system, si, end) {
+		if gi < len(gold) && (si >= len(system) || gold[gi].span.start <= system[si].span.start) {
+			end = extendEnd(gold[gi], end)
+			gi++
+		} else {
+			end = extendEnd(system[si], end)
+			si++
+		}
+	}
+	return gs, ss, gi, si
+}
+
+func sameForm(a, b *alignedWord) bool {
+	return strings.ToLower(a.Form) == strings.ToLower(b.Form)
+}
+
+// alignLCS aligns the words of a multiword span by the longest common
+// subsequence of their forms
+func alignLCS(gold, system []*alignedWord, aligned map[int]int, gs, ss int) {
+	lcs := make([][]int, len(gold)+1)
+	for g := range lcs {
+		lcs[g] = make([]int, len(system)+1)
+	}
+	for g := len(gold) - 1; g >= 0; g-- {
+		for s := len(system) - 1; s >= 0; s-- {
+			if sameForm(gold[g], system[s]) {
+				lcs[g][s] = 1 + lcs[g+1][s+1]
+			}
+			if lcs[g+1][s] > lcs[g][s] {
+				lcs[g][s] = lcs[g+1][s]
+			}
+			if lcs[g][s+1] > lcs[g][s] {
+				lcs[g][s] = lcs[g][s+1]
+			}
+		}
+	}
+	var g, s int
+	for g < len(gold) && s < len(system) {
+		if sameForm(gold[g], system[s]) {
+			aligned[gs+g] = ss + s
+			g++
+			s++
+		} else if lcs[g][s] == lcs[g+1][s] {
+			g++
+		} else {
+			s++
+		}
+	}
+}
+
+// alignWords maps gold word indices to aligned system word indices
+func alignWords(gold, system []*alignedWord) map[int]int {
+	aligned := make(map[int]int, len(gold))
+	var gi, si int
+	for gi < len(gold) && si < len(system) {
+		if gold[gi].multiword || system[si].multiword {
+			var gs, ss int
+			gs, ss, gi, si = multiwordSpan(gold, system, gi, si)
+			if gi > gs && si > ss {
+				alignLCS(gold[gs:gi], system[ss:si], aligned, gs, ss)
+			}
+			continue
+		}
+		switch {
+		case gold[gi].span == system[si].span:
+			aligned[gi] = si
+			gi++
+			si++
+		case gold[gi].span.start <= system[si].span.start:
+			gi++
+		default:
+			si++
+		}
+	}
+	return aligned
+}
+
+// Align aligns system sentences to gold sentences by the characters of
+// their tokens, ignoring whitespace, so tokenization, segmentation and
+// sentence splitting may differ. The texts must otherwise be the same
+func Align(gold, system [][]AlignToken) (*Alignment, error) {
+	goldDoc, sysDoc := newAlignDoc(gold), newAlignDoc(system)
+	for i := 0; i < len(goldDoc.text) || i < len(sysDoc.text); i++ {
+		if i >= len(goldDoc.text) || i >= len(sysDoc.text) || goldDoc.text[i] != sysDoc.text[i] {
+			return nil, fmt.Errorf("Gold and system texts differ at character %d", i)
+		}
+	}
+	alignment := &Alignment{
+		Sentences: spanResult(goldDoc.sentences, sysDoc.sentences),
+		Tokens:    spanResult(goldDoc.tokens, sysDoc.tokens),
+	}
+	results := []**Result{
+		&alignment.Words, &alignment.POS, &alignment.Feats, &alignment.AllTags,
+		&alignment.Lemmas, &alignment.UAS, &alignment.LAS,
+	}
+	for _, result := range results {
+		*result = &Result{}
+	}
+	aligned := alignWords(goldDoc.words, sysDoc.words)
+	for gi, si := range aligned {
+		goldWord, sysWord := goldDoc.words[gi], sysDoc.words[si]
+		alignment.Words.TP++
+		pos, feats := goldWord.POS == sysWord.POS, goldWord.Feats == sysWord.Feats
+		if pos {
+			alignment.POS.TP++
+		}
+		if feats {
+			alignment.Feats.TP++
+		}
+		if pos && feats {
+			alignment.AllTags.TP++
+		}
+		if goldWord.Lemma == sysWord.Lemma {
+			alignment.Lemmas.TP++
+		}
+		var attached bool
+		if goldWord.head < 0 {
+			attached = sysWord.head < 0
+		} else if sysHead, exists := aligned[goldWord.head]; exists {
+			attached = sysHead == sysWord.head
+		}
+		if attached {
+			alignment.UAS.TP++
+			if goldWord.Rel == sysWord.Rel {
+				alignment.LAS.TP++
+			}
+		}
+	}
+	for _, result := range results {
+		(*result).FP = len(sysDoc.words) - (*result).TP
+		(*result).TN = len(goldDoc.words) - (*result).TP
+	}
+	return alignment, nil
+}
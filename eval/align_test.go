@@ -0,0 +1,63 @@
+package eval
+
+import "testing"
+
+func checkAlignResult(t *testing.T, name string, r *Result, tp, fp, tn int) {
+	if r.TP != tp || r.FP != fp || r.TN != tn {
+		t.Errorf("%s: expected TP, FP, TN %d %d %d, got %d %d %d", name, tp, fp, tn, r.TP, r.FP, r.TN)
+	}
+}
+
+func TestAlignSegmentation(t *testing.T) {
+	gold := [][]AlignToken{{
+		{"בבית", []AlignWord{
+			{"ב", "ב", "PREPOSITION", "_", 3, "prepmod"},
+			{"ה", "ה", "DEF", "_", 3, "def"},
+			{"בית", "בית", "NN", "gen=M|num=S", 0, "ROOT"},
+		}},
+		{"גדול", []AlignWord{{"גדול", "גדול", "JJ", "gen=M|num=S", 3, "amod"}}},
+	}}
+	system := [][]AlignToken{{
+		{"בבית", []AlignWord{
+			{"ב", "ב", "PREPOSITION", "_", 2, "prepmod"},
+			{"בית", "בית", "NN", "gen=F|num=S", 0, "ROOT"},
+		}},
+		{"גדול", []AlignWord{{"גדול", "גדול", "JJ", "gen=M|num=S", 2, "nn"}}},
+	}}
+	alignment, err := Align(gold, system)
+	if err != nil {
+		t.Fatalf("Failed aligning: %v", err)
+	}
+	checkAlignResult(t, "Sentences", alignment.Sentences, 1, 0, 0)
+	checkAlignResult(t, "Tokens", alignment.Tokens, 2, 0, 0)
+	checkAlignResult(t, "Words", alignment.Words, 3, 0, 1)
+	checkAlignResult(t, "POS", alignment.POS, 3, 0, 1)
+	checkAlignResult(t, "Feats", alignment.Feats, 2, 1, 2)
+	checkAlignResult(t, "UAS", alignment.UAS, 3, 0, 1)
+	checkAlignResult(t, "LAS", alignment.LAS, 2, 1, 2)
+}
+
+func TestAlignTokenization(t *testing.T) {
+	// gold has one sentence of two tokens, system splits it in two
+	// sentences and splits the second token
+	gold := [][]AlignToken{{
+		{"אבג", []AlignWord{{Form: "אבג"}}},
+		{"דה", []AlignWord{{Form: "דה"}}},
+	}}
+	system := [][]AlignToken{
+		{{"אבג", []AlignWord{{Form: "אבג"}}}},
+		{{"ד", []AlignWord{{Form: "ד"}}}, {"ה", []AlignWord{{Form: "ה"}}}},
+	}
+	alignment, err := Align(gold, system)
+	if err != nil {
+		t.Fatalf("Failed aligning: %v", err)
+	}
+	checkAlignResult(t, "Sentences", alignment.Sentences, 0, 2, 1)
+	checkAlignResult(t, "Tokens", alignment.Tokens, 1, 2, 1)
+	checkAlignResult(t, "Words", alignment.Words, 1, 2, 1)
+
+	system[1][1].Form = "ו"
+	if _, err := Align(gold, system); err == nil {
+		t.Error("Expected an error aligning different texts")
+	}
+}
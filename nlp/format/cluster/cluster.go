@@ -0,0 +1,47 @@
+package cluster
+
+// Package cluster reads hierarchical word clusters in the paths format of
+// Brown clustering (Liang 2005): a cluster bit string, a word and
+// optionally its count per line, separated by tabs
+
+import (
+	nlp "yap/nlp/types"
+
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Read adds the clusters of the words to the word clusters
+func Read(reader io.Reader, clusters *nlp.WordClusters) error {
+	var i int
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		i++
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			return fmt.Errorf("Line %d: expected a bit string and a word", i)
+		}
+		if strings.Trim(fields[0], "01") != "" {
+			return fmt.Errorf("Line %d: %s is not a bit string", i, fields[0])
+		}
+		clusters.AddBrown(fields[1], fields[0])
+	}
+	return scanner.Err()
+}
+
+func ReadFile(filename string, clusters *nlp.WordClusters) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return Read(file, clusters)
+}
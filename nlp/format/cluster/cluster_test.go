@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+
+	nlp "yap/nlp/types"
+)
+
+func TestRead(t *testing.T) {
+	clusters := nlp.NewWordClusters()
+	input := "0010\tBIT\t12\n\n0011\tBITH\n10\tHLK\t3\n"
+	if err := Read(strings.NewReader(input), clusters); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"BIT": "0010", "BITH": "0011", "HLK": "10"}
+	if len(clusters.Brown) != len(expected) {
+		t.Errorf("Expected %d words, got %d", len(expected), len(clusters.Brown))
+	}
+	for word, bits := range expected {
+		if clusters.Brown[word] != bits {
+			t.Errorf("Expected cluster %s of %s, got %s", bits, word, clusters.Brown[word])
+		}
+	}
+	// the prefixes 0, 00, 001, 0010, 0011, 1 and 10
+	if clusters.EClusters.Len() != 7 {
+		t.Errorf("Expected 7 enumerated prefixes, got %d", clusters.EClusters.Len())
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, input := range []string{"0010 BIT\n", "0010\tBIT\n0x1\tHLK\n"} {
+		if err := Read(strings.NewReader(input), nlp.NewWordClusters()); err == nil {
+			t.Errorf("Expected error reading %q", input)
+		}
+	}
+}
@@ -7,6 +7,7 @@ package conllu
 
 import (
 	"yap/alg/graph"
+	"yap/nlp/format/lattice"
 	"yap/nlp/parser/dependency/transition"
 	morphtypes "yap/nlp/parser/dependency/transition/morph"
 	nlp "yap/nlp/types"
@@ -22,6 +23,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -36,6 +38,9 @@ var (
 	WORD_TYPE    = "form"
 	IGNORE_LEMMA bool
 	STRIP_VOICE  bool
+	// HEB2UD converts Hebrew (SPMRL) POS tags and features of output
+	// morphemes to UD using util.HEB2UD, keeping the original POS as XPOS
+	HEB2UD bool
 )
 
 type Features map[string]string
@@ -127,6 +132,75 @@ type Sentence struct {
 	Tokens   []string
 	Mappings nlp.Mappings
 	Comments []string
+	// TokenRanges are the character ranges of Tokens in the input
+	TokenRanges []nlp.TokenRange
+
+	tokenMisc []string
+}
+
+func (s *Sentence) addToken(token, misc string) {
+	s.Tokens = append(s.Tokens, token)
+	s.tokenMisc = append(s.tokenMisc, misc)
+}
+
+// SetTokenRanges sets the character ranges of the sentence's tokens,
+// starting at offset. Ranges are taken from TokenRange= in MISC if present,
+// otherwise tokens are separated by a space unless SpaceAfter=No.
+// Returns the offset of the next sentence
+func (s *Sentence) SetTokenRanges(offset int) int {
+	s.TokenRanges = make([]nlp.TokenRange, len(s.Tokens))
+	pos, lastEnd := offset, offset-1
+	for i, token := range s.Tokens {
+		var misc string
+		if i < len(s.tokenMisc) {
+			misc = s.tokenMisc[i]
+		}
+		tokenRange := nlp.TokenRange{Start: pos, End: pos + utf8.RuneCountInString(token)}
+		if value, exists := MiscValue(misc, "TokenRange"); exists {
+			if parsed, err := nlp.ParseTokenRange(value); err == nil {
+				tokenRange = parsed
+			}
+		}
+		s.TokenRanges[i] = tokenRange
+		pos, lastEnd = tokenRange.End, tokenRange.End
+		if value, _ := MiscValue(misc, "SpaceAfter"); value != "No" {
+			pos++
+		}
+	}
+	return lastEnd + 1
+}
+
+func (s *Sentence) tokenRange(tokenID int) nlp.TokenRange {
+	if tokenID < 0 || tokenID >= len(s.TokenRanges) {
+		return nlp.TokenRange{}
+	}
+	return s.TokenRanges[tokenID]
+}
+
+// MiscValue returns the value of an attribute of a MISC field
+func MiscValue(misc, name string) (string, bool) {
+	for _, attr := range strings.Split(misc, FEATURES_SEPARATOR) {
+		if strings.HasPrefix(attr, name+FEATURE_SEPARATOR) {
+			return attr[len(name)+1:], true
+		}
+	}
+	return "", false
+}
+
+// addTokenRange adds the TokenRange attribute to a MISC field, if valid
+// and not already present
+func addTokenRange(misc string, tokenRange nlp.TokenRange) string {
+	if !tokenRange.Valid() {
+		return misc
+	}
+	if _, exists := MiscValue(misc, "TokenRange"); exists {
+		return misc
+	}
+	attr := "TokenRange" + FEATURE_SEPARATOR + tokenRange.String()
+	if len(misc) == 0 || misc == "_" {
+		return attr
+	}
+	return misc + FEATURES_SEPARATOR + attr
 }
 
 func NewSentence() *Sentence {
@@ -280,6 +354,13 @@ func ParseTokenRow(record []string) (string, int, error) {
 	return token, id2 - id1 + 1, nil
 }
 
+func tokenRowMisc(record []string) string {
+	if len(record) < NUM_FIELDS {
+		return ""
+	}
+	return ParseString(record[NUM_FIELDS-1])
+}
+
 func ReadStream(reader *os.File, limit int) chan *Sentence {
 	sentences := make(chan *Sentence, 2)
 
@@ -296,6 +377,7 @@ func ReadStream(reader *os.File, limit int) chan *Sentence {
 			numSyntacticWords int
 			numTokens         int
 			numSentences      int
+			offset            int
 		)
 		curLine, isPrefix, err := bufReader.ReadLine()
 		if err != nil {
@@ -309,6 +391,7 @@ func ReadStream(reader *os.File, limit int) chan *Sentence {
 			buf := bytes.NewBuffer(curLine)
 			// '#' is a start of comment for CONLL-U
 			if len(curLine) == 0 {
+				offset = currentSent.SetTokenRanges(offset)
 				sentences <- currentSent
 				numSentences++
 				if limit > 0 && numSentences >= limit {
@@ -334,7 +417,7 @@ func ReadStream(reader *os.File, limit int) chan *Sentence {
 					log.Println("Error at record", i, errors.New(fmt.Sprintf("Error processing record %d at statement %d: %s", line, numSentences, err.Error())))
 					return
 				}
-				currentSent.Tokens = append(currentSent.Tokens, token)
+				currentSent.addToken(token, tokenRowMisc(record))
 				numTokens++
 			} else {
 				numSyntacticWords++
@@ -346,7 +429,7 @@ func ReadStream(reader *os.File, limit int) chan *Sentence {
 				if numForms > 0 {
 					numForms--
 				} else {
-					currentSent.Tokens = append(currentSent.Tokens, row.Form)
+					currentSent.addToken(row.Form, row.Misc)
 					numTokens++
 				}
 				row.TokenID = len(currentSent.Tokens) - 1
@@ -373,6 +456,7 @@ func Read(reader io.Reader, limit int) (Sentences, bool, error) {
 		hasSegmentation   bool
 		numSyntacticWords int
 		numTokens         int
+		offset            int
 	)
 	currentSent := NewSentence()
 	// log.Println("At record", i)
@@ -384,6 +468,7 @@ func Read(reader io.Reader, limit int) (Sentences, bool, error) {
 		buf := bytes.NewBuffer(curLine)
 		// '#' is a start of comment for CONLL-U
 		if len(curLine) == 0 {
+			offset = currentSent.SetTokenRanges(offset)
 			sentences = append(sentences, currentSent)
 			if limit > 0 && len(sentences) >= limit {
 				break
@@ -412,7 +497,7 @@ func Read(reader io.Reader, limit int) (Sentences, bool, error) {
 				return nil, false, errors.New(fmt.Sprintf("Error processing record %d at statement %d: %s", line, len(sentences), err.Error()))
 			}
 			hasSegmentation = true
-			currentSent.Tokens = append(currentSent.Tokens, token)
+			currentSent.addToken(token, tokenRowMisc(record))
 			numTokens++
 		} else {
 			numSyntacticWords++
@@ -423,7 +508,7 @@ func Read(reader io.Reader, limit int) (Sentences, bool, error) {
 			if numForms > 0 {
 				numForms--
 			} else {
-				currentSent.Tokens = append(currentSent.Tokens, row.Form)
+				currentSent.addToken(row.Form, row.Misc)
 				numTokens++
 			}
 			row.TokenID = len(currentSent.Tokens) - 1
@@ -454,55 +539,43 @@ func ReadFileAsStream(filename string, limit int) (chan *Sentence, error) {
 	return ReadStream(file, limit), nil
 }
 
-func Write(writer io.Writer, sents []interface{}) {
+func writeSentence(writer io.Writer, sent Sentence) {
 	var lastToken int
-	for _, genericsent := range sents {
-		lastToken = 0
-		// log.Println("Write sent")
-		sent := genericsent.(Sentence)
-		for i := 1; i <= len(sent.Deps); i++ {
-			// log.Println("At dep", i)
-			row := sent.Deps[i]
-			if row.TokenID > lastToken {
-				mapping := sent.Mappings[row.TokenID-1]
-				if len(mapping.Spellout) > 1 {
-					writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", i, i+len(mapping.Spellout)-1, mapping.Token)))
-					for j := 0; j < 8; j++ {
-						writer.Write([]byte("\t_"))
-					}
-					writer.Write([]byte("\n"))
+	for i := 1; i <= len(sent.Deps); i++ {
+		// log.Println("At dep", i)
+		row := sent.Deps[i]
+		if row.TokenID > lastToken {
+			mapping := sent.Mappings[row.TokenID-1]
+			var tokenRange nlp.TokenRange
+			if lattice.WRITE_TOKEN_RANGE && len(mapping.Spellout) > 0 {
+				tokenRange = mapping.Spellout[0].TokenRange
+			}
+			if len(mapping.Spellout) > 1 {
+				writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", i, i+len(mapping.Spellout)-1, mapping.Token)))
+				for j := 0; j < 7; j++ {
+					writer.Write([]byte("\t_"))
 				}
+				writer.Write([]byte("\t" + addTokenRange("_", tokenRange) + "\n"))
+			} else {
+				row.Misc = addTokenRange(row.Misc, tokenRange)
 			}
-			writer.Write(append([]byte(row.String()), '\n'))
-			lastToken = row.TokenID
 		}
-		writer.Write([]byte{'\n'})
+		writer.Write(append([]byte(row.String()), '\n'))
+		lastToken = row.TokenID
+	}
+	writer.Write([]byte{'\n'})
+}
+
+func Write(writer io.Writer, sents []interface{}) {
+	for _, genericsent := range sents {
+		// log.Println("Write sent")
+		writeSentence(writer, genericsent.(Sentence))
 	}
 }
 
 func WriteStream(writer io.Writer, sents chan interface{}) {
-	var lastToken int
 	for genericsent := range sents {
-		lastToken = 0
-		// log.Println("Write sent")
-		sent := genericsent.(Sentence)
-		for i := 1; i <= len(sent.Deps); i++ {
-			// log.Println("At dep", i)
-			row := sent.Deps[i]
-			if row.TokenID > lastToken {
-				mapping := sent.Mappings[row.TokenID-1]
-				if len(mapping.Spellout) > 1 {
-					writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", i, i+len(mapping.Spellout)-1, mapping.Token)))
-					for j := 0; j < 8; j++ {
-						writer.Write([]byte("\t_"))
-					}
-					writer.Write([]byte("\n"))
-				}
-			}
-			writer.Write(append([]byte(row.String()), '\n'))
-			lastToken = row.TokenID
-		}
-		writer.Write([]byte{'\n'})
+		writeSentence(writer, genericsent.(Sentence))
 	}
 }
 
@@ -612,6 +685,9 @@ func Graph2ConllU(graph nlp.LabeledDependencyGraph, eMHost, eMSuffix *util.EnumS
 			Head:    headID + 1,
 			DepRel:  depRel,
 		}
+		if HEB2UD {
+			row.UPosTag, row.FeatStr = util.Heb2UDMorph(posTag, row.FeatStr)
+		}
 		sent.Deps[row.ID] = row
 	}
 	return *sent
@@ -708,6 +784,7 @@ func ConllU2MorphGraph(sent *Sentence, eWord, ePOS, eWPOS, eRel, eMFeat, eMHost,
 			row.FeatStr,
 		}
 		eFeat, _ := eMFeat.Add(row.FeatStr)
+		lattice.TokenRange = sent.tokenRange(row.TokenID)
 		lattice.Morphemes = append(lattice.Morphemes, &nlp.EMorpheme{
 			morph,
 			node.Token,
@@ -717,6 +794,7 @@ func ConllU2MorphGraph(sent *Sentence, eWord, ePOS, eWPOS, eRel, eMFeat, eMHost,
 			eFeat,
 			node.MHost,
 			node.MSuffix,
+			lattice.TokenRange,
 		})
 
 		curLatNode++
@@ -867,6 +945,9 @@ func MorphGraph2ConllU(graph nlp.MorphDependencyGraph) Sentence {
 			DepRel:  depRel,
 			TokenID: node.TokenID,
 		}
+		if HEB2UD {
+			row.UPosTag, row.FeatStr = util.Heb2UDMorph(node.CPOS, node.FeatureStr)
+		}
 		sent.Deps[row.ID] = row
 	}
 	return *sent
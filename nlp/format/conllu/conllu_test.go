@@ -0,0 +1,83 @@
+package conllu
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+)
+
+const testSentence = `1	dogs	dog	NOUN	NOUN	Number=Plur	0	root	_	_
+2-3	inthe	_	_	_	_	_	_	_	_
+2	in	in	ADP	ADP	_	4	case	_	_
+3	the	the	DET	DET	_	4	det	_	_
+4	house	house	NOUN	NOUN	Number=Sing	1	nmod	_	SpaceAfter=No
+5	.	.	PUNCT	PUNCT	_	1	punct	_	_
+
+`
+
+// writeTestSentence writes a read sentence as parsed output, with a
+// mapping of each token to its rows
+func writeTestSentence(t *testing.T, input string) string {
+	sents, _, err := Read(strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sents) != 1 {
+		t.Fatalf("Expected 1 sentence, got %d", len(sents))
+	}
+	sent := sents[0]
+	sent.Mappings = make(nlp.Mappings, len(sent.Tokens))
+	for i, token := range sent.Tokens {
+		sent.Mappings[i] = &nlp.Mapping{Token: nlp.Token(token)}
+	}
+	for id := 1; id <= len(sent.Deps); id++ {
+		// output rows have 1-based token ids
+		row := sent.Deps[id]
+		row.TokenID++
+		sent.Deps[id] = row
+		mapping := sent.Mappings[row.TokenID-1]
+		mapping.Spellout = append(mapping.Spellout, &nlp.EMorpheme{TokenRange: sent.TokenRanges[row.TokenID-1]})
+	}
+	buf := new(bytes.Buffer)
+	Write(buf, []interface{}{*sent})
+	return buf.String()
+}
+
+func TestWriteWithoutTokenRanges(t *testing.T) {
+	if output := writeTestSentence(t, testSentence); output != testSentence {
+		t.Errorf("Expected output\n%s\ngot\n%s", testSentence, output)
+	}
+}
+
+func TestTokenRangesRoundTrip(t *testing.T) {
+	defer func(write bool) { lattice.WRITE_TOKEN_RANGE = write }(lattice.WRITE_TOKEN_RANGE)
+	lattice.WRITE_TOKEN_RANGE = true
+	output := writeTestSentence(t, testSentence)
+	for _, line := range []string{
+		"1	dogs	dog	NOUN	NOUN	Number=Plur	0	root	_	TokenRange=0:4\n",
+		"2-3	inthe	_	_	_	_	_	_	_	TokenRange=5:10\n",
+		"2	in	in	ADP	ADP	_	4	case	_	_\n",
+		"4	house	house	NOUN	NOUN	Number=Sing	1	nmod	_	SpaceAfter=No|TokenRange=11:16\n",
+		"5	.	.	PUNCT	PUNCT	_	1	punct	_	TokenRange=16:17\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected output line %q, got\n%s", line, output)
+		}
+	}
+	// ranges written to MISC are read back as is
+	sents, _, err := Read(strings.NewReader(output), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []nlp.TokenRange{{Start: 0, End: 4}, {Start: 5, End: 10}, {Start: 11, End: 16}, {Start: 16, End: 17}}
+	if !reflect.DeepEqual(sents[0].TokenRanges, expected) {
+		t.Errorf("Expected token ranges %v, got %v", expected, sents[0].TokenRanges)
+	}
+	if rewritten := writeTestSentence(t, output); rewritten != output {
+		t.Errorf("Expected rewritten output\n%s\ngot\n%s", output, rewritten)
+	}
+}
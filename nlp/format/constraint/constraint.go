@@ -0,0 +1,96 @@
+package constraint
+
+// Package constraint reads token constraint sidecar files
+// each non-comment line constrains a token's analyses:
+//   SENTENCE TOKEN SEGMENTATION POS FEATS
+// sentence and token are 1-based, the other fields list one value per
+// morpheme separated by ':' ("*" matches any value), a field of "_" is not
+// constrained. Lines starting with '#' and empty lines are ignored
+
+import (
+	nlp "yap/nlp/types"
+
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	FIELD_SEPARATOR    = '\t'
+	MORPHEME_SEPARATOR = ":"
+	NUM_FIELDS         = 5
+)
+
+func parseList(field string) []string {
+	if field == "" || field == "_" {
+		return nil
+	}
+	return strings.Split(field, MORPHEME_SEPARATOR)
+}
+
+func ParseConstraint(record []string) (nlp.TokenConstraint, error) {
+	var constraint nlp.TokenConstraint
+	if len(record) != NUM_FIELDS {
+		return constraint, fmt.Errorf("Expected %d fields, got %d", NUM_FIELDS, len(record))
+	}
+	sentence, err := strconv.Atoi(record[0])
+	if err != nil || sentence < 1 {
+		return constraint, fmt.Errorf("Error parsing SENTENCE field (%s)", record[0])
+	}
+	token, err := strconv.Atoi(record[1])
+	if err != nil || token < 1 {
+		return constraint, fmt.Errorf("Error parsing TOKEN field (%s)", record[1])
+	}
+	constraint.Sentence = sentence
+	constraint.Token = token
+	constraint.Segmentation = parseList(record[2])
+	constraint.POS = parseList(record[3])
+	constraint.Features = parseList(record[4])
+	return constraint, nil
+}
+
+func Read(reader io.Reader) ([]nlp.TokenConstraint, error) {
+	var constraints []nlp.TokenConstraint
+	scanner := bufio.NewScanner(reader)
+	var line int
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if len(strings.TrimSpace(text)) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		constraint, err := ParseConstraint(strings.Split(text, string(FIELD_SEPARATOR)))
+		if err != nil {
+			return nil, fmt.Errorf("Error processing line %d: %v", line, err)
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints, scanner.Err()
+}
+
+func ReadFile(filename string) ([]nlp.TokenConstraint, error) {
+	file, err := os.Open(filename)
+	defer file.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(file)
+}
+
+// BySentence groups constraints by their 0-based sentence index, an unset
+// sentence is the first one
+func BySentence(constraints []nlp.TokenConstraint) map[int][]nlp.TokenConstraint {
+	sentences := make(map[int][]nlp.TokenConstraint)
+	for _, constraint := range constraints {
+		sentence := constraint.Sentence - 1
+		if sentence < 0 {
+			sentence = 0
+		}
+		sentences[sentence] = append(sentences[sentence], constraint)
+	}
+	return sentences
+}
@@ -0,0 +1,64 @@
+package embedding
+
+// Package embedding reads word vectors in the text format of word2vec and
+// GloVe: a word and its vector of space separated numbers per line.
+// A word2vec header line (number of words and dimension) is skipped
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Read returns the vectors of the words and their dimension
+func Read(reader io.Reader) (map[string][]float64, int, error) {
+	var (
+		vectors = make(map[string][]float64)
+		dim     int
+		i       int
+	)
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		i++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if i == 1 && len(fields) == 2 {
+			// word2vec header
+			continue
+		}
+		vector := make([]float64, len(fields)-1)
+		for j, field := range fields[1:] {
+			val, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("Line %d: %v", i, err)
+			}
+			vector[j] = val
+		}
+		if dim == 0 {
+			dim = len(vector)
+		} else if len(vector) != dim {
+			return nil, 0, fmt.Errorf("Line %d: got vector of size %d, expected %d", i, len(vector), dim)
+		}
+		vectors[fields[0]] = vector
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return vectors, dim, nil
+}
+
+func ReadFile(filename string) (map[string][]float64, int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
@@ -38,6 +38,10 @@ var (
 	WORD_TYPE               = "form"
 	IGNORE_NNP_FEATS        = false
 	OVERRIDE_XPOS_WITH_UPOS bool
+	// WRITE_TOKEN_RANGE adds a token character range (start:end) column to
+	// SPMRL lattices and a TokenRange MISC attribute to UD lattices, mappings
+	// and CoNLL-U
+	WRITE_TOKEN_RANGE bool
 )
 
 type Features map[string]string
@@ -111,9 +115,10 @@ type Edge struct {
 	PosTag   string
 	Feats    Features
 	FeatStr  string
-	Token    int
-	Id       int
-	TokenStr string
+	Token      int
+	Id         int
+	TokenStr   string
+	TokenRange nlp.TokenRange
 }
 
 type EdgeSlice []Edge
@@ -155,6 +160,13 @@ func (e Edge) String() string {
 	if len(e.Lemma) == 0 {
 		fields[3] = "_"
 	}
+	if WRITE_TOKEN_RANGE {
+		if e.TokenRange.Valid() {
+			fields = append(fields, e.TokenRange.String())
+		} else {
+			fields = append(fields, "_")
+		}
+	}
 	return strings.Join(fields, "\t")
 }
 
@@ -432,9 +444,32 @@ func ParseEdge(record []string) (*Edge, error) {
 	}
 	row.Feats = features
 	row.FeatStr = ParseString(record[6])
+
+	// optional token range column
+	if len(record) > 8 && len(ParseString(record[8])) > 0 {
+		row.TokenRange, err = nlp.ParseTokenRange(record[8])
+		if err != nil {
+			return row, err
+		}
+	}
 	return row, nil
 }
 
+// tokenLineRange returns the token range in the misc field of a UD lattice
+// token line, if any
+func tokenLineRange(record []string) nlp.TokenRange {
+	var tokenRange nlp.TokenRange
+	if len(record) < 3 {
+		return tokenRange
+	}
+	for _, attr := range strings.Split(record[2], "|") {
+		if strings.HasPrefix(attr, "TokenRange=") {
+			tokenRange, _ = nlp.ParseTokenRange(attr[len("TokenRange="):])
+		}
+	}
+	return tokenRange
+}
+
 func ReadStream(in *os.File, limit int) chan Lattice {
 	s := make(chan Lattice, 2)
 	go func(sentences chan Lattice, r *os.File) {
@@ -583,12 +618,14 @@ func ULReadStream(in *os.File, limit int) chan Lattice {
 			i                 int
 			dup               bool
 			tokens            []string
+			tokenRanges       []nlp.TokenRange
 			curToken          int = -1
 			tokTop, tokBottom int
 			parseErr          error
 			numSentences      int
 		)
 		tokens = make([]string, 0, 10)
+		tokenRanges = make([]nlp.TokenRange, 0, 10)
 		curLine, isPrefix, err := bufReader.ReadLine()
 		if err != nil {
 			log.Println("Error at record", i, errors.New(fmt.Sprintf("Error processing record %d at statement %d: %s", i, numSentences, err.Error())))
@@ -614,6 +651,7 @@ func ULReadStream(in *os.File, limit int) chan Lattice {
 				currentEdge = 0
 				i++
 				tokens = make([]string, 0, 10)
+				tokenRanges = make([]nlp.TokenRange, 0, 10)
 				curToken = -1
 				tokTop, tokBottom = 0, 0
 				continue
@@ -624,6 +662,8 @@ func ULReadStream(in *os.File, limit int) chan Lattice {
 
 			if strings.Contains(record[0], "-") {
 				tokens = append(tokens, record[1])
+				tokenRange := tokenLineRange(record)
+				tokenRanges = append(tokenRanges, tokenRange)
 				curToken++
 				tokSpan := strings.Split(record[0], "-")
 				tokTop, parseErr = ParseInt(tokSpan[0])
@@ -647,6 +687,7 @@ func ULReadStream(in *os.File, limit int) chan Lattice {
 			if edge.Start >= tokTop && edge.End > tokBottom {
 				// log.Println("Starting a new token for edge", currentEdge, buf.String())
 				tokens = append(tokens, edge.Word)
+				tokenRanges = append(tokenRanges, nlp.TokenRange{})
 				tokTop = edge.Start
 				tokBottom = edge.End
 				curToken++
@@ -654,6 +695,7 @@ func ULReadStream(in *os.File, limit int) chan Lattice {
 
 			edge.Token = curToken + 1
 			edge.TokenStr = tokens[edge.Token-1]
+			edge.TokenRange = tokenRanges[edge.Token-1]
 			if edge.Start == edge.End {
 				log.Println("At sent:", len(sentences), "Warning: found circular edge", edge, ", optimistically incrementing end")
 				edge.End += 1
@@ -695,11 +737,13 @@ func ULRead(r io.Reader, limit int) ([]Lattice, error) {
 		i                 int
 		dup               bool
 		tokens            []string
+		tokenRanges       []nlp.TokenRange
 		curToken          int = -1
 		tokTop, tokBottom int
 		parseErr          error
 	)
 	tokens = make([]string, 0, 10)
+	tokenRanges = make([]nlp.TokenRange, 0, 10)
 	for curLine, isPrefix, err := bufReader.ReadLine(); err == nil; curLine, isPrefix, err = bufReader.ReadLine() {
 		if isPrefix {
 			panic("Buffer not large enough, fix me :(")
@@ -718,6 +762,7 @@ func ULRead(r io.Reader, limit int) ([]Lattice, error) {
 			currentEdge = 0
 			i++
 			tokens = make([]string, 0, 10)
+			tokenRanges = make([]nlp.TokenRange, 0, 10)
 			curToken = -1
 			tokTop, tokBottom = 0, 0
 			continue
@@ -728,6 +773,8 @@ func ULRead(r io.Reader, limit int) ([]Lattice, error) {
 
 		if strings.Contains(record[0], "-") {
 			tokens = append(tokens, record[1])
+			tokenRange := tokenLineRange(record)
+			tokenRanges = append(tokenRanges, tokenRange)
 			curToken++
 			tokSpan := strings.Split(record[0], "-")
 			tokTop, parseErr = ParseInt(tokSpan[0])
@@ -747,6 +794,7 @@ func ULRead(r io.Reader, limit int) ([]Lattice, error) {
 		if edge.Start >= tokTop && edge.End > tokBottom {
 			// log.Println("Starting a new token for edge", currentEdge, buf.String())
 			tokens = append(tokens, edge.Word)
+			tokenRanges = append(tokenRanges, nlp.TokenRange{})
 			tokTop = edge.Start
 			tokBottom = edge.End
 			curToken++
@@ -754,6 +802,7 @@ func ULRead(r io.Reader, limit int) ([]Lattice, error) {
 
 		edge.Token = curToken + 1
 		edge.TokenStr = tokens[edge.Token-1]
+		edge.TokenRange = tokenRanges[edge.Token-1]
 		if edge.Start == edge.End {
 			log.Println("At sent:", len(sentences), "Warning: found circular edge", edge, ", optimistically incrementing end")
 			edge.End += 1
@@ -889,8 +938,15 @@ func UDWrite(writer io.Writer, lattices []Lattice, comments [][]string, oovVecto
 								}
 							}
 						}
+						tokenAttrs := make([]string, 0, 2)
 						if oovVectors != nil && oovVectors[latIdx][edge.Token-1] == "1" {
-							tokenComment = "oov=1"
+							tokenAttrs = append(tokenAttrs, "oov=1")
+						}
+						if WRITE_TOKEN_RANGE && edge.TokenRange.Valid() {
+							tokenAttrs = append(tokenAttrs, "TokenRange="+edge.TokenRange.String())
+						}
+						if len(tokenAttrs) > 0 {
+							tokenComment = strings.Join(tokenAttrs, "|")
 						} else {
 							tokenComment = "_"
 						}
@@ -1086,7 +1142,8 @@ func WriteUDFile(filename string, sents []Lattice, comments [][]string, oov inte
 	if err != nil {
 		return err
 	}
-	UDWrite(file, sents, comments, oov.([]nlp.BasicSentence))
+	oovVectors, _ := oov.([]nlp.BasicSentence)
+	UDWrite(file, sents, comments, oovVectors)
 	return nil
 }
 
@@ -1250,7 +1307,9 @@ func Lattice2Sentence(lattice Lattice, eWord, ePOS, eWPOS, eMorphFeat, eMHost, e
 					edge.Token,
 					edge.FeatStr,
 				},
+				TokenRange: edge.TokenRange,
 			}
+			lat.TokenRange = edge.TokenRange
 			switch WORD_TYPE {
 			case "form":
 				newMorpheme.EForm, _ = eWord.Add(edge.Word)
@@ -1345,6 +1404,7 @@ func Sentence2Lattice(lattice nlp.LatticeSentence, xliter8or xliter8.Interface)
 				m.TokenID,
 				m.ID(),
 				string(sentlat.Token),
+				m.TokenRange,
 			}
 			if len(m.FeatureStr) == 0 {
 				e.FeatStr = "_"
@@ -41,3 +41,16 @@ func TestParseEdgeWithRepeatingParams(t *testing.T) {
 		t.Error("Failure concatenating multiple features: should be F,M got " + parsed.Feats["suf_gen"])
 	}
 }
+
+func TestParseEdgeWithTokenRange(t *testing.T) {
+	row := strings.Split("3	4	AT	_	AT	AT	_	4	13:17",
+		string(FIELD_SEPARATOR))
+
+	parsed, err := ParseEdge(row)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if parsed.TokenRange.Start != 13 || parsed.TokenRange.End != 17 {
+		t.Error("Failure parsing token range: should be 13:17 got " + parsed.TokenRange.String())
+	}
+}
@@ -323,18 +323,12 @@ func ProcessUDAnalyzedToken(analysis string) (*AnalyzedToken, error) {
 		}
 
 		// convert POS to UDv2
-		if UDMSR, udPOSExists = util.HEB2UDPOS[CPOS]; !udPOSExists {
+		if UDMSR, udPOSExists = util.HEB2UD.POS[CPOS]; !udPOSExists {
 			panic(fmt.Sprintf("Unknown POS for UD conversion lookup %s", CPOS))
 		}
-		UDSplit := strings.Split(UDMSR, "-")
-		if len(UDSplit) == 0 {
+		UDPOS, UDFeats = util.HEB2UD.SplitMSR(UDMSR)
+		if len(UDPOS) == 0 {
 			panic("Got empty UDMSR")
-		} else if len(UDSplit) == 1 {
-			UDPOS, UDFeats = UDSplit[0], ""
-		} else if len(UDSplit) == 2 {
-			UDPOS, UDFeats = UDSplit[0], UDSplit[1]
-		} else if len(UDSplit) > 2 {
-			panic("Error - UD MSR should have POS and features split by dash (-)")
 		}
 
 		if CPOS == "CC" {
@@ -358,15 +352,8 @@ func ProcessUDAnalyzedToken(analysis string) (*AnalyzedToken, error) {
 
 		// special handling of tense=BEINONI
 		if tenseValue, tenseExists := Features["tense"]; tenseExists {
-			if tenseValue == "BEINONI" {
-				switch CPOS {
-				case "VB":
-					FeatureStr = util.AddToFeatureStr(FeatureStr, "VerbForm=Part")
-				case "MD":
-					FeatureStr = util.AddToFeatureStr(FeatureStr, "VerbForm=Part")
-				case "EX":
-					FeatureStr = util.AddToFeatureStr(FeatureStr, "VerbForm=Part")
-				}
+			if tenseValue == "BEINONI" && util.HEB2UD.IsBeinoniVerbForm(CPOS) {
+				FeatureStr = util.AddToFeatureStr(FeatureStr, "VerbForm=Part")
 			}
 		}
 
@@ -426,7 +413,7 @@ func ProcessUDAnalyzedToken(analysis string) (*AnalyzedToken, error) {
 						BasicDirectedEdge: graph.BasicDirectedEdge{curID, curNode, curNode + 1},
 						Form:              "_" + bridge + "_",
 						Lemma:             bridge,
-						CPOS:              "ADP",
+						CPOS:              util.HEB2UD.Suffix.BridgePOS,
 						POS:               "_",
 						Features:          nil,
 						TokenID:           0,
@@ -440,8 +427,8 @@ func ProcessUDAnalyzedToken(analysis string) (*AnalyzedToken, error) {
 				morphs = append(morphs, &types.Morpheme{
 					BasicDirectedEdge: graph.BasicDirectedEdge{curID, curNode, curNode + 1},
 					Form:              "_" + sufForm,
-					Lemma:             "הוא",
-					CPOS:              "PRON",
+					Lemma:             util.HEB2UD.Suffix.Lemma,
+					CPOS:              util.HEB2UD.Suffix.POS,
 					POS:               "_",
 					Features:          sufFeatures,
 					TokenID:           0,
@@ -496,16 +483,9 @@ func ProcessUDAnalyzedPrefix(analysis string) (*AnalyzedToken, error) {
 			if len(msrs[0]) > 0 {
 				// replace -SUBCONJ for TEMP-SUBCONJ/REL-SUBCONJ
 				HEBPOS = strings.Replace(msrs[0], "-SUBCONJ", "", -1)
-				UDPOS = util.HEB2UDPrefixPOS[HEBPOS]
-				switch HEBPOS {
-				case "TEMP":
-					featureMap = make(map[string]string, 1)
-					featureMap["Case"] = "Tem"
-					featureStr = "Case=Tem"
-				case "DEF":
-					featureMap = make(map[string]string, 1)
-					featureMap["PronType"] = "Art"
-					featureStr = "PronType=Art"
+				UDPOS = util.HEB2UD.PrefixPOS[HEBPOS]
+				if prefixFeats, exists := util.HEB2UD.PrefixFeatures[HEBPOS]; exists && len(prefixFeats) > 0 {
+					featureStr, featureMap = util.MergeFeatureStrs(prefixFeats, "")
 				}
 				morphs = append(morphs, &types.Morpheme{
 					BasicDirectedEdge: graph.BasicDirectedEdge{curID, curNode, curNode + 1},
@@ -7,11 +7,22 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	// "log"
 	"yap/nlp/format/conllul"
+	"yap/nlp/format/lattice"
 )
 
-func UDWriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph int) {
+// tokenRangeMisc returns a UD MISC field holding the token range, if valid
+// and token ranges are written
+func tokenRangeMisc(tokenRange nlp.TokenRange) string {
+	if !lattice.WRITE_TOKEN_RANGE || !tokenRange.Valid() {
+		return "_"
+	}
+	return "TokenRange=" + tokenRange.String()
+}
+
+func UDWriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph int, misc string) {
 	writer.Write([]byte(fmt.Sprintf("%d\t", curMorph)))
 	//writer.Write([]byte(morph.Lemma))
 	writer.Write([]byte(morph.Form))
@@ -28,9 +39,11 @@ func UDWriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph int) {
 	} else {
 		writer.Write([]byte(morph.FeatureStr))
 	}
-	for j := 0; j < 4; j++ {
+	for j := 0; j < 3; j++ {
 		writer.Write([]byte("\t_"))
 	}
+	writer.Write([]byte{'\t'})
+	writer.Write([]byte(misc))
 	writer.Write([]byte{'\n'})
 }
 
@@ -54,24 +67,39 @@ func WriteMorph(writer io.Writer, morph *nlp.EMorpheme, curMorph, curToken int)
 		writer.Write([]byte(morph.FeatureStr))
 	}
 	writer.Write([]byte{'\t'})
-	writer.Write([]byte(fmt.Sprintf("%d\n", curToken+1)))
+	writer.Write([]byte(fmt.Sprintf("%d", curToken+1)))
+	if lattice.WRITE_TOKEN_RANGE {
+		writer.Write([]byte{'\t'})
+		if morph.TokenRange.Valid() {
+			writer.Write([]byte(morph.TokenRange.String()))
+		} else {
+			writer.Write([]byte{'_'})
+		}
+	}
+	writer.Write([]byte{'\n'})
 }
 
 func UDWrite(writer io.Writer, mappedSents []interface{}, conllul []conllul.ConlluLattice) {
 	var curMorph int
 	for i, mappedSent := range mappedSents {
 		curMorph = 1
-		lattice := conllul[i]
-		for _, comment := range lattice.Comments {
+		sentLattice := conllul[i]
+		for _, comment := range sentLattice.Comments {
 			writer.Write([]byte(comment))
 			writer.Write([]byte("\n"))
 		}
 		for _, mapping := range mappedSent.(*disambig.MDConfig).Mappings {
 			if len(mapping.Spellout) > 1 {
 				writer.Write([]byte(fmt.Sprintf("%d-%d\t%s", curMorph, curMorph+len(mapping.Spellout)-1, mapping.Token)))
-				for j := 0; j < 8; j++ {
+				for j := 0; j < 7; j++ {
 					writer.Write([]byte("\t_"))
 				}
+				var tokenRange nlp.TokenRange
+				if mapping.Spellout[0] != nil {
+					tokenRange = mapping.Spellout[0].TokenRange
+				}
+				writer.Write([]byte{'\t'})
+				writer.Write([]byte(tokenRangeMisc(tokenRange)))
 				writer.Write([]byte("\n"))
 			}
 			for _, morph := range mapping.Spellout {
@@ -79,7 +107,11 @@ func UDWrite(writer io.Writer, mappedSents []interface{}, conllul []conllul.Conl
 					// log.Println("\t", "Morph is nil, continuing")
 					continue
 				}
-				UDWriteMorph(writer, morph, curMorph)
+				misc := "_"
+				if len(mapping.Spellout) == 1 {
+					misc = tokenRangeMisc(morph.TokenRange)
+				}
+				UDWriteMorph(writer, morph, curMorph, misc)
 				curMorph++
 			}
 		}
@@ -87,34 +119,67 @@ func UDWrite(writer io.Writer, mappedSents []interface{}, conllul []conllul.Conl
 	}
 }
 
-func Write(writer io.Writer, mappedSents []interface{}) {
+func writeMappings(writer io.Writer, mappings nlp.Mappings) {
 	var curMorph int
-	for _, mappedSent := range mappedSents {
-		curMorph = 0
-		for i, mapping := range mappedSent.(*disambig.MDConfig).Mappings {
-			// log.Println("At token", i, mapping.Token)
-			if mapping.Token == nlp.ROOT_TOKEN {
+	for i, mapping := range mappings {
+		// log.Println("At token", i, mapping.Token)
+		if mapping.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		// if mapping.Spellout != nil {
+		// 	log.Println("\t", mapping.Spellout.AsString())
+		// } else {
+		// 	log.Println("\t", "*No spellout")
+		// }
+		for _, morph := range mapping.Spellout {
+			if morph == nil {
+				// log.Println("\t", "Morph is nil, continuing")
 				continue
 			}
-			// if mapping.Spellout != nil {
-			// 	log.Println("\t", mapping.Spellout.AsString())
-			// } else {
-			// 	log.Println("\t", "*No spellout")
-			// }
-			for _, morph := range mapping.Spellout {
-				if morph == nil {
-					// log.Println("\t", "Morph is nil, continuing")
-					continue
+			WriteMorph(writer, morph, curMorph, i)
+			// log.Println("\t", "At morph", j, morph.Form)
+			curMorph++
+		}
+	}
+	writer.Write([]byte{'\n'})
+}
+
+func Write(writer io.Writer, mappedSents []interface{}) {
+	for _, mappedSent := range mappedSents {
+		writeMappings(writer, mappedSent.(*disambig.MDConfig).Mappings)
+	}
+}
+
+// WriteNBest writes each sentence's paths as mapping blocks, best first.
+// Every block is preceded by a comment with the sentence number, the path's
+// rank and its score; the best path's block also has the per-token confidence
+func WriteNBest(writer io.Writer, nbests []*disambig.NBest) {
+	for i, nbest := range nbests {
+		for j, path := range nbest.Paths {
+			writer.Write([]byte(fmt.Sprintf("# sent %d path %d/%d score %g\n", i+1, j+1, len(nbest.Paths), nbest.Scores[j])))
+			if j == 0 {
+				confidence := nbest.TokenConfidence()
+				confStrs := make([]string, len(confidence))
+				for k, value := range confidence {
+					confStrs[k] = fmt.Sprintf("%.4f", value)
 				}
-				WriteMorph(writer, morph, curMorph, i)
-				// log.Println("\t", "At morph", j, morph.Form)
-				curMorph++
+				writer.Write([]byte("# token_confidence " + strings.Join(confStrs, " ") + "\n"))
 			}
+			writeMappings(writer, path.Mappings)
 		}
-		writer.Write([]byte{'\n'})
 	}
 }
 
+func WriteNBestFile(filename string, nbests []*disambig.NBest) error {
+	file, err := os.Create(filename)
+	defer file.Close()
+	if err != nil {
+		return err
+	}
+	WriteNBest(file, nbests)
+	return nil
+}
+
 func WriteStream(writer *os.File, mappedSents chan interface{}) {
 	var curMorph int
 	var i int
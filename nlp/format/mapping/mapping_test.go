@@ -0,0 +1,71 @@
+package mapping
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"yap/nlp/format/conllu"
+	"yap/nlp/format/conllul"
+	"yap/nlp/format/lattice"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+)
+
+func testMorpheme(form, cpos, feats string, tokenRange nlp.TokenRange) *nlp.EMorpheme {
+	morph := &nlp.EMorpheme{TokenRange: tokenRange}
+	morph.Form, morph.CPOS, morph.POS, morph.FeatureStr = form, cpos, cpos, feats
+	return morph
+}
+
+// udWriteTest writes a sentence of a token and a two morpheme token
+func udWriteTest() string {
+	dogs, inthe := nlp.TokenRange{Start: 0, End: 4}, nlp.TokenRange{Start: 5, End: 10}
+	mappings := nlp.Mappings{
+		{Token: "dogs", Spellout: nlp.Spellout{testMorpheme("dogs", "NOUN", "Number=Plur", dogs)}},
+		{Token: "inthe", Spellout: nlp.Spellout{testMorpheme("in", "ADP", "", inthe), testMorpheme("the", "DET", "", inthe)}},
+	}
+	comments := conllul.ConlluLattice{Comments: []string{"# text = dogs inthe"}}
+	buf := new(bytes.Buffer)
+	UDWrite(buf, []interface{}{&disambig.MDConfig{Mappings: mappings}}, []conllul.ConlluLattice{comments})
+	return buf.String()
+}
+
+func TestUDWrite(t *testing.T) {
+	expected := strings.Join([]string{
+		"# text = dogs inthe",
+		"1	dogs	dogs	NOUN	NOUN	Number=Plur	_	_	_	_",
+		"2-3	inthe	_	_	_	_	_	_	_	_",
+		"2	in	in	ADP	ADP	_	_	_	_	_",
+		"3	the	the	DET	DET	_	_	_	_	_",
+		"", "",
+	}, "\n")
+	if output := udWriteTest(); output != expected {
+		t.Errorf("Expected output\n%s\ngot\n%s", expected, output)
+	}
+}
+
+func TestUDWriteTokenRanges(t *testing.T) {
+	defer func(write bool) { lattice.WRITE_TOKEN_RANGE = write }(lattice.WRITE_TOKEN_RANGE)
+	lattice.WRITE_TOKEN_RANGE = true
+	output := udWriteTest()
+	for _, line := range []string{
+		"1	dogs	dogs	NOUN	NOUN	Number=Plur	_	_	_	TokenRange=0:4\n",
+		"2-3	inthe	_	_	_	_	_	_	_	TokenRange=5:10\n",
+		"2	in	in	ADP	ADP	_	_	_	_	_\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected output line %q, got\n%s", line, output)
+		}
+	}
+	// the output is read back with its token ranges
+	sents, _, err := conllu.Read(strings.NewReader(output), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []nlp.TokenRange{{Start: 0, End: 4}, {Start: 5, End: 10}}
+	if len(sents) != 1 || !reflect.DeepEqual(sents[0].TokenRanges, expected) {
+		t.Errorf("Expected token ranges %v, got %v", expected, sents)
+	}
+}
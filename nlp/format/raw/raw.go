@@ -3,6 +3,7 @@ package raw
 // Package raw reads raw format files
 // raw files contain a token per line
 // sentences end with a new line
+// token ranges are the character offsets of the tokens in the input
 
 import (
 	nlp "yap/nlp/types"
@@ -12,16 +13,27 @@ import (
 	"io"
 	// "log"
 	"os"
+	"unicode/utf8"
 )
 
 func Read(reader io.Reader, limit int) ([]nlp.BasicSentence, error) {
-	var sentences []nlp.BasicSentence
+	sentences, _, err := ReadWithRanges(reader, limit)
+	return sentences, err
+}
+
+func ReadWithRanges(reader io.Reader, limit int) ([]nlp.BasicSentence, [][]nlp.TokenRange, error) {
+	var (
+		sentences []nlp.BasicSentence
+		ranges    [][]nlp.TokenRange
+	)
 	bufReader := bufio.NewReader(reader)
 
 	var (
-		i int
+		i      int
+		offset int
 	)
 	currentSent := make(nlp.BasicSentence, 0, 10)
+	currentRanges := make([]nlp.TokenRange, 0, 10)
 	for curLine, isPrefix, err := bufReader.ReadLine(); err == nil; curLine, isPrefix, err = bufReader.ReadLine() {
 		if isPrefix {
 			panic("Buffer not large enough, fix me :(")
@@ -29,19 +41,25 @@ func Read(reader io.Reader, limit int) ([]nlp.BasicSentence, error) {
 		buf := bytes.NewBuffer(curLine)
 		// log.Println("At record", i)
 		// an empty line indicates a new record
+		lineLen := utf8.RuneCount(curLine)
 		if len(curLine) == 0 {
 			sentences = append(sentences, currentSent)
+			ranges = append(ranges, currentRanges)
 			if limit > 0 && len(sentences) >= limit {
 				break
 			}
 			currentSent = make(nlp.BasicSentence, 0, 10)
+			currentRanges = make([]nlp.TokenRange, 0, 10)
 		} else {
 			currentSent = append(currentSent, nlp.Token(buf.String()))
+			currentRanges = append(currentRanges, nlp.TokenRange{Start: offset, End: offset + lineLen})
 		}
+		// account for the newline
+		offset += lineLen + 1
 
 		i++
 	}
-	return sentences, nil
+	return sentences, ranges, nil
 }
 
 func ReadFile(filename string, limit int) ([]nlp.BasicSentence, error) {
@@ -54,6 +72,16 @@ func ReadFile(filename string, limit int) ([]nlp.BasicSentence, error) {
 	return Read(file, limit)
 }
 
+func ReadFileWithRanges(filename string, limit int) ([]nlp.BasicSentence, [][]nlp.TokenRange, error) {
+	file, err := os.Open(filename)
+	defer file.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ReadWithRanges(file, limit)
+}
+
 func Write(writer io.Writer, sents []interface{}) {
 	for _, sent := range sents {
 		for _, token := range sent.(nlp.BasicSentence) {
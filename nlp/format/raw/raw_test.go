@@ -0,0 +1,52 @@
+package raw
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	nlp "yap/nlp/types"
+)
+
+const testInput = "הבית\nגדול\n.\n\nshalom\n\n"
+
+func TestReadWithRanges(t *testing.T) {
+	sents, ranges, err := ReadWithRanges(strings.NewReader(testInput), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]nlp.TokenRange{
+		{{Start: 0, End: 4}, {Start: 5, End: 9}, {Start: 10, End: 11}},
+		{{Start: 13, End: 19}},
+	}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected token ranges %v, got %v", expected, ranges)
+	}
+	// ranges are in characters of the input
+	input := []rune(testInput)
+	for i, sent := range sents {
+		for j, token := range sent {
+			tokenRange := ranges[i][j]
+			if got := string(input[tokenRange.Start:tokenRange.End]); got != string(token) {
+				t.Errorf("Expected range %v to be token %s, got %s", tokenRange, token, got)
+			}
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	sents, err := Read(strings.NewReader(testInput), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generic := make([]interface{}, len(sents))
+	for i, sent := range sents {
+		generic[i] = sent
+	}
+	buf := new(bytes.Buffer)
+	Write(buf, generic)
+	if buf.String() != testInput {
+		t.Errorf("Expected output %q, got %q", testInput, buf.String())
+	}
+}
@@ -0,0 +1,173 @@
+package dependency
+
+// Pseudo-projective parsing (Nivre & Nilsson 2005) with the path encoding:
+// non-projective arcs of the training trees are lifted until the trees are
+// projective, the label of a lifted arc is marked with LIFTED and the labels
+// of the arcs on the path from its new (linear) head down to its original
+// (syntactic) head are marked with PATH. Deprojectivizing parser output
+// searches below the linear head along the PATH marked arcs for the
+// syntactic head.
+//
+// Trees are given as heads and relations indexed by node, index 0 is the
+// root and is ignored
+
+import "strings"
+
+const (
+	LIFTED = "↑"
+	PATH   = "↓"
+)
+
+// PseudoProjectiveRelations extends relations with their encoded
+// variations, keeping the original relations first
+func PseudoProjectiveRelations(relations []string) []string {
+	retval := make([]string, 0, len(relations)*4)
+	retval = append(retval, relations...)
+	for _, suffix := range []string{LIFTED, PATH, LIFTED + PATH} {
+		for _, rel := range relations {
+			retval = append(retval, rel+suffix)
+		}
+	}
+	return retval
+}
+
+func encodeRel(base string, lifted, path bool) string {
+	if lifted {
+		base += LIFTED
+	}
+	if path {
+		base += PATH
+	}
+	return base
+}
+
+func decodeRel(rel string) (base string, lifted, path bool) {
+	if strings.HasSuffix(rel, PATH) {
+		rel, path = strings.TrimSuffix(rel, PATH), true
+	}
+	if strings.HasSuffix(rel, LIFTED) {
+		rel, lifted = strings.TrimSuffix(rel, LIFTED), true
+	}
+	return rel, lifted, path
+}
+
+// dominates returns whether head is node or one of its ancestors
+func dominates(heads []int, head, node int) bool {
+	for steps := 0; steps < len(heads) && node > 0; steps++ {
+		if node == head {
+			return true
+		}
+		node = heads[node]
+	}
+	return head == 0 || node == head
+}
+
+func nonProjectiveArc(heads []int, modifier int) bool {
+	head := heads[modifier]
+	from, to := head, modifier
+	if from > to {
+		from, to = to, from
+	}
+	for node := from + 1; node < to; node++ {
+		if !dominates(heads, head, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProjective returns whether a tree has no crossing arcs
+func IsProjective(heads []int) bool {
+	for modifier := 1; modifier < len(heads); modifier++ {
+		if nonProjectiveArc(heads, modifier) {
+			return false
+		}
+	}
+	return true
+}
+
+// Projectivize lifts the shortest non-projective arc to the head of its head
+// until the tree is projective, encoding the lifts in the relations. It
+// returns the number of lifts
+func Projectivize(heads []int, rels []string) int {
+	var lifts int
+	for {
+		shortest, shortestLen := 0, len(heads)
+		for modifier := 1; modifier < len(heads); modifier++ {
+			if !nonProjectiveArc(heads, modifier) {
+				continue
+			}
+			arcLen := heads[modifier] - modifier
+			if arcLen < 0 {
+				arcLen = -arcLen
+			}
+			if arcLen < shortestLen {
+				shortest, shortestLen = modifier, arcLen
+			}
+		}
+		if shortest == 0 {
+			return lifts
+		}
+		head := heads[shortest]
+		base, _, path := decodeRel(rels[shortest])
+		rels[shortest] = encodeRel(base, true, path)
+		base, lifted, _ := decodeRel(rels[head])
+		rels[head] = encodeRel(base, lifted, true)
+		heads[shortest] = heads[head]
+		lifts++
+	}
+}
+
+func children(heads []int, head int) []int {
+	var retval []int
+	for node := 1; node < len(heads); node++ {
+		if heads[node] == head {
+			retval = append(retval, node)
+		}
+	}
+	return retval
+}
+
+// syntacticHead searches breadth first below the head of a lifted node,
+// along PATH marked arcs, for the first node without PATH marked dependents
+func syntacticHead(heads []int, rels []string, lifted int) int {
+	queue := []int{heads[lifted]}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		var pathDeps []int
+		for _, dep := range children(heads, node) {
+			if _, _, path := decodeRel(rels[dep]); path && dep != lifted {
+				pathDeps = append(pathDeps, dep)
+			}
+		}
+		if len(pathDeps) == 0 && node != heads[lifted] {
+			return node
+		}
+		queue = append(queue, pathDeps...)
+	}
+	return heads[lifted]
+}
+
+// Deprojectivize reattaches lifted nodes to their syntactic heads, top down,
+// and removes the encoding from the relations
+func Deprojectivize(heads []int, rels []string) {
+	// reattached nodes may be reached again under their new heads
+	visited := make([]bool, len(heads))
+	queue := children(heads, 0)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		if visited[node] {
+			continue
+		}
+		visited[node] = true
+		if _, lifted, _ := decodeRel(rels[node]); lifted {
+			heads[node] = syntacticHead(heads, rels, node)
+		}
+		queue = append(queue, children(heads, node)...)
+	}
+	for node := 1; node < len(rels); node++ {
+		rels[node], _, _ = decodeRel(rels[node])
+	}
+}
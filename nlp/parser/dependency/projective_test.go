@@ -0,0 +1,42 @@
+package dependency
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPseudoProjective(t *testing.T) {
+	// ה(1) בית(2) ה(3) גדול(4) נפל(5), the first definite article attached
+	// to the adjective crosses the arc of the noun to the verb
+	heads := []int{-1, 4, 5, 2, 2, 0}
+	rels := []string{"", "def", "subj", "def", "amod", "ROOT"}
+	goldHeads := append([]int(nil), heads...)
+	goldRels := append([]string(nil), rels...)
+	if IsProjective(heads) {
+		t.Fatal("Expected a non-projective tree")
+	}
+	if lifts := Projectivize(heads, rels); lifts != 1 {
+		t.Errorf("Expected 1 lift, got %d", lifts)
+	}
+	if !IsProjective(heads) {
+		t.Errorf("Expected a projective tree, got heads %v", heads)
+	}
+	if expected := []int{-1, 2, 5, 2, 2, 0}; !reflect.DeepEqual(heads, expected) {
+		t.Errorf("Expected heads %v, got %v", expected, heads)
+	}
+	if expected := []string{"", "def" + LIFTED, "subj", "def", "amod" + PATH, "ROOT"}; !reflect.DeepEqual(rels, expected) {
+		t.Errorf("Expected relations %v, got %v", expected, rels)
+	}
+	Deprojectivize(heads, rels)
+	if !reflect.DeepEqual(heads, goldHeads) || !reflect.DeepEqual(rels, goldRels) {
+		t.Errorf("Expected %v %v after deprojectivizing, got %v %v", goldHeads, goldRels, heads, rels)
+	}
+}
+
+func TestPseudoProjectiveRelations(t *testing.T) {
+	rels := PseudoProjectiveRelations([]string{"subj", "obj"})
+	expected := []string{"subj", "obj", "subj↑", "obj↑", "subj↓", "obj↓", "subj↑↓", "obj↑↓"}
+	if !reflect.DeepEqual(rels, expected) {
+		t.Errorf("Expected %v, got %v", expected, rels)
+	}
+}
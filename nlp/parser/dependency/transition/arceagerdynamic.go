@@ -0,0 +1,258 @@
+package transition
+
+import (
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// ArcEagerDynamicOracle is the dynamic oracle of Goldberg & Nivre 2012 for
+// the arc eager system. The cost of a transition is the number of gold arcs
+// that are reachable before it and are not after it.
+//
+// The zpar variant of arc eager differs from the system of the paper:
+// there is no root node on the stack, the node shifted onto an empty stack
+// (the stack bottom) is attached to the root by pop root unless it is left
+// arced; a shift can't follow a reduce; and the last word can't be shifted
+// onto the stack, nor right arced while there are other nodes without heads
+// on the stack. Reachability and costs take these into account, so the
+// costs are exact for projective gold trees
+type ArcEagerDynamicOracle struct {
+	ZparArcEagerOracle
+	System *ArcEager
+
+	goldHeads []int
+	goldRels  []DepRel
+	relIndex  []int
+}
+
+var _ DynamicOracle = &ArcEagerDynamicOracle{}
+
+// DynamicOracle returns a dynamic oracle for the arc system
+func (a *ArcEager) DynamicOracle() *ArcEagerDynamicOracle {
+	return &ArcEagerDynamicOracle{
+		ZparArcEagerOracle: ZparArcEagerOracle{Transitions: a.Transitions, LA: a.LEFT, RA: a.RIGHT},
+		System:             a,
+	}
+}
+
+func (o *ArcEagerDynamicOracle) SetGold(g interface{}) {
+	o.ZparArcEagerOracle.SetGold(g)
+	numNodes := o.gold.NumberOfNodes()
+	o.goldHeads, o.goldRels, o.relIndex = make([]int, numNodes), make([]DepRel, numNodes), make([]int, numNodes)
+	for node := range o.goldHeads {
+		// -1 is the root, -2 a node without a gold arc
+		o.goldHeads[node], o.relIndex[node] = -2, -1
+		if arc := o.gold.GetLabeledArc(node); arc != nil {
+			o.goldHeads[node], o.goldRels[node] = arc.GetHead(), arc.GetRelation()
+			if index, exists := o.System.Relations.IndexOf(o.goldRels[node]); exists {
+				o.relIndex[node] = index
+			}
+		}
+	}
+}
+
+// reachable returns, for each node, whether its gold arc is in the
+// configuration or can still be added to it
+func (o *ArcEagerDynamicOracle) reachable(c *SimpleConfiguration) []bool {
+	numNodes := len(o.goldHeads)
+	stackPos, inQueue := make([]int, numNodes), make([]bool, numNodes)
+	for node := range stackPos {
+		stackPos[node] = -1
+	}
+	stackSize, queueSize := c.Stack().Size(), c.Queue().Size()
+	for i := 0; i < stackSize; i++ {
+		node, _ := c.Stack().Index(i)
+		stackPos[node] = i
+	}
+	for i := 0; i < queueSize; i++ {
+		node, _ := c.Queue().Index(i)
+		inQueue[node] = true
+	}
+	// a headless node above the stack bottom whose gold head is not in the
+	// queue is dead, it will be left arced by a wrong head; the last word
+	// can't be right arced from a dead node or from above one
+	lowestDead := -1
+	for i := 0; i < stackSize-1; i++ {
+		node, _ := c.Stack().Index(i)
+		if head := o.goldHeads[node]; !c.Arcs().HasHead(node) && !(head >= 0 && inQueue[head]) {
+			lowestDead = i
+		}
+	}
+	lastWord := -1
+	if queueSize > 0 {
+		lastWord, _ = c.Queue().Index(queueSize - 1)
+	}
+	// the head of the last word and the chain of its gold heads in the queue
+	// are pushed above the first gold ancestor on the stack, which stays
+	// until they are: the last word is blocked if that ancestor is dead or
+	// above a dead node
+	lastBlocked := func(head int) bool {
+		for head >= 0 && inQueue[head] {
+			head = o.goldHeads[head]
+		}
+		return head >= 0 && stackPos[head] >= 0 && lowestDead >= stackPos[head]
+	}
+	retval := make([]bool, numNodes)
+	for node, head := range o.goldHeads {
+		switch arc := c.GetLabeledArc(node); {
+		case head == -2:
+		case arc != nil && head == -1:
+			// pop root is the last transition, attaching to 0 before it is
+			// a wrong arc
+			retval[node] = stackSize == 0 && queueSize == 0 && arc.GetHead() == 0 && arc.GetRelation() == o.goldRels[node]
+		case arc != nil:
+			retval[node] = arc.GetHead() == head && arc.GetRelation() == o.goldRels[node]
+		case inQueue[node]:
+			retval[node] = head == -1 || inQueue[head] || stackPos[head] >= 0
+			if node == lastWord && head >= 0 && lastBlocked(head) {
+				retval[node] = false
+			}
+		case stackPos[node] >= 0:
+			if head == -1 {
+				retval[node] = stackPos[node] == stackSize-1
+			} else {
+				retval[node] = inQueue[head]
+			}
+		}
+	}
+	return retval
+}
+
+// numReachable returns the number of gold arcs that are in the
+// configuration or can still be added to it together
+func (o *ArcEagerDynamicOracle) numReachable(c *SimpleConfiguration) int {
+	var count int
+	for _, reachable := range o.reachable(c) {
+		if reachable {
+			count++
+		}
+	}
+	return count
+}
+
+// best returns the number of gold arcs in the best configuration reachable
+// from the configuration; a shift can't follow a reduce, so after a reduce
+// it is the best of the possible transitions
+func (o *ArcEagerDynamicOracle) best(c *SimpleConfiguration) int {
+	best := o.numReachable(c)
+	if last := c.GetLastTransition(); last != nil && last.Value() == o.System.REDUCE && c.Queue().Size() > 0 {
+		_, losses := o.losses(c, best)
+		best -= minCost(losses)
+	}
+	return best
+}
+
+// losses returns the possible transitions and the gold arcs each loses of
+// the reachable arcs; arcs are only simulated with one wrong relation, all
+// wrong relations lose the same arcs
+func (o *ArcEagerDynamicOracle) losses(c *SimpleConfiguration, reachable int) ([]int, []int) {
+	a := o.System
+	_, possible := a.GetTransitions(c)
+	losses := make([]int, len(possible))
+	s, _ := c.Stack().Peek()
+	b, _ := c.Queue().Peek()
+	wrongRelation := map[int]int{}
+	loss := func(transition int) int {
+		return reachable - o.best(a.Transition(c, &TypedTransition{TransitionType, transition}).(*SimpleConfiguration))
+	}
+	for i, transition := range possible {
+		var modifier, head, first int
+		switch {
+		case transition >= a.LEFT && transition < a.RIGHT:
+			modifier, head, first = s, b, a.LEFT
+		case transition >= a.RIGHT:
+			modifier, head, first = b, s, a.RIGHT
+		default:
+			losses[i] = loss(transition)
+			continue
+		}
+		if o.goldHeads[modifier] == head && transition-first == o.relIndex[modifier] {
+			losses[i] = loss(transition)
+			continue
+		}
+		if _, exists := wrongRelation[first]; !exists {
+			wrongRelation[first] = loss(transition)
+		}
+		losses[i] = wrongRelation[first]
+	}
+	return possible, losses
+}
+
+// costs returns the possible transitions and their costs, the gold arcs
+// they lose of the best reachable configuration
+func (o *ArcEagerDynamicOracle) costs(c *SimpleConfiguration) ([]int, []int) {
+	if o.goldHeads == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	possible, costs := o.losses(c, o.numReachable(c))
+	least := minCost(costs)
+	for i := range costs {
+		costs[i] -= least
+	}
+	return possible, costs
+}
+
+func minCost(costs []int) int {
+	var retval int
+	for i, cost := range costs {
+		if i == 0 || cost < retval {
+			retval = cost
+		}
+	}
+	return retval
+}
+
+// Cost returns the number of reachable gold arcs lost by a transition
+func (o *ArcEagerDynamicOracle) Cost(conf Configuration, transition int) int {
+	possible, costs := o.costs(conf.(*SimpleConfiguration))
+	for i, possibleTransition := range possible {
+		if possibleTransition == transition {
+			return costs[i]
+		}
+	}
+	panic("Can't cost a transition that is not possible")
+}
+
+// ZeroCost returns the possible transitions that lose no reachable arcs
+func (o *ArcEagerDynamicOracle) ZeroCost(conf Configuration) []Transition {
+	possible, costs := o.costs(conf.(*SimpleConfiguration))
+	retval := make([]Transition, 0, len(possible))
+	for i, transition := range possible {
+		if costs[i] == 0 {
+			retval = append(retval, &TypedTransition{TransitionType, transition})
+		}
+	}
+	return retval
+}
+
+// Transition returns the static oracle's transition if it is zero cost, so
+// gold configurations follow the static oracle
+func (o *ArcEagerDynamicOracle) Transition(conf Configuration) Transition {
+	zeroCost := o.ZeroCost(conf)
+	if len(zeroCost) == 0 {
+		panic("Dynamic oracle found no possible transitions")
+	}
+	if static, exists := o.staticTransition(conf); exists {
+		for _, transition := range zeroCost {
+			if transition.Equal(static) {
+				return static
+			}
+		}
+	}
+	return zeroCost[0]
+}
+
+// staticTransition returns the static oracle's transition. The static oracle
+// is defined on any configuration with a non-empty stack or queue, gold or
+// not; there is no static transition once both are empty
+func (o *ArcEagerDynamicOracle) staticTransition(conf Configuration) (Transition, bool) {
+	c := conf.(*SimpleConfiguration)
+	if c.Queue().Size() == 0 && c.Stack().Size() == 0 {
+		return nil, false
+	}
+	return o.ZparArcEagerOracle.Transition(conf), true
+}
+
+func (o *ArcEagerDynamicOracle) Name() string {
+	return "Arc Eager Dynamic Oracle (Goldberg & Nivre 2012)"
+}
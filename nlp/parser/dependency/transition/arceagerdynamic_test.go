@@ -0,0 +1,280 @@
+package transition_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"yap/alg/perceptron"
+	"yap/alg/search"
+	. "yap/alg/transition"
+	"yap/alg/transition/model"
+	"yap/nlp/format/conll"
+	. "yap/nlp/parser/dependency/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+var dynamicTestConll = []string{"1	economic	economic	JJ	JJ	_	2	ATT	_	_\n" +
+	"2	news	news	NN	NN	_	3	SBJ	_	_\n" +
+	"3	had	had	VBD	VBD	_	0	ROOT	_	_\n" +
+	"4	little	little	JJ	JJ	_	5	ATT	_	_\n" +
+	"5	effect	effect	NN	NN	_	3	OBJ	_	_\n" +
+	"6	on	on	IN	IN	_	5	ATT	_	_\n" +
+	"7	financial	financial	JJ	JJ	_	8	ATT	_	_\n" +
+	"8	markets	markets	NNS	NNS	_	6	PC	_	_\n" +
+	"9	.	.	.	.	_	3	PU	_	_\n" +
+	"\n",
+	// the last word is not attached to the root word
+	"1	she	she	PRP	PRP	_	2	SBJ	_	_\n" +
+		"2	gave	gave	VBD	VBD	_	0	ROOT	_	_\n" +
+		"3	him	him	PRP	PRP	_	2	OBJ	_	_\n" +
+		"4	a	a	DT	DT	_	5	ATT	_	_\n" +
+		"5	book	book	NN	NN	_	2	OBJ	_	_\n" +
+		"6	about	about	IN	IN	_	5	ATT	_	_\n" +
+		"7	cats	cats	NNS	NNS	_	6	PC	_	_\n" +
+		"\n",
+	"1	quickly	quickly	RB	RB	_	4	ATT	_	_\n2	the	the	DT	DT	_	3	ATT	_	_\n3	dog	dog	NN	NN	_	4	SBJ	_	_\n4	ran	ran	VBD	VBD	_	0	ROOT	_	_\n\n",
+	"1	I	I	PRP	PRP	_	2	SBJ	_	_\n2	think	think	VB	VB	_	0	ROOT	_	_\n3	that	that	IN	IN	_	2	OBJ	_	_\n4	he	he	PRP	PRP	_	5	SBJ	_	_\n5	said	said	VBD	VBD	_	3	PC	_	_\n6	she	she	PRP	PRP	_	7	SBJ	_	_\n7	left	left	VBD	VBD	_	5	OBJ	_	_\n8	early	early	RB	RB	_	7	ATT	_	_\n\n",
+	"1	a	a	X	X	_	0	ROOT	_	_\n2	b	b	X	X	_	1	OBJ	_	_\n3	c	c	X	X	_	2	OBJ	_	_\n4	d	d	X	X	_	3	OBJ	_	_\n5	e	e	X	X	_	1	PU	_	_\n\n",
+	"1	a	a	X	X	_	2	ATT	_	_\n2	b	b	X	X	_	5	SBJ	_	_\n3	c	c	X	X	_	4	ATT	_	_\n4	d	d	X	X	_	2	OBJ	_	_\n5	e	e	X	X	_	0	ROOT	_	_\n6	f	f	X	X	_	5	OBJ	_	_\n7	g	g	X	X	_	8	ATT	_	_\n8	h	h	X	X	_	6	PC	_	_\n\n",
+}
+
+var dynamicTestRelations = []string{"ATT", "SBJ", "OBJ", "PC", "PU"}
+
+// dynamicTestSystem returns the arc eager system and an empty configuration,
+// with the enumerations of the dep command
+func dynamicTestSystem() (*ArcEager, *SimpleConfiguration) {
+	eWord, ePOS, eWPOS := util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	eMHost, eMSuffix := util.NewEnumSet(1), util.NewEnumSet(1)
+	eRel, eTrans := util.NewEnumSet(len(dynamicTestRelations)+1), util.NewEnumSet(20)
+	eMHost.Add("")
+	eMSuffix.Add("")
+	eRel.Add(nlp.DepRel(nlp.ROOT_LABEL))
+	for _, rel := range dynamicTestRelations {
+		eRel.Add(nlp.DepRel(rel))
+	}
+	// the transition enumeration, as set up by the dep command
+	eTrans.Add("NO")
+	sh, _ := eTrans.Add("SH")
+	re, _ := eTrans.Add("RE")
+	pr, _ := eTrans.Add("PR")
+	la := eTrans.Len()
+	for _, rel := range eRel.Index {
+		eTrans.Add("LA-" + string(rel.(nlp.DepRel)))
+	}
+	ra := eTrans.Len()
+	for _, rel := range eRel.Index {
+		eTrans.Add("RA-" + string(rel.(nlp.DepRel)))
+	}
+
+	arcEager := &ArcEager{
+		ArcStandard: ArcStandard{
+			SHIFT:       sh,
+			LEFT:        la,
+			RIGHT:       ra,
+			Relations:   eRel,
+			Transitions: eTrans,
+		},
+		REDUCE:  re,
+		POPROOT: pr,
+	}
+	arcEager.AddDefaultOracle()
+	conf := &SimpleConfiguration{
+		EWord:    eWord,
+		EPOS:     ePOS,
+		EWPOS:    eWPOS,
+		EMHost:   eMHost,
+		EMSuffix: eMSuffix,
+		ERel:     eRel,
+		ETrans:   eTrans,
+	}
+	return arcEager, conf
+}
+
+// dynamicTestGold reads a gold graph with the enumerations of conf
+func dynamicTestGold(t *testing.T, conf *SimpleConfiguration, conllData string) nlp.LabeledDependencyGraph {
+	sents, err := conll.Read(strings.NewReader(conllData), 0)
+	if err != nil || len(sents) != 1 {
+		t.Fatalf("Failed reading test conll: %v", err)
+	}
+	return conll.Conll2Graph(sents[0], conf.EWord, conf.EPOS, conf.EWPOS, conf.ERel, conf.EMHost, conf.EMSuffix)
+}
+
+func dynamicTestSetup(t *testing.T, conllData string) (*ArcEager, *SimpleConfiguration, nlp.LabeledDependencyGraph) {
+	arcEager, conf := dynamicTestSystem()
+	gold := dynamicTestGold(t, conf, conllData)
+	conf.Init(gold.TaggedSentence())
+	return arcEager, conf, gold
+}
+
+// correctArcs counts the nodes attached to their gold head with the gold
+// relation, the root is attached to 0 by pop root
+func correctArcs(conf *SimpleConfiguration, gold nlp.LabeledDependencyGraph) int {
+	var correct int
+	for node := 0; node < gold.NumberOfNodes(); node++ {
+		goldArc, arc := gold.GetLabeledArc(node), conf.GetLabeledArc(node)
+		if goldArc == nil || arc == nil {
+			continue
+		}
+		goldHead := goldArc.GetHead()
+		if goldHead == -1 {
+			goldHead = 0
+		}
+		if goldHead == arc.GetHead() && goldArc.GetRelation() == arc.GetRelation() {
+			correct++
+		}
+	}
+	return correct
+}
+
+func TestArcEagerDynamicOracleGold(t *testing.T) {
+	for _, conllData := range dynamicTestConll {
+		arcEager, conf, gold := dynamicTestSetup(t, conllData)
+		static := arcEager.Oracle()
+		static.SetGold(gold)
+		dynamic := arcEager.DynamicOracle()
+		dynamic.SetGold(gold)
+
+		var c Configuration = conf
+		for !c.Terminal() {
+			staticTransition := static.Transition(c)
+			if cost := dynamic.Cost(c, staticTransition.Value()); cost != 0 {
+				t.Fatalf("Static oracle transition %v at %v has cost %d", staticTransition, c, cost)
+			}
+			if transition := dynamic.Transition(c); !transition.Equal(staticTransition) {
+				t.Fatalf("Dynamic oracle transition %v at %v differs from static %v", transition, c, staticTransition)
+			}
+			c = arcEager.Transition(c, staticTransition)
+		}
+		if correct := correctArcs(c.(*SimpleConfiguration), gold); correct != gold.NumberOfNodes() {
+			t.Errorf("Got %d correct arcs, expected %d", correct, gold.NumberOfNodes())
+		}
+	}
+}
+
+// followDynamic follows the dynamic oracle to a terminal configuration and
+// returns its number of correct arcs
+func followDynamic(arcEager *ArcEager, dynamic *ArcEagerDynamicOracle, c Configuration, gold nlp.LabeledDependencyGraph) int {
+	for !c.Terminal() {
+		c = arcEager.Transition(c, dynamic.Transition(c))
+	}
+	return correctArcs(c.(*SimpleConfiguration), gold)
+}
+
+// TestArcEagerDynamicOracleCosts walks through random configurations, and at
+// each takes every possible transition and then follows the dynamic oracle;
+// the arcs lost relative to following the oracle right away should be the
+// cost of the transition
+func TestArcEagerDynamicOracleCosts(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, conllData := range dynamicTestConll {
+		arcEager, conf, gold := dynamicTestSetup(t, conllData)
+		dynamic := arcEager.DynamicOracle()
+		dynamic.SetGold(gold)
+		rootRelation, _ := arcEager.Relations.IndexOf(nlp.DepRel(nlp.ROOT_LABEL))
+
+		for walk := 0; walk < 100; walk++ {
+			var c Configuration = conf
+			for !c.Terminal() {
+				best := followDynamic(arcEager, dynamic, c, gold)
+				tType, possible := arcEager.GetTransitions(c)
+				var zeroCost int
+				for _, transition := range possible {
+					// arcs with the root relation aren't told apart from the root
+					if transition == arcEager.LEFT+rootRelation || transition == arcEager.RIGHT+rootRelation {
+						continue
+					}
+					cost := dynamic.Cost(c, transition)
+					if cost == 0 {
+						zeroCost++
+					}
+					explored := arcEager.Transition(c, &TypedTransition{T: tType, V: transition})
+					if correct := followDynamic(arcEager, dynamic, explored, gold); correct != best-cost {
+						t.Errorf("Transition %v at %v has cost %d, got %d correct arcs instead of %d %s %v", arcEager.Transitions.ValueOf(transition), c, cost, correct, best, c.(*SimpleConfiguration).StringArcs(), c.GetSequence())
+					}
+				}
+				if zeroCost == 0 {
+					t.Errorf("No zero cost transitions at %v", c)
+				}
+				c = arcEager.Transition(c, &TypedTransition{T: tType, V: possible[random.Intn(len(possible))]})
+			}
+		}
+	}
+}
+
+var explorationTestFeatures = [][2]string{
+	{"S0|w", "S0|w"},
+	{"S0|p", "S0|w"},
+	{"N0|w", "N0|w"},
+	{"N0|p", "N0|w"},
+	{"N1|w", "N1|w"},
+	{"N1|p", "N1|w"},
+	{"S0|w+N0|w", "S0|w;N0|w"},
+	{"S0|p+N0|p", "S0|w;N0|w"},
+	{"S0|p+N0|p+N1|p", "S0|w;N0|w"},
+	{"S0|w|d", "S0|w;N0|w"},
+	{"S0h|w", "S0h|w"},
+	{"S0h|p", "S0h|w"},
+	{"S0|l", "S0h|w"},
+}
+
+// TestErrorExplorationTraining trains greedily with the dynamic oracle while
+// following wrong predictions, and parses the training sentences back
+func TestErrorExplorationTraining(t *testing.T) {
+	// the last sentences share their words and contradict each other
+	sentences := dynamicTestConll[:4]
+	arcEager, conf := dynamicTestSystem()
+	golds := make([]nlp.LabeledDependencyGraph, len(sentences))
+	instances := make([]perceptron.DecodedInstance, len(sentences))
+	for i, conllData := range sentences {
+		golds[i] = dynamicTestGold(t, conf, conllData)
+		instances[i] = &perceptron.Decoded{InstanceVal: golds[i].TaggedSentence(), DecodedVal: golds[i]}
+	}
+	extractor := &GenericExtractor{
+		EFeatures: util.NewEnumSet(len(explorationTestFeatures)),
+		EWord:     conf.EWord,
+		EPOS:      conf.EPOS,
+		EWPOS:     conf.EWPOS,
+		ERel:      conf.ERel,
+		EMHost:    conf.EMHost,
+		EMSuffix:  conf.EMSuffix,
+	}
+	extractor.InitTypes([]byte{TransitionType})
+	for _, feature := range explorationTestFeatures {
+		if err := extractor.LoadFeature(feature[0], feature[1], string(TransitionType), false, false); err != nil {
+			t.Fatalf("Failed loading feature %v: %v", feature[0], err)
+		}
+	}
+	deterministic := &search.Deterministic{
+		TransFunc:        arcEager,
+		FeatExtractor:    extractor,
+		ReturnSequence:   true,
+		Base:             conf,
+		NoRecover:        true,
+		DefaultTransType: TransitionType,
+	}
+	exploration := &search.ErrorExploration{
+		Deterministic:      deterministic,
+		Oracle:             arcEager.DynamicOracle(),
+		ExploreAfter:       len(instances),
+		ExploreProbability: 0.9,
+		Seed:               1,
+	}
+	p := &perceptron.LinearPerceptron{
+		Decoder:     exploration,
+		GoldDecoder: exploration,
+		Updater:     new(model.AveragedModelStrategy),
+		Iterations:  10,
+	}
+	p.Init(model.NewAvgMatrixSparse(extractor.EFeatures.Len(), nil, false))
+	p.Train(instances)
+
+	deterministic.Model = p.Model.(model.Interface)
+	for i, gold := range golds {
+		parsed, _ := deterministic.Parse(gold.TaggedSentence())
+		if correct := correctArcs(parsed.(*SimpleConfiguration), gold); correct != gold.NumberOfNodes() {
+			t.Errorf("Sentence %d: Got %d correct arcs after training, expected %d", i, correct, gold.NumberOfNodes())
+		}
+	}
+}
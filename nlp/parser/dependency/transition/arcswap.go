@@ -0,0 +1,231 @@
+package transition
+
+import (
+	"fmt"
+	"sort"
+
+	. "yap/alg/transition"
+	. "yap/nlp/types"
+)
+
+// ArcSwap is arc standard with a SWAP transition (Nivre 2009), which
+// reorders the input so non-projective trees can be built
+type ArcSwap struct {
+	ArcStandard
+	SWAP int
+}
+
+// Verify that ArcSwap is a TransitionSystem
+var _ TransitionSystem = &ArcSwap{}
+
+func (a *ArcSwap) Transition(from Configuration, rawTransition Transition) Configuration {
+	// Transition System (in addition to arc standard):
+	// SW	(S|wi,	wj|B,	A) => (S   ,	wj|wi|B,	A)	if: i < j
+	if rawTransition.Value() != a.SWAP {
+		return a.ArcStandard.Transition(from, rawTransition)
+	}
+	conf, ok := from.Copy().(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	wi, wiExists := conf.Stack().Pop()
+	wj, wjExists := conf.Queue().Pop()
+	if !(wiExists && wjExists) {
+		panic(fmt.Sprintf("Can't swap, Stack and/or Queue are/is empty: %v", conf))
+	}
+	if wi > wj {
+		panic(fmt.Sprintf("Can't swap %d back after %d", wi, wj))
+	}
+	if isRootNode(conf, wi) {
+		panic(fmt.Sprintf("Can't swap ROOT node %d", wi))
+	}
+	// wi goes back to the buffer behind wj, which can now attach to the
+	// rest of the stack
+	conf.Queue().Push(wi)
+	conf.Queue().Push(wj)
+	conf.Assign(uint16(conf.Nodes[wi].ID()))
+	conf.NumHeadStack--
+	conf.SetLastTransition(rawTransition)
+	return conf
+}
+
+func (a *ArcSwap) possibleTransitions(from Configuration, transitions chan int) {
+	conf, ok := from.(*SimpleConfiguration)
+	if !ok {
+		panic("Got wrong configuration type")
+	}
+	_, standard := a.ArcStandard.YieldTransitions(conf)
+	for transition := range standard {
+		transitions <- transition
+	}
+	sPeek, sExists := conf.Stack().Peek()
+	qPeek, qExists := conf.Queue().Peek()
+	if sExists && qExists && sPeek < qPeek && !isRootNode(conf, sPeek) {
+		transitions <- a.SWAP
+	}
+	close(transitions)
+}
+
+// isRootNode is true if the node is an explicit ROOT node (node 0 of graphs
+// with one), which must stay before the words and is never swapped
+func isRootNode(conf *SimpleConfiguration, node int) bool {
+	if node != 0 || len(conf.Nodes) == 0 {
+		return false
+	}
+	switch n := conf.Nodes[node].Node.(type) {
+	case *TaggedDepNode:
+		return n.RawToken == ROOT_TOKEN
+	case *EMorpheme:
+		return n.Form == ROOT_TOKEN
+	}
+	return false
+}
+
+func (a *ArcSwap) GetTransitions(from Configuration) (byte, []int) {
+	retval := make([]int, 0, 10)
+	tType, transitions := a.YieldTransitions(from)
+	for transition := range transitions {
+		retval = append(retval, transition)
+	}
+	return tType, retval
+}
+
+func (a *ArcSwap) YieldTransitions(from Configuration) (byte, chan int) {
+	transitions := make(chan int)
+	go a.possibleTransitions(from, transitions)
+	return TransitionType, transitions
+}
+
+func (a *ArcSwap) TransitionTypes() []string {
+	return append(a.ArcStandard.TransitionTypes(), "SW")
+}
+
+func (a *ArcSwap) Projective() bool {
+	return false
+}
+
+func (a *ArcSwap) Oracle() Oracle {
+	return a.oracle
+}
+
+func (a *ArcSwap) AddDefaultOracle() {
+	a.oracle = Oracle(&ArcSwapOracle{
+		ArcStandardOracle: ArcStandardOracle{Transitions: a.Transitions, LA: int(a.LEFT), RA: int(a.RIGHT)},
+	})
+}
+
+func (a *ArcSwap) Name() string {
+	return "Arc Standard + Swap (Nivre 2009)"
+}
+
+// ArcSwapOracle is the static oracle of Nivre 2009: it attaches a node
+// only after it has all of its gold dependents, and swaps nodes that are
+// out of the projective order of the gold tree
+type ArcSwapOracle struct {
+	ArcStandardOracle
+	// projective order (in-order traversal) position of each gold node
+	order map[int]int
+}
+
+var _ Decision = &ArcSwapOracle{}
+
+func (o *ArcSwapOracle) SetGold(g interface{}) {
+	o.ArcStandardOracle.SetGold(g)
+	o.order = ProjectiveOrder(o.gold)
+}
+
+// ProjectiveOrder returns the position of each node of a dependency graph
+// in an in-order traversal of the tree, in which every subtree is
+// contiguous
+func ProjectiveOrder(graph LabeledDependencyGraph) map[int]int {
+	var (
+		children = make(map[int][]int)
+		hasHead  = make(map[int]bool)
+		order    = make(map[int]int, graph.NumberOfNodes())
+		visit    func(int)
+	)
+	for _, edgeNum := range graph.GetEdges() {
+		arc := graph.GetLabeledArc(edgeNum)
+		if arc == nil || arc.GetHead() < 0 {
+			continue
+		}
+		children[arc.GetHead()] = append(children[arc.GetHead()], arc.GetModifier())
+		hasHead[arc.GetModifier()] = true
+	}
+	visit = func(node int) {
+		deps := children[node]
+		sort.Ints(deps)
+		i := 0
+		for ; i < len(deps) && deps[i] < node; i++ {
+			visit(deps[i])
+		}
+		order[node] = len(order)
+		for ; i < len(deps); i++ {
+			visit(deps[i])
+		}
+	}
+	roots := make([]int, 0, 1)
+	for _, node := range graph.GetVertices() {
+		if !hasHead[node] {
+			roots = append(roots, node)
+		}
+	}
+	sort.Ints(roots)
+	for _, root := range roots {
+		visit(root)
+	}
+	return order
+}
+
+// complete returns whether all gold dependents of a node are attached
+func (o *ArcSwapOracle) complete(c *SimpleConfiguration, node int) bool {
+	for _, arc := range o.arcSet.Get(&BasicDepArc{node, -1, -1, DepRel("")}) {
+		if len(c.Arcs().Get(arc)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *ArcSwapOracle) Transition(conf Configuration) Transition {
+	c := conf.(*SimpleConfiguration)
+
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	// o(c = (S|wi,wj|B,A)) =
+	// LA-r	if	(wj,r,wi) in Ad and all dependents of wi are in A
+	// RA-r	if	(wi,r,wj) in Ad and all dependents of wj are in A
+	// SW	if	wj precedes wi in the projective order of Gd
+	// SH	otherwise
+	bTop, bExists := c.Queue().Peek()
+	sTop, sExists := c.Stack().Peek()
+	var index int
+	if !bExists {
+		panic(fmt.Sprintf("Got empty configuration %v", c))
+	}
+	if sExists {
+		arcs := o.arcSet.Get(&BasicDepArc{bTop, -1, sTop, DepRel("")})
+		if len(arcs) > 0 && o.complete(c, sTop) {
+			index, _ = o.Transitions.IndexOf("LA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		arcs = o.arcSet.Get(&BasicDepArc{sTop, -1, bTop, DepRel("")})
+		if len(arcs) > 0 && o.complete(c, bTop) {
+			index, _ = o.Transitions.IndexOf("RA-" + string(arcs[0].GetRelation()))
+			return &TypedTransition{TransitionType, index}
+		}
+		sOrder, sOrdered := o.order[sTop]
+		bOrder, bOrdered := o.order[bTop]
+		if sOrdered && bOrdered && sTop < bTop && sOrder > bOrder && !isRootNode(c, sTop) {
+			index, _ = o.Transitions.IndexOf("SW")
+			return &TypedTransition{TransitionType, index}
+		}
+	}
+	index, _ = o.Transitions.IndexOf("SH")
+	return &TypedTransition{TransitionType, index}
+}
+
+func (o *ArcSwapOracle) Name() string {
+	return "Arc Standard + Swap (Nivre 2009)"
+}
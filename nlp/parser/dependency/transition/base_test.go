@@ -7,15 +7,15 @@ import (
 )
 
 var rawTestSent nlp.BasicETaggedSentence = nlp.BasicETaggedSentence{
-	{TaggedToken: nlp.TaggedToken{"Economic", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"news", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"had", "VB"}},
-	{TaggedToken: nlp.TaggedToken{"little", "ADJ"}},
-	{TaggedToken: nlp.TaggedToken{"effect", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"on", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"financial", "NN"}},
-	{TaggedToken: nlp.TaggedToken{"markets", "NN"}},
-	{TaggedToken: nlp.TaggedToken{".", "yyDOT"}}}
+	{TaggedToken: nlp.TaggedToken{Token: "Economic", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "news", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "had", POS: "VB"}},
+	{TaggedToken: nlp.TaggedToken{Token: "little", POS: "ADJ"}},
+	{TaggedToken: nlp.TaggedToken{Token: "effect", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "on", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "financial", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: "markets", POS: "NN"}},
+	{TaggedToken: nlp.TaggedToken{Token: ".", POS: "yyDOT"}}}
 
 var TEST_SENT nlp.TaggedSentence
 
@@ -1,12 +1,13 @@
 package transition
 
 import (
-	. "yap/nlp/types"
 	"testing"
+	"yap/alg"
+	. "yap/nlp/types"
 )
 
 type StackArrayTest struct {
-	stack *StackArray
+	stack *alg.StackArray
 	t     *testing.T
 }
 
@@ -116,7 +117,7 @@ func (s *StackArrayTest) Copy() {
 	s.stack.Push(4)
 	s.stack.Push(3)
 	s.stack.Push(2)
-	newStack := s.stack.Copy().(*StackArray)
+	newStack := s.stack.Copy().(*alg.StackArray)
 	if len(newStack.Array) != len(s.stack.Array) {
 		s.t.Error("Stack copy failed to produce copy of same length")
 	}
@@ -143,7 +144,7 @@ func (test *StackArrayTest) All() {
 
 func TestStackArray(t *testing.T) {
 	const CAPACITY = 5
-	stack := NewStackArray(CAPACITY)
+	stack := alg.NewStackArray(CAPACITY)
 	if cap(stack.Array) != CAPACITY {
 		t.Error("NewStackArray has wrong capacity")
 	}
@@ -24,6 +24,8 @@ type SimpleConfiguration struct {
 	lastOpAssignment uint16
 	// Pointers                                           int
 	EWord, EPOS, EWPOS, EMHost, EMSuffix, ERel, ETrans *util.EnumSet
+	// word clusters of the cluster attributes, optional
+	Clusters *nlp.WordClusters
 	// test zpar parity
 	NumHeadStack  int
 	TerminalQueue int
@@ -155,6 +157,7 @@ func (c *SimpleConfiguration) CopyTo(target Configuration) {
 	newConf.InternalPrevious = c
 
 	newConf.EWord, newConf.EPOS, newConf.EWPOS, newConf.ERel, newConf.ETrans, newConf.EMHost, newConf.EMSuffix = c.EWord, c.EPOS, c.EWPOS, c.ERel, c.ETrans, c.EMHost, c.EMSuffix
+	newConf.Clusters = c.Clusters
 }
 
 func (c *SimpleConfiguration) AddArc(arc *BasicDepArc) {
@@ -1,10 +1,11 @@
 package transition
 
 import (
+	"fmt"
 	. "yap/alg"
 	// "log"
-	// nlp "yap/nlp/types"
-	// "yap/util"
+	nlp "yap/nlp/types"
+	"yap/util"
 	// "math"
 	// "regexp"
 	// "sort"
@@ -86,6 +87,62 @@ func (c *SimpleConfiguration) Address(location []byte, sourceOffset int) (int, b
 	return 0, false, false
 }
 
+// ValidateAddress accepts a stack (S) or queue (N) element of a single digit
+// offset, optionally followed by its leftmost (l, l2), rightmost (r, r2)
+// modifiers, head (h, h2) or children (Ci)
+func (c *SimpleConfiguration) ValidateAddress(address []byte) error {
+	if len(address) < 2 || address[0] != 'S' && address[0] != 'N' {
+		return fmt.Errorf("Address %s is not of the stack (S) or queue (N)", address)
+	}
+	if address[1] < '0' || address[1] > '9' {
+		return fmt.Errorf("Address %s does not have a single digit offset", address)
+	}
+	switch string(address[2:]) {
+	case "", "l", "l2", "r", "r2", "h", "h2", "Ci":
+		return nil
+	}
+	return fmt.Errorf("Address %s has an unknown location %s", address, address[2:])
+}
+
+// attributeProbe is a configuration in which every attribute answered by
+// Attribute has a value for node 1: it has a head, left and right modifiers
+// and word clusters, and is at the stack top with node 3 at the queue
+func attributeProbe() *SimpleConfiguration {
+	const word = "probe"
+	c := &SimpleConfiguration{
+		InternalStack: NewStackArray(4),
+		InternalQueue: NewQueueSlice(4),
+		InternalArcs:  NewArcSetSimple(4),
+		ERel:          util.NewEnumSet(1),
+		Clusters:      nlp.NewWordClusters(),
+	}
+	c.ERel.Add(nlp.DepRel(word))
+	c.Clusters.AddBrown(word, "01")
+	c.Clusters.Embedding[word] = 0
+	for i := 0; i < 4; i++ {
+		c.Nodes = append(c.Nodes, NewArcCachedDepNode(&TaggedDepNode{Id: i, RawToken: word}))
+	}
+	for _, arc := range [][2]int{{3, 1}, {1, 0}, {1, 2}} {
+		c.AddArc(&BasicDepArc{Head: arc[0], Modifier: arc[1], RawRelation: nlp.DepRel(word)})
+	}
+	c.Stack().Push(1)
+	c.Queue().Enqueue(3)
+	return c
+}
+
+// ValidateAttribute accepts the attributes answered by Attribute, and the
+// character attributes registered when the templates were parsed (they are
+// answered for words long enough)
+func (c *SimpleConfiguration) ValidateAttribute(source byte, attribute []byte) error {
+	if _, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+		return nil
+	}
+	if _, exists, _ := attributeProbe().Attribute(source, 1, attribute, nil); !exists {
+		return fmt.Errorf("Unknown attribute %s of %c", attribute, source)
+	}
+	return nil
+}
+
 func (c *SimpleConfiguration) GenerateAddresses(nodeID int, location []byte) (nodeIDs []int) {
 	if nodeID < 0 || nodeID >= len(c.Nodes) {
 		return
@@ -119,6 +176,10 @@ func (c *SimpleConfiguration) Attribute(source byte, nodeID int, attribute []byt
 		return 0, false, false
 	}
 	exists = true
+	if affix, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+		att, exists = affix.Attribute(c.GetRawNode(nodeID).RawToken)
+		return
+	}
 	switch attribute[0] {
 	case 'o':
 		att = c.NumHeadStack
@@ -154,6 +215,8 @@ func (c *SimpleConfiguration) Attribute(source byte, nodeID int, attribute []byt
 			att = rightMods
 		case 'f':
 			att = leftMods + rightMods
+		default:
+			return 0, false, false
 		}
 		return
 	case 's':
@@ -168,6 +231,8 @@ func (c *SimpleConfiguration) Attribute(source byte, nodeID int, attribute []byt
 			att = rightLabelSet
 		case 'f':
 			att = allLabels
+		default:
+			return 0, false, false
 		}
 		return
 	case 'f':
@@ -184,6 +249,9 @@ func (c *SimpleConfiguration) Attribute(source byte, nodeID int, attribute []byt
 		node := c.GetRawNode(nodeID)
 		att = node.MSuffix
 		return
+	case 'c', 'e':
+		att, exists = c.Clusters.Attribute(c.GetRawNode(nodeID).RawToken, attribute)
+		return
 	}
 	return 0, false, false
 }
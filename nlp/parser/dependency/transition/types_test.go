@@ -7,7 +7,7 @@ import (
 )
 
 func TestTaggedDepNode(t *testing.T) {
-	node := &TaggedDepNode{0, 0, 0, 0, "token", "tag"}
+	node := &TaggedDepNode{Id: 0, Token: 0, POS: 0, TokenPOS: 0, RawToken: "token", RawPOS: "tag"}
 	if node.ID() != 0 {
 		t.Error("Got wrong ID")
 	}
@@ -24,7 +24,7 @@ func TestTaggedDepNode(t *testing.T) {
 	if !node.Equal(other) {
 		t.Error("Failed equality on equal pointers")
 	}
-	other = &TaggedDepNode{0, 0, 1, 1, "token", "tag2"}
+	other = &TaggedDepNode{Id: 0, Token: 0, POS: 1, TokenPOS: 1, RawToken: "token", RawPOS: "tag2"}
 	if node.Equal(other) {
 		t.Error("Returned equal on non-equal nodes")
 	}
@@ -83,9 +83,9 @@ func TestBasicDepGraph(t *testing.T) {
 		t.Error("Got non-nil edge/vertex/arc/node for empty graph")
 	}
 	g = &BasicDepGraph{
-		[]nlp.DepNode{&TaggedDepNode{0, 0, 0, 0, "v1", "tag1"},
-			&TaggedDepNode{1, 0, 1, 1, "v1", "tag2"}},
-		[]*BasicDepArc{&BasicDepArc{0, 1, 1, "a"}}}
+		[]nlp.DepNode{&TaggedDepNode{Id: 0, Token: 0, POS: 0, TokenPOS: 0, RawToken: "v1", RawPOS: "tag1"},
+			&TaggedDepNode{Id: 1, Token: 0, POS: 1, TokenPOS: 1, RawToken: "v1", RawPOS: "tag2"}},
+		[]*BasicDepArc{&BasicDepArc{Head: 0, Relation: 1, Modifier: 1, RawRelation: "a"}}}
 	if g.NumberOfNodes() != 2 || g.NumberOfVertices() != 2 {
 		t.Error("Got wrong number of nodes/vertices")
 	}
@@ -110,17 +110,25 @@ func TestBasicDepGraph(t *testing.T) {
 	if g.GetNode(1) != g.Nodes[1] {
 		t.Error("Got wrong node")
 	}
-	if g.GetArc(0) != g.Arcs[0] {
-		t.Error("Got wrong arc")
-	}
 	if g.GetEdge(0) != g.Arcs[0] {
 		t.Error("Got wrong edge")
 	}
 	if g.GetDirectedEdge(0) != g.Arcs[0] {
 		t.Error("Got wrong directed edge")
 	}
-	if g.GetLabeledArc(0) != g.Arcs[0] {
-		t.Error("Got wrong labeled arc")
+	// arcs are looked up by their modifier, and the only arc modifies node 1
+	for name, lookup := range map[string]func(){
+		"arc":         func() { g.GetArc(0) },
+		"labeled arc": func() { g.GetLabeledArc(0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s 0 to panic for an arc of modifier 1", name)
+				}
+			}()
+			lookup()
+		}()
 	}
 	if len(g.StringEdges()) == 0 {
 		t.Error("Got empty StringEdges()")
@@ -1,9 +1,9 @@
 package disambig
 
 import (
-	// "yap/alg/graph"
 	. "yap/alg"
 	"yap/alg/featurevector"
+	"yap/alg/graph"
 	. "yap/alg/transition"
 	nlp "yap/nlp/types"
 	"yap/util"
@@ -28,6 +28,9 @@ type MDConfig struct {
 	Mappings     nlp.Mappings
 	Morphemes    nlp.Morphemes
 	Lemmas       []int
+	// LemmaSpellouts holds the indices of the current lattice's spellouts
+	// ambiguous by lemma only (word-based disambiguation)
+	LemmaSpellouts []int
 
 	CurrentLatNode int
 
@@ -35,6 +38,8 @@ type MDConfig struct {
 	Last             Transition
 	ETokens          *util.EnumSet
 	Log              bool
+	// word clusters of the cluster attributes, optional
+	Clusters *nlp.WordClusters
 
 	POP         Transition
 	Transitions *util.EnumSet
@@ -96,6 +101,7 @@ func (c *MDConfig) CopyTo(target Configuration) {
 		panic("Can't copy into non *MDConfig")
 	}
 	newConf.ETokens = c.ETokens
+	newConf.Clusters = c.Clusters
 	newConf.Mappings = make([]*nlp.Mapping, len(c.Mappings), util.Max(cap(c.Mappings), len(c.Lattices)))
 	copy(newConf.Mappings, c.Mappings)
 
@@ -122,6 +128,10 @@ func (c *MDConfig) CopyTo(target Configuration) {
 		newConf.Lemmas = make([]int, len(c.Lemmas))
 		copy(newConf.Lemmas, c.Lemmas)
 	}
+	if len(c.LemmaSpellouts) > 0 {
+		newConf.LemmaSpellouts = make([]int, len(c.LemmaSpellouts))
+		copy(newConf.LemmaSpellouts, c.LemmaSpellouts)
+	}
 	// lattices slice is read only, no need for copy
 	newConf.Lattices = c.Lattices
 	newConf.InternalPrevious = c
@@ -158,7 +168,7 @@ func (c *MDConfig) GetLastTransition() Transition {
 }
 
 func (c *MDConfig) State() byte {
-	if c.Lemmas != nil && len(c.Lemmas) > 0 {
+	if (c.Lemmas != nil && len(c.Lemmas) > 0) || len(c.LemmaSpellouts) > 0 {
 		// needs lemmatization
 		return 'L'
 	}
@@ -191,7 +201,15 @@ func (c *MDConfig) String() string {
 		transStr = "POP"
 	}
 	lemmaStr := ""
-	if c.State() == 'L' {
+	if c.State() == 'L' && len(c.LemmaSpellouts) > 0 {
+		currentLat, _ := c.LatticeQueue.Peek()
+		spellouts := c.Lattices[currentLat].Spellouts
+		lemmas := make([]string, len(c.LemmaSpellouts))
+		for i, idx := range c.LemmaSpellouts {
+			lemmas[i] = SpelloutLemma(spellouts[idx])
+		}
+		lemmaStr = fmt.Sprintf("%v;%s", spellouts[c.LemmaSpellouts[0]].AsString(), strings.Join(lemmas, ","))
+	} else if c.State() == 'L' {
 		currentLat, _ := c.LatticeQueue.Peek()
 		latticeMorphemes := c.Lattices[currentLat].Morphemes
 		lemmas := make([]string, len(c.Lemmas))
@@ -302,27 +320,111 @@ func (c *MDConfig) Clear() {
 
 func (c *MDConfig) AddSpellout(spellout string, paramFunc nlp.MDParam) bool {
 	// log.Println("\tAdding spellout")
-	if curLatticeId, exists := c.LatticeQueue.Pop(); exists {
-		curLattice := c.Lattices[curLatticeId]
-		if UsePOP && POP_ONLY_VAR_LEN {
-			poppedLat := c.Lattices[curLatticeId]
-			// only need to pop variable length
-			if !poppedLat.IsVarLen() {
-				c.Pop()
-			}
+	curLatticeId, exists := c.LatticeQueue.Peek()
+	if !exists {
+		panic("No lattices left in queue")
+	}
+	curLattice := c.Lattices[curLatticeId]
+	// log.Println("\tAt Lattice", curLattice.Token)
+	var (
+		matches []int
+		lemmas  = make(map[string]bool)
+	)
+	for i, s := range curLattice.Spellouts {
+		if nlp.ProjectSpellout(s, paramFunc) == spellout {
+			matches = append(matches, i)
+			lemmas[SpelloutLemma(s)] = true
+		}
+	}
+	if len(matches) == 0 {
+		return false
+	}
+	if LEMMAS && len(lemmas) > 1 {
+		c.LemmaSpellouts = matches
+		return true
+	}
+	c.addSpelloutMapping(curLattice.Spellouts[matches[0]])
+	return true
+}
+
+// ChooseSpelloutLemma completes a word-based disambiguation step left
+// ambiguous by AddSpellout, choosing the spellout with the given lemma
+func (c *MDConfig) ChooseSpelloutLemma(lemma string) {
+	currentLat, exists := c.LatticeQueue.Peek()
+	if !exists {
+		panic("Can't choose lemma if no lattices are in the queue")
+	}
+	if len(c.LemmaSpellouts) == 0 {
+		panic("Can't disambiguate lemmas if no ambiguous spellouts exist")
+	}
+	spellouts := c.Lattices[currentLat].Spellouts
+	lemmas := make([]string, len(c.LemmaSpellouts))
+	for i, idx := range c.LemmaSpellouts {
+		if SpelloutLemma(spellouts[idx]) == lemma {
+			c.LemmaSpellouts = nil
+			c.addSpelloutMapping(spellouts[idx])
+			return
+		}
+		lemmas[i] = SpelloutLemma(spellouts[idx])
+	}
+	panic(fmt.Sprintf("Lemma not found in ambiguous spellouts: (%v, %v)", lemma, strings.Join(lemmas, "|")))
+}
+
+func (c *MDConfig) addSpelloutMapping(s nlp.Spellout) {
+	curLatticeId, _ := c.LatticeQueue.Pop()
+	curLattice := c.Lattices[curLatticeId]
+	if UsePOP && POP_ONLY_VAR_LEN {
+		// only need to pop variable length
+		if !curLattice.IsVarLen() {
+			c.Pop()
 		}
-		// log.Println("\tAt Lattice", curLattice.Token)
-		for _, s := range curLattice.Spellouts {
-			if nlp.ProjectSpellout(s, paramFunc) == spellout {
-				c.CurrentLatNode = curLattice.Top()
-				c.Mappings = append(c.Mappings, &nlp.Mapping{Token: curLattice.Token, Spellout: s})
-				// log.Println("\tPost mappings:", c.Mappings)
-				return true
+	}
+	c.CurrentLatNode = curLattice.Top()
+	c.Mappings = append(c.Mappings, &nlp.Mapping{Token: curLattice.Token, Spellout: s})
+	c.Morphemes = append(c.Morphemes, s...)
+	// log.Println("\tPost mappings:", c.Mappings)
+}
+
+// ReplaceMorphemes replaces disambiguated morphemes by the given ones in
+// new mappings, the replaced morphemes and mappings may be shared with the
+// lattices and other configurations and are left as is
+func (c *MDConfig) ReplaceMorphemes(replaced map[*nlp.EMorpheme]*nlp.EMorpheme) {
+	if len(replaced) == 0 {
+		return
+	}
+	mappings := make(nlp.Mappings, len(c.Mappings))
+	for i, mapping := range c.Mappings {
+		mappings[i] = mapping
+		var spellout nlp.Spellout
+		for j, m := range mapping.Spellout {
+			if morph, exists := replaced[m]; exists {
+				if spellout == nil {
+					spellout = make(nlp.Spellout, len(mapping.Spellout))
+					copy(spellout, mapping.Spellout)
+				}
+				spellout[j] = morph
 			}
 		}
-		return false
+		if spellout != nil {
+			mappings[i] = &nlp.Mapping{Token: mapping.Token, Spellout: spellout}
+		}
+	}
+	c.Mappings = mappings
+	// the disambiguated morphemes (dependency nodes of joint parsing) are
+	// the same morphemes as the mappings'
+	morphs := make(nlp.Morphemes, len(c.Morphemes))
+	for i, m := range c.Morphemes {
+		if morph, exists := replaced[m]; exists {
+			m = morph
+		}
+		morphs[i] = m
 	}
-	panic("No lattices left in queue")
+	c.Morphemes = morphs
+}
+
+// SpelloutLemma is the lemma projection of a spellout
+func SpelloutLemma(s nlp.Spellout) string {
+	return nlp.ProjectSpellout(s, nlp.Lemma)
 }
 
 func (c *MDConfig) AddLemmaAmbiguity(morphIDs []int) {
@@ -457,12 +559,17 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 	defer func() {
 		if r := recover(); r != nil {
 			fmt.Println("Recovered in MDConfig::Attribute", r)
+			exists = false
 		}
 	}()
 	exists = true
 	switch source {
 	case 'M':
 		morpheme := c.Morphemes[nodeID]
+		if affix, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+			att, exists = affix.Attribute(morpheme.Form)
+			return
+		}
 		switch attribute[0] {
 		case 'm':
 			if len(attribute) > 1 && attribute[1] == 'p' {
@@ -482,6 +589,9 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 		case 'f':
 			att = morpheme.EFeatures
 			return
+		case 'c', 'e':
+			att, exists = c.Clusters.Attribute(morpheme.Form, attribute)
+			return
 		case 't':
 			lat := c.Lattices[morpheme.TokenID]
 			// tokId, _ := c.ETokens.Add(lat.Token)
@@ -524,6 +634,10 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 		}
 		lat := c.Lattices[nodeID]
 		// log.Println("At lattice", lat)
+		if affix, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+			att, exists = affix.Attribute(string(lat.Token))
+			return
+		}
 		switch attribute[0] {
 		case 'c':
 			if lat.Top() == c.CurrentLatNode {
@@ -531,7 +645,9 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 				return
 			}
 			if len(attribute) < 2 {
-				panic("(c)urrent morphemes attribute needs a sub-attribute")
+				// (c)urrent morphemes attribute needs a sub-attribute
+				exists = false
+				return
 			}
 			curTransMap := make(map[int]bool, len(transitions))
 			for _, val := range transitions {
@@ -624,7 +740,9 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 					case "fpg":
 						feature = [3]string{curEdge.FeatureStr, curEdge.CPOS, util.Signature(curEdge.Form)}
 					default:
-						panic("Don't know what this feature is")
+						// not a current morphemes attribute
+						exists = false
+						return
 					}
 					transition, _ = c.Transitions.Add(c.ParamFunc(curEdge))
 					if _, tExists := curTransMap[transition]; tExists {
@@ -699,6 +817,73 @@ func (c *MDConfig) Attribute(source byte, nodeID int, attribute []byte, transiti
 	return
 }
 
+// ValidateAddress accepts a morpheme (M) or lattice (L) element of a single
+// digit offset; lattices may also be previous ones (e.g. L-1) or generate
+// all lattices (L0Ci)
+func (c *MDConfig) ValidateAddress(address []byte) error {
+	if len(address) < 2 || address[0] != 'M' && address[0] != 'L' {
+		return fmt.Errorf("Address %s is not of the morphemes (M) or lattices (L)", address)
+	}
+	location := address[1:]
+	if address[0] == 'L' && location[0] == '-' {
+		location = location[1:]
+	}
+	if len(location) == 0 || location[0] < '0' || location[0] > '9' {
+		return fmt.Errorf("Address %s does not have a single digit offset", address)
+	}
+	switch string(location[1:]) {
+	case "":
+		return nil
+	case "Ci":
+		if address[0] == 'L' && address[1] != '-' {
+			return nil
+		}
+	}
+	return fmt.Errorf("Address %s has an unknown location %s", address, location[1:])
+}
+
+// attributeProbe is a configuration in which every attribute answered by
+// Attribute has a value for morpheme and lattice 0: the lattice is of a
+// single morpheme with features and word clusters, and is being disambiguated
+func attributeProbe() *MDConfig {
+	const word = "probe"
+	morpheme := &nlp.EMorpheme{Morpheme: nlp.Morpheme{
+		BasicDirectedEdge: graph.BasicDirectedEdge{0, 0, 1},
+		Form:              word,
+		Lemma:             word,
+		CPOS:              word,
+		POS:               word,
+		Features:          map[string]string{word: word},
+		FeatureStr:        word + "=" + word,
+	}}
+	lat := nlp.Lattice{Token: nlp.Token(word), Morphemes: nlp.Morphemes{morpheme}, BottomId: 0, TopId: 1}
+	lat.GenNexts(false)
+	c := &MDConfig{
+		Lattices:    nlp.LatticeSentence{lat},
+		Mappings:    nlp.Mappings{{Token: nlp.Token(word), Spellout: nlp.Spellout{morpheme}}},
+		Morphemes:   nlp.Morphemes{morpheme},
+		Clusters:    nlp.NewWordClusters(),
+		Transitions: util.NewEnumSet(1),
+		ParamFunc:   nlp.Funcs_Main_POS_Both_Prop,
+	}
+	c.Clusters.AddBrown(word, "01")
+	c.Clusters.Embedding[word] = 0
+	return c
+}
+
+// ValidateAttribute accepts the attributes answered by Attribute, and the
+// character attributes registered when the templates were parsed (they are
+// answered for words long enough)
+func (c *MDConfig) ValidateAttribute(source byte, attribute []byte) error {
+	if _, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+		return nil
+	}
+	if _, exists, _ := attributeProbe().Attribute(source, 0, attribute, nil); !exists {
+		return fmt.Errorf("Unknown attribute %s of %c", attribute, source)
+	}
+	return nil
+}
+
 func (c *MDConfig) GenerateAddresses(nodeID int, location []byte) (nodeIDs []int) {
 	return util.RangeInt(len(c.Lattices))
 }
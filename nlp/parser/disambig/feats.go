@@ -0,0 +1,574 @@
+package disambig
+
+import (
+	. "yap/alg/transition"
+	nlp "yap/nlp/types"
+	"yap/util"
+
+	"fmt"
+	"log"
+	"strings"
+)
+
+// TaggedFeatures are the morphological features predicted by the feature
+// tagger, other features of a morpheme are left as is
+var TaggedFeatures = []string{"gen", "num", "per"}
+
+// ProjectFeats returns the tagged features of a feature string
+// (e.g. gen=M|num=S|per=3|tense=PAST -> gen=M|num=S|per=3)
+func ProjectFeats(featureStr string) string {
+	if len(featureStr) == 0 || featureStr == "_" {
+		return ""
+	}
+	var result []string
+	for _, pair := range strings.Split(featureStr, "|") {
+		if isTaggedFeature(strings.SplitN(pair, "=", 2)[0]) {
+			result = append(result, pair)
+		}
+	}
+	return strings.Join(result, "|")
+}
+
+func isTaggedFeature(name string) bool {
+	for _, feature := range TaggedFeatures {
+		if name == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// featValue returns the value(s) of a feature in a feature string
+func featValue(featureStr, name string) string {
+	var values []string
+	for _, pair := range strings.Split(featureStr, "|") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && kv[0] == name {
+			values = append(values, kv[1])
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func featsSuffix(form string) string {
+	runes := []rune(form)
+	if len(runes) > 2 {
+		runes = runes[len(runes)-2:]
+	}
+	return string(runes)
+}
+
+// FeatsBundles are the (projected) feature bundles of a sentence's
+// morphemes, the gold of the feature tagger
+type FeatsBundles []string
+
+func (b FeatsBundles) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(FeatsBundles)
+	if !ok || len(other) != len(b) {
+		return false
+	}
+	for i, bundle := range b {
+		if other[i] != bundle {
+			return false
+		}
+	}
+	return true
+}
+
+// FeatsSentence is the input of the feature tagger, the disambiguated
+// morphemes of a sentence
+type FeatsSentence struct {
+	Morphemes nlp.Morphemes
+	Tokens    []string
+	// Choices holds the bundles each morpheme may be assigned, a nil entry
+	// allows any bundle seen with the morpheme's POS
+	Choices [][]string
+
+	alternatives []nlp.Morphemes
+}
+
+func (s *FeatsSentence) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(*FeatsSentence)
+	if !ok || len(other.Morphemes) != len(s.Morphemes) {
+		return false
+	}
+	for i, m := range s.Morphemes {
+		if other.Morphemes[i] != m {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *FeatsSentence) add(m *nlp.EMorpheme, token string) {
+	s.Morphemes = append(s.Morphemes, m)
+	s.Tokens = append(s.Tokens, token)
+}
+
+// NewFeatsTrainingSentence returns the tagger input and gold bundles of a
+// disambiguated (gold) lattice sentence
+func NewFeatsTrainingSentence(sent nlp.LatticeSentence) (*FeatsSentence, FeatsBundles) {
+	s := &FeatsSentence{}
+	var gold FeatsBundles
+	for _, lat := range sent {
+		if len(lat.Spellouts) == 0 {
+			continue
+		}
+		for _, m := range lat.Spellouts[0] {
+			s.add(m, string(lat.Token))
+			gold = append(gold, ProjectFeats(m.FeatureStr))
+		}
+	}
+	return s, gold
+}
+
+// NewFeatsSentence returns the tagger input of a disambiguated configuration.
+// A morpheme is underspecified if its lattice has other analyses differing
+// only by the tagged features (e.g. the guessed bundles of OOV tokens), it
+// may then be assigned any of them. A morpheme without tagged features of a
+// POS seen with several bundles (e.g. NNPs stripped of features) may be
+// assigned any of those bundles. Other morphemes keep their features
+func NewFeatsSentence(c *MDConfig, bundles map[string][]string) *FeatsSentence {
+	s := &FeatsSentence{}
+	for i, mapping := range c.Mappings {
+		if i >= len(c.Lattices) {
+			break
+		}
+		lat := c.Lattices[i]
+		for _, m := range mapping.Spellout {
+			s.add(m, string(mapping.Token))
+			own := ProjectFeats(m.FeatureStr)
+			choices := []string{own}
+			var alternatives nlp.Morphemes
+			for _, other := range lat.Morphemes {
+				if other == m || other.From() != m.From() || other.To() != m.To() ||
+					other.Form != m.Form || other.CPOS != m.CPOS {
+					continue
+				}
+				projected := ProjectFeats(other.FeatureStr)
+				if !containsString(choices, projected) {
+					choices = append(choices, projected)
+					alternatives = append(alternatives, other)
+				}
+			}
+			if len(alternatives) == 0 && len(own) == 0 && len(bundles[m.CPOS]) > 1 {
+				choices = nil
+			}
+			s.Choices = append(s.Choices, choices)
+			s.alternatives = append(s.alternatives, alternatives)
+		}
+	}
+	return s
+}
+
+// Underspecified is true if any of the morphemes may be assigned more than
+// one bundle
+func (s *FeatsSentence) Underspecified() bool {
+	for _, choices := range s.Choices {
+		if choices == nil || len(choices) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFeats sets the features of the underspecified morphemes to the tagged
+// bundles. A morpheme with a lattice alternative of the tagged bundle takes
+// its features, otherwise the tagged features are added to its own.
+// Morphemes are shared with the lattices, so the features are set on copies
+// which replace them in the sentence and are returned by the morpheme they
+// replace (see MDConfig.ReplaceMorphemes)
+func (s *FeatsSentence) SetFeats(feats []string, eMorphProp *util.EnumSet) map[*nlp.EMorpheme]*nlp.EMorpheme {
+	tagged := make(map[*nlp.EMorpheme]*nlp.EMorpheme)
+	for i, orig := range s.Morphemes {
+		if i >= len(feats) || i >= len(s.Choices) {
+			break
+		}
+		if (s.Choices[i] != nil && len(s.Choices[i]) < 2) || feats[i] == ProjectFeats(orig.FeatureStr) {
+			continue
+		}
+		m := orig.Copy()
+		featureStr, found := feats[i], false
+		for _, alt := range s.alternatives[i] {
+			if ProjectFeats(alt.FeatureStr) == feats[i] {
+				featureStr, found = alt.FeatureStr, true
+				break
+			}
+		}
+		if !found {
+			// keep the untagged features
+			var pairs []string
+			if len(feats[i]) > 0 {
+				pairs = append(pairs, feats[i])
+			}
+			for _, pair := range strings.Split(m.FeatureStr, "|") {
+				if len(pair) > 0 && pair != "_" && !isTaggedFeature(strings.SplitN(pair, "=", 2)[0]) {
+					pairs = append(pairs, pair)
+				}
+			}
+			featureStr = strings.Join(pairs, "|")
+		}
+		m.FeatureStr = featureStr
+		m.Features = make(map[string]string)
+		for _, pair := range strings.Split(featureStr, "|") {
+			if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+				if value, exists := m.Features[kv[0]]; exists {
+					m.Features[kv[0]] = value + "," + kv[1]
+				} else {
+					m.Features[kv[0]] = kv[1]
+				}
+			}
+		}
+		if eMorphProp != nil {
+			m.EFeatures, _ = eMorphProp.Add(featureStr)
+		}
+		s.Morphemes[i] = m
+		tagged[orig] = m
+	}
+	return tagged
+}
+
+// FeatsConfig is a configuration of the feature tagger, which assigns a
+// bundle to each morpheme from left to right
+type FeatsConfig struct {
+	Sentence *FeatsSentence
+	Feats    []string
+
+	InternalPrevious Configuration
+	Last             Transition
+	Transitions      *util.EnumSet
+}
+
+var _ Configuration = &FeatsConfig{}
+
+func (c *FeatsConfig) Init(abstractSentence interface{}) {
+	c.Sentence = abstractSentence.(*FeatsSentence)
+	c.Feats = make([]string, 0, len(c.Sentence.Morphemes))
+	c.Last = ConstTransition(0)
+}
+
+func (c *FeatsConfig) Terminal() bool {
+	return len(c.Feats) >= len(c.Sentence.Morphemes)
+}
+
+func (c *FeatsConfig) Copy() Configuration {
+	newConf := new(FeatsConfig)
+	c.CopyTo(newConf)
+	return newConf
+}
+
+func (c *FeatsConfig) CopyTo(target Configuration) {
+	newConf, ok := target.(*FeatsConfig)
+	if !ok {
+		panic("Can't copy into non *FeatsConfig")
+	}
+	newConf.Sentence = c.Sentence
+	newConf.Feats = make([]string, len(c.Feats), cap(c.Feats))
+	copy(newConf.Feats, c.Feats)
+	newConf.InternalPrevious = c
+	newConf.Transitions = c.Transitions
+}
+
+func (c *FeatsConfig) GetSequence() ConfigurationSequence {
+	retval := make(ConfigurationSequence, 0, len(c.Feats)+1)
+	currentConf := c
+	for {
+		retval = append(retval, currentConf)
+		if currentConf.InternalPrevious == nil {
+			break
+		} else {
+			currentConf = currentConf.InternalPrevious.(*FeatsConfig)
+		}
+	}
+	return retval
+}
+
+func (c *FeatsConfig) SetLastTransition(t Transition) {
+	c.Last = t
+}
+
+func (c *FeatsConfig) GetLastTransition() Transition {
+	return c.Last
+}
+
+func (c *FeatsConfig) State() byte {
+	return 'F'
+}
+
+func (c *FeatsConfig) String() string {
+	if c.Sentence == nil {
+		return "FeatsConfig (empty)"
+	}
+	var next string
+	if !c.Terminal() {
+		next = c.Sentence.Morphemes[len(c.Feats)].Form
+	}
+	return fmt.Sprintf("%v\t%s", c.Feats, next)
+}
+
+func (c *FeatsConfig) Equal(otherEq util.Equaler) bool {
+	other, ok := otherEq.(*FeatsConfig)
+	if !ok || other == nil || c == nil {
+		return false
+	}
+	if !other.Last.Equal(c.Last) {
+		return false
+	}
+	if c.InternalPrevious == nil && other.InternalPrevious == nil {
+		return true
+	}
+	if c.InternalPrevious != nil && other.InternalPrevious != nil {
+		return c.InternalPrevious.Equal(other.InternalPrevious)
+	}
+	return false
+}
+
+func (c *FeatsConfig) Previous() Configuration {
+	return c.InternalPrevious
+}
+
+func (c *FeatsConfig) SetPrevious(prev Configuration) {
+	c.InternalPrevious = prev
+}
+
+func (c *FeatsConfig) Clear() {
+	c.InternalPrevious = nil
+}
+
+func (c *FeatsConfig) Len() int {
+	if c == nil {
+		return 0
+	}
+	if c.Previous() != nil {
+		return 1 + c.Previous().Len()
+	}
+	return 1
+}
+
+// Address sources are N (morphemes to tag, N0 is the next) and M (tagged
+// morphemes, M0 is the last)
+func (c *FeatsConfig) Address(location []byte, offset int) (int, bool, bool) {
+	var atAddress int
+	switch location[0] {
+	case 'N':
+		atAddress = len(c.Feats) + offset
+		if atAddress < 0 || atAddress >= len(c.Sentence.Morphemes) {
+			return 0, false, false
+		}
+	case 'M':
+		atAddress = len(c.Feats) - 1 - offset
+		if atAddress < 0 {
+			return 0, false, false
+		}
+	default:
+		return 0, false, false
+	}
+	return atAddress, true, false
+}
+
+// ValidateAddress accepts a morpheme to tag (N) or a tagged morpheme (M) of
+// a single digit offset
+func (c *FeatsConfig) ValidateAddress(address []byte) error {
+	if len(address) < 2 || address[0] != 'N' && address[0] != 'M' {
+		return fmt.Errorf("Address %s is not of the morphemes to tag (N) or tagged morphemes (M)", address)
+	}
+	if len(address) != 2 || address[1] < '0' || address[1] > '9' {
+		return fmt.Errorf("Address %s does not have a single digit offset", address)
+	}
+	return nil
+}
+
+// ValidateAttribute accepts the attributes answered by Attribute for a
+// tagged morpheme, and the character attributes registered when the
+// templates were parsed (they are answered for words long enough)
+func (c *FeatsConfig) ValidateAttribute(source byte, attribute []byte) error {
+	if _, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+		return nil
+	}
+	const word = "probe"
+	probe := &FeatsConfig{
+		Sentence: &FeatsSentence{
+			Morphemes: nlp.Morphemes{&nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: word, CPOS: word, POS: word}}},
+			Tokens:    []string{word},
+		},
+		Feats: []string{word + "=" + word},
+	}
+	if _, exists, _ := probe.Attribute(source, 0, attribute, nil); !exists {
+		return fmt.Errorf("Unknown attribute %s of %c", attribute, source)
+	}
+	return nil
+}
+
+func (c *FeatsConfig) GenerateAddresses(nodeID int, location []byte) []int {
+	return nil
+}
+
+// Attributes are (m)orpheme form, (p)os, (t)oken, si(g)nature, (s)uffix,
+// and of tagged morphemes the assigned (f)eatures or a single feature (e.g.
+// fgen)
+func (c *FeatsConfig) Attribute(source byte, nodeID int, attribute []byte, transitions []int) (att interface{}, exists bool, isGenerator bool) {
+	if nodeID >= len(c.Sentence.Morphemes) {
+		return nil, false, false
+	}
+	morpheme := c.Sentence.Morphemes[nodeID]
+	exists = true
+	if affix, isAffix := nlp.LookupAffixAttribute(attribute); isAffix {
+		att, exists = affix.Attribute(morpheme.Form)
+		return
+	}
+	switch attribute[0] {
+	case 'm':
+		att = morpheme.Form
+	case 'p':
+		att = morpheme.CPOS
+	case 't':
+		att = c.Sentence.Tokens[nodeID]
+	case 'g':
+		att = util.Signature(morpheme.Form)
+	case 's':
+		att = featsSuffix(morpheme.Form)
+	case 'f':
+		if source != 'M' || nodeID >= len(c.Feats) {
+			return nil, false, false
+		}
+		if len(attribute) > 1 {
+			att = featValue(c.Feats[nodeID], string(attribute[1:]))
+		} else {
+			att = c.Feats[nodeID]
+		}
+	default:
+		exists = false
+	}
+	return
+}
+
+func (c *FeatsConfig) Assignment() uint16 {
+	return uint16(len(c.Feats))
+}
+
+// FeatsTrans is the transition system of the feature tagger, each transition
+// assigns a bundle to the next morpheme
+type FeatsTrans struct {
+	Transitions *util.EnumSet
+	// Bundles holds the bundles seen with each POS in training
+	Bundles map[string][]string
+
+	oracle Oracle
+	Log    bool
+}
+
+var _ TransitionSystem = &FeatsTrans{}
+
+// AddBundles adds the bundles of a gold sentence to the POS bundles
+func (t *FeatsTrans) AddBundles(s *FeatsSentence, gold FeatsBundles) {
+	if t.Bundles == nil {
+		t.Bundles = make(map[string][]string)
+	}
+	for i, m := range s.Morphemes {
+		if !containsString(t.Bundles[m.CPOS], gold[i]) {
+			t.Bundles[m.CPOS] = append(t.Bundles[m.CPOS], gold[i])
+		}
+	}
+}
+
+func (t *FeatsTrans) Transition(from Configuration, transition Transition) Configuration {
+	c := from.Copy().(*FeatsConfig)
+	bundle := t.Transitions.ValueOf(transition.Value()).(string)
+	if t.Log {
+		log.Println("Assigning", bundle)
+	}
+	c.Feats = append(c.Feats, bundle)
+	c.SetLastTransition(transition)
+	return c
+}
+
+func (t *FeatsTrans) TransitionTypes() []string {
+	return []string{"Feats:F-*"}
+}
+
+func (t *FeatsTrans) choices(c *FeatsConfig) []string {
+	i := len(c.Feats)
+	if i < len(c.Sentence.Choices) && c.Sentence.Choices[i] != nil {
+		return c.Sentence.Choices[i]
+	}
+	m := c.Sentence.Morphemes[i]
+	if bundles := t.Bundles[m.CPOS]; len(bundles) > 0 {
+		return bundles
+	}
+	return []string{ProjectFeats(m.FeatureStr)}
+}
+
+func (t *FeatsTrans) GetTransitions(from Configuration) (byte, []int) {
+	c := from.(*FeatsConfig)
+	if c.Terminal() {
+		return 'F', nil
+	}
+	choices := t.choices(c)
+	retval := make([]int, len(choices))
+	for i, bundle := range choices {
+		retval[i], _ = t.Transitions.Add(bundle)
+	}
+	return 'F', retval
+}
+
+func (t *FeatsTrans) YieldTransitions(from Configuration) (byte, chan int) {
+	tType, transitions := t.GetTransitions(from)
+	retChan := make(chan int, len(transitions))
+	for _, transition := range transitions {
+		retChan <- transition
+	}
+	close(retChan)
+	return tType, retChan
+}
+
+func (t *FeatsTrans) Oracle() Oracle {
+	return t.oracle
+}
+
+func (t *FeatsTrans) AddDefaultOracle() {
+	t.oracle = &FeatsOracle{Transitions: t.Transitions}
+}
+
+func (t *FeatsTrans) Name() string {
+	return "Morphological Feature Tagger"
+}
+
+type FeatsOracle struct {
+	Transitions *util.EnumSet
+	gold        FeatsBundles
+}
+
+var _ Decision = &FeatsOracle{}
+
+func (o *FeatsOracle) SetGold(g interface{}) {
+	gold, ok := g.(FeatsBundles)
+	if !ok {
+		panic("Gold is not feature bundles")
+	}
+	o.gold = gold
+}
+
+func (o *FeatsOracle) Transition(conf Configuration) Transition {
+	c := conf.(*FeatsConfig)
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
+	if len(c.Feats) >= len(o.gold) {
+		panic("Gold has less bundles than given configuration")
+	}
+	transition, _ := o.Transitions.Add(o.gold[len(c.Feats)])
+	return &TypedTransition{T: 'F', V: transition}
+}
+
+func (o *FeatsOracle) Name() string {
+	return "Morphological Feature Tagger Oracle"
+}
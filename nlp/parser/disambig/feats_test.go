@@ -0,0 +1,103 @@
+package disambig
+
+import (
+	"strings"
+	"testing"
+
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// token 1 is an NNP without features, token 2 an OOV with guessed bundles
+const featsTestLattice = "0	1	דנה	דנה	NNP	NNP	_	1\n" +
+	"1	2	הלכה	הלכה	VB	VB	gen=M|num=S|per=3|tense=PAST	2\n" +
+	"1	2	הלכה	הלכה	VB	VB	gen=F|num=S|per=3|tense=PAST	2\n" +
+	"\n"
+
+func TestFeatsSentenceSetFeats(t *testing.T) {
+	lats, err := lattice.Read(strings.NewReader(featsTestLattice), 0)
+	if err != nil || len(lats) != 1 {
+		t.Fatalf("Failed reading test lattice: %v", err)
+	}
+	eWord, ePOS, eWPOS := util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	eMorphFeat, eMHost, eMSuffix := util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	sent := lattice.Lattice2Sentence(lats[0], eWord, ePOS, eWPOS, eMorphFeat, eMHost, eMSuffix)
+
+	conf := &MDConfig{Lattices: sent}
+	for _, lat := range sent {
+		conf.Mappings = append(conf.Mappings, &nlp.Mapping{Token: lat.Token, Spellout: lat.Spellouts[0]})
+	}
+	bundles := map[string][]string{"NNP": {"", "gen=F|num=S", "gen=M|num=S"}}
+	s := NewFeatsSentence(conf, bundles)
+	if len(s.Morphemes) != 2 || !s.Underspecified() {
+		t.Fatalf("Expected two underspecified morphemes, got %v %v", s.Morphemes, s.Choices)
+	}
+	if s.Choices[0] != nil {
+		t.Errorf("Expected any NNP bundle for an NNP without features, got %v", s.Choices[0])
+	}
+	if len(s.Choices[1]) != 2 {
+		t.Errorf("Expected the two guessed bundles of the OOV, got %v", s.Choices[1])
+	}
+
+	oov := s.Morphemes[1]
+	other := ProjectFeats(oov.FeatureStr)
+	for _, choice := range s.Choices[1] {
+		if choice != ProjectFeats(oov.FeatureStr) {
+			other = choice
+		}
+	}
+	nnp, ambiguous := s.Morphemes[0], oov.FeatureStr
+	tagged := s.SetFeats([]string{"gen=F|num=S", other}, eMorphFeat)
+	if len(tagged) != 2 || tagged[oov] != s.Morphemes[1] || oov.FeatureStr != ambiguous || len(nnp.FeatureStr) > 0 && nnp.FeatureStr != "_" {
+		t.Errorf("Expected the tagged morphemes to be copies, got %v", tagged)
+	}
+	conf.ReplaceMorphemes(tagged)
+	if conf.Mappings[1].Spellout[0] != s.Morphemes[1] || sent[1].Spellouts[0][0] != oov {
+		t.Errorf("Expected the configuration's mappings to have the tagged copies and the lattice the original")
+	}
+	oov = s.Morphemes[1]
+	if s.Morphemes[0].FeatureStr != "gen=F|num=S" || s.Morphemes[0].Features["gen"] != "F" {
+		t.Errorf("Expected NNP features gen=F|num=S, got %v", s.Morphemes[0].FeatureStr)
+	}
+	if ProjectFeats(oov.FeatureStr) != other || !strings.HasSuffix(oov.FeatureStr, "tense=PAST") {
+		t.Errorf("Expected the OOV to take the lattice bundle %v, got %v", other, oov.FeatureStr)
+	}
+}
+
+func TestFeatsOracle(t *testing.T) {
+	s := &FeatsSentence{
+		Morphemes: nlp.Morphemes{
+			{Morpheme: nlp.Morpheme{Form: "דנה", CPOS: "NNP"}},
+			{Morpheme: nlp.Morpheme{Form: "הלכה", CPOS: "VB"}},
+		},
+		Tokens: []string{"דנה", "הלכה"},
+	}
+	gold := FeatsBundles{"gen=F|num=S", "gen=F|num=S|per=3"}
+	trans := &FeatsTrans{Transitions: util.NewEnumSet(10)}
+	trans.AddBundles(s, gold)
+	trans.AddDefaultOracle()
+	trans.Oracle().SetGold(gold)
+
+	conf := &FeatsConfig{Transitions: trans.Transitions}
+	conf.Init(s)
+	c := conf
+	for !c.Terminal() {
+		_, possible := trans.GetTransitions(c)
+		transition := trans.Oracle().Transition(c)
+		var found bool
+		for _, value := range possible {
+			found = found || value == transition.Value()
+		}
+		if !found {
+			t.Fatalf("Oracle transition %v not in possible transitions %v", transition, possible)
+		}
+		c = trans.Transition(c, transition).(*FeatsConfig)
+	}
+	if !FeatsBundles(c.Feats).Equal(gold) {
+		t.Errorf("Expected oracle bundles %v, got %v", gold, c.Feats)
+	}
+	if att, exists, _ := c.Attribute('M', 0, []byte("fgen"), nil); !exists || att != "F" {
+		t.Errorf("Expected tagged gen attribute F, got %v", att)
+	}
+}
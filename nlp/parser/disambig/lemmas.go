@@ -0,0 +1,159 @@
+package disambig
+
+import (
+	nlp "yap/nlp/types"
+
+	"fmt"
+)
+
+// LemmaModel chooses the lemmas of disambiguated morphemes.
+// Lexicons often have several lemmas for the same form, POS and features,
+// which the disambiguator does not tell apart unless lemmas are used
+// (-nolemma=false). The model counts the gold lemmas of a training corpus by
+// form, POS and features, backing off to form and POS and to the form alone
+type LemmaModel struct {
+	FormPOSFeats map[string]map[string]int
+	FormPOS      map[string]map[string]int
+	Form         map[string]map[string]int
+}
+
+func NewLemmaModel() *LemmaModel {
+	return &LemmaModel{
+		FormPOSFeats: make(map[string]map[string]int),
+		FormPOS:      make(map[string]map[string]int),
+		Form:         make(map[string]map[string]int),
+	}
+}
+
+func lemmaKeys(m *nlp.EMorpheme) [3]string {
+	return [3]string{
+		fmt.Sprintf("%s_%s_%s", m.Form, m.CPOS, m.FeatureStr),
+		fmt.Sprintf("%s_%s", m.Form, m.CPOS),
+		m.Form,
+	}
+}
+
+func (l *LemmaModel) levels() [3]map[string]map[string]int {
+	return [3]map[string]map[string]int{l.FormPOSFeats, l.FormPOS, l.Form}
+}
+
+// Add counts the lemma of a gold morpheme, morphemes without a lemma are
+// skipped
+func (l *LemmaModel) Add(m *nlp.EMorpheme) {
+	if !HasLemma(m) {
+		return
+	}
+	keys := lemmaKeys(m)
+	for i, level := range l.levels() {
+		counts, exists := level[keys[i]]
+		if !exists {
+			counts = make(map[string]int, 1)
+			level[keys[i]] = counts
+		}
+		counts[m.Lemma]++
+	}
+}
+
+// AddSentence counts the lemmas of a disambiguated (gold) lattice sentence
+func (l *LemmaModel) AddSentence(sent nlp.LatticeSentence) {
+	for _, lat := range sent {
+		for _, m := range lat.Morphemes {
+			l.Add(m)
+		}
+	}
+}
+
+// Choose returns the most frequent of the candidate lemmas of a morpheme at
+// the most specific level where any of them was seen. With no candidates
+// the most frequent lemma of the morpheme is chosen. If nothing was seen
+// the first candidate is returned, or the form if there are no candidates
+func (l *LemmaModel) Choose(m *nlp.EMorpheme, candidates []string) string {
+	if l != nil {
+		keys := lemmaKeys(m)
+		for i, level := range l.levels() {
+			counts, exists := level[keys[i]]
+			if !exists {
+				continue
+			}
+			var (
+				best      string
+				bestCount int
+			)
+			if len(candidates) > 0 {
+				for _, lemma := range candidates {
+					if counts[lemma] > bestCount {
+						best, bestCount = lemma, counts[lemma]
+					}
+				}
+			} else {
+				for lemma, count := range counts {
+					// break ties by lemma for a deterministic choice
+					if count > bestCount || (count == bestCount && lemma < best) {
+						best, bestCount = lemma, count
+					}
+				}
+			}
+			if bestCount > 0 {
+				return best
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return m.Form
+}
+
+// Lemmatize sets the lemmas of the morphemes of a disambiguated
+// configuration. Candidates are the lemmas of the lattice morphemes
+// differing from the chosen one only by lemma. If keepLemmas is set (lemmas
+// were disambiguated by lemma transitions) only missing lemmas are filled.
+// The morphemes are shared with the lattices and other configurations, so
+// lemmas are set on copies
+func (l *LemmaModel) Lemmatize(c *MDConfig, keepLemmas bool) {
+	lemmatized := make(map[*nlp.EMorpheme]*nlp.EMorpheme)
+	for i, mapping := range c.Mappings {
+		if i >= len(c.Lattices) {
+			break
+		}
+		lat := c.Lattices[i]
+		for _, m := range mapping.Spellout {
+			if keepLemmas && HasLemma(m) {
+				continue
+			}
+			morph := m.Copy()
+			morph.Lemma = l.Choose(m, lemmaCandidates(lat, m))
+			lemmatized[m] = morph
+		}
+	}
+	c.ReplaceMorphemes(lemmatized)
+}
+
+func lemmaCandidates(lat nlp.Lattice, m *nlp.EMorpheme) []string {
+	var candidates []string
+	if HasLemma(m) {
+		candidates = append(candidates, m.Lemma)
+	}
+	for _, other := range lat.Morphemes {
+		if other == m || !HasLemma(other) || other.From() != m.From() || other.To() != m.To() ||
+			other.Form != m.Form || other.CPOS != m.CPOS || other.FeatureStr != m.FeatureStr {
+			continue
+		}
+		var seen bool
+		for _, lemma := range candidates {
+			if lemma == other.Lemma {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			candidates = append(candidates, other.Lemma)
+		}
+	}
+	return candidates
+}
+
+// HasLemma is true if the morpheme's lemma is set
+func HasLemma(m *nlp.EMorpheme) bool {
+	return len(m.Lemma) > 0 && m.Lemma != "_"
+}
@@ -0,0 +1,69 @@
+package disambig
+
+import (
+	"testing"
+
+	nlp "yap/nlp/types"
+)
+
+func TestLemmaModelChoose(t *testing.T) {
+	model := NewLemmaModel()
+	gold := []*nlp.EMorpheme{
+		{Morpheme: nlp.Morpheme{Form: "ספר", Lemma: "סיפר", CPOS: "NN", FeatureStr: "gen=M|num=S"}},
+		{Morpheme: nlp.Morpheme{Form: "ספר", Lemma: "ספר", CPOS: "NN", FeatureStr: "gen=M|num=P"}},
+		{Morpheme: nlp.Morpheme{Form: "ספר", Lemma: "ספר", CPOS: "NN", FeatureStr: "gen=M|num=P"}},
+		{Morpheme: nlp.Morpheme{Form: "ספר", CPOS: "NN", FeatureStr: "gen=M|num=S"}},
+	}
+	for _, m := range gold {
+		model.Add(m)
+	}
+	morph := &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: "ספר", CPOS: "NN", FeatureStr: "gen=M|num=S"}}
+	if lemma := model.Choose(morph, []string{"ספר", "סיפר"}); lemma != "סיפר" {
+		t.Errorf("Expected the lemma of the same form, POS and features, got %v", lemma)
+	}
+	if lemma := model.Choose(morph, nil); lemma != "סיפר" {
+		t.Errorf("Expected the most frequent lemma without candidates, got %v", lemma)
+	}
+	// backs off to form and POS
+	morph.FeatureStr = "gen=F|num=S"
+	if lemma := model.Choose(morph, []string{"סיפר", "ספר"}); lemma != "ספר" {
+		t.Errorf("Expected the most frequent lemma of the form and POS, got %v", lemma)
+	}
+	unseen := &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: "גדול", CPOS: "JJ"}}
+	if lemma := model.Choose(unseen, []string{"גדל", "גדול"}); lemma != "גדל" {
+		t.Errorf("Expected the first candidate of an unseen morpheme, got %v", lemma)
+	}
+	if lemma := model.Choose(unseen, nil); lemma != "גדול" {
+		t.Errorf("Expected the form of an unseen morpheme without candidates, got %v", lemma)
+	}
+	var empty *LemmaModel
+	if lemma := empty.Choose(unseen, nil); lemma != "גדול" {
+		t.Errorf("Expected the form without a model, got %v", lemma)
+	}
+}
+
+func TestLemmatizeCopies(t *testing.T) {
+	var (
+		morph = &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: "ספר", CPOS: "NN", FeatureStr: "gen=M|num=S"}}
+		other = &nlp.EMorpheme{Morpheme: nlp.Morpheme{Form: "ספר", Lemma: "סיפר", CPOS: "NN", FeatureStr: "gen=M|num=S"}}
+		lat   = nlp.Lattice{Token: "ספר", Morphemes: nlp.Morphemes{morph, other}}
+	)
+	other.BasicDirectedEdge[0] = 1
+	mapping := &nlp.Mapping{Token: "ספר", Spellout: nlp.Spellout{morph}}
+	conf := &MDConfig{
+		Lattices:  nlp.LatticeSentence{lat},
+		Mappings:  nlp.Mappings{mapping},
+		Morphemes: nlp.Morphemes{morph},
+	}
+	NewLemmaModel().Lemmatize(conf, false)
+	lemmatized := conf.Mappings[0].Spellout[0]
+	if lemmatized.Lemma != "סיפר" {
+		t.Errorf("Expected the lemma of the lattice candidate, got %v", lemmatized.Lemma)
+	}
+	if conf.Morphemes[0] != lemmatized {
+		t.Errorf("Expected the configuration's morphemes to have the lemmatized morpheme")
+	}
+	if len(morph.Lemma) > 0 || lat.Morphemes[0] != morph || mapping.Spellout[0] != morph {
+		t.Errorf("Expected the input lattice and mapping to be unchanged, got lemma %v", morph.Lemma)
+	}
+}
@@ -0,0 +1,108 @@
+package disambig
+
+import (
+	nlp "yap/nlp/types"
+
+	"math"
+)
+
+// NBest holds the K best distinct disambiguation paths of a sentence, best
+// first, with their model scores
+type NBest struct {
+	Paths  []*MDConfig
+	Scores []float64
+
+	// Confidence holds, for each mapping of the best path, the marginal
+	// probability of its spellout over the K paths. Path probabilities are
+	// a softmax of the path scores
+	Confidence []float64
+}
+
+// NewNBest keeps the first K paths with distinct Mappings, paths are
+// expected to be sorted by descending score
+func NewNBest(paths []*MDConfig, scores []float64, K int) *NBest {
+	nbest := &NBest{
+		Paths:  make([]*MDConfig, 0, K),
+		Scores: make([]float64, 0, K),
+	}
+	for i, path := range paths {
+		if len(nbest.Paths) >= K {
+			break
+		}
+		var duplicate bool
+		for _, kept := range nbest.Paths {
+			if kept.Mappings.Equal(path.Mappings) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			nbest.Paths = append(nbest.Paths, path)
+			nbest.Scores = append(nbest.Scores, scores[i])
+		}
+	}
+	nbest.computeConfidence()
+	return nbest
+}
+
+func (n *NBest) Best() *MDConfig {
+	if len(n.Paths) == 0 {
+		return nil
+	}
+	return n.Paths[0]
+}
+
+func (n *NBest) Probabilities() []float64 {
+	probs := make([]float64, len(n.Scores))
+	if len(n.Scores) == 0 {
+		return probs
+	}
+	max := n.Scores[0]
+	for _, score := range n.Scores[1:] {
+		if score > max {
+			max = score
+		}
+	}
+	var total float64
+	for i, score := range n.Scores {
+		probs[i] = math.Exp(score - max)
+		total += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= total
+	}
+	return probs
+}
+
+func (n *NBest) computeConfidence() {
+	best := n.Best()
+	if best == nil {
+		return
+	}
+	probs := n.Probabilities()
+	n.Confidence = make([]float64, len(best.Mappings))
+	for i, mapping := range best.Mappings {
+		for j, path := range n.Paths {
+			if i < len(path.Mappings) && mapping.Equal(path.Mappings[i]) {
+				n.Confidence[i] += probs[j]
+			}
+		}
+	}
+}
+
+// TokenConfidence returns the confidence of the best path's mappings,
+// skipping the root token
+func (n *NBest) TokenConfidence() []float64 {
+	best := n.Best()
+	if best == nil {
+		return nil
+	}
+	confidence := make([]float64, 0, len(n.Confidence))
+	for i, mapping := range best.Mappings {
+		if mapping.Token == nlp.ROOT_TOKEN {
+			continue
+		}
+		confidence = append(confidence, n.Confidence[i])
+	}
+	return confidence
+}
@@ -0,0 +1,55 @@
+package disambig
+
+import (
+	"math"
+	"testing"
+
+	nlp "yap/nlp/types"
+)
+
+func nbestTestPath(analyses ...string) *MDConfig {
+	conf := &MDConfig{Mappings: nlp.Mappings{{Token: nlp.ROOT_TOKEN}}}
+	for i, pos := range analyses {
+		token := nlp.Token([]string{"דנה", "הלכה"}[i])
+		conf.Mappings = append(conf.Mappings, &nlp.Mapping{
+			Token:    token,
+			Spellout: nlp.Spellout{{Form: string(token), CPOS: pos, POS: pos}},
+		})
+	}
+	return conf
+}
+
+func TestNewNBest(t *testing.T) {
+	paths := []*MDConfig{
+		nbestTestPath("NNP", "VB"),
+		nbestTestPath("NNP", "VB"),
+		nbestTestPath("NNP", "NN"),
+		nbestTestPath("NN", "VB"),
+	}
+	scores := []float64{3, 3, 3 - math.Log(3), 1}
+	nbest := NewNBest(paths, scores, 2)
+	if len(nbest.Paths) != 2 || nbest.Paths[0] != paths[0] || nbest.Paths[1] != paths[2] {
+		t.Fatalf("Expected the first and third paths, got %v", nbest.Paths)
+	}
+	if nbest.Scores[0] != 3 || nbest.Scores[1] != scores[2] {
+		t.Errorf("Expected the scores of the kept paths, got %v", nbest.Scores)
+	}
+	probs := nbest.Probabilities()
+	if math.Abs(probs[0]-0.75) > 1e-9 || math.Abs(probs[1]-0.25) > 1e-9 {
+		t.Errorf("Expected probabilities 0.75 and 0.25, got %v", probs)
+	}
+	// both paths agree on the first token, only the best has VB
+	confidence := nbest.TokenConfidence()
+	if len(confidence) != 2 || math.Abs(confidence[0]-1) > 1e-9 || math.Abs(confidence[1]-0.75) > 1e-9 {
+		t.Errorf("Expected token confidence 1 and 0.75, got %v", confidence)
+	}
+
+	all := NewNBest(paths, scores, 10)
+	if len(all.Paths) != 3 {
+		t.Errorf("Expected 3 distinct paths, got %d", len(all.Paths))
+	}
+	empty := NewNBest(nil, nil, 2)
+	if empty.Best() != nil || empty.TokenConfidence() != nil {
+		t.Errorf("Expected no best path and confidence without paths")
+	}
+}
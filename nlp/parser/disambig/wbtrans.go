@@ -24,7 +24,19 @@ var _ TransitionSystem = &MDWBTrans{}
 func (t *MDWBTrans) Transition(from Configuration, transition Transition) Configuration {
 	c := from.Copy().(*MDConfig)
 
-	if transition.Equal(t.POP) && t.UsePOP {
+	if transition.Type() == 'L' {
+		lemma := t.Transitions.ValueOf(transition.Value()).(string)
+		if TSAllOut || t.Log {
+			log.Println("Choosing lemma", lemma, "of", c.LemmaSpellouts)
+		}
+		c.ChooseSpelloutLemma(lemma)
+		c.SetLastTransition(transition)
+		if !t.UsePOP {
+			c.Pop()
+		}
+		return c
+	}
+	if t.UsePOP && (transition.Type() == 'P' || transition == t.POP) {
 		c.Pop()
 		c.SetLastTransition(transition)
 		if TSAllOut || t.Log {
@@ -61,7 +73,7 @@ func (t *MDWBTrans) Transition(from Configuration, transition Transition) Config
 			log.Println("Adding spellout", paramStr)
 		}
 		c.SetLastTransition(transition)
-		if !t.UsePOP {
+		if !t.UsePOP && c.State() != 'L' {
 			c.Pop()
 		}
 		return c
@@ -73,7 +85,7 @@ func (t *MDWBTrans) Transition(from Configuration, transition Transition) Config
 }
 
 func (t *MDWBTrans) TransitionTypes() []string {
-	return []string{"MD-*"}
+	return []string{"MD:M-*", "MD:L-*", "MD:P-*"}
 }
 
 func (t *MDWBTrans) possibleTransitions(from Configuration, transitions chan int) {
@@ -84,13 +96,26 @@ func (t *MDWBTrans) possibleTransitions(from Configuration, transitions chan int
 		panic("Got wrong configuration type")
 	}
 	qTop, qExists := conf.LatticeQueue.Peek()
-	if t.UsePOP && conf.State() == 'P' {
+	if conf.State() == 'L' {
+		spellouts := conf.Lattices[qTop].Spellouts
+		for _, idx := range conf.LemmaSpellouts {
+			transition, _ = t.Transitions.Add(SpelloutLemma(spellouts[idx]))
+			transitions <- transition
+		}
+	} else if t.UsePOP && conf.State() == 'P' {
 		transitions <- t.POP.Value()
 	} else {
 		if qExists {
 			lat := conf.Lattices[qTop]
+			// spellouts differing only outside the param func (e.g. by
+			// lemma) project to the same transition
+			seen := make(map[int]bool, len(lat.Spellouts))
 			for _, s := range lat.Spellouts {
 				transition, _ = t.Transitions.Add(ProjectSpellout(s, t.ParamFunc))
+				if seen[transition] {
+					continue
+				}
+				seen[transition] = true
 				transitions <- transition
 			}
 		} else {
@@ -163,8 +188,7 @@ func (o *MDWBOracle) Transition(conf Configuration) Transition {
 	}
 
 	qTop, qExists := c.LatticeQueue.Peek()
-	if o.UsePOP && ((!qExists && len(c.Mappings) != c.popped) ||
-		(qExists && qTop != c.popped)) {
+	if o.UsePOP && c.State() == 'P' {
 		return c.POP
 	}
 	if !qExists {
@@ -178,6 +202,10 @@ func (o *MDWBOracle) Transition(conf Configuration) Transition {
 		panic("Gold has less mappings than given configuration")
 	}
 	goldSpellout := o.gold[qTop].Spellout
+	if c.State() == 'L' {
+		transition, _ := o.Transitions.Add(SpelloutLemma(goldSpellout))
+		return &TypedTransition{T: 'L', V: transition}
+	}
 
 	// log.Println("Confspellout")
 	// log.Println(confSpellout)
@@ -189,7 +217,7 @@ func (o *MDWBOracle) Transition(conf Configuration) Transition {
 	paramVal := ProjectSpellout(goldSpellout, o.ParamFunc)
 	// log.Println("Gold transition", paramVal)
 	transition, _ := o.Transitions.Add(paramVal)
-	return &TypedTransition{conf.State(), transition}
+	return &TypedTransition{T: conf.State(), V: transition}
 }
 
 func (o *MDWBOracle) Name() string {
@@ -0,0 +1,156 @@
+package disambig
+
+import (
+	"strings"
+	"testing"
+
+	. "yap/alg/transition"
+	"yap/nlp/format/lattice"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// token 1 is ambiguous by segmentation, token 2 by lemma only
+const oracleTestLattice = "0	1	ה	ה	DEF	DEF	_	1\n" +
+	"1	2	בית	בית	NN	NN	gen=M|num=S	1\n" +
+	"0	2	הבית	הבית	NNP	NNP	_	1\n" +
+	"2	3	ספר	ספר	NN	NN	gen=M|num=S	2\n" +
+	"2	3	ספר	סיפר	NN	NN	gen=M|num=S	2\n" +
+	"\n"
+
+func oracleTestSentence(t *testing.T) nlp.LatticeSentence {
+	lats, err := lattice.Read(strings.NewReader(oracleTestLattice), 0)
+	if err != nil || len(lats) != 1 {
+		t.Fatalf("Failed reading test lattice: %v", err)
+	}
+	eWord, ePOS, eWPOS := util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	eMorphFeat, eMHost, eMSuffix := util.NewEnumSet(10), util.NewEnumSet(10), util.NewEnumSet(10)
+	return lattice.Lattice2Sentence(lats[0], eWord, ePOS, eWPOS, eMorphFeat, eMHost, eMSuffix)
+}
+
+// oracleTestGold selects for each token the spellout with the given
+// form/POS/lemma projection
+func oracleTestGold(t *testing.T, sent nlp.LatticeSentence, gold []string) nlp.Mappings {
+	mappings := make(nlp.Mappings, len(sent))
+	for i, lat := range sent {
+		for _, s := range lat.Spellouts {
+			if nlp.ProjectSpellout(s, nlp.Form_Lemma_POS_Prop) == gold[i] {
+				mappings[i] = &nlp.Mapping{Token: lat.Token, Spellout: s}
+			}
+		}
+		if mappings[i] == nil {
+			t.Fatalf("Gold spellout %v not found in lattice %v", gold[i], lat.Spellouts)
+		}
+	}
+	return mappings
+}
+
+func oracleParse(t *testing.T, ts TransitionSystem, sent nlp.LatticeSentence, gold nlp.Mappings, transitions *util.EnumSet, pop Transition) *MDConfig {
+	ts.AddDefaultOracle()
+	oracle := ts.Oracle()
+	oracle.SetGold(gold)
+	conf := &MDConfig{
+		POP:         pop,
+		Transitions: transitions,
+		ParamFunc:   nlp.Form_POS_Prop,
+	}
+	conf.Init(sent)
+	var c Configuration = conf
+	for i := 0; !c.Terminal(); i++ {
+		if i > 20 {
+			t.Fatalf("Oracle did not reach a terminal configuration: %v", c)
+		}
+		c = ts.Transition(c, oracle.Transition(c))
+	}
+	return c.(*MDConfig)
+}
+
+func checkOracleMappings(t *testing.T, name string, c *MDConfig, gold nlp.Mappings) {
+	var mappings nlp.Mappings
+	for _, mapping := range c.Mappings {
+		if len(mapping.Spellout) > 0 {
+			mappings = append(mappings, mapping)
+		}
+	}
+	if len(mappings) != len(gold) {
+		t.Fatalf("%s: expected %d mappings, got %d: %v", name, len(gold), len(mappings), mappings)
+	}
+	for i, mapping := range mappings {
+		expected := nlp.ProjectSpellout(gold[i].Spellout, nlp.Form_Lemma_POS_Prop)
+		if got := nlp.ProjectSpellout(mapping.Spellout, nlp.Form_Lemma_POS_Prop); got != expected {
+			t.Errorf("%s: token %d expected %v, got %v", name, i, expected, got)
+		}
+	}
+	if len(c.Morphemes) != len(gold[0].Spellout)+len(gold[1].Spellout) {
+		t.Errorf("%s: expected disambiguated morphemes of all mappings, got %v", name, c.Morphemes)
+	}
+}
+
+func TestMDWBOracleMatchesMDOracle(t *testing.T) {
+	defer func(usePOP, lemmas bool) {
+		UsePOP, LEMMAS = usePOP, lemmas
+	}(UsePOP, LEMMAS)
+	LEMMAS = true
+	golds := [][]string{
+		{"ה_ה_DEF_;בית_בית_NN_gen=M|num=S", "ספר_סיפר_NN_gen=M|num=S"},
+		{"הבית_הבית_NNP_", "ספר_ספר_NN_gen=M|num=S"},
+	}
+	for _, usePOP := range []bool{true, false} {
+		UsePOP = usePOP
+		for _, goldStrs := range golds {
+			sent := oracleTestSentence(t)
+			gold := oracleTestGold(t, sent, goldStrs)
+
+			transitions := util.NewEnumSet(10)
+			_, _ = transitions.Add("IDLE")
+			iPOP, _ := transitions.Add("POP")
+			pop := &TypedTransition{T: 'P', V: iPOP}
+
+			mdTrans := &MDTrans{ParamFunc: nlp.Form_POS_Prop, UsePOP: usePOP, POP: pop, Transitions: transitions}
+			checkOracleMappings(t, "MDTrans", oracleParse(t, mdTrans, sent, gold, transitions, pop), gold)
+
+			wbTrans := &MDWBTrans{ParamFunc: nlp.Form_POS_Prop, UsePOP: usePOP, POP: pop, Transitions: transitions}
+			checkOracleMappings(t, "MDWBTrans", oracleParse(t, wbTrans, sent, gold, transitions, pop), gold)
+		}
+	}
+}
+
+func TestMDWBTransLemmaAmbiguity(t *testing.T) {
+	defer func(usePOP, lemmas bool) {
+		UsePOP, LEMMAS = usePOP, lemmas
+	}(UsePOP, LEMMAS)
+	UsePOP, LEMMAS = true, true
+
+	sent := oracleTestSentence(t)
+	transitions := util.NewEnumSet(10)
+	_, _ = transitions.Add("IDLE")
+	iPOP, _ := transitions.Add("POP")
+	pop := &TypedTransition{T: 'P', V: iPOP}
+	wbTrans := &MDWBTrans{ParamFunc: nlp.Form_POS_Prop, UsePOP: true, POP: pop, Transitions: transitions}
+
+	conf := &MDConfig{POP: pop, Transitions: transitions, ParamFunc: nlp.Form_POS_Prop}
+	conf.Init(sent)
+	// skip the first token
+	nnp, _ := transitions.Add(nlp.ProjectSpellout(sent[0].Spellouts[0], nlp.Form_POS_Prop))
+	c := wbTrans.Transition(conf, &TypedTransition{T: 'M', V: nnp}).(*MDConfig)
+	if c.State() == 'P' {
+		c = wbTrans.Transition(c, pop).(*MDConfig)
+	}
+	tType, possible := wbTrans.GetTransitions(c)
+	if tType != 'M' || len(possible) != 1 {
+		t.Fatalf("Expected a single MD transition for spellouts differing by lemma, got %c %v", tType, possible)
+	}
+	c = wbTrans.Transition(c, &TypedTransition{T: 'M', V: possible[0]}).(*MDConfig)
+	tType, possible = wbTrans.GetTransitions(c)
+	if tType != 'L' || len(possible) != 2 {
+		t.Fatalf("Expected two lemma transitions, got %c %v", tType, possible)
+	}
+	if len(c.Mappings) != 1 {
+		t.Errorf("Expected no mapping before choosing a lemma, got %v", c.Mappings)
+	}
+	lemma, _ := transitions.Add("סיפר")
+	c = wbTrans.Transition(c, &TypedTransition{T: 'L', V: lemma}).(*MDConfig)
+	if c.State() == 'L' || len(c.Mappings) != 2 || SpelloutLemma(c.Mappings[1].Spellout) != "סיפר" {
+		t.Errorf("Expected lemma סיפר to be chosen, got %v", c.Mappings)
+	}
+}
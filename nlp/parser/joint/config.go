@@ -156,6 +156,22 @@ func (c *JointConfig) Address(location []byte, offset int) (nodeID int, exists b
 	}
 }
 
+func (c *JointConfig) ValidateAddress(address []byte) error {
+	if len(address) > 0 && (address[0] == 'M' || address[0] == 'L') {
+		return c.MDConfig.ValidateAddress(address)
+	} else {
+		return c.SimpleConfiguration.ValidateAddress(address)
+	}
+}
+
+func (c *JointConfig) ValidateAttribute(source byte, attribute []byte) error {
+	if source == 'M' || source == 'L' {
+		return c.MDConfig.ValidateAttribute(source, attribute)
+	} else {
+		return c.SimpleConfiguration.ValidateAttribute(source, attribute)
+	}
+}
+
 func (c *JointConfig) GenerateAddresses(nodeID int, location []byte) (nodeIDs []int) {
 	return c.SimpleConfiguration.GenerateAddresses(nodeID, location)
 }
@@ -0,0 +1,96 @@
+package joint
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JointStrategy interleaves morphological disambiguation and arc
+// transitions: for a configuration it decides whether the next transition
+// is a disambiguation transition, an arc transition or (for parsing only)
+// either
+type JointStrategy interface {
+	// Next returns which underlying systems may transition from c
+	Next(c *JointConfig) (shouldMD bool, shouldDep bool)
+}
+
+// MDFirst disambiguates the whole sentence before any arc transition
+type MDFirst struct{}
+
+func (s *MDFirst) Next(c *JointConfig) (shouldMD bool, shouldDep bool) {
+	if !c.MDConfig.Terminal() {
+		return true, false
+	}
+	return false, true
+}
+
+// All allows both disambiguation and arc transitions at every step
+type All struct{}
+
+func (s *All) Next(c *JointConfig) (shouldMD bool, shouldDep bool) {
+	return true, true
+}
+
+// ArcGreedy disambiguates only while fewer than QueueSize morphemes are
+// waiting in the arc system's queue
+type ArcGreedy struct {
+	QueueSize int
+}
+
+func (s *ArcGreedy) Next(c *JointConfig) (shouldMD bool, shouldDep bool) {
+	if c.SimpleConfiguration.Queue().Size() < s.QueueSize && !c.MDConfig.Terminal() {
+		return true, false
+	}
+	return false, true
+}
+
+type registeredStrategy struct {
+	strategy JointStrategy
+	oracle   bool
+}
+
+var (
+	strategies     = make(map[string]registeredStrategy)
+	strategyNames  []string
+	oracleStrategy []string
+)
+
+// RegisterJointStrategy adds a strategy by name. Oracle strategies choose
+// a single underlying system per configuration, so they can also guide the
+// oracle
+func RegisterJointStrategy(name string, strategy JointStrategy, oracle bool) {
+	if _, exists := strategies[name]; exists {
+		panic("Joint strategy already registered: " + name)
+	}
+	strategies[name] = registeredStrategy{strategy, oracle}
+	strategyNames = append(strategyNames, name)
+	JointStrategies = strings.Join(strategyNames, ", ")
+	if oracle {
+		oracleStrategy = append(oracleStrategy, name)
+		OracleStrategies = strings.Join(oracleStrategy, ", ")
+	}
+}
+
+// GetJointStrategy returns a registered strategy by name
+func GetJointStrategy(name string) (JointStrategy, error) {
+	registered, exists := strategies[name]
+	if !exists {
+		return nil, fmt.Errorf("Unknown joint strategy %s, expected one of: %s", name, JointStrategies)
+	}
+	return registered.strategy, nil
+}
+
+// GetOracleStrategy returns a registered oracle strategy by name
+func GetOracleStrategy(name string) (JointStrategy, error) {
+	registered, exists := strategies[name]
+	if !exists || !registered.oracle {
+		return nil, fmt.Errorf("Unknown oracle strategy %s, expected one of: %s", name, OracleStrategies)
+	}
+	return registered.strategy, nil
+}
+
+func init() {
+	RegisterJointStrategy("MDFirst", &MDFirst{}, true)
+	RegisterJointStrategy("All", &All{}, false)
+	RegisterJointStrategy("ArcGreedy", &ArcGreedy{QueueSize: 3}, true)
+}
@@ -0,0 +1,323 @@
+package joint
+
+import (
+	"strings"
+	"testing"
+
+	"yap/alg"
+	. "yap/alg/transition"
+	"yap/nlp/format/conll"
+	"yap/nlp/format/lattice"
+	dep "yap/nlp/parser/dependency/transition"
+	"yap/nlp/parser/dependency/transition/morph"
+	"yap/nlp/parser/disambig"
+	nlp "yap/nlp/types"
+	"yap/util"
+)
+
+// "the big house fell": הבית (ה+בית) הגדול (ה+גדול) נפל
+const strategyTestGoldLattice = "0	1	ה	ה	DEF	DEF	_	1\n" +
+	"1	2	בית	בית	NN	NN	gen=M|num=S	1\n" +
+	"2	3	ה	ה	DEF	DEF	_	2\n" +
+	"3	4	גדול	גדול	JJ	JJ	gen=M|num=S	2\n" +
+	"4	5	נפל	נפל	VB	VB	gen=M|num=S|per=3|tense=PAST	3\n" +
+	"\n"
+
+const strategyTestAmbLattice = "0	1	ה	ה	DEF	DEF	_	1\n" +
+	"1	2	בית	בית	NN	NN	gen=M|num=S	1\n" +
+	"0	2	הבית	הבית	NNP	NNP	_	1\n" +
+	"2	3	ה	ה	DEF	DEF	_	2\n" +
+	"3	4	גדול	גדול	JJ	JJ	gen=M|num=S	2\n" +
+	"2	4	הגדול	הגדול	NNP	NNP	_	2\n" +
+	"4	5	נפל	נפל	VB	VB	gen=M|num=S|per=3|tense=PAST	3\n" +
+	"4	5	נפל	נפל	NN	NN	gen=M|num=S	3\n" +
+	"\n"
+
+const strategyTestConll = "1	ה	ה	DEF	DEF	_	2	def	_	_\n" +
+	"2	בית	בית	NN	NN	gen=M|num=S	5	subj	_	_\n" +
+	"3	ה	ה	DEF	DEF	_	4	def	_	_\n" +
+	"4	גדול	גדול	JJ	JJ	gen=M|num=S	2	amod	_	_\n" +
+	"5	נפל	נפל	VB	VB	gen=M|num=S|per=3|tense=PAST	0	ROOT	_	_\n" +
+	"\n"
+
+// the first definite article attached to the adjective instead of the noun
+// makes the tree non-projective
+const strategyTestNonProjConll = "1	ה	ה	DEF	DEF	_	4	def	_	_\n" +
+	"2	בית	בית	NN	NN	gen=M|num=S	5	subj	_	_\n" +
+	"3	ה	ה	DEF	DEF	_	2	def	_	_\n" +
+	"4	גדול	גדול	JJ	JJ	gen=M|num=S	2	amod	_	_\n" +
+	"5	נפל	נפל	VB	VB	gen=M|num=S|per=3|tense=PAST	0	ROOT	_	_\n" +
+	"\n"
+
+var strategyTestRelations = []string{"def", "subj", "amod"}
+
+type strategyTestEnv struct {
+	eWord, ePOS, eWPOS, eMHost, eMSuffix, eMorphProp *util.EnumSet
+	eRel, eTrans                                     *util.EnumSet
+	sh, la, ra, re, pr, sw                           int
+	pop                                              Transition
+}
+
+func newStrategyTestEnv() *strategyTestEnv {
+	env := &strategyTestEnv{
+		eWord: util.NewEnumSet(10), ePOS: util.NewEnumSet(10), eWPOS: util.NewEnumSet(10),
+		eMHost: util.NewEnumSet(10), eMSuffix: util.NewEnumSet(10), eMorphProp: util.NewEnumSet(10),
+		eRel: util.NewEnumSet(10), eTrans: util.NewEnumSet(30),
+	}
+	env.eMHost.Add("")
+	env.eMSuffix.Add("")
+	env.eRel.Add(nlp.DepRel(nlp.ROOT_LABEL))
+	for _, rel := range strategyTestRelations {
+		env.eRel.Add(nlp.DepRel(rel))
+	}
+	// the joint transition enumeration, as set up by the joint command
+	env.eTrans.Add("NO")
+	env.sh, _ = env.eTrans.Add("SH")
+	env.re, _ = env.eTrans.Add("RE")
+	env.eTrans.Add("AL")
+	env.eTrans.Add("AR")
+	env.pr, _ = env.eTrans.Add("PR")
+	env.la = env.pr + 1
+	env.eTrans.Add("LA-" + nlp.ROOT_LABEL)
+	for _, rel := range strategyTestRelations {
+		env.eTrans.Add("LA-" + rel)
+	}
+	env.ra = env.eTrans.Len()
+	env.eTrans.Add("RA-" + nlp.ROOT_LABEL)
+	for _, rel := range strategyTestRelations {
+		env.eTrans.Add("RA-" + rel)
+	}
+	iPOP, _ := env.eTrans.Add("POP")
+	env.pop = &TypedTransition{T: 'P', V: iPOP}
+	// only used by arc swap, the position doesn't matter to the oracles
+	env.sw, _ = env.eTrans.Add("SW")
+	return env
+}
+
+func (env *strategyTestEnv) readLattice(t *testing.T, data string) nlp.LatticeSentence {
+	lats, err := lattice.Read(strings.NewReader(data), 0)
+	if err != nil || len(lats) != 1 {
+		t.Fatalf("Failed reading test lattice: %v", err)
+	}
+	return lattice.Lattice2Sentence(lats[0], env.eWord, env.ePOS, env.eWPOS, env.eMorphProp, env.eMHost, env.eMSuffix)
+}
+
+func (env *strategyTestEnv) gold(t *testing.T, conllData string) *morph.BasicMorphGraph {
+	sents, err := conll.Read(strings.NewReader(conllData), 0)
+	if err != nil || len(sents) != 1 {
+		t.Fatalf("Failed reading test conll: %v", err)
+	}
+	graph := conll.Conll2Graph(sents[0], env.eWord, env.ePOS, env.eWPOS, env.eRel, env.eMHost, env.eMSuffix)
+	goldLat := env.readLattice(t, strategyTestGoldLattice)
+	ambLat := env.readLattice(t, strategyTestAmbLattice)
+	gold, _ := morph.CombineToGoldMorph(graph, goldLat, ambLat)
+	return gold
+}
+
+func (env *strategyTestEnv) transitionSystem(arcSystemName string, strategy string) (*JointTrans, int) {
+	standard := dep.ArcStandard{
+		SHIFT:       env.sh,
+		LEFT:        env.la,
+		RIGHT:       env.ra,
+		Relations:   env.eRel,
+		Transitions: env.eTrans,
+	}
+	var (
+		arcSystem     TransitionSystem = &standard
+		terminalStack                  = 1
+	)
+	switch arcSystemName {
+	case "eager":
+		arcSystem = &dep.ArcEager{ArcStandard: standard, REDUCE: env.re, POPROOT: env.pr}
+		terminalStack = 0
+	case "swap":
+		arcSystem = &dep.ArcSwap{ArcStandard: standard, SWAP: env.sw}
+	}
+	arcSystem.AddDefaultOracle()
+	mdTrans := &disambig.MDTrans{
+		ParamFunc:   nlp.Form_POS_Prop,
+		Transitions: env.eTrans,
+		POP:         env.pop,
+	}
+	mdTrans.AddDefaultOracle()
+	jointTrans := &JointTrans{
+		MDTrans:       mdTrans,
+		ArcSys:        arcSystem,
+		Transitions:   env.eTrans,
+		JointStrategy: strategy,
+	}
+	jointTrans.AddDefaultOracle()
+	jointTrans.Oracle().(*JointOracle).OracleStrategy = strategy
+	return jointTrans, terminalStack
+}
+
+// runOracle applies the oracle transitions from the initial configuration
+// of the gold lattice until the configuration is terminal
+func (env *strategyTestEnv) runOracle(t *testing.T, name string, jointTrans *JointTrans, terminalStack int, gold *morph.BasicMorphGraph) *JointConfig {
+	conf := &JointConfig{
+		SimpleConfiguration: dep.SimpleConfiguration{
+			EWord:         env.eWord,
+			EPOS:          env.ePOS,
+			EWPOS:         env.eWPOS,
+			EMHost:        env.eMHost,
+			EMSuffix:      env.eMSuffix,
+			ERel:          env.eRel,
+			ETrans:        env.eTrans,
+			TerminalStack: terminalStack,
+		},
+		MDConfig: disambig.MDConfig{
+			POP:         env.pop,
+			Transitions: env.eTrans,
+			ParamFunc:   nlp.Form_POS_Prop,
+		},
+	}
+	conf.Init(gold.Lattice)
+	oracle := jointTrans.Oracle()
+	oracle.SetGold(gold)
+	var c Configuration = conf
+	for i := 0; !c.Terminal(); i++ {
+		if i > 40 {
+			t.Fatalf("%s: oracle did not reach a terminal configuration: %v", name, c)
+		}
+		transition := oracle.Transition(c)
+		tType, possible := jointTrans.GetTransitions(c)
+		if tType != transition.Type() && !(tType == 'M' && transition.Type() == 'P') {
+			t.Fatalf("%s: oracle transition type %c, strategy allows %c", name, transition.Type(), tType)
+		}
+		if len(possible) == 0 {
+			t.Fatalf("%s: no possible transitions at %v", name, c)
+		}
+		c = jointTrans.Transition(c, transition)
+	}
+	return c.(*JointConfig)
+}
+
+func TestRegisterJointStrategy(t *testing.T) {
+	if _, err := GetJointStrategy("All"); err != nil {
+		t.Errorf("Expected All to be a joint strategy: %v", err)
+	}
+	if _, err := GetOracleStrategy("All"); err == nil {
+		t.Error("Expected All not to be an oracle strategy")
+	}
+	// registered oracle strategies are also tested by the oracle test
+	RegisterJointStrategy("ArcGreedy1", &ArcGreedy{QueueSize: 1}, true)
+	if !strings.HasSuffix(OracleStrategies, ", ArcGreedy1") {
+		t.Errorf("Expected ArcGreedy1 in oracle strategies, got %v", OracleStrategies)
+	}
+}
+
+func TestJointStrategyOracles(t *testing.T) {
+	for _, eager := range []bool{false, true} {
+		for _, strategy := range strings.Split(OracleStrategies, ", ") {
+			env := newStrategyTestEnv()
+			gold := env.gold(t, strategyTestConll)
+			arcSystem := "standard"
+			if eager {
+				arcSystem = "eager"
+			}
+			jointTrans, terminalStack := env.transitionSystem(arcSystem, strategy)
+			name := strategy + " " + jointTrans.ArcSys.Name()
+
+			result := env.runOracle(t, name, jointTrans, terminalStack, gold)
+
+			var mappings nlp.Mappings
+			for _, mapping := range result.Mappings {
+				if len(mapping.Spellout) > 0 {
+					mappings = append(mappings, mapping)
+				}
+			}
+			if len(mappings) != len(gold.Mappings) {
+				t.Fatalf("%s: expected %d mappings, got %v", name, len(gold.Mappings), mappings)
+			}
+			for i, mapping := range mappings {
+				if !mapping.Spellout.Equal(gold.Mappings[i].Spellout) {
+					t.Errorf("%s: expected spellout %v, got %v", name, gold.Mappings[i].Spellout.AsString(), mapping.Spellout.AsString())
+				}
+			}
+
+			// arc standard leaves the root on the stack, arc eager attaches
+			// it with pop root
+			var arcs, goldArcs int
+			for _, arc := range result.Arcs().(*dep.ArcSetSimple).Arcs {
+				if arc.GetRelation() != nlp.ROOT_LABEL {
+					arcs++
+				} else if !eager || arc.GetModifier() != 4 {
+					t.Errorf("%s: unexpected root arc %v", name, arc)
+				}
+			}
+			for _, goldArc := range gold.Arcs {
+				if goldArc.GetHead() < 0 {
+					continue
+				}
+				goldArcs++
+				if len(result.Arcs().Get(goldArc)) != 1 {
+					t.Errorf("%s: gold arc %v not found in %v", name, goldArc, result.StringArcs())
+				}
+			}
+			if arcs != goldArcs {
+				t.Errorf("%s: expected %d arcs, got %d: %v", name, goldArcs, arcs, result.StringArcs())
+			}
+		}
+	}
+}
+
+func TestJointArcSwapOracle(t *testing.T) {
+	env := newStrategyTestEnv()
+	gold := env.gold(t, strategyTestNonProjConll)
+	order := dep.ProjectiveOrder(&gold.BasicDepGraph)
+	// nodes in projective order: בית(1) ה(2) ה(0) גדול(3) נפל(4)
+	for node, pos := range []int{2, 0, 1, 3, 4} {
+		if order[node] != pos {
+			t.Errorf("Expected node %d at projective position %d, got %d", node, pos, order[node])
+		}
+	}
+	for _, strategy := range strings.Split(OracleStrategies, ", ") {
+		env := newStrategyTestEnv()
+		gold := env.gold(t, strategyTestNonProjConll)
+		jointTrans, terminalStack := env.transitionSystem("swap", strategy)
+		name := strategy + " " + jointTrans.ArcSys.Name()
+		result := env.runOracle(t, name, jointTrans, terminalStack, gold)
+		var goldArcs int
+		for _, goldArc := range gold.Arcs {
+			if goldArc.GetHead() < 0 {
+				continue
+			}
+			goldArcs++
+			if len(result.Arcs().Get(goldArc)) != 1 {
+				t.Errorf("%s: gold arc %v not found in %v", name, goldArc, result.StringArcs())
+			}
+		}
+		if arcs := len(result.Arcs().(*dep.ArcSetSimple).Arcs); arcs != goldArcs {
+			t.Errorf("%s: expected %d arcs, got %d: %v", name, goldArcs, arcs, result.StringArcs())
+		}
+	}
+}
+
+func TestArcSwapRoot(t *testing.T) {
+	env := newStrategyTestEnv()
+	jointTrans, _ := env.transitionSystem("swap", "ArcGreedy")
+	arcSwap := jointTrans.ArcSys.(*dep.ArcSwap)
+	for _, first := range []string{nlp.ROOT_TOKEN, "בית"} {
+		conf := &dep.SimpleConfiguration{
+			ERel:          env.eRel,
+			ETrans:        env.eTrans,
+			TerminalStack: 1,
+			InternalStack: alg.NewStackArray(2),
+			InternalQueue: alg.NewQueueSlice(2),
+			InternalArcs:  dep.NewArcSetSimple(2),
+		}
+		for i, form := range []string{first, "נפל"} {
+			conf.Nodes = append(conf.Nodes, dep.NewArcCachedDepNode(&dep.TaggedDepNode{Id: i, RawToken: form}))
+		}
+		conf.Stack().Push(0)
+		conf.Queue().Enqueue(1)
+		_, transitions := arcSwap.GetTransitions(conf)
+		var swap bool
+		for _, transition := range transitions {
+			swap = swap || transition == env.sw
+		}
+		if swap != (first != nlp.ROOT_TOKEN) {
+			t.Errorf("Expected SWAP of node 0 (%s) to be possible: %v, got %v", first, first != nlp.ROOT_TOKEN, swap)
+		}
+	}
+}
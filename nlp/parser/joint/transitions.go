@@ -7,33 +7,28 @@ import (
 	. "yap/nlp/types"
 	"yap/util"
 
-	"strings"
 	dep "yap/nlp/parser/dependency/transition"
 	morph "yap/nlp/parser/dependency/transition/morph"
 	"yap/nlp/parser/disambig"
 )
 
 var (
-	TSAllOut         bool
+	TSAllOut bool
+	// JointStrategies and OracleStrategies list the registered strategies
 	JointStrategies  string
 	OracleStrategies string
 )
 
-func init() {
-	jointStrategies := []string{"MDFirst", "All", "ArcGreedy"}
-	oracleStrategies := []string{"MDFirst", "ArcGreedy"}
-	JointStrategies = strings.Join(jointStrategies, ", ")
-	OracleStrategies = strings.Join(oracleStrategies, ", ")
-}
-
 type JointTrans struct {
 	MDTrans       TransitionSystem
 	ArcSys        TransitionSystem
 	Transitions   *util.EnumSet
 	oracle        Oracle
 	JointStrategy string
-	MDTransition  Transition
-	Log           bool
+	// Strategy, if set, is used instead of the registered JointStrategy
+	Strategy     JointStrategy
+	MDTransition Transition
+	Log          bool
 }
 
 var _ TransitionSystem = &JointTrans{}
@@ -43,41 +38,43 @@ func (t *JointTrans) Transition(from Configuration, transition Transition) Confi
 	// transition systems
 	c := from.Copy().(*JointConfig)
 	if transition.Type() == 'M' || transition.Type() == 'P' || transition.Type() == 'L' {
-		t.MDTrans.(*disambig.MDTrans).Log = t.Log
+		switch mdTrans := t.MDTrans.(type) {
+		case *disambig.MDTrans:
+			mdTrans.Log = t.Log
+		case *disambig.MDWBTrans:
+			mdTrans.Log = t.Log
+		}
 		// log.Println("Applying transition", t.Transitions.ValueOf(transition.Value()), "to\n", c.MDConfig)
 		c.MDConfig = *t.MDTrans.Transition(&c.MDConfig, transition).(*disambig.MDConfig)
 		// log.Println("MD Config is now:\n", c.MDConfig)
-		if transition.Type() == 'M' && len(c.MDConfig.Morphemes) > len(from.(*JointConfig).MDConfig.Morphemes) {
-			// if a new morpheme was disambiguated
-			// enqueue last disambiguated morpheme
-			// and add as "node"
-			nodeId := len(c.Nodes)
-			if nodeId != len(c.MDConfig.Morphemes)-1 {
-				// log.Println("With original config", from)
-				// log.Println("Currently", c)
-				// log.Println("Sequence", c.GetSequence())
-				// log.Println("Got nodeId", nodeId, "but len(c.MDConfig.Morphemes)-1 is different:", len(c.MDConfig.Morphemes)-1)
-				log.Println("Nodes is", c.Nodes, "with morphemes", c.MDConfig.Morphemes)
-				panic("Mismatch between Nodes and Morphemes")
+		// word-based disambiguation adds a whole spellout, possibly after
+		// a lemma transition
+		if prevLen := len(from.(*JointConfig).MDConfig.Morphemes); len(c.MDConfig.Morphemes) > prevLen {
+			// for each newly disambiguated morpheme
+			// enqueue it and add as "node"
+			for nodeId := prevLen; nodeId < len(c.MDConfig.Morphemes); nodeId++ {
+				if nodeId != len(c.Nodes) {
+					log.Println("Nodes is", c.Nodes, "with morphemes", c.MDConfig.Morphemes)
+					panic("Mismatch between Nodes and Morphemes")
+				}
+				curMorpheme := c.MDConfig.Morphemes[nodeId]
+				c.SimpleConfiguration.Queue().Enqueue(nodeId)
+				newNode := &dep.TaggedDepNode{
+					nodeId,
+					curMorpheme.EForm,
+					curMorpheme.EPOS,
+					curMorpheme.EFCPOS,
+					curMorpheme.EMHost,
+					curMorpheme.EMSuffix,
+					curMorpheme.Form,
+					curMorpheme.Lemma,
+					curMorpheme.POS,
+				}
+
+				c.SimpleConfiguration.Nodes = append(c.SimpleConfiguration.Nodes,
+					dep.NewArcCachedDepNode(DepNode(newNode)))
 			}
-			curMorpheme := c.MDConfig.Morphemes[nodeId]
-			c.SimpleConfiguration.Queue().Enqueue(nodeId)
-			newNode := &dep.TaggedDepNode{
-				nodeId,
-				curMorpheme.EForm,
-				curMorpheme.EPOS,
-				curMorpheme.EFCPOS,
-				curMorpheme.EMHost,
-				curMorpheme.EMSuffix,
-				curMorpheme.Form,
-				curMorpheme.Lemma,
-				curMorpheme.POS,
-			}
-
-			c.SimpleConfiguration.Nodes = append(c.SimpleConfiguration.Nodes,
-				dep.NewArcCachedDepNode(DepNode(newNode)))
 			c.Assign(c.MDConfig.Assignment())
-
 		}
 	} else {
 		c.SimpleConfiguration = *t.ArcSys.Transition(&c.SimpleConfiguration, transition).(*dep.SimpleConfiguration)
@@ -92,28 +89,19 @@ func (t *JointTrans) TransitionTypes() []string {
 	return append(t.MDTrans.TransitionTypes(), t.ArcSys.TransitionTypes()...)
 }
 
-func (t *JointTrans) TransitionStrategy(c *JointConfig) (shouldMD bool, shouldDep bool) {
-	shouldMD = false
-	shouldDep = false
-	switch t.JointStrategy {
-	case "All":
-		shouldMD = true
-		shouldDep = true
-	case "MDFirst":
-		if !c.MDConfig.Terminal() {
-			shouldMD = true
-		} else {
-			shouldDep = true
-		}
-	case "ArcGreedy":
-		if c.SimpleConfiguration.Queue().Size() < 3 && !c.MDConfig.Terminal() {
-			shouldMD = true
-		} else {
-			shouldDep = true
-		}
-	default:
-		panic("Unknown transition strategy: " + t.JointStrategy)
+func (t *JointTrans) strategy() JointStrategy {
+	if t.Strategy != nil {
+		return t.Strategy
 	}
+	strategy, err := GetJointStrategy(t.JointStrategy)
+	if err != nil {
+		panic(err.Error())
+	}
+	return strategy
+}
+
+func (t *JointTrans) TransitionStrategy(c *JointConfig) (shouldMD bool, shouldDep bool) {
+	shouldMD, shouldDep = t.strategy().Next(c)
 	if !(shouldMD || shouldDep) && !(c.MDConfig.Terminal() && c.SimpleConfiguration.Terminal()) {
 		panic("One of the underlying configurations is not terminal but no transition type specified")
 	}
@@ -174,6 +162,8 @@ type JointOracle struct {
 	ArcSysOracle   Oracle
 	JointStrategy  string
 	OracleStrategy string
+	// Strategy, if set, is used instead of the registered OracleStrategy
+	Strategy JointStrategy
 }
 
 var _ Decision = &JointOracle{}
@@ -189,45 +179,33 @@ func (o *JointOracle) SetGold(g interface{}) {
 	o.ArcSysOracle.SetGold(&graph.BasicDepGraph)
 }
 
-func (o *JointOracle) MDFirst(conf Configuration) Transition {
-	c, ok := conf.(*JointConfig)
-	if !ok {
-		panic("Conf must be *JointConfig")
+func (o *JointOracle) strategy() JointStrategy {
+	if o.Strategy != nil {
+		return o.Strategy
 	}
-	if !c.MDConfig.Terminal() {
-		return o.MDOracle.Transition(&c.MDConfig)
-	} else {
-		return o.ArcSysOracle.Transition(&c.SimpleConfiguration)
+	strategy, err := GetOracleStrategy(o.OracleStrategy)
+	if err != nil {
+		panic(err.Error())
 	}
+	return strategy
 }
 
-func (o *JointOracle) ArcGreedy(conf Configuration) Transition {
+func (o *JointOracle) Transition(conf Configuration) Transition {
+	if o.gold == nil {
+		panic("Oracle needs gold reference, use SetGold")
+	}
 	c, ok := conf.(*JointConfig)
 	if !ok {
 		panic("Conf must be *JointConfig")
 	}
-	if c.SimpleConfiguration.Queue().Size() < 3 && !c.MDConfig.Terminal() {
-		return o.MDOracle.Transition(&c.MDConfig)
-	} else {
-		return o.ArcSysOracle.Transition(&c.SimpleConfiguration)
+	shouldMD, shouldDep := o.strategy().Next(c)
+	if shouldMD == shouldDep {
+		panic("Oracle strategy must choose exactly one transition type")
 	}
-}
-
-func (o *JointOracle) Transition(conf Configuration) Transition {
-	if o.gold == nil {
-		panic("Oracle needs gold reference, use SetGold")
-	}
-
-	switch o.OracleStrategy {
-	case "MDFirst":
-		return o.MDFirst(conf)
-	case "ArcGreedy":
-		return o.ArcGreedy(conf)
-	default:
-		panic("Unknown oracle strategy: " + o.OracleStrategy)
+	if shouldMD {
+		return o.MDOracle.Transition(&c.MDConfig)
 	}
-
-	return ConstTransition(0)
+	return o.ArcSysOracle.Transition(&c.SimpleConfiguration)
 }
 
 func (o *JointOracle) Name() string {
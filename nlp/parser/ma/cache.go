@@ -0,0 +1,96 @@
+package ma
+
+import (
+	. "yap/nlp/types"
+
+	"container/list"
+	"sync"
+)
+
+// LatticeCache is a bounded LRU cache of token lattices.
+// Lattices are stored as analyzed at node 0 and are copied and shifted to
+// the requested starting node on retrieval
+type LatticeCache struct {
+	Size int
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type latticeCacheEntry struct {
+	key     string
+	lattice *Lattice
+	oov     bool
+}
+
+func NewLatticeCache(size int) *LatticeCache {
+	return &LatticeCache{
+		Size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// Get returns a copy of the cached lattice of key, starting at startingNode
+// with its morphemes belonging to token numToken
+func (c *LatticeCache) Get(key string, startingNode, numToken int) (*Lattice, bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, exists := c.entries[key]
+	if !exists {
+		return nil, false, false
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*latticeCacheEntry)
+	return shiftedLattice(entry.lattice, startingNode, numToken), entry.oov, true
+}
+
+// Add caches a lattice starting at node 0, evicting the least recently
+// used lattice if the cache is full
+func (c *LatticeCache) Add(key string, lat *Lattice, oov bool) {
+	if c.Size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, exists := c.entries[key]; exists {
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*latticeCacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&latticeCacheEntry{key, shiftedLattice(lat, 0, 0), oov})
+}
+
+func (c *LatticeCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+// shiftedLattice returns a copy of lat starting at startingNode, a
+// numToken of 0 keeps the morphemes' token ids
+func shiftedLattice(lat *Lattice, startingNode, numToken int) *Lattice {
+	newLat := &Lattice{
+		Token:     lat.Token,
+		Morphemes: make(Morphemes, len(lat.Morphemes)),
+		Next:      make(map[int][]int, len(lat.Next)),
+		BottomId:  lat.BottomId,
+		TopId:     lat.TopId,
+	}
+	for i, m := range lat.Morphemes {
+		newLat.Morphemes[i] = m.Copy()
+		if numToken > 0 {
+			newLat.Morphemes[i].TokenID = numToken
+		}
+	}
+	for node, next := range lat.Next {
+		newLat.Next[node] = append([]int(nil), next...)
+	}
+	newLat.BumpAll(startingNode - lat.BottomId)
+	return newLat
+}
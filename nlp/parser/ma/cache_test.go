@@ -0,0 +1,56 @@
+package ma
+
+import (
+	"reflect"
+	"testing"
+
+	. "yap/nlp/types"
+)
+
+func testLex() *BGULex {
+	morph := func(form, pos string) *Morpheme {
+		return &Morpheme{Form: form, Lemma: form, CPOS: pos, POS: pos, Features: map[string]string{}}
+	}
+	return &BGULex{
+		Prefixes: map[string][]BasicMorphemes{
+			"ב": {{morph("ב", "PREPOSITION")}, {morph("ב", "PREPOSITION"), morph("ה", "DEF")}},
+		},
+		Lex: map[string][]BasicMorphemes{
+			"בית":  {{morph("בית", "NN")}, {morph("בית", "NNT")}},
+			"בבית": {{morph("בבית", "NNP")}},
+		},
+		MaxPrefixLen: 1,
+	}
+}
+
+func TestLatticeCacheShift(t *testing.T) {
+	uncached, cached := testLex(), testLex()
+	cached.Cache = NewLatticeCache(10)
+	// the token is seen at the start of a sentence, then at node 3
+	for _, at := range []struct{ node, token int }{{0, 0}, {3, 2}} {
+		expected, expectedOOV := uncached.AnalyzeToken("בבית", at.node, at.token)
+		lat, oov := cached.AnalyzeToken("בבית", at.node, at.token)
+		if oov != expectedOOV {
+			t.Errorf("At node %d: expected OOV %v, got %v", at.node, expectedOOV, oov)
+		}
+		if !reflect.DeepEqual(lat.Morphemes, expected.Morphemes) {
+			t.Errorf("At node %d: expected morphemes\n%v\ngot\n%v", at.node, expected.Morphemes, lat.Morphemes)
+		}
+		if lat.BottomId != expected.BottomId || lat.TopId != expected.TopId {
+			t.Errorf("At node %d: expected nodes %d-%d, got %d-%d", at.node, expected.BottomId, expected.TopId, lat.BottomId, lat.TopId)
+		}
+		for node, next := range expected.Next {
+			if len(next) > 0 && !reflect.DeepEqual(lat.Next[node], next) {
+				t.Errorf("At node %d: expected next of %d %v, got %v", at.node, node, next, lat.Next[node])
+			}
+		}
+		for node, next := range lat.Next {
+			if len(next) > 0 && len(expected.Next[node]) == 0 {
+				t.Errorf("At node %d: unexpected next of %d %v", at.node, node, next)
+			}
+		}
+	}
+	if cached.Cache.Len() != 1 {
+		t.Errorf("Expected 1 cached lattice, got %d", cached.Cache.Len())
+	}
+}
@@ -33,6 +33,23 @@ type TokenDictionary map[string][]BasicMorphemes
 
 type MSRFreq map[string]int
 
+// MADict is a data-driven morphological analyzer learned from a treebank
+//
+// A dictionary is serialized (see Write/WriteFile) as a single JSON object:
+//
+//	Language       free text name of the dictionary's language
+//	NumTokens      number of distinct tokens in Data
+//	MaxTopPOS      number of most frequent CPOS tags considered for OOV tokens
+//	MaxMSRsPerPOS  max number of MSRs (morpho-syntactic representations) for OOV tokens
+//	TopPOS         the MaxTopPOS most frequent CPOS tags
+//	OOVMSRs        "CPOS|POS|Feats" strings, each becomes an analysis of an OOV token
+//	POSMSRs        CPOS -> "CPOS|Feats" -> frequency in the training data
+//	Files          training files and their md5 sums
+//	Data           token -> list of analyses, each a list of morphemes
+//
+// An analysis with more than one morpheme (e.g. a CoNLL-U multiword token
+// with a "1-2" range) is a single path of the token's lattice; morpheme
+// edges within an analysis are numbered from 0.
 type MADict struct {
 	Language  string
 	NumTokens int
@@ -47,10 +64,11 @@ type MADict struct {
 	Files []TrainingFile
 	Data  TokenDictionary
 
-	Stats *AnalyzeStats
+	// runtime only, not serialized
+	Stats *AnalyzeStats `json:"-"`
 
-	TopPOSSet map[string]bool
-	Dope      bool
+	TopPOSSet map[string]bool `json:"-"`
+	Dope      bool            `json:"-"`
 }
 
 var _ MorphologicalAnalyzer = &MADict{}
@@ -365,6 +383,15 @@ func (m *MADict) ReadFile(filename string) error {
 type AnalyzeStats struct {
 	TotalTokens, OOVTokens    int
 	UniqTokens, UniqOOVTokens map[string]int
+	CacheHits, CacheMisses    int
+}
+
+// CacheHitRate is the share of token analyses served from the lattice cache
+func (a *AnalyzeStats) CacheHitRate() float64 {
+	if a.CacheHits+a.CacheMisses == 0 {
+		return 0
+	}
+	return float64(a.CacheHits) / float64(a.CacheHits+a.CacheMisses)
 }
 
 func (a *AnalyzeStats) Init() {
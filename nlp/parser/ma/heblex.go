@@ -26,6 +26,9 @@ type BGULex struct {
 	AlwaysNNP bool
 	LogOOV    bool
 	MAType    string
+
+	// Cache holds recently analyzed token lattices (optional)
+	Cache *LatticeCache
 }
 
 var (
@@ -125,7 +128,7 @@ func (l *BGULex) AddOOVAnalysis(lat *Lattice, prefix BasicMorphemes, hostStr str
 		msrsplit := strings.Split(msr, "-")
 		OOVPOS, featuresStr = msrsplit[0], msrsplit[1]
 		if l.MAType == "ud" {
-			OOVPOS = util.HEB2UDPOS[OOVPOS]
+			OOVPOS, _ = util.HEB2UD.SplitMSR(util.HEB2UD.POS[OOVPOS])
 			if len(featuresStr) > 0 {
 				featuresStr = util.Heb2UDFeaturesString(featuresStr)
 			}
@@ -216,7 +219,39 @@ func (l *BGULex) analyzeTokenForLen(lat *Lattice, input string, startingNode, nu
 	return found
 }
 
+func (l *BGULex) cacheKey(input string) string {
+	return fmt.Sprintf("%s\t%v\t%s", input, l.AlwaysNNP, l.MAType)
+}
+
 func (l *BGULex) AnalyzeToken(input string, startingNode, indexToken int) (*Lattice, interface{}) {
+	if l.Cache == nil {
+		return l.analyzeToken(input, startingNode, indexToken)
+	}
+	numToken := indexToken + 1
+	key := l.cacheKey(input)
+	if lat, oov, exists := l.Cache.Get(key, startingNode, numToken); exists {
+		if l.Stats != nil {
+			l.Stats.CacheHits++
+			if oov {
+				l.Stats.OOVTokens++
+				l.Stats.AddOOVToken(input)
+			}
+		}
+		if oov && l.LogOOV {
+			log.Println("Token", numToken, "is OOV:", input)
+		}
+		return lat, oov
+	}
+	if l.Stats != nil {
+		l.Stats.CacheMisses++
+	}
+	lat, oov := l.analyzeToken(input, 0, indexToken)
+	l.Cache.Add(key, lat, oov.(bool))
+	lat.BumpAll(startingNode)
+	return lat, oov
+}
+
+func (l *BGULex) analyzeToken(input string, startingNode, indexToken int) (*Lattice, interface{}) {
 	numToken := indexToken + 1
 	if logAnalyze {
 		log.Println("Analyzing token", numToken, "starting at", startingNode)
@@ -0,0 +1,123 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Character attributes of words in feature templates: pre<N> and suf<N> are
+// the first and last N characters (runes) of a word, and shape is its word
+// shape
+const (
+	PREFIX_ATTRIBUTE = "pre"
+	SUFFIX_ATTRIBUTE = "suf"
+	SHAPE_ATTRIBUTE  = "shape"
+)
+
+// Affix is a parsed character attribute
+type Affix struct {
+	Kind string
+	N    int
+}
+
+// affixes holds the character attributes of the loaded feature templates,
+// they are registered when the templates are parsed, before extraction
+var affixes = make(map[string]*Affix)
+
+// IsAffixAttribute is true for the attributes of ParseAffixAttribute,
+// including malformed ones
+func IsAffixAttribute(attribute []byte) bool {
+	str := string(attribute)
+	return str == SHAPE_ATTRIBUTE ||
+		strings.HasPrefix(str, PREFIX_ATTRIBUTE) ||
+		strings.HasPrefix(str, SUFFIX_ATTRIBUTE)
+}
+
+// ParseAffixAttribute returns the kind (pre, suf or shape) and length of a
+// character attribute
+func ParseAffixAttribute(attribute []byte) (*Affix, error) {
+	str := string(attribute)
+	if str == SHAPE_ATTRIBUTE {
+		return &Affix{Kind: SHAPE_ATTRIBUTE}, nil
+	}
+	for _, kind := range []string{PREFIX_ATTRIBUTE, SUFFIX_ATTRIBUTE} {
+		if !strings.HasPrefix(str, kind) {
+			continue
+		}
+		n, err := strconv.Atoi(str[len(kind):])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("Attribute %s needs a positive length, e.g. %s3", str, kind)
+		}
+		return &Affix{Kind: kind, N: n}, nil
+	}
+	return nil, fmt.Errorf("Unknown character attribute %s", str)
+}
+
+// RegisterAffixAttribute parses a character attribute of a feature template
+// for LookupAffixAttribute
+func RegisterAffixAttribute(attribute []byte) error {
+	affix, err := ParseAffixAttribute(attribute)
+	if err != nil {
+		return err
+	}
+	affixes[string(attribute)] = affix
+	return nil
+}
+
+// LookupAffixAttribute returns the registered character attribute; most
+// attributes are shorter than any character attribute and skip the lookup
+func LookupAffixAttribute(attribute []byte) (*Affix, bool) {
+	if len(attribute) <= len(PREFIX_ATTRIBUTE) {
+		return nil, false
+	}
+	affix, exists := affixes[string(attribute)]
+	return affix, exists
+}
+
+// Attribute returns the character attribute of a word; exists is false
+// for words shorter than the affix, so they don't share its features
+func (a *Affix) Attribute(word string) (att string, exists bool) {
+	switch a.Kind {
+	case SHAPE_ATTRIBUTE:
+		return Shape(word), len(word) > 0
+	case PREFIX_ATTRIBUTE:
+		runes := []rune(word)
+		if len(runes) < a.N {
+			return "", false
+		}
+		return string(runes[:a.N]), true
+	case SUFFIX_ATTRIBUTE:
+		runes := []rune(word)
+		if len(runes) < a.N {
+			return "", false
+		}
+		return string(runes[len(runes)-a.N:]), true
+	}
+	return "", false
+}
+
+// Shape maps upper case letters to X, other letters to x and digits to d,
+// keeping other characters, and collapses repeats, e.g. Tel-Aviv2 is Xx-Xxd
+func Shape(word string) string {
+	var (
+		shape []rune
+		last  rune
+	)
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			r = 'X'
+		case unicode.IsLetter(r):
+			r = 'x'
+		case unicode.IsDigit(r):
+			r = 'd'
+		}
+		if r != last {
+			shape = append(shape, r)
+			last = r
+		}
+	}
+	return string(shape)
+}
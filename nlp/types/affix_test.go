@@ -0,0 +1,62 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestShape(t *testing.T) {
+	for word, expected := range map[string]string{
+		"Tel-Aviv2": "Xx-Xxd",
+		"HBIT":      "X",
+		"1948":      "d",
+		"iPhone":    "xXx",
+		"בית":       "x",
+		"":          "",
+	} {
+		if shape := Shape(word); shape != expected {
+			t.Errorf("Expected shape %s of %q, got %s", expected, word, shape)
+		}
+	}
+}
+
+func TestAffixAttribute(t *testing.T) {
+	tests := []struct {
+		attribute, word, expected string
+		exists                    bool
+	}{
+		{"pre2", "HBIT", "HB", true},
+		{"suf3", "HBIT", "BIT", true},
+		{"pre4", "HBIT", "HBIT", true},
+		{"pre5", "HBIT", "", false},
+		{"suf1", "", "", false},
+		{"shape", "HBIT", "X", true},
+		{"shape", "", "", false},
+		// lengths are in characters, not UTF-8 bytes
+		{"pre2", "בית", "בי", true},
+		{"suf1", "בית", "ת", true},
+		{"suf3", "בית", "בית", true},
+		{"pre4", "בית", "", false},
+	}
+	for _, test := range tests {
+		if err := RegisterAffixAttribute([]byte(test.attribute)); err != nil {
+			t.Fatalf("Failed registering %s: %v", test.attribute, err)
+		}
+		affix, registered := LookupAffixAttribute([]byte(test.attribute))
+		if !registered {
+			t.Fatalf("Expected %s registered", test.attribute)
+		}
+		if att, exists := affix.Attribute(test.word); att != test.expected || exists != test.exists {
+			t.Errorf("Expected %s of %q %q (%v), got %q (%v)", test.attribute, test.word, test.expected, test.exists, att, exists)
+		}
+	}
+	for _, attribute := range []string{"pre", "suf0", "prex", "shapes"} {
+		if err := RegisterAffixAttribute([]byte(attribute)); err == nil {
+			t.Errorf("Expected error registering %s", attribute)
+		}
+	}
+	for _, attribute := range []string{"p", "wp", "c10", "pre9"} {
+		if _, registered := LookupAffixAttribute([]byte(attribute)); registered {
+			t.Errorf("Expected %s not registered", attribute)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package types
+
+import (
+	"math"
+	"sort"
+	"yap/util"
+)
+
+const APPROX_CLUSTERS = 1000
+
+// WordClusters maps words to distributional classes for features: the bit
+// strings of hierarchical (Brown) clusters, and the clusters of discretized
+// word embeddings. The bit string prefixes are enumerated in EClusters
+type WordClusters struct {
+	Brown     map[string]string
+	Embedding map[string]int
+	EClusters *util.EnumSet
+}
+
+func NewWordClusters() *WordClusters {
+	return &WordClusters{
+		Brown:     make(map[string]string),
+		Embedding: make(map[string]int),
+		EClusters: util.NewEnumSet(APPROX_CLUSTERS),
+	}
+}
+
+// AddBrown sets the cluster bit string of a word and enumerates its prefixes
+func (w *WordClusters) AddBrown(word, bits string) {
+	w.Brown[word] = bits
+	for i := 1; i <= len(bits); i++ {
+		w.EClusters.Add(bits[:i])
+	}
+}
+
+// Prefix returns the enumerated prefix of (at most) bits bits of the
+// cluster of a word, the whole bit string if bits is 0
+func (w *WordClusters) Prefix(word string, bits int) (int, bool) {
+	if w == nil {
+		return 0, false
+	}
+	path, exists := w.Brown[word]
+	if !exists {
+		return 0, false
+	}
+	if bits > 0 && bits < len(path) {
+		path = path[:bits]
+	}
+	return w.EClusters.IndexOf(path)
+}
+
+// EmbeddingCluster returns the cluster of the embedding of a word
+func (w *WordClusters) EmbeddingCluster(word string) (int, bool) {
+	if w == nil {
+		return 0, false
+	}
+	cluster, exists := w.Embedding[word]
+	return cluster, exists
+}
+
+// Attribute returns the cluster feature attribute of a word: c<bits> is the
+// prefix of the cluster bit string (c is the whole bit string), and e is
+// the embedding cluster
+func (w *WordClusters) Attribute(word string, attribute []byte) (int, bool) {
+	switch attribute[0] {
+	case 'c':
+		var bits int
+		for _, digit := range attribute[1:] {
+			if digit < '0' || digit > '9' {
+				return 0, false
+			}
+			bits = bits*10 + int(digit-'0')
+		}
+		return w.Prefix(word, bits)
+	case 'e':
+		return w.EmbeddingCluster(word)
+	}
+	return 0, false
+}
+
+// IsClusterAttribute is true for the attributes answered by Attribute
+func IsClusterAttribute(attribute []byte) bool {
+	if len(attribute) == 0 {
+		return false
+	}
+	switch attribute[0] {
+	case 'c':
+		for _, digit := range attribute[1:] {
+			if digit < '0' || digit > '9' {
+				return false
+			}
+		}
+		return true
+	case 'e':
+		return len(attribute) == 1
+	}
+	return false
+}
+
+// AddEmbeddings discretizes word embeddings into k clusters with k-means;
+// words are sorted so the clusters are the same for the same embeddings
+func (w *WordClusters) AddEmbeddings(vectors map[string][]float64, k, iterations int) {
+	words := make([]string, 0, len(vectors))
+	for word := range vectors {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	if k > len(words) {
+		k = len(words)
+	}
+	if k == 0 {
+		return
+	}
+	// start from evenly spaced words
+	centroids := make([][]float64, k)
+	for c := range centroids {
+		centroids[c] = append([]float64(nil), vectors[words[c*len(words)/k]]...)
+	}
+	assignment := make([]int, len(words))
+	for it := 0; it < iterations; it++ {
+		changed := it == 0
+		for i, word := range words {
+			if nearest := nearestCentroid(vectors[word], centroids); nearest != assignment[i] {
+				assignment[i], changed = nearest, true
+			}
+		}
+		if !changed {
+			break
+		}
+		counts := make([]int, k)
+		sums := make([][]float64, k)
+		for c := range sums {
+			sums[c] = make([]float64, len(centroids[c]))
+		}
+		for i, word := range words {
+			counts[assignment[i]]++
+			for d, val := range vectors[word] {
+				sums[assignment[i]][d] += val
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for d := range centroids[c] {
+				centroids[c][d] = sums[c][d] / float64(counts[c])
+			}
+		}
+	}
+	for i, word := range words {
+		w.Embedding[word] = assignment[i]
+	}
+}
+
+func nearestCentroid(vector []float64, centroids [][]float64) int {
+	var (
+		nearest  int
+		distance = math.Inf(1)
+	)
+	for c, centroid := range centroids {
+		var cur float64
+		for d, val := range vector {
+			diff := val - centroid[d]
+			cur += diff * diff
+		}
+		if cur < distance {
+			nearest, distance = c, cur
+		}
+	}
+	return nearest
+}
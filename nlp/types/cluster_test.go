@@ -0,0 +1,79 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordClustersAttribute(t *testing.T) {
+	clusters := NewWordClusters()
+	clusters.AddBrown("BIT", "0010")
+	clusters.Embedding["BIT"] = 3
+
+	prefix := func(bits string) int {
+		index, _ := clusters.EClusters.IndexOf(bits)
+		return index
+	}
+	tests := []struct {
+		word, attribute string
+		expected        int
+		exists          bool
+	}{
+		{"BIT", "c", prefix("0010"), true},
+		{"BIT", "c2", prefix("00"), true},
+		{"BIT", "c10", prefix("0010"), true},
+		{"BIT", "e", 3, true},
+		{"BIT", "cx", 0, false},
+		{"HLK", "c2", 0, false},
+		{"HLK", "e", 0, false},
+	}
+	for _, test := range tests {
+		value, exists := clusters.Attribute(test.word, []byte(test.attribute))
+		if value != test.expected || exists != test.exists {
+			t.Errorf("Expected %s of %s %d (%v), got %d (%v)", test.attribute, test.word, test.expected, test.exists, value, exists)
+		}
+	}
+	var missing *WordClusters
+	if _, exists := missing.Attribute("BIT", []byte("c")); exists {
+		t.Errorf("Expected no cluster attributes without clusters")
+	}
+
+	for attribute, expected := range map[string]bool{"c": true, "c12": true, "e": true, "c1x": false, "e1": false, "w": false, "": false} {
+		if isCluster := IsClusterAttribute([]byte(attribute)); isCluster != expected {
+			t.Errorf("Expected %q cluster attribute %v, got %v", attribute, expected, isCluster)
+		}
+	}
+}
+
+func TestAddEmbeddings(t *testing.T) {
+	vectors := map[string][]float64{
+		"a": {0, 0}, "b": {0.1, 0}, "c": {0, 0.1},
+		"x": {5, 5}, "y": {5.1, 5}, "z": {5, 5.1},
+	}
+	clusters := NewWordClusters()
+	clusters.AddEmbeddings(vectors, 2, 10)
+	for _, group := range [][]string{{"a", "b", "c"}, {"x", "y", "z"}} {
+		for _, word := range group[1:] {
+			if clusters.Embedding[word] != clusters.Embedding[group[0]] {
+				t.Errorf("Expected %s in the cluster of %s", word, group[0])
+			}
+		}
+	}
+	if clusters.Embedding["a"] == clusters.Embedding["x"] {
+		t.Errorf("Expected a and x in different clusters")
+	}
+	// map iteration order doesn't change the clusters
+	for i := 0; i < 10; i++ {
+		again := NewWordClusters()
+		again.AddEmbeddings(vectors, 2, 10)
+		if !reflect.DeepEqual(again.Embedding, clusters.Embedding) {
+			t.Fatalf("Expected the same clusters %v, got %v", clusters.Embedding, again.Embedding)
+		}
+	}
+	// more clusters than words
+	few := NewWordClusters()
+	few.AddEmbeddings(map[string][]float64{"a": {0}, "b": {1}}, 5, 10)
+	if len(few.Embedding) != 2 || few.Embedding["a"] == few.Embedding["b"] {
+		t.Errorf("Expected a cluster per word, got %v", few.Embedding)
+	}
+}
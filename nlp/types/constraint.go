@@ -0,0 +1,200 @@
+package types
+
+import (
+	"fmt"
+)
+
+// CONSTRAINT_ANY matches any value of a single morpheme in a constraint
+const CONSTRAINT_ANY = "*"
+
+// TokenConstraint restricts the analyses of a token to the lattice paths
+// matching its segmentation, POS tags and features. Each non-empty list must
+// have one element per morpheme of the path, CONSTRAINT_ANY matches any value.
+// Sentence and Token are 1-based
+type TokenConstraint struct {
+	Sentence     int      `json:"sentence"`
+	Token        int      `json:"token"`
+	Segmentation []string `json:"segmentation,omitempty"`
+	POS          []string `json:"pos,omitempty"`
+	Features     []string `json:"feats,omitempty"`
+}
+
+func (c TokenConstraint) String() string {
+	return fmt.Sprintf("sentence %d token %d segmentation %v pos %v feats %v", c.Sentence, c.Token, c.Segmentation, c.POS, c.Features)
+}
+
+func (c TokenConstraint) Empty() bool {
+	return len(c.Segmentation) == 0 && len(c.POS) == 0 && len(c.Features) == 0
+}
+
+func constraintMatch(values []string, i int, value string) bool {
+	if len(values) == 0 || values[i] == CONSTRAINT_ANY {
+		return true
+	}
+	if values[i] == "_" {
+		return value == "" || value == "_"
+	}
+	return values[i] == value
+}
+
+func constraintLenMatch(values []string, length int) bool {
+	return len(values) == 0 || len(values) == length
+}
+
+// Accept returns true if the spellout satisfies the constraint
+func (c TokenConstraint) Accept(s Spellout) bool {
+	if !constraintLenMatch(c.Segmentation, len(s)) || !constraintLenMatch(c.POS, len(s)) || !constraintLenMatch(c.Features, len(s)) {
+		return false
+	}
+	for i, morph := range s {
+		if !constraintMatch(c.Segmentation, i, morph.Form) ||
+			!constraintMatch(c.POS, i, morph.CPOS) ||
+			!constraintMatch(c.Features, i, morph.FeatureStr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Constrain keeps only the paths accepted by accept, morpheme IDs are
+// renumbered. If accepted paths cross each other, the kept morphemes would
+// also combine into paths that are not accepted, so the lattice is rebuilt
+// from the accepted paths with new nodes between its bottom and a new top
+// (see LatticeSentence.Constrain). If no path is accepted the lattice is
+// unchanged and false is returned
+func (l *Lattice) Constrain(accept func(Spellout) bool) bool {
+	l.GenSpellouts()
+	accepted := make(Spellouts, 0, len(l.Spellouts))
+	keep := make(map[*EMorpheme]bool, len(l.Morphemes))
+	for _, spellout := range l.Spellouts {
+		if !accept(spellout) {
+			continue
+		}
+		accepted = append(accepted, spellout)
+		for _, morph := range spellout {
+			keep[morph] = true
+		}
+	}
+	if len(accepted) == 0 {
+		return false
+	}
+	morphs := make(Morphemes, 0, len(keep))
+	for _, morph := range l.Morphemes {
+		if keep[morph] {
+			morph.BasicDirectedEdge[0] = len(morphs)
+			morphs = append(morphs, morph)
+		}
+	}
+	l.Morphemes = morphs
+	l.GenNexts(true)
+	l.Spellouts = nil
+	l.GenSpellouts()
+	if len(l.Spellouts) != len(accepted) {
+		l.rebuild(accepted)
+	}
+	return true
+}
+
+// rebuild replaces the lattice's graph with a prefix tree of the spellouts,
+// numbering its nodes breadth first from the bottom. Morphemes on more than
+// one branch are copied
+func (l *Lattice) rebuild(spellouts Spellouts) {
+	type branch struct {
+		morph *EMorpheme
+		to    int
+	}
+	var (
+		branches = [][]branch{nil}
+		used     = make(map[*EMorpheme]bool, len(l.Morphemes))
+	)
+	const top = -1
+	for _, spellout := range spellouts {
+		node := 0
+	morphs:
+		for i, morph := range spellout {
+			for _, b := range branches[node] {
+				if b.morph == morph {
+					node = b.to
+					continue morphs
+				}
+			}
+			to := top
+			if i < len(spellout)-1 {
+				to = len(branches)
+				branches = append(branches, nil)
+			}
+			branches[node] = append(branches[node], branch{morph, to})
+			node = to
+		}
+	}
+	// breadth first numbering keeps every morpheme's nodes increasing
+	ids := make([]int, len(branches))
+	queue := []int{0}
+	ids[0] = l.BottomId
+	nextId := l.BottomId + 1
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, b := range branches[node] {
+			if b.to != top {
+				ids[b.to] = nextId
+				nextId++
+				queue = append(queue, b.to)
+			}
+		}
+	}
+	l.TopId = nextId
+	l.Morphemes = make(Morphemes, 0, len(l.Morphemes))
+	queue = []int{0}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, b := range branches[node] {
+			morph := b.morph
+			if used[morph] {
+				morph = morph.Copy()
+			}
+			used[morph] = true
+			to := l.TopId
+			if b.to != top {
+				to = ids[b.to]
+				queue = append(queue, b.to)
+			}
+			morph.BasicDirectedEdge[0] = len(l.Morphemes)
+			morph.BasicDirectedEdge[1] = ids[node]
+			morph.BasicDirectedEdge[2] = to
+			l.Morphemes = append(l.Morphemes, morph)
+		}
+	}
+	l.GenNexts(true)
+	l.Spellouts = nil
+	l.GenSpellouts()
+}
+
+// Constrain applies the token constraints of the sentence, returning an
+// error for each constraint that could not be applied
+func (ls LatticeSentence) Constrain(constraints []TokenConstraint) []error {
+	var errs []error
+	for _, constraint := range constraints {
+		if constraint.Token < 1 || constraint.Token > len(ls) {
+			errs = append(errs, fmt.Errorf("Constraint token out of range (%d tokens): %v", len(ls), constraint))
+			continue
+		}
+		if constraint.Empty() {
+			continue
+		}
+		lat := &ls[constraint.Token-1]
+		top := lat.Top()
+		if !lat.Constrain(constraint.Accept) {
+			errs = append(errs, fmt.Errorf("No analysis of token %v matches constraint: %v", lat.Token, constraint))
+			continue
+		}
+		// a rebuilt lattice may have a different number of nodes
+		if diff := lat.Top() - top; diff != 0 {
+			for i := constraint.Token; i < len(ls); i++ {
+				ls[i].BumpAll(diff)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"testing"
+)
+
+// testCrossLat has the paths ה/DEF-בית/NN, ה/DEF-בית/VB, ה/REL-בית/NN and
+// ה/REL-בית/VB between nodes 2 and 4
+func testCrossLat() *Lattice {
+	lat := &Lattice{
+		Token: "הבית",
+		Morphemes: Morphemes{
+			testMorph(0, 2, 3, "ה", "DEF", nil),
+			testMorph(1, 2, 3, "ה", "REL", nil),
+			testMorph(2, 3, 4, "בית", "NN", map[string]string{"gen": "M"}),
+			testMorph(3, 3, 4, "בית", "VB", map[string]string{"gen": "M"}),
+		},
+		BottomId: 2,
+		TopId:    4,
+	}
+	for _, morph := range lat.Morphemes {
+		morph.FeatureStr = "_"
+		if morph.Features != nil {
+			morph.FeatureStr = "gen=M"
+		}
+	}
+	lat.GenNexts(true)
+	return lat
+}
+
+func spelloutPOS(s Spellout) string {
+	var pos string
+	for i, morph := range s {
+		if i > 0 {
+			pos += "-"
+		}
+		pos += morph.CPOS
+	}
+	return pos
+}
+
+func checkLatGraph(t *testing.T, lat *Lattice) {
+	for i, morph := range lat.Morphemes {
+		if morph.ID() != i {
+			t.Errorf("Expected morpheme %d to have ID %d, got %d", i, i, morph.ID())
+		}
+	}
+	for from, next := range lat.Next {
+		for _, i := range next {
+			if lat.Morphemes[i].From() != from {
+				t.Errorf("Expected morpheme %d in Next[%d] to start at %d, got %d", i, from, from, lat.Morphemes[i].From())
+			}
+		}
+	}
+	for _, spellout := range lat.Spellouts {
+		if spellout[0].From() != lat.Bottom() || spellout[len(spellout)-1].To() != lat.Top() {
+			t.Errorf("Expected spellout %v to span %d-%d", spellout, lat.Bottom(), lat.Top())
+		}
+	}
+}
+
+func TestConstraintAccept(t *testing.T) {
+	lat := testCrossLat()
+	lat.GenSpellouts()
+	tests := []struct {
+		constraint TokenConstraint
+		accepted   int
+	}{
+		{TokenConstraint{}, 4},
+		{TokenConstraint{Segmentation: []string{"ה", "בית"}}, 4},
+		{TokenConstraint{Segmentation: []string{"הבית"}}, 0},
+		{TokenConstraint{POS: []string{"DEF", CONSTRAINT_ANY}}, 2},
+		{TokenConstraint{POS: []string{"DEF", "VB"}}, 1},
+		{TokenConstraint{POS: []string{"DEF"}}, 0},
+		{TokenConstraint{Features: []string{"_", "gen=M"}}, 4},
+		{TokenConstraint{Features: []string{"_", "gen=F"}}, 0},
+	}
+	for _, test := range tests {
+		var accepted int
+		for _, spellout := range lat.Spellouts {
+			if test.constraint.Accept(spellout) {
+				accepted++
+			}
+		}
+		if accepted != test.accepted {
+			t.Errorf("Expected %v to accept %d paths, got %d", test.constraint, test.accepted, accepted)
+		}
+	}
+}
+
+func TestLatticeConstrain(t *testing.T) {
+	lat := testCrossLat()
+	if !lat.Constrain(TokenConstraint{POS: []string{"REL", CONSTRAINT_ANY}}.Accept) {
+		t.Fatalf("Expected constraint to be applied")
+	}
+	if len(lat.Morphemes) != 3 || len(lat.Spellouts) != 2 || lat.Top() != 4 {
+		t.Errorf("Expected 3 morphemes and 2 paths to 4, got %d %d %d", len(lat.Morphemes), len(lat.Spellouts), lat.Top())
+	}
+	checkLatGraph(t, lat)
+
+	lat = testCrossLat()
+	if lat.Constrain(TokenConstraint{POS: []string{"NN", "NN"}}.Accept) {
+		t.Errorf("Expected constraint without matching paths to fail")
+	}
+	if len(lat.Morphemes) != 4 {
+		t.Errorf("Expected the lattice to be unchanged, got %d morphemes", len(lat.Morphemes))
+	}
+}
+
+func TestLatticeConstrainCrossing(t *testing.T) {
+	lat := testCrossLat()
+	keep := map[string]bool{"DEF-NN": true, "REL-VB": true}
+	if !lat.Constrain(func(s Spellout) bool { return keep[spelloutPOS(s)] }) {
+		t.Fatalf("Expected constraint to be applied")
+	}
+	if len(lat.Spellouts) != 2 {
+		t.Fatalf("Expected 2 paths, got %d", len(lat.Spellouts))
+	}
+	for _, spellout := range lat.Spellouts {
+		if !keep[spelloutPOS(spellout)] {
+			t.Errorf("Expected only accepted paths, got %v", spelloutPOS(spellout))
+		}
+	}
+	if len(lat.Morphemes) != 4 || lat.Bottom() != 2 || lat.Top() != 5 {
+		t.Errorf("Expected 4 morphemes between 2 and 5, got %d between %d and %d", len(lat.Morphemes), lat.Bottom(), lat.Top())
+	}
+	checkLatGraph(t, lat)
+}
+
+func TestLatticeSentenceConstrain(t *testing.T) {
+	first, second := testCrossLat(), testCrossLat()
+	first.BumpAll(-2)
+	sent := LatticeSentence{*first, *second}
+	errs := sent.Constrain([]TokenConstraint{
+		{Token: 1, POS: []string{"DEF", "NN"}},
+		{Token: 2, POS: []string{"NN", "NN"}},
+		{Token: 3, POS: []string{"DEF", "NN"}},
+	})
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+	if len(sent[0].Spellouts) != 1 || len(sent[1].Morphemes) != 4 {
+		t.Errorf("Expected only the first token to be constrained")
+	}
+	checkLatGraph(t, &sent[0])
+}
@@ -28,6 +28,7 @@ type EMorpheme struct {
 	EFCPOS, EPOS     int
 	EFeatures        int
 	EMHost, EMSuffix int
+	TokenRange       TokenRange
 }
 
 var _ DepNode = &Morpheme{}
@@ -383,6 +384,16 @@ type Lattice struct {
 	Spellouts       Spellouts
 	Next            map[int][]int
 	BottomId, TopId int
+	TokenRange      TokenRange
+}
+
+// SetTokenRange sets the character range of the lattice's token and of
+// its morphemes
+func (l *Lattice) SetTokenRange(r TokenRange) {
+	l.TokenRange = r
+	for _, m := range l.Morphemes {
+		m.TokenRange = r
+	}
 }
 
 func (l *Lattice) Signature() string {
@@ -822,6 +833,7 @@ func NewRootLattice() Lattice {
 		make(map[int][]int),
 		0,
 		0,
+		TokenRange{},
 	}
 	return *lat
 }
@@ -830,6 +842,17 @@ type LatticeSentence []Lattice
 
 var _ Sentence = LatticeSentence{}
 
+// SetTokenRanges sets the character ranges of the sentence's tokens,
+// ignoring ranges if their number does not match the number of tokens
+func (ls LatticeSentence) SetTokenRanges(ranges []TokenRange) {
+	if len(ranges) != len(ls) {
+		return
+	}
+	for i := range ls {
+		ls[i].SetTokenRange(ranges[i])
+	}
+}
+
 func (ls LatticeSentence) TaggedSentence() TaggedSentence {
 	// assume morphs ~= 2x tokens (or say ~80%)
 	var res BasicETaggedSentence = make([]EnumTaggedToken, 0, len(ls)*2)
@@ -1,22 +1,38 @@
 package types
 
 import (
-	G "yap/alg/graph"
 	"testing"
+	G "yap/alg/graph"
 )
 
+func testMorph(id, from, to int, form, pos string, feats map[string]string) *EMorpheme {
+	return &EMorpheme{Morpheme: Morpheme{
+		BasicDirectedEdge: G.BasicDirectedEdge{id, from, to},
+		Form:              form,
+		CPOS:              pos,
+		POS:               pos,
+		Features:          feats,
+		TokenID:           6,
+	}}
+}
+
 var testLat *Lattice = &Lattice{
-	"KFHM",
-	[]*Morpheme{
-		&Morpheme{G.BasicDirectedEdge{0, 7, 8}, "K", "ADVERB", "ADVERB", nil, 6},
-		&Morpheme{G.BasicDirectedEdge{1, 7, 9}, "KF", "TEMP", "TEMP", nil, 6},
-		&Morpheme{G.BasicDirectedEdge{2, 8, 10}, "FHM", "NNP", "NNP", nil, 6},
-		&Morpheme{G.BasicDirectedEdge{3, 9, 10}, "HM", "PRP", "PRP", map[string]string{"gen": "M", "num": "P", "per": "3"}, 6},
-		&Morpheme{G.BasicDirectedEdge{4, 9, 10}, "HM", "COP", "COP", map[string]string{"gen": "M", "num": "P", "per": "3", "polar": "pos"}, 6},
+	Token: "KFHM",
+	Morphemes: Morphemes{
+		testMorph(0, 7, 8, "K", "ADVERB", nil),
+		testMorph(1, 7, 9, "KF", "TEMP", nil),
+		testMorph(2, 8, 10, "FHM", "NNP", nil),
+		testMorph(3, 9, 10, "HM", "PRP", map[string]string{"gen": "M", "num": "P", "per": "3"}),
+		testMorph(4, 9, 10, "HM", "COP", map[string]string{"gen": "M", "num": "P", "per": "3", "polar": "pos"}),
 	},
-	nil,
+	BottomId: 7,
+	TopId:    10,
 }
 
 func TestLattice(t *testing.T) {
+	testLat.GenNexts(true)
 	testLat.GenSpellouts()
+	if len(testLat.Spellouts) != 3 {
+		t.Errorf("Expected 3 spellouts, got %d", len(testLat.Spellouts))
+	}
 }
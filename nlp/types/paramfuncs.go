@@ -15,6 +15,7 @@ var (
 	Main_POS map[string]bool
 	MDParams map[string]MDParam = map[string]MDParam{
 		"Form":                            Form,
+		"Lemma":                           Lemma,
 		"Form_Prop":                       Form_Prop,
 		"POS":                             POS,
 		"POS_Prop":                        POS_Prop,
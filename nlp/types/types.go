@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"yap/util"
@@ -13,14 +14,51 @@ const (
 
 type Token string
 
+// TokenRange is the character span [Start, End) of a token in its input,
+// counted in runes from the start of the input
+type TokenRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Valid is false for a token without a known range
+func (r TokenRange) Valid() bool {
+	return r.End > r.Start
+}
+
+func (r TokenRange) String() string {
+	return fmt.Sprintf("%d:%d", r.Start, r.End)
+}
+
+func ParseTokenRange(value string) (TokenRange, error) {
+	var r TokenRange
+	if _, err := fmt.Sscanf(value, "%d:%d", &r.Start, &r.End); err != nil {
+		return r, fmt.Errorf("Error parsing token range (%s): %v", value, err)
+	}
+	if r.End < r.Start {
+		return r, fmt.Errorf("Error parsing token range (%s): end before start", value)
+	}
+	return r, nil
+}
+
 func (t Token) Signature() string {
 	return util.Signature(string(t))
 }
 
+// Prefix returns the first n bytes of the token
+func (t Token) Prefix(n int) string {
+	return util.Prefix(string(t), n)
+}
+
+// Suffix returns the last n bytes of the token
+func (t Token) Suffix(n int) string {
+	return util.Suffix(string(t), n)
+}
+
 func (t Token) Prefixes(n int) []interface{} {
 	prefixes := make([]interface{}, 0, n)
 	for i := 0; i < util.Min(n, len(t)); i++ {
-		prefixes = append(prefixes, util.Prefix(string(t), i+1))
+		prefixes = append(prefixes, t.Prefix(i+1))
 	}
 	return prefixes
 }
@@ -28,7 +66,7 @@ func (t Token) Prefixes(n int) []interface{} {
 func (t Token) Suffixes(n int) []interface{} {
 	suffixes := make([]interface{}, 0, n)
 	for i := 0; i < util.Min(n, len(t)); i++ {
-		suffixes = append(suffixes, util.Suffix(string(t), i+1))
+		suffixes = append(suffixes, t.Suffix(i+1))
 	}
 	return suffixes
 }
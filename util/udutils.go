@@ -2,13 +2,50 @@ package util
 
 import (
 	"fmt"
+	"io/ioutil"
 	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 type FeatureLookup struct {
-	UDName   string
-	ValueMap map[string]string
+	UDName   string            `yaml:"name"`
+	ValueMap map[string]string `yaml:"values"`
+	// Passthrough keeps values missing from ValueMap as is
+	Passthrough bool `yaml:"passthrough,omitempty"`
+}
+
+// Heb2UDSuffix describes the morphemes of split pronominal suffixes
+type Heb2UDSuffix struct {
+	POS       string `yaml:"pos"`
+	Lemma     string `yaml:"lemma"`
+	BridgePOS string `yaml:"bridge pos"`
+}
+
+// Heb2UDLexicon holds the lexicon (BGU) features kept for UD analyses
+type Heb2UDLexicon struct {
+	SkipBinyan         bool     `yaml:"skip binyan"`
+	SkipPolar          bool     `yaml:"skip polar"`
+	SkipAllType        bool     `yaml:"skip all type"`
+	SkipTypes          []string `yaml:"skip types"`
+	StripPROPNFeatures bool     `yaml:"strip propn features"`
+}
+
+// Heb2UDConversion is a table converting Hebrew (SPMRL) POS tags and
+// features to UD. POS values are a UD POS optionally followed by a dash and
+// features added to the morpheme (e.g. NOUN-Definite=Cons).
+// A table loaded from a file replaces the default table entirely
+// (see conf/heb2ud.yaml)
+type Heb2UDConversion struct {
+	POS              map[string]string        `yaml:"pos"`
+	PrefixPOS        map[string]string        `yaml:"prefix pos"`
+	PrefixFeatures   map[string]string        `yaml:"prefix features"`
+	Features         map[string]FeatureLookup `yaml:"features"`
+	FeatureOverrides map[string]string        `yaml:"feature overrides"`
+	BeinoniVerbForm  []string                 `yaml:"beinoni verbform pos"`
+	Suffix           Heb2UDSuffix             `yaml:"suffix"`
+	Lexicon          Heb2UDLexicon            `yaml:"lexicon"`
 }
 
 var (
@@ -80,6 +117,21 @@ var (
 		"tense": TenseMap,
 		"type":  TypeMap,
 		"polar": PolarMap,
+		"binyan": FeatureLookup{
+			UDName:      "HebBinyan",
+			ValueMap:    map[string]string{},
+			Passthrough: true,
+		},
+	}
+	HEB2UDFeatureOverrides = map[string]string{
+		"tense=BEINONI":     "Tense=Part",
+		"type=TOINFINITIVE": "VerbForm=Inf",
+		"tense=IMPERATIVE":  "Mood=Imp",
+		"binyan=HITPAEL":    "",
+	}
+	HEB2UDPrefixFeatures = map[string]string{
+		"DEF":  "PronType=Art",
+		"TEMP": "Case=Tem",
 	}
 	HEB2UDPrefixPOS = map[string]string{
 		"ADVERB":      "ADP",
@@ -122,33 +174,147 @@ var (
 		"VB":       "VERB",
 		// "UNK" should be dropped
 	}
+
+	// HEB2UD is the conversion table in use
+	HEB2UD = &Heb2UDConversion{
+		POS:              HEB2UDPOS,
+		PrefixPOS:        HEB2UDPrefixPOS,
+		PrefixFeatures:   HEB2UDPrefixFeatures,
+		Features:         HEB2UDFeatureNameLookup,
+		FeatureOverrides: HEB2UDFeatureOverrides,
+		BeinoniVerbForm:  []string{"VB", "MD", "EX"},
+		Suffix: Heb2UDSuffix{
+			POS:       "PRON",
+			Lemma:     "הוא",
+			BridgePOS: "ADP",
+		},
+		Lexicon: Heb2UDLexicon{
+			SkipTypes: []string{},
+			// Compatibility: No features for PROPN in UD Hebrew
+			StripPROPNFeatures: true,
+		},
+	}
 )
 
+// LoadHeb2UDConversion parses and validates a YAML conversion table
+func LoadHeb2UDConversion(data []byte) (*Heb2UDConversion, error) {
+	conv := new(Heb2UDConversion)
+	if err := yaml.Unmarshal(data, conv); err != nil {
+		return nil, err
+	}
+	if len(conv.POS) == 0 {
+		return nil, fmt.Errorf("UD conversion table has no pos section")
+	}
+	for hebPOS, udMSR := range conv.POS {
+		if len(udMSR) == 0 || len(strings.Split(udMSR, "-")) > 2 {
+			return nil, fmt.Errorf("UD conversion of POS %s should be a POS optionally followed by -features, got %q", hebPOS, udMSR)
+		}
+	}
+	for name, lookup := range conv.Features {
+		if len(lookup.UDName) == 0 {
+			return nil, fmt.Errorf("UD conversion of feature %s has no name", name)
+		}
+	}
+	if len(conv.Suffix.POS) == 0 {
+		conv.Suffix.POS = HEB2UD.Suffix.POS
+	}
+	if len(conv.Suffix.BridgePOS) == 0 {
+		conv.Suffix.BridgePOS = HEB2UD.Suffix.BridgePOS
+	}
+	return conv, nil
+}
+
+func LoadHeb2UDConversionFile(filename string) (*Heb2UDConversion, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadHeb2UDConversion(data)
+}
+
+// SplitMSR splits a UD POS conversion value to the POS and its features
+func (c *Heb2UDConversion) SplitMSR(udMSR string) (string, string) {
+	split := strings.SplitN(udMSR, "-", 2)
+	if len(split) == 1 {
+		return split[0], ""
+	}
+	return split[0], split[1]
+}
+
+// IsBeinoniVerbForm returns whether a Hebrew POS with tense=BEINONI is
+// marked VerbForm=Part
+func (c *Heb2UDConversion) IsBeinoniVerbForm(hebPOS string) bool {
+	for _, pos := range c.BeinoniVerbForm {
+		if pos == hebPOS {
+			return true
+		}
+	}
+	return false
+}
+
+// Heb2UDMorph converts a Hebrew POS and feature string to UD using the
+// table in use. Unknown POS tags and features (e.g. suffix features) are
+// kept as is
+func Heb2UDMorph(hebPOS, features string) (string, string) {
+	udMSR, exists := HEB2UD.POS[hebPOS]
+	if !exists {
+		return hebPOS, features
+	}
+	udPOS, udFeats := HEB2UD.SplitMSR(udMSR)
+	if HEB2UD.Lexicon.StripPROPNFeatures && udPOS == "PROPN" {
+		return udPOS, ""
+	}
+	udPairs := make([]string, 0, 5)
+	if len(features) > 0 && features != "_" {
+		for _, hebFeature := range strings.Split(features, "|") {
+			if hebFeature == "tense=BEINONI" && HEB2UD.IsBeinoniVerbForm(hebPOS) {
+				udPairs = append(udPairs, "VerbForm=Part")
+			}
+			if !heb2UDConvertible(hebFeature) {
+				udPairs = append(udPairs, hebFeature)
+				continue
+			}
+			if udFeature := Heb2UDFeature(hebFeature); len(udFeature) > 0 {
+				udPairs = append(udPairs, udFeature)
+			}
+		}
+	}
+	features, _ = MergeFeatureStrs(strings.Join(udPairs, "|"), udFeats)
+	return udPOS, features
+}
+
+func heb2UDConvertible(feature string) bool {
+	if _, exists := HEB2UD.FeatureOverrides[feature]; exists {
+		return true
+	}
+	pair := strings.Split(feature, "=")
+	if len(pair) != 2 {
+		return false
+	}
+	propMap, exists := HEB2UD.Features[pair[0]]
+	if !exists {
+		return false
+	}
+	_, valExists := propMap.ValueMap[pair[1]]
+	return valExists || propMap.Passthrough
+}
+
 func Heb2UDFeature(feature string) string {
 	if len(feature) == 0 {
 		return feature
 	}
-	switch feature {
-	case "tense=BEINONI":
-		return "Tense=Part"
-	case "type=TOINFINITIVE":
-		return "VerbForm=Inf"
-	case "tense=IMPERATIVE":
-		return "Mood=Imp"
+	if override, exists := HEB2UD.FeatureOverrides[feature]; exists {
+		return override
 	}
 	pair := strings.Split(feature, "=")
 	if len(pair) == 1 {
 		panic(fmt.Sprintf("Can't transform non-attribute feature %s", feature))
 	}
-	if pair[0] == "binyan" {
-		if pair[1] == "HITPAEL" {
-			return ""
-		}
-		return fmt.Sprintf("HebBinyan=%s", pair[1])
-	}
-	if propMap, exists := HEB2UDFeatureNameLookup[pair[0]]; exists {
+	if propMap, exists := HEB2UD.Features[pair[0]]; exists {
 		if propValue, valExists := propMap.ValueMap[pair[1]]; valExists {
 			return fmt.Sprintf("%s=%s", propMap.UDName, propValue)
+		} else if propMap.Passthrough {
+			return fmt.Sprintf("%s=%s", propMap.UDName, pair[1])
 		} else {
 			panic(fmt.Sprintf("Morphological feature value does not exist in Heb2UD transform %s", feature))
 		}
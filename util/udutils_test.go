@@ -0,0 +1,62 @@
+package util
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadHeb2UDConversion(t *testing.T) {
+	conv, err := LoadHeb2UDConversion([]byte(`
+pos:
+  NN: NOUN
+  NNT: NOUN-Definite=Cons
+features:
+  gen:
+    name: Gender
+    values:
+      M: Masc
+`))
+	if err != nil {
+		t.Fatalf("Failed loading conversion table: %v", err)
+	}
+	if pos, feats := conv.SplitMSR(conv.POS["NNT"]); pos != "NOUN" || feats != "Definite=Cons" {
+		t.Errorf("Expected NOUN and Definite=Cons, got %s and %s", pos, feats)
+	}
+	if conv.Features["gen"].ValueMap["M"] != "Masc" {
+		t.Errorf("Expected gen=M to convert to Masc, got %v", conv.Features["gen"])
+	}
+	if conv.Suffix.POS != HEB2UD.Suffix.POS || conv.Suffix.BridgePOS != HEB2UD.Suffix.BridgePOS {
+		t.Errorf("Expected the default suffix POS tags, got %v", conv.Suffix)
+	}
+	for _, invalid := range []string{
+		"features:\n  gen:\n    name: Gender\n",
+		"pos:\n  NN: \"\"\n",
+		"pos:\n  NN: NOUN-Definite=Cons-Abbr=Yes\n",
+		"pos:\n  NN: NOUN\nfeatures:\n  gen:\n    values:\n      M: Masc\n",
+		"pos: [NN]\n",
+	} {
+		if _, err := LoadHeb2UDConversion([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error loading %q", invalid)
+		}
+	}
+}
+
+func TestHeb2UDConfMatchesBuiltin(t *testing.T) {
+	conv, err := LoadHeb2UDConversionFile("../conf/heb2ud.yaml")
+	if err != nil {
+		t.Fatalf("Failed loading conf/heb2ud.yaml: %v", err)
+	}
+	// compare the serialized tables, so empty and missing values are equal
+	loaded, err := yaml.Marshal(conv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builtin, err := yaml.Marshal(HEB2UD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(loaded) != string(builtin) {
+		t.Errorf("conf/heb2ud.yaml differs from the built-in table:\n%s\nbuilt-in:\n%s", loaded, builtin)
+	}
+}
@@ -51,12 +51,14 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 		panic(fmt.Sprintf("Dep model not found"))
 	}
 	app.DepModelName = modelLocation
-	app.DepConfigOut(modelLocation, &search.Beam{}, transitionSystem)
+	serialization := app.ReadModel(modelLocation)
+	serialization.Config.Apply()
+	app.DepConfigOut(app.DepFlagOptions(), modelLocation, &search.Beam{}, transitionSystem)
 	relations, err := conf.ReadFile(labelsLocation)
 	if err != nil {
 		panic(fmt.Sprintf("Failed reading Dep labels from file: %v", labelsLocation))
 	}
-	app.SetupDepEnum(relations.Values)
+	app.SetupDepEnum(app.PseudoProjectiveRelations(relations.Values))
 	arcSystem = &ArcEager{
 		ArcStandard: ArcStandard{
 			SHIFT: app.SH.Value(),
@@ -85,7 +87,6 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 	}
 
 	log.Println("Found model file", modelLocation, " ... loading model")
-	serialization := app.ReadModel(modelLocation)
 	model.Deserialize(serialization.WeightModel)
 	app.EWord = serialization.EWord
 	app.EPOS = serialization.EPOS
@@ -104,6 +105,7 @@ func DepParserInitialize(cmd *commander.Command, args []string) {
 		ETrans: app.ETrans,
 		TerminalStack: terminalStack,
 		TerminalQueue: 0,
+		Clusters: app.LoadWordClusters(),
 	}
 
 	depBeam = &search.Beam{
@@ -136,7 +138,7 @@ func DepParseDisambiguatedLattice(input string) string {
 	parsedGraphs := app.Parse(sents, depBeam)
 	graphAsConll := conll.Graph2ConllCorpus(parsedGraphs, app.EMHost, app.EMSuffix)
 	buf := new(bytes.Buffer)
-	conll.Write(buf, graphAsConll)
+	conll.Write(buf, app.DeprojectivizeCorpus(graphAsConll))
 	depLock.Unlock()
 	return buf.String()
 }
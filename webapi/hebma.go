@@ -41,21 +41,25 @@ func HebrewMorphAnalyazerInitialize(cmd *commander.Command, args []string) {
 	maData.LoadPrefixes(app.HebMaPrefixFile)
 	log.Println("Reading Morphological Analyzer BGU Lexicon")
 	maData.LoadLex(app.HebMaLexiconFile, app.HebMaNnpnofeats)
+	if app.HebMaCacheSize > 0 {
+		maData.Cache = ma.NewLatticeCache(app.HebMaCacheSize)
+	}
 	log.Println()
 	maData.AlwaysNNP = app.HebMaAlwaysnnp
 	maData.LogOOV = app.HebMaShowoov
 
 }
 
-func HebrewMorphAnalyzeRawSentences(input string) string {
+func HebrewMorphAnalyzeRawSentences(input string) (string, [][]nlp.TokenRange) {
 	maLock.Lock()
 	var (
 		reader io.Reader
 		sents []nlp.BasicSentence
+		ranges [][]nlp.TokenRange
 		err error
 	)
 	reader = strings.NewReader(input)
-	sents, err = raw.Read(reader, 0)
+	sents, ranges, err = raw.ReadWithRanges(reader, 0)
 	if err != nil {
 		panic(fmt.Sprintf("Failed reading raw input - %v", err))
 	}
@@ -70,11 +74,15 @@ func HebrewMorphAnalyzeRawSentences(input string) string {
 	for i, sent := range sents {
 		//log.SetPrefix(fmt.Sprintf("%v graph# %v ", prefix, i))
 		lattices[i], oovInd[i] = maData.Analyze(sent.Tokens())
+		lattices[i].SetTokenRanges(ranges[i])
+	}
+	if maData.Cache != nil {
+		log.Printf("Lattice cache hit rate %.4f (%d hits, %d misses, %d cached)", stats.CacheHitRate(), stats.CacheHits, stats.CacheMisses, maData.Cache.Len())
 	}
 	log.Println()
 	output := lattice.Sentence2LatticeCorpus(lattices, maHebrew)
 	buf := new(bytes.Buffer)
 	err = lattice.Write(buf, output)
 	maLock.Unlock()
-	return buf.String()
+	return buf.String(), ranges
 }
@@ -3,6 +3,7 @@ package webapi
 import (
 	"log"
 	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
 	"yap/nlp/parser/disambig"
 	"yap/nlp/parser/joint"
 	"yap/alg/search"
@@ -29,6 +30,8 @@ var (
 	model *transitionmodel.AvgMatrixSparse
 	terminalStack int
 	paramFunc nlp.MDParam
+	JointArcSystem string
+	jointClusters *nlp.WordClusters
 	jointLock sync.Mutex
 )
 
@@ -37,14 +40,14 @@ func JointParserInitialize() {
 	if !exists {
 		log.Fatalln("Param Func", app.MdParamFuncName, "does not exist")
 	}
-	mdTrans := &disambig.MDTrans{
-		ParamFunc: paramFunc,
-		UsePOP:    app.UsePOP,
-	}
-	arcSystem = &ArcEager{
-		ArcStandard: ArcStandard{},
+	mdTrans := app.NewMDTransitionSystem(paramFunc)
+	// the joint model's arc system is independent of the dep model's
+	app.DepArcSystemStr = JointArcSystem
+	var err error
+	arcSystem, terminalStack, err = app.NewArcSystem(app.DepArcSystemStr)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	terminalStack = 0
 	arcSystem.AddDefaultOracle()
 	jointTrans := &joint.JointTrans{
 		MDTrans:       mdTrans,
@@ -75,32 +78,26 @@ func JointParserInitialize() {
 		}
 		app.JointModelFile = modelLocation
 	}
+	serialization := app.ReadModel(app.JointModelFile)
+	serialization.Config.Apply()
 	confBeam := &search.Beam{}
 	confBeam.Align = app.AlignBeam
 	confBeam.Averaged = app.AverageScores
-	app.JointConfigOut(app.JointModelFile, confBeam, transitionSystem)
+	app.JointConfigOut(app.JointFlagOptions(), app.JointModelFile, confBeam, transitionSystem)
+	jointClusters = app.LoadWordClusters()
 	relations, err := conf.ReadFile(app.DepLabelsFile)
 	if err != nil {
 		panic(fmt.Sprintf("Joint labels not found"))
 	}
-	app.SetupEnum(relations.Values)
-	arcSystem = &ArcEager{
-		ArcStandard: ArcStandard{
-			SHIFT:       app.SH.Value(),
-			LEFT:        app.LA.Value(),
-			RIGHT:       app.RA.Value(),
-			Relations:   app.ERel,
-			Transitions: app.ETrans,
-		},
-		REDUCE:  app.RE.Value(),
-		POPROOT: app.PR.Value(),
+	app.SetupEnum(app.PseudoProjectiveRelations(relations.Values))
+	arcSystem, _, err = app.NewArcSystem(app.DepArcSystemStr)
+	if err != nil {
+		log.Fatalln(err)
 	}
 	arcSystem.AddDefaultOracle()
 	jointTrans.ArcSys = arcSystem
 	jointTrans.Transitions = app.ETrans
-	mdTrans.Transitions = app.ETrans
-	mdTrans.UsePOP = app.UsePOP
-	mdTrans.POP = app.POP
+	app.SetMDTransitions(mdTrans)
 	disambig.UsePOP = app.UsePOP
 	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
 	disambig.LEMMAS = !lattice.IGNORE_LEMMA
@@ -121,7 +118,6 @@ func JointParserInitialize() {
 	log.Println()
 
 	log.Println("Found model file", app.JointModelFile, " ... loading model")
-	serialization := app.ReadModel(app.JointModelFile)
 	model = &transitionmodel.AvgMatrixSparse{}
 	model.Deserialize(serialization.WeightModel)
 	app.EWord = serialization.EWord
@@ -132,24 +128,18 @@ func JointParserInitialize() {
 	app.EMorphProp = serialization.EMorphProp
 	app.ETrans = serialization.ETrans
 	app.ETokens = serialization.ETokens
+	app.Lemmatizer = serialization.Lemmas
+	app.FeatsTagger = serialization.Feats
+	app.SetupFeatsTagger()
 	log.Println("Loaded model")
-	arcSystem = &ArcEager{
-		ArcStandard: ArcStandard{
-			SHIFT:       app.SH.Value(),
-			LEFT:        app.LA.Value(),
-			RIGHT:       app.RA.Value(),
-			Relations:   app.ERel,
-			Transitions: app.ETrans,
-		},
-		REDUCE:  app.RE.Value(),
-		POPROOT: app.PR.Value(),
+	arcSystem, _, err = app.NewArcSystem(app.DepArcSystemStr)
+	if err != nil {
+		log.Fatalln(err)
 	}
 	arcSystem.AddDefaultOracle()
 	jointTrans.ArcSys = arcSystem
 	jointTrans.Transitions = app.ETrans
-	mdTrans.Transitions = app.ETrans
-	mdTrans.UsePOP = app.UsePOP
-	mdTrans.POP = app.POP
+	app.SetMDTransitions(mdTrans)
 	disambig.UsePOP = app.UsePOP
 	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
 	disambig.LEMMAS = !lattice.IGNORE_LEMMA
@@ -159,7 +149,10 @@ func JointParserInitialize() {
 	transitionSystem = transition.TransitionSystem(jointTrans)
 }
 
-func JointParseAmbiguousLattices(input string) (string, string, string) {
+// JointParseAmbiguousLattices returns the dependency trees, mappings,
+// segmentations and, if nbest > 0, the n-best disambiguations of input.
+// Constraints that can't be applied are returned as errors without parsing
+func JointParseAmbiguousLattices(input string, nbest int, constraints []nlp.TokenConstraint) (string, string, string, string, string, []error) {
 	jointLock.Lock()
 	log.Println("Reading ambiguous lattices")
 	log.Println("input:\n",input)
@@ -169,6 +162,12 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 		panic(fmt.Sprintf("Failed reading raw input - %v", lAmbE))
 	}
 	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
+	if len(constraints) > 0 {
+		if errs := app.ConstrainLattices(predAmbLat, constraints); len(errs) > 0 {
+			jointLock.Unlock()
+			return "", "", "", "", "", errs
+		}
+	}
 	conf := &joint.JointConfig{
 		SimpleConfiguration: SimpleConfiguration{
 			EWord: app.EWord,
@@ -180,12 +179,14 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 			ETrans: app.ETrans,
 			TerminalStack: terminalStack,
 			TerminalQueue: 0,
+			Clusters: jointClusters,
 		},
 		MDConfig: disambig.MDConfig{
 			ETokens: app.ETokens,
 			POP: app.POP,
 			Transitions: app.ETrans,
 			ParamFunc: paramFunc,
+			Clusters: jointClusters,
 		},
 		MDTrans: app.MD,
 	}
@@ -200,10 +201,22 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 	}
 	beam.Model = model
 	beam.ShortTempAgenda = true
-	parsedGraphs := app.Parse(predAmbLat, beam)
+	var (
+		parsedGraphs []interface{}
+		nbests []*disambig.NBest
+		nbestOut string
+	)
+	if nbest > 0 {
+		parsedGraphs, nbests = app.ParseNBest(predAmbLat, beam, nbest)
+		nbestBuf := new(bytes.Buffer)
+		mapping.WriteNBest(nbestBuf, nbests)
+		nbestOut = nbestBuf.String()
+	} else {
+		parsedGraphs = app.Parse(predAmbLat, beam)
+	}
 	graphAsConll := conll.MorphGraph2ConllCorpus(parsedGraphs)
 	buf1 := new(bytes.Buffer)
-	conll.Write(buf1, graphAsConll)
+	conll.Write(buf1, app.DeprojectivizeCorpus(graphAsConll))
 	conllDepOut := buf1.String()
 	buf2 := new(bytes.Buffer)
 	mapping.Write(buf2, app.GetInstances(parsedGraphs, app.GetJointMDConfig))
@@ -211,6 +224,12 @@ func JointParseAmbiguousLattices(input string) (string, string, string) {
 	buf3 := new(bytes.Buffer)
 	segmentation.Write(buf3, parsedGraphs)
 	segmentationMdOut := buf3.String()
+	var conllUDDepOut string
+	if conllu.HEB2UD {
+		buf4 := new(bytes.Buffer)
+		conllu.Write(buf4, app.DeprojectivizeCorpus(conllu.MorphGraph2ConllCorpus(parsedGraphs)))
+		conllUDDepOut = buf4.String()
+	}
 	jointLock.Unlock()
-	return conllDepOut, mappingMdOut, segmentationMdOut
+	return conllDepOut, mappingMdOut, segmentationMdOut, conllUDDepOut, nbestOut, nil
 }
@@ -32,10 +32,7 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 		mdTrans transition.TransitionSystem
 		model *transitionmodel.AvgMatrixSparse = &transitionmodel.AvgMatrixSparse{}
 	)
-	mdTrans = &disambig.MDTrans{
-		ParamFunc: paramFunc,
-		UsePOP: app.UsePOP,
-	}
+	mdTrans = app.NewMDTransitionSystem(paramFunc)
 	disambig.UsePOP = app.UsePOP
 	transitionSystem := transition.TransitionSystem(mdTrans)
 	featuresLocation, found := util.LocateFile(app.MdFeaturesFile, app.DEFAULT_CONF_DIRS)
@@ -48,12 +45,13 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 		panic(fmt.Sprintf("MD model not found"))
 	}
 	app.MdModelName = modelLocation
+	serialization := app.ReadModel(modelLocation)
+	serialization.Config.Apply()
 	confBeam := &search.Beam{}
-	app.MDConfigOut(modelLocation, confBeam, transitionSystem)
+	app.MDConfigOut(app.MDFlagOptions(), modelLocation, confBeam, transitionSystem)
 	disambig.SwitchFormLemma = !lattice.IGNORE_LEMMA
 	app.SetupMDEnum()
-	mdTrans.(*disambig.MDTrans).POP = app.POP
-	mdTrans.(*disambig.MDTrans).Transitions = app.ETrans
+	app.SetMDTransitions(mdTrans)
 	mdTrans.AddDefaultOracle()
 	featureSetup, err := transition.LoadFeatureConfFile(featuresLocation)
 	if err != nil {
@@ -65,7 +63,6 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	log.Println()
 	log.Println("Found MD model file", modelLocation, " ... loading model")
 
-	serialization := app.ReadModel(modelLocation)
 	model.Deserialize(serialization.WeightModel)
 	app.EWord = serialization.EWord
 	app.EPOS = serialization.EPOS
@@ -75,13 +72,12 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	app.EMorphProp = serialization.EMorphProp
 	app.ETrans = serialization.ETrans
 	app.ETokens = serialization.ETokens
+	app.Lemmatizer = serialization.Lemmas
+	app.FeatsTagger = serialization.Feats
+	app.SetupFeatsTagger()
 
-	mdTrans = &disambig.MDTrans{
-		ParamFunc: paramFunc,
-		UsePOP: app.UsePOP,
-		POP: app.POP,
-		Transitions: app.ETrans,
-	}
+	mdTrans = app.NewMDTransitionSystem(paramFunc)
+	app.SetMDTransitions(mdTrans)
 
 	transitionSystem = transition.TransitionSystem(mdTrans)
 	extractor = app.SetupExtractor(featureSetup, []byte("MPL"))
@@ -91,6 +87,7 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 		POP: app.POP,
 		Transitions: app.ETrans,
 		ParamFunc: paramFunc,
+		Clusters: app.LoadWordClusters(),
 	}
 
 	mdBeam = &search.Beam{
@@ -106,7 +103,11 @@ func MorphDisambiguatorInitialize(cmd *commander.Command, args []string) {
 	mdBeam.Model = model
 }
 
-func MorphDisambiguateLattices(input string) string {
+// MorphDisambiguateLattices returns the disambiguated lattices of input and,
+// if nbest > 0, the nbest distinct disambiguations of each with scores.
+// The constraints restrict the analyses of the given tokens, constraints
+// that can't be applied are returned as errors without parsing
+func MorphDisambiguateLattices(input string, nbest int, constraints []nlp.TokenConstraint) (string, string, []error) {
 	mdLock.Lock()
 	log.Println("Reading ambiguous lattices")
 	log.Println("input:\n ",input)
@@ -116,9 +117,27 @@ func MorphDisambiguateLattices(input string) string {
 		panic(fmt.Sprintf("Failed reading raw input - %v", lAmbE))
 	}
 	predAmbLat := lattice.Lattice2SentenceCorpus(lAmb, app.EWord, app.EPOS, app.EWPOS, app.EMorphProp, app.EMHost, app.EMSuffix)
-	mappings := app.Parse(predAmbLat, mdBeam)
+	if len(constraints) > 0 {
+		if errs := app.ConstrainLattices(predAmbLat, constraints); len(errs) > 0 {
+			mdLock.Unlock()
+			return "", "", errs
+		}
+	}
+	var (
+		mappings []interface{}
+		nbests []*disambig.NBest
+		nbestOut string
+	)
+	if nbest > 0 {
+		mappings, nbests = app.ParseNBest(predAmbLat, mdBeam, nbest)
+		nbestBuf := new(bytes.Buffer)
+		mapping.WriteNBest(nbestBuf, nbests)
+		nbestOut = nbestBuf.String()
+	} else {
+		mappings = app.Parse(predAmbLat, mdBeam)
+	}
 	buf := new(bytes.Buffer)
 	mapping.Write(buf, mappings)
 	mdLock.Unlock()
-	return buf.String()
+	return buf.String(), nbestOut, nil
 }
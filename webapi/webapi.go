@@ -14,6 +14,7 @@ import (
 	"yap/nlp/parser/joint"
 	"yap/nlp/format/lattice"
 	"yap/nlp/format/conll"
+	"yap/nlp/format/conllu"
 )
 
 
@@ -25,13 +26,28 @@ type Request struct {
 	Text string `json:text`
 	AmbLattice string `json:amb_lattice`
 	DisambLattice string `json:disamb_lattice`
+	NBest int `json:"nbest"`
+	Constraints []types.TokenConstraint `json:"constraints"`
 }
 
 type Data struct {
 	MALattice string `json:"ma_lattice,omitempty"`
 	MDLattice string `json:"md_lattice,omitempty"`
+	MDNBest string `json:"md_nbest,omitempty"`
 	DepTree string `json:"dep_tree,omitempty"`
+	DepTreeUD string `json:"dep_tree_ud,omitempty"`
+	TokenRanges [][]types.TokenRange `json:"token_ranges,omitempty"`
 	Error error `json:"error,omitempty"`
+	ConstraintErrors []string `json:"constraint_errors,omitempty"`
+}
+
+// constraintErrors responds with the constraints that could not be applied
+func constraintErrors(resp http.ResponseWriter, errs []error) {
+	data := Data{ ConstraintErrors: make([]string, len(errs)) }
+	for i, err := range errs {
+		data.ConstraintErrors[i] = err.Error()
+	}
+	respondWithJSON(resp, http.StatusBadRequest, data)
 }
 
 func HebrewMorphAnalyzerHandler(resp http.ResponseWriter, req *http.Request) {
@@ -43,8 +59,8 @@ func HebrewMorphAnalyzerHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	data := Data{ MALattice: maLattice }
+	maLattice, tokenRanges := HebrewMorphAnalyzeRawSentences(rawText)
+	data := Data{ MALattice: maLattice, TokenRanges: tokenRanges }
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
@@ -58,8 +74,12 @@ func MorphDisambiguatorHandler(resp http.ResponseWriter, req *http.Request) {
 	}
 	ambLattice := strings.Replace(request.AmbLattice, "\\t", "\t", -1)
 	ambLattice = strings.Replace(ambLattice, "\\n", "\n", -1)
-	mdLattice := MorphDisambiguateLattices(ambLattice)
-	data := Data { MDLattice: mdLattice }
+	mdLattice, mdNBest, errs := MorphDisambiguateLattices(ambLattice, request.NBest, request.Constraints)
+	if len(errs) > 0 {
+		constraintErrors(resp, errs)
+		return
+	}
+	data := Data { MDLattice: mdLattice, MDNBest: mdNBest }
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
@@ -87,10 +107,14 @@ func HebrewPipelineHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	mdLattice := MorphDisambiguateLattices(maLattice)
+	maLattice, tokenRanges := HebrewMorphAnalyzeRawSentences(rawText)
+	mdLattice, mdNBest, errs := MorphDisambiguateLattices(maLattice, request.NBest, request.Constraints)
+	if len(errs) > 0 {
+		constraintErrors(resp, errs)
+		return
+	}
 	depTree := DepParseDisambiguatedLattice(mdLattice)
-	data := Data { MALattice: maLattice, MDLattice: mdLattice, DepTree: depTree }
+	data := Data { MALattice: maLattice, MDLattice: mdLattice, MDNBest: mdNBest, DepTree: depTree, TokenRanges: tokenRanges }
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
@@ -103,9 +127,13 @@ func HebrewJointHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 	rawText := strings.Replace(request.Text, " ", "\n", -1)
-	maLattice := HebrewMorphAnalyzeRawSentences(rawText)
-	depTree, mdLattice, _ := JointParseAmbiguousLattices(maLattice)
-	data := Data { MALattice: maLattice, MDLattice: mdLattice, DepTree: depTree }
+	maLattice, tokenRanges := HebrewMorphAnalyzeRawSentences(rawText)
+	depTree, mdLattice, _, depTreeUD, mdNBest, errs := JointParseAmbiguousLattices(maLattice, request.NBest, request.Constraints)
+	if len(errs) > 0 {
+		constraintErrors(resp, errs)
+		return
+	}
+	data := Data { MALattice: maLattice, MDLattice: mdLattice, MDNBest: mdNBest, DepTree: depTree, DepTreeUD: depTreeUD, TokenRanges: tokenRanges }
 	respondWithJSON(resp, http.StatusOK, data)
 }
 
@@ -140,25 +168,37 @@ listen to api requests
 	cmd.Flag.BoolVar(&app.HebMaAlwaysnnp, "ma_always_nnp", false, "Always add NNP to tokens and prefixed subtokens")
 	cmd.Flag.BoolVar(&app.HebMaNnpnofeats, "ma_add_nnp_no_feats", false, "Add NNP in lex but without features")
 	cmd.Flag.BoolVar(&app.HebMaShowoov, "ma_show_oov", false, "Output OOV tokens")
+	cmd.Flag.IntVar(&app.HebMaCacheSize, "ma_cache", 10000, "Number of token lattices to cache (0 disables)")
+	cmd.Flag.BoolVar(&lattice.WRITE_TOKEN_RANGE, "ma_token_ranges", false, "Add token character ranges column to ma lattices")
 	cmd.Flag.BoolVar(&lex.LOG_FAILURES, "ma_show_lex_error", false, "Log errors encountered when loading the lexicon")
 	cmd.Flag.IntVar(&app.BeamSize, "beam", 64, "Beam size")
 	cmd.Flag.BoolVar(&app.UsePOP, "use_end_token", true, "Use end token (pop)")
 	cmd.Flag.BoolVar(&lattice.IGNORE_LEMMA, "nolemma", true, "Ignore lemmas")
+	cmd.Flag.BoolVar(&app.Lemmatize, "lemmatize", true, "Choose and fill the lemmas of the md and joint output morphemes")
+	cmd.Flag.BoolVar(&app.TagFeats, "tagfeats", false, "Tag gen/num/per of underspecified md and joint output morphemes (models trained with -tagfeats)")
+	cmd.Flag.StringVar(&app.FeatsTaggerFile, "tagfeatsf", "feats.tagger.yaml", "Feature tagger features configuration file")
 	//cmd.Flag.BoolVar(&conll.IGNORE_LEMMA, "conll_nolemma", true, "Ignore lemmas")
 	cmd.Flag.StringVar(&conll.WORD_TYPE, "conll_wordtype", "form", "Word type [form, lemma, lemma+f (=lemma if present else form)]")
 	cmd.Flag.StringVar(&app.MdParamFuncName, "md_param_func", "Funcs_Main_POS_Both_Prop", "MD param func types: ["+types.AllParamFuncNames+"]")
+	cmd.Flag.BoolVar(&app.MdUseWB, "md_wb", false, "Use the word-based MD transition system (md and joint models trained with -wb, none is shipped)")
 	cmd.Flag.StringVar(&app.MdModelName, "md_model_name", "md_model_temp_i9.b64", "MD model file")
 	cmd.Flag.StringVar(&app.DepModelName, "dep_model_name", "dep_zeager_model_temp_i18.b64", "Dep model file")
 	cmd.Flag.StringVar(&app.DepFeaturesFile, "dep_features", "zhangnivre2011.yaml", "Dep features file")
 	cmd.Flag.StringVar(&app.DepLabelsFile, "dep_labels", "hebtb.labels.conf", "Dep labels file")
 	cmd.Flag.StringVar(&app.JointFeaturesFile, "joint_features", "jointzeager.yaml", "Joint features file")
 	cmd.Flag.StringVar(&app.JointModelFile, "joint_model_name", "joint_arc_zeager_model_temp_i33.b64", "Joint model file")
+	cmd.Flag.StringVar(&JointArcSystem, "joint_arc_system", "eager", "Joint model arc system: ["+app.ArcSystems+"]")
 	cmd.Flag.StringVar(&app.JointStrategy, "joint_strategy", "ArcGreedy", "Joint Strategy: ["+joint.JointStrategies+"]")
+	cmd.Flag.BoolVar(&conllu.HEB2UD, "heb2ud", false, "Add the joint parse as CoNLL-U converted from Hebrew to UD (dep_tree_ud)")
+	cmd.Flag.StringVar(&app.Heb2UDConvFile, "ud_conversion", "", "Optional - Hebrew to UD conversion table (YAML, see conf/heb2ud.yaml)")
 	cmd.Flag.StringVar(&app.OracleStrategy, "joint_oracle_strategy", "ArcGreedy", "Oracle Strategy: ["+joint.OracleStrategies+"]")
 	return cmd
 }
 
 func StartAPIServer(cmd *commander.Command, args []string) error {
+	if conllu.HEB2UD {
+		app.LoadHeb2UDConversion(app.Heb2UDConvFile)
+	}
 	HebrewMorphAnalyazerInitialize(cmd, args)
 	MorphDisambiguatorInitialize(cmd, args)
 	DepParserInitialize(cmd, args)